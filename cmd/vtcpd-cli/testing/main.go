@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 
@@ -9,6 +10,7 @@ import (
 	"github.com/vTCP-Foundation/vtcpd-cli/internal/conf"
 	"github.com/vTCP-Foundation/vtcpd-cli/internal/handler"
 	"github.com/vTCP-Foundation/vtcpd-cli/internal/logger"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/tracing"
 )
 
 var (
@@ -31,6 +33,19 @@ var (
 	maxNegativeBalance        = kingpin.Flag("max-negative-balance", "Max negative balance.").Default("").String()
 	maxPositiveBalance        = kingpin.Flag("max-positive-balance", "Max positive balance.").Default("").String()
 	balance                   = kingpin.Flag("balance", "Settlement line balance.").Default("").String()
+	uuidFlag                  = kingpin.Flag("uuid", "UUID of the bulk payment or of the background job.").Default("").String()
+	file                      = kingpin.Flag("file", "Path to the CSV or JSON file with payments, to the YAML file with settlement lines or to the backup archive.").Default("").String()
+	dryRun                    = kingpin.Flag("dry-run", "Validate payments without sending them or verify the backup archive without restoring it.").Bool()
+	checkPayment              = kingpin.Flag("check", "Check whether the payment is feasible without sending it.").Bool()
+	sendIfFeasible            = kingpin.Flag("send-if-feasible", "Send the payment only if it is feasible.").Bool()
+	historyKind               = kingpin.Flag("kind", "Kind of the exported history (payments, payments-all, settlement-lines, additional or with-contractor) or of the listed background jobs.").Default("").String()
+	exportFormat              = kingpin.Flag("format", "Format of the exported history (csv, ndjson or ledger) or of the reconciliation report and settlement lines plan (json or text).").Default("").String()
+	output                    = kingpin.Flag("output", "Path to the file of the exported history or of the backup archive.").Default("").String()
+	reportFrom                = kingpin.Flag("from", "Beginning of the report period (RFC3339 or unix timestamp).").Default("").String()
+	reportTo                  = kingpin.Flag("to", "End of the report period (RFC3339 or unix timestamp).").Default("").String()
+	autoApprove               = kingpin.Flag("yes", "Apply the settlement lines plan without the confirmation.").Bool()
+	online                    = kingpin.Flag("online", "Stop the running node for the time of the backup or restore.").Bool()
+	passphraseFile            = kingpin.Flag("passphrase-file", "Path to the file with the passphrase of the backup archive.").Default("").String()
 )
 
 func main() {
@@ -46,6 +61,13 @@ func main() {
 		os.Exit(-1)
 	}
 
+	shutdownTracing, err := tracing.Init()
+	if err != nil {
+		logger.Error("Can't init tracing. Details: " + err.Error())
+		os.Exit(-1)
+	}
+	defer shutdownTracing(context.Background())
+
 	kingpin.Version("0.0.1")
 	kingpin.Parse()
 
@@ -67,6 +89,19 @@ func main() {
 	handler.MaxNegativeBalance = *maxNegativeBalance
 	handler.MaxPositiveBalance = *maxPositiveBalance
 	handler.Balance = *balance
+	handler.UUID = *uuidFlag
+	handler.File = *file
+	handler.DryRun = *dryRun
+	handler.CheckPayment = *checkPayment
+	handler.SendIfFeasible = *sendIfFeasible
+	handler.HistoryKind = *historyKind
+	handler.ExportFormat = *exportFormat
+	handler.Output = *output
+	handler.ReportFrom = *reportFrom
+	handler.ReportTo = *reportTo
+	handler.AutoApprove = *autoApprove
+	handler.Online = *online
+	handler.PassphraseFile = *passphraseFile
 
 	cmdHandler, err := cmd_handler.NewCommandHandlerTesting()
 	if err != nil {
@@ -77,6 +112,7 @@ func main() {
 	err = cmdHandler.HandleCommand(*command)
 	if err != nil {
 		fmt.Println(err.Error())
+		shutdownTracing(context.Background())
 		os.Exit(1)
 	}
 
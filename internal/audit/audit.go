@@ -0,0 +1,261 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/auth"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/conf"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/logger"
+)
+
+var (
+	DEFAULT_AUDIT_FILE_PATH = "audit.log"
+
+	// Previous hash of the first entry of the log.
+	GENESIS_HASH = strings.Repeat("0", 64)
+
+	REDACTED_VALUE = "***"
+
+	// Operations, that are not the engine commands.
+	OPERATION_STOP                = "stop"
+	OPERATION_PAYMENT_POLICY      = "policy:payment"
+	OPERATION_APPROVAL_REQUEST    = "approval:request"
+	OPERATION_APPROVAL_APPROVE    = "approval:approve"
+	OPERATION_APPROVAL_REJECT     = "approval:reject"
+	OPERATION_SCHEDULE_CREATE     = "schedule:create"
+	OPERATION_SCHEDULE_UPDATE     = "schedule:update"
+	OPERATION_SCHEDULE_DELETE     = "schedule:delete"
+	OPERATION_BULK_PAYMENT_CREATE = "bulk-payment:create"
+	OPERATION_BULK_PAYMENT_RESUME = "bulk-payment:resume"
+	OPERATION_JOB_CREATE          = "job:create"
+	OPERATION_JOB_PAUSE           = "job:pause"
+	OPERATION_JOB_RESUME          = "job:resume"
+	OPERATION_JOB_CANCEL          = "job:cancel"
+	OPERATION_BACKUP              = "backup"
+	OPERATION_RESTORE             = "restore"
+
+	// Prefixes of the engine commands, that are changing the state of the node.
+	mutatingCommandsPrefixes = []string{"INIT:", "SET:", "DELETE:", "CREATE:"}
+)
+
+// Record of the audit log.
+// Each entry is a separate JSON line, that is chained to the previous one by the PrevHash.
+type Entry struct {
+	Sequence    uint64   `json:"seq"`
+	Time        string   `json:"time"`
+	Caller      string   `json:"caller"`
+	AuthMethod  string   `json:"auth_method"`
+	SourceIP    string   `json:"source_ip"`
+	Operation   string   `json:"operation"`
+	Arguments   []string `json:"arguments"`
+	CommandUUID string   `json:"command_uuid"`
+	ResultCode  int      `json:"result_code"`
+	PrevHash    string   `json:"prev_hash"`
+	Hash        string   `json:"hash"`
+}
+
+// Hash of the entry is the SHA-256 of it's JSON form (without hash itself).
+// Previous hash is a part of the JSON, so each entry covers all the previous ones.
+func (e Entry) computeHash() string {
+	e.Hash = ""
+	js, _ := json.Marshal(e)
+	hash := sha256.Sum256(js)
+	return hex.EncodeToString(hash[:])
+}
+
+func filePath() string {
+	if conf.Params.Audit.FilePath == "" {
+		return DEFAULT_AUDIT_FILE_PATH
+	}
+	return conf.Params.Audit.FilePath
+}
+
+// Returns true if the engine command changes the state of the node and must be audited.
+func IsMutatingCommand(name string) bool {
+	for _, prefix := range mutatingCommandsPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Serializes writes of the goroutines of the current process.
+// Writes of the different processes (e.g. HTTP server and CLI commands) are serialized by the file lock.
+var lock sync.Mutex
+
+// Appends entry about the operation to the audit log.
+// Caller is taken from the context. If there is no caller, operation is treated as executed by the local CLI user.
+// Failures are only logged: audit must not break processing of the operations.
+func Record(ctx context.Context, operation string, arguments []string, commandUUID string, resultCode int) {
+	caller := auth.CallerFromContext(ctx)
+	if caller == nil {
+		caller = auth.LocalCaller()
+	}
+
+	entry := Entry{
+		Time:        time.Now().UTC().Format(time.RFC3339Nano),
+		Caller:      caller.Name,
+		AuthMethod:  caller.AuthMethod,
+		SourceIP:    caller.SourceIP,
+		Operation:   operation,
+		Arguments:   redact(operation, arguments),
+		CommandUUID: commandUUID,
+		ResultCode:  resultCode,
+	}
+
+	err := appendEntry(entry)
+	if err != nil {
+		logger.Error("Can't write audit entry for " + operation + " " + commandUUID + ". Details: " + err.Error())
+	}
+}
+
+func appendEntry(entry Entry) error {
+	lock.Lock()
+	defer lock.Unlock()
+
+	file, err := os.OpenFile(filePath(), os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return errors.New("can't open audit log -> " + err.Error())
+	}
+	defer file.Close()
+
+	err = syscall.Flock(int(file.Fd()), syscall.LOCK_EX)
+	if err != nil {
+		return errors.New("can't lock audit log -> " + err.Error())
+	}
+	defer syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+
+	lastLine, err := readLastLine(file)
+	if err != nil {
+		return err
+	}
+
+	entry.Sequence = 1
+	entry.PrevHash = GENESIS_HASH
+	if lastLine != "" {
+		var last Entry
+		err = json.Unmarshal([]byte(lastLine), &last)
+		if err != nil {
+			return errors.New("can't parse the last entry of audit log -> " + err.Error())
+		}
+		entry.Sequence = last.Sequence + 1
+		entry.PrevHash = last.Hash
+	}
+	entry.Hash = entry.computeHash()
+
+	js, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(append(js, '\n'))
+	if err != nil {
+		return errors.New("can't write audit log -> " + err.Error())
+	}
+	return file.Sync()
+}
+
+// Reads the last non empty line of the file without reading the whole file.
+func readLastLine(file *os.File) (string, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	chunkSize := int64(4096)
+	end := info.Size()
+	var tail []byte
+	for end > 0 {
+		start := end - chunkSize
+		if start < 0 {
+			start = 0
+		}
+		chunk := make([]byte, end-start)
+		_, err = file.ReadAt(chunk, start)
+		if err != nil && err != io.EOF {
+			return "", errors.New("can't read audit log -> " + err.Error())
+		}
+		tail = append(chunk, tail...)
+		end = start
+
+		trimmed := strings.TrimRight(string(tail), "\n")
+		index := strings.LastIndexByte(trimmed, '\n')
+		if index >= 0 {
+			return trimmed[index+1:], nil
+		}
+		if end == 0 {
+			return trimmed, nil
+		}
+	}
+	return "", nil
+}
+
+// Result of the audit log verification.
+type VerificationReport struct {
+	Entries uint64 `json:"entries"`
+	Valid   bool   `json:"valid"`
+	// Line number of the first broken entry and the reason.
+	BrokenLine uint64 `json:"broken_line,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// Checks the hash chain of the audit log.
+func Verify() (*VerificationReport, error) {
+	file, err := os.Open(filePath())
+	if err != nil {
+		return nil, errors.New("can't open audit log -> " + err.Error())
+	}
+	defer file.Close()
+
+	report := &VerificationReport{Valid: true}
+	broken := func(line uint64, reason string) (*VerificationReport, error) {
+		report.Valid = false
+		report.BrokenLine = line
+		report.Error = reason
+		return report, nil
+	}
+
+	reader := bufio.NewReader(file)
+	prevHash := GENESIS_HASH
+	line := uint64(0)
+	for {
+		content, err := reader.ReadBytes('\n')
+		if len(content) > 0 {
+			line++
+			var entry Entry
+			if jsonErr := json.Unmarshal(content, &entry); jsonErr != nil {
+				return broken(line, "entry can't be parsed: "+jsonErr.Error())
+			}
+			if entry.Sequence != line {
+				return broken(line, "unexpected sequence number "+strconv.FormatUint(entry.Sequence, 10))
+			}
+			if entry.PrevHash != prevHash {
+				return broken(line, "previous hash doesn't match the hash of the previous entry")
+			}
+			if entry.computeHash() != entry.Hash {
+				return broken(line, "hash doesn't match the content of the entry")
+			}
+			prevHash = entry.Hash
+			report.Entries++
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.New("can't read audit log -> " + err.Error())
+		}
+	}
+	return report, nil
+}
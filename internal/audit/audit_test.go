@@ -0,0 +1,190 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/auth"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/conf"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/conf/conftest"
+)
+
+// Writes entries to the temporary audit log and returns it's lines.
+func writeTestLog(t *testing.T, count int) []string {
+	t.Helper()
+	conftest.Set(t, &conf.Params.Audit.FilePath, filepath.Join(t.TempDir(), "audit.log"))
+
+	ctx := auth.WithCaller(context.Background(), &auth.Caller{Name: "alice", AuthMethod: "api_key", SourceIP: "127.0.0.1"})
+	for i := 0; i < count; i++ {
+		Record(ctx, "SET:contractors/trust-line", []string{"1", "100", "1"}, "command-"+strconv.Itoa(i+1), 200)
+	}
+	return readTestLog(t)
+}
+
+func readTestLog(t *testing.T) []string {
+	t.Helper()
+	content, err := os.ReadFile(conf.Params.Audit.FilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimRight(string(content), "\n"), "\n")
+}
+
+func writeTestLines(t *testing.T, lines []string) {
+	t.Helper()
+	err := os.WriteFile(conf.Params.Audit.FilePath, []byte(strings.Join(lines, "\n")+"\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func parseTestEntry(t *testing.T, line string) Entry {
+	t.Helper()
+	var entry Entry
+	err := json.Unmarshal([]byte(line), &entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return entry
+}
+
+func marshalTestEntry(t *testing.T, entry Entry) string {
+	t.Helper()
+	js, err := json.Marshal(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(js)
+}
+
+func TestRecordChainsEntries(t *testing.T) {
+	lines := writeTestLog(t, 3)
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 lines, got %d", len(lines))
+	}
+	prevHash := GENESIS_HASH
+	for i, line := range lines {
+		entry := parseTestEntry(t, line)
+		if entry.Sequence != uint64(i+1) || entry.PrevHash != prevHash || entry.Hash != entry.computeHash() {
+			t.Fatalf("entry %d is not chained: %+v", i+1, entry)
+		}
+		if entry.Caller != "alice" || entry.AuthMethod != "api_key" || entry.SourceIP != "127.0.0.1" {
+			t.Fatalf("caller of the entry %d is not recorded: %+v", i+1, entry)
+		}
+		prevHash = entry.Hash
+	}
+}
+
+func TestVerify(t *testing.T) {
+	cases := []struct {
+		name string
+		// Changes lines of the valid log of 4 entries.
+		tamper             func(t *testing.T, lines []string) []string
+		expectedBrokenLine uint64
+		expectedError      string
+	}{
+		{
+			name:   "valid log",
+			tamper: func(t *testing.T, lines []string) []string { return lines },
+		},
+		{
+			name: "changed line",
+			tamper: func(t *testing.T, lines []string) []string {
+				entry := parseTestEntry(t, lines[1])
+				entry.Arguments[1] = "1000000"
+				lines[1] = marshalTestEntry(t, entry)
+				return lines
+			},
+			expectedBrokenLine: 2,
+			expectedError:      "hash doesn't match the content of the entry",
+		},
+		{
+			name: "changed text of the line",
+			tamper: func(t *testing.T, lines []string) []string {
+				lines[2] = strings.Replace(lines[2], `"caller":"alice"`, `"caller":"bob"`, 1)
+				return lines
+			},
+			expectedBrokenLine: 3,
+			expectedError:      "hash doesn't match the content of the entry",
+		},
+		{
+			name: "changed line with the recomputed hash",
+			tamper: func(t *testing.T, lines []string) []string {
+				entry := parseTestEntry(t, lines[1])
+				entry.ResultCode = 500
+				entry.Hash = entry.computeHash()
+				lines[1] = marshalTestEntry(t, entry)
+				return lines
+			},
+			expectedBrokenLine: 3,
+			expectedError:      "previous hash doesn't match the hash of the previous entry",
+		},
+		{
+			name: "removed line",
+			tamper: func(t *testing.T, lines []string) []string {
+				return append(lines[:1], lines[2:]...)
+			},
+			expectedBrokenLine: 2,
+			expectedError:      "unexpected sequence number 3",
+		},
+		{
+			name: "swapped lines",
+			tamper: func(t *testing.T, lines []string) []string {
+				lines[1], lines[2] = lines[2], lines[1]
+				return lines
+			},
+			expectedBrokenLine: 2,
+			expectedError:      "unexpected sequence number 3",
+		},
+		{
+			name: "line, that is not JSON",
+			tamper: func(t *testing.T, lines []string) []string {
+				lines[3] = "not an entry"
+				return lines
+			},
+			expectedBrokenLine: 4,
+			expectedError:      "entry can't be parsed",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			lines := writeTestLog(t, 4)
+			writeTestLines(t, c.tamper(t, lines))
+
+			report, err := Verify()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.expectedBrokenLine == 0 {
+				if !report.Valid || report.Entries != 4 {
+					t.Fatalf("valid log is reported as broken: %+v", report)
+				}
+				return
+			}
+			if report.Valid || report.BrokenLine != c.expectedBrokenLine || !strings.HasPrefix(report.Error, c.expectedError) {
+				t.Fatalf("expected broken line %d (%s), got %+v", c.expectedBrokenLine, c.expectedError, report)
+			}
+		})
+	}
+}
+
+func TestRecordContinuesExistingChain(t *testing.T) {
+	writeTestLog(t, 2)
+	Record(context.Background(), OPERATION_STOP, nil, "", 200)
+
+	lines := readTestLog(t)
+	last := parseTestEntry(t, lines[len(lines)-1])
+	if last.Sequence != 3 || last.PrevHash != parseTestEntry(t, lines[1]).Hash {
+		t.Fatalf("entry is not chained to the existing log: %+v", last)
+	}
+	report, err := Verify()
+	if err != nil || !report.Valid || report.Entries != 3 {
+		t.Fatalf("unexpected verification result: %+v, %v", report, err)
+	}
+}
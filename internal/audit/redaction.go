@@ -0,0 +1,89 @@
+package audit
+
+import (
+	"strconv"
+	"strings"
+)
+
+var (
+	// Names of the arguments, whose values are never written to the audit log.
+	// Name matches if it is equal to the listed one or ends with "_<listed one>" (e.g. "backup_passphrase"),
+	// case and "-" instead of "_" don't matter.
+	SECRET_ARGUMENTS = []string{
+		"crypto_key",
+		"passphrase",
+		"password",
+		"api_key",
+		"secret",
+		"signing_secret",
+		"hmac_secret",
+		"webhook_secret",
+		"token",
+	}
+
+	// Names of the positional arguments of the engine commands, that contain secrets.
+	// Arguments of the other operations could be passed as "<name>=<value>".
+	commandsArguments = map[string]func(arguments []string) []string{
+		// <contractor id> <crypto key> [<channel id on contractor side>]
+		"SET:channel/crypto-key": func([]string) []string {
+			return []string{"contractor_id", "crypto_key", "contractor_channel_id"}
+		},
+		// <addresses count> (<type> <address>)... [<crypto key> <contractor channel id>]
+		"INIT:contractors/channel": func(arguments []string) []string {
+			count, err := strconv.Atoi(firstArgument(arguments))
+			if err != nil || count < 0 || count > len(arguments) {
+				// Unknown format, so all the arguments after the addresses count are hidden.
+				names := []string{"addresses_count"}
+				for range arguments {
+					names = append(names, "secret")
+				}
+				return names
+			}
+			names := []string{"addresses_count"}
+			for i := 0; i < count; i++ {
+				names = append(names, "address_type", "address")
+			}
+			return append(names, "crypto_key", "contractor_channel_id")
+		},
+	}
+)
+
+// Returns true if the value of the argument with the name must not be written to the audit log.
+func isSecretArgument(name string) bool {
+	name = strings.ReplaceAll(strings.ToLower(strings.TrimSpace(name)), "-", "_")
+	for _, secret := range SECRET_ARGUMENTS {
+		if name == secret || strings.HasSuffix(name, "_"+secret) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns copy of the operation arguments, where the values of the secret arguments are replaced.
+func redact(operation string, arguments []string) []string {
+	result := make([]string, len(arguments))
+	copy(result, arguments)
+
+	var names []string
+	if commandArguments, isPresent := commandsArguments[operation]; isPresent {
+		names = commandArguments(arguments)
+	}
+	for i, argument := range result {
+		if i < len(names) && isSecretArgument(names[i]) {
+			result[i] = REDACTED_VALUE
+			continue
+		}
+		name, _, isNamed := strings.Cut(argument, "=")
+		if isNamed && isSecretArgument(name) {
+			result[i] = name + "=" + REDACTED_VALUE
+		}
+	}
+	return result
+}
+
+func firstArgument(arguments []string) string {
+	if len(arguments) == 0 {
+		return ""
+	}
+	return arguments[0]
+}
@@ -0,0 +1,81 @@
+package audit
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/conf"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/conf/conftest"
+)
+
+func TestRedact(t *testing.T) {
+	cases := []struct {
+		name      string
+		operation string
+		arguments []string
+		expected  []string
+	}{
+		{"crypto key of channel", "SET:channel/crypto-key",
+			[]string{"7", "crypto-key-1", "3"}, []string{"7", REDACTED_VALUE, "3"}},
+		{"crypto key of new channel", "INIT:contractors/channel",
+			[]string{"2", "12", "127.0.0.1:2000", "12", "127.0.0.1:2001", "crypto-key-1", "3"},
+			[]string{"2", "12", "127.0.0.1:2000", "12", "127.0.0.1:2001", REDACTED_VALUE, "3"}},
+		{"new channel without crypto key", "INIT:contractors/channel",
+			[]string{"1", "12", "127.0.0.1:2000"}, []string{"1", "12", "127.0.0.1:2000"}},
+		{"new channel of unknown format", "INIT:contractors/channel",
+			[]string{"x", "crypto-key-1"}, []string{"x", REDACTED_VALUE}},
+		{"named secrets", OPERATION_BACKUP,
+			[]string{"passphrase=secret-1", "Signing-Secret=secret-2", "webhook_secret=secret-3", "backup_passphrase=secret-4", "api-key=secret-5", "archive=backup.tar.gz"},
+			[]string{"passphrase=***", "Signing-Secret=***", "webhook_secret=***", "backup_passphrase=***", "api-key=***", "archive=backup.tar.gz"}},
+		{"other operation", "SET:contractors/trust-line",
+			[]string{"1", "100", "1"}, []string{"1", "100", "1"}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			arguments := slices.Clone(c.arguments)
+			redacted := redact(c.operation, arguments)
+			if !slices.Equal(redacted, c.expected) {
+				t.Fatalf("expected %v, got %v", c.expected, redacted)
+			}
+			if !slices.Equal(arguments, c.arguments) {
+				t.Fatal("arguments of the operation are changed")
+			}
+		})
+	}
+}
+
+func TestSecretsAreNotWritten(t *testing.T) {
+	conftest.Set(t, &conf.Params.Audit.FilePath, filepath.Join(t.TempDir(), "audit.log"))
+	secrets := []string{"crypto-key-1", "crypto-key-2", "passphrase-1", "password-1", "api-key-1", "hmac-secret-1", "signing-secret-1", "webhook-secret-1"}
+
+	ctx := context.Background()
+	Record(ctx, "SET:channel/crypto-key", []string{"7", "crypto-key-1"}, "command-1", 200)
+	Record(ctx, "INIT:contractors/channel", []string{"1", "12", "127.0.0.1:2000", "crypto-key-2", "3"}, "command-2", 200)
+	Record(ctx, OPERATION_BACKUP, []string{
+		"passphrase=passphrase-1",
+		"password=password-1",
+		"api_key=api-key-1",
+		"hmac_secret=hmac-secret-1",
+		"signing_secret=signing-secret-1",
+		"webhook_secret=webhook-secret-1",
+	}, "", 200)
+
+	content, err := os.ReadFile(conf.Params.Audit.FilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range secrets {
+		if strings.Contains(string(content), secret) {
+			t.Fatalf("secret %s is written to the audit log", secret)
+		}
+	}
+	// Redacted entries are chained as usual.
+	report, err := Verify()
+	if err != nil || !report.Valid || report.Entries != 3 {
+		t.Fatalf("unexpected verification %+v, %v", report, err)
+	}
+}
@@ -0,0 +1,179 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"os/user"
+	"slices"
+	"strings"
+
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/conf"
+)
+
+var (
+	// Scopes of the API callers.
+	SCOPE_READ                   = "read"
+	SCOPE_SETTLEMENT_LINES_WRITE = "settlement-lines:write"
+	SCOPE_CHANNELS_WRITE         = "channels:write"
+	SCOPE_PAYMENTS_CREATE        = "payments:create"
+	// Approval or rejection of the payments, that are waiting for the approval.
+	SCOPE_APPROVER = "approver"
+	// Admin scope grants all other scopes.
+	SCOPE_ADMIN = "admin"
+
+	// Methods of the caller authentication.
+	AUTH_METHOD_API_KEY     = "api-key"
+	AUTH_METHOD_SIGNATURE   = "signature"
+	AUTH_METHOD_CERTIFICATE = "certificate"
+	AUTH_METHOD_NONE        = "none"
+	// Commands, executed by the CLI itself (not via HTTP API).
+	AUTH_METHOD_LOCAL = "local"
+	// Payments, executed by the scheduler on behalf of the schedule owner.
+	AUTH_METHOD_SCHEDULER = "scheduler"
+	// Jobs, created by the scheduled maintenance tasks.
+	AUTH_METHOD_MAINTENANCE = "maintenance"
+
+	// Name of the key from the legacy security.api_key setting.
+	LEGACY_API_KEY_NAME = "default"
+
+	API_KEY_HASH_PREFIX = "sha256:"
+)
+
+// Identity of the API caller.
+type Caller struct {
+	Name       string
+	AuthMethod string
+	Scopes     []string
+	// Equivalents, which caller is allowed to operate with.
+	// Empty list means all equivalents.
+	Equivalents []string
+	// Address, from which the request was received.
+	SourceIP string
+}
+
+type callerContextKey struct{}
+
+// Returns copy of the context, that carries the caller.
+func WithCaller(ctx context.Context, caller *Caller) context.Context {
+	return context.WithValue(ctx, callerContextKey{}, caller)
+}
+
+// Returns caller, on behalf of which the context was created, or nil.
+func CallerFromContext(ctx context.Context) *Caller {
+	caller, _ := ctx.Value(callerContextKey{}).(*Caller)
+	return caller
+}
+
+// Returns caller, that represents the user, who runs the CLI commands.
+func LocalCaller() *Caller {
+	name := "unknown"
+	if currentUser, err := user.Current(); err == nil {
+		name = currentUser.Username
+	}
+	return &Caller{
+		Name:       "local:" + name,
+		AuthMethod: AUTH_METHOD_LOCAL,
+		Scopes:     []string{SCOPE_ADMIN},
+		SourceIP:   "local",
+	}
+}
+
+func (c *Caller) HasScope(scope string) bool {
+	return slices.Contains(c.Scopes, SCOPE_ADMIN) || slices.Contains(c.Scopes, scope)
+}
+
+func (c *Caller) IsRestrictedByEquivalents() bool {
+	return len(c.Equivalents) > 0
+}
+
+func (c *Caller) IsEquivalentAllowed(equivalent string) bool {
+	return !c.IsRestrictedByEquivalents() || slices.Contains(c.Equivalents, equivalent)
+}
+
+// Identifies the caller of the request.
+// Caller is identified by the verified TLS client certificate (if it is mapped in the settings),
+// by the request signature (see signature.go) or by the api-key header.
+// If there are no keys in the settings, anonymous caller with admin scope is returned.
+func Authenticate(r *http.Request) (*Caller, error) {
+	caller := certificateCaller(r)
+	if caller != nil {
+		return caller, nil
+	}
+
+	if isSignedRequest(r) {
+		return signatureCaller(r)
+	}
+
+	settings := conf.Params.Security
+	if settings.ApiKey == "" && len(settings.ApiKeys) == 0 {
+		return &Caller{Name: "anonymous", AuthMethod: AUTH_METHOD_NONE, Scopes: []string{SCOPE_ADMIN}}, nil
+	}
+
+	apiKey := r.Header.Get("api-key")
+	if apiKey == "" {
+		return nil, errors.New("api-key is missing")
+	}
+
+	if settings.ApiKey != "" && subtle.ConstantTimeCompare([]byte(apiKey), []byte(settings.ApiKey)) == 1 {
+		return &Caller{Name: LEGACY_API_KEY_NAME, AuthMethod: AUTH_METHOD_API_KEY, Scopes: []string{SCOPE_ADMIN}}, nil
+	}
+
+	hash := HashApiKey(apiKey)
+	for _, key := range settings.ApiKeys {
+		if key.Hash == "" {
+			// Key could be used only for the requests signing.
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(hash), []byte(strings.ToLower(key.Hash))) == 1 {
+			return &Caller{
+				Name:        key.Name,
+				AuthMethod:  AUTH_METHOD_API_KEY,
+				Scopes:      key.Scopes,
+				Equivalents: key.Equivalents,
+			}, nil
+		}
+	}
+	return nil, errors.New("invalid api-key")
+}
+
+// Returns caller, if the request was made with verified TLS client certificate
+// and it's subject is mapped in security.client_certificates.
+func certificateCaller(r *http.Request) *Caller {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.PeerCertificates) == 0 {
+		return nil
+	}
+
+	subject := r.TLS.PeerCertificates[0].Subject.CommonName
+	for _, client := range conf.Params.Security.ClientCertificates {
+		if client.Subject == subject {
+			return &Caller{
+				Name:        "cert:" + subject,
+				AuthMethod:  AUTH_METHOD_CERTIFICATE,
+				Scopes:      client.Scopes,
+				Equivalents: client.Equivalents,
+			}
+		}
+	}
+	return nil
+}
+
+// Returns hash of the API key in the form, that is stored in the settings.
+func HashApiKey(apiKey string) string {
+	hash := sha256.Sum256([]byte(apiKey))
+	return API_KEY_HASH_PREFIX + hex.EncodeToString(hash[:])
+}
+
+// Generates new random API key.
+func GenerateApiKey() (string, error) {
+	buffer := make([]byte, 32)
+	_, err := rand.Read(buffer)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buffer), nil
+}
@@ -0,0 +1,189 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/conf"
+)
+
+var (
+	// Headers of the signed request.
+	SIGNATURE_KEY_HEADER       = "X-Signature-Key"
+	SIGNATURE_TIMESTAMP_HEADER = "X-Signature-Timestamp"
+	SIGNATURE_NONCE_HEADER     = "X-Signature-Nonce"
+	SIGNATURE_HEADER           = "X-Signature"
+
+	// Allowed difference between the timestamp of the request and the server time.
+	DEFAULT_SIGNATURE_WINDOW = time.Minute * 5
+
+	MAX_NONCE_LENGTH = 128
+)
+
+// Returns the string, that is signed by the client:
+// method, path with the query, timestamp, nonce and SHA-256 of the body, separated by "\n".
+func canonicalRequest(method, requestURI, timestamp, nonce string, body []byte) string {
+	bodyHash := sha256.Sum256(body)
+	return method + "\n" +
+		requestURI + "\n" +
+		timestamp + "\n" +
+		nonce + "\n" +
+		hex.EncodeToString(bodyHash[:])
+}
+
+func computeSignature(secret, canonical string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(canonical))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Signs the request by the signing secret of the API key.
+// Sets signature headers, so the request could be sent as is.
+// Body of the request is read and restored.
+func SignRequest(r *http.Request, keyName, secret string) error {
+	body, err := readBody(r)
+	if err != nil {
+		return err
+	}
+
+	nonceBytes := make([]byte, 16)
+	_, err = rand.Read(nonceBytes)
+	if err != nil {
+		return err
+	}
+	nonce := hex.EncodeToString(nonceBytes)
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	canonical := canonicalRequest(r.Method, r.URL.RequestURI(), timestamp, nonce, body)
+	r.Header.Set(SIGNATURE_KEY_HEADER, keyName)
+	r.Header.Set(SIGNATURE_TIMESTAMP_HEADER, timestamp)
+	r.Header.Set(SIGNATURE_NONCE_HEADER, nonce)
+	r.Header.Set(SIGNATURE_HEADER, computeSignature(secret, canonical))
+	return nil
+}
+
+// Reads body of the request and replaces it with the copy, so it could be read again.
+func readBody(r *http.Request) ([]byte, error) {
+	if r.Body == nil {
+		return nil, nil
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, errors.New("can't read request body -> " + err.Error())
+	}
+	r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+func isSignedRequest(r *http.Request) bool {
+	return r.Header.Get(SIGNATURE_HEADER) != ""
+}
+
+// Checks signature of the request and returns the caller of the signing key.
+func signatureCaller(r *http.Request) (*Caller, error) {
+	keyName := r.Header.Get(SIGNATURE_KEY_HEADER)
+	timestamp := r.Header.Get(SIGNATURE_TIMESTAMP_HEADER)
+	nonce := r.Header.Get(SIGNATURE_NONCE_HEADER)
+	signature := r.Header.Get(SIGNATURE_HEADER)
+	if keyName == "" || timestamp == "" || nonce == "" {
+		return nil, errors.New("signature headers are incomplete")
+	}
+	if len(nonce) > MAX_NONCE_LENGTH {
+		return nil, errors.New("signature nonce is too long")
+	}
+
+	var key *conf.ApiKeySettings
+	for i := range conf.Params.Security.ApiKeys {
+		if conf.Params.Security.ApiKeys[i].Name == keyName && conf.Params.Security.ApiKeys[i].SigningSecret != "" {
+			key = &conf.Params.Security.ApiKeys[i]
+			break
+		}
+	}
+	if key == nil {
+		return nil, errors.New("unknown signing key " + keyName)
+	}
+
+	unixTime, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return nil, errors.New("invalid signature timestamp " + timestamp)
+	}
+	window := signatureWindow()
+	requestTime := time.Unix(unixTime, 0)
+	if time.Since(requestTime) > window || time.Until(requestTime) > window {
+		return nil, errors.New("signature timestamp is out of the allowed window")
+	}
+
+	body, err := readBody(r)
+	if err != nil {
+		return nil, err
+	}
+	canonical := canonicalRequest(r.Method, r.URL.RequestURI(), timestamp, nonce, body)
+	expected := computeSignature(key.SigningSecret, canonical)
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return nil, errors.New("invalid signature")
+	}
+
+	// Nonce is remembered only for the valid signatures,
+	// so the unsigned garbage can't fill the cache.
+	if !nonces.remember(keyName+":"+nonce, requestTime.Add(window)) {
+		return nil, errors.New("signature nonce was already used")
+	}
+
+	return &Caller{
+		Name:        key.Name,
+		AuthMethod:  AUTH_METHOD_SIGNATURE,
+		Scopes:      key.Scopes,
+		Equivalents: key.Equivalents,
+	}, nil
+}
+
+func signatureWindow() time.Duration {
+	if conf.Params.Security.SignatureWindowSeconds <= 0 {
+		return DEFAULT_SIGNATURE_WINDOW
+	}
+	return time.Second * time.Duration(conf.Params.Security.SignatureWindowSeconds)
+}
+
+// Nonces of the signed requests, that were already accepted.
+// Nonces are kept until the timestamp of their request leaves the allowed window,
+// after that the request would be rejected by the timestamp check anyway.
+type noncesCache struct {
+	lock          sync.Mutex
+	expirations   map[string]time.Time
+	lastCleanupAt time.Time
+}
+
+var nonces = &noncesCache{expirations: make(map[string]time.Time)}
+
+// Returns false if the nonce is already present.
+func (c *noncesCache) remember(nonce string, expiresAt time.Time) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	now := time.Now()
+	if now.Sub(c.lastCleanupAt) >= time.Minute {
+		c.lastCleanupAt = now
+		for cachedNonce, cachedExpiresAt := range c.expirations {
+			if now.After(cachedExpiresAt) {
+				delete(c.expirations, cachedNonce)
+			}
+		}
+	}
+
+	_, isPresent := c.expirations[nonce]
+	if isPresent {
+		return false
+	}
+	c.expirations[nonce] = expiresAt
+	return true
+}
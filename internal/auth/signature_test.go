@@ -0,0 +1,204 @@
+package auth
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/conf"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/conf/conftest"
+)
+
+var (
+	TEST_KEY_NAME       = "signer"
+	TEST_SIGNING_SECRET = "signing-secret"
+	TEST_REQUEST_URI    = "/api/v1/node/contractors/transactions/1/?amount=10&contractor_address=12-127.0.0.1:2000"
+	TEST_REQUEST_BODY   = `{"payload":"invoice"}`
+)
+
+// Configures the signing key and the empty nonces cache.
+func useTestSigningKey(t *testing.T) {
+	t.Helper()
+	conftest.Set(t, &conf.Params.Security, conf.SecuritySettings{
+		ApiKeys: []conf.ApiKeySettings{{
+			Name:          TEST_KEY_NAME,
+			Scopes:        []string{SCOPE_ADMIN},
+			SigningSecret: TEST_SIGNING_SECRET,
+		}},
+	})
+	conftest.Set(t, &nonces, &noncesCache{expirations: make(map[string]time.Time)})
+}
+
+// Builds request, that is signed the way described in the readme.
+func signedTestRequest(keyName, secret string, timestamp time.Time, nonce string) *http.Request {
+	r := httptest.NewRequest("POST", TEST_REQUEST_URI, strings.NewReader(TEST_REQUEST_BODY))
+	unixTime := strconv.FormatInt(timestamp.Unix(), 10)
+	canonical := canonicalRequest("POST", TEST_REQUEST_URI, unixTime, nonce, []byte(TEST_REQUEST_BODY))
+	r.Header.Set(SIGNATURE_KEY_HEADER, keyName)
+	r.Header.Set(SIGNATURE_TIMESTAMP_HEADER, unixTime)
+	r.Header.Set(SIGNATURE_NONCE_HEADER, nonce)
+	r.Header.Set(SIGNATURE_HEADER, computeSignature(secret, canonical))
+	return r
+}
+
+func TestSignatureTimestampWindow(t *testing.T) {
+	cases := []struct {
+		name          string
+		windowSeconds int
+		offset        time.Duration
+		isAccepted    bool
+	}{
+		{"current time", 0, 0, true},
+		{"past inside the default window", 0, -DEFAULT_SIGNATURE_WINDOW + 10*time.Second, true},
+		{"past outside the default window", 0, -DEFAULT_SIGNATURE_WINDOW - 10*time.Second, false},
+		{"future inside the default window", 0, DEFAULT_SIGNATURE_WINDOW - 10*time.Second, true},
+		{"future outside the default window", 0, DEFAULT_SIGNATURE_WINDOW + 10*time.Second, false},
+		{"past inside the configured window", 30, -20 * time.Second, true},
+		{"past outside the configured window", 30, -40 * time.Second, false},
+		{"future outside the configured window", 30, 40 * time.Second, false},
+	}
+
+	for i, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			useTestSigningKey(t)
+			conf.Params.Security.SignatureWindowSeconds = c.windowSeconds
+
+			r := signedTestRequest(TEST_KEY_NAME, TEST_SIGNING_SECRET, time.Now().Add(c.offset), "nonce-"+strconv.Itoa(i))
+			caller, err := Authenticate(r)
+			if !c.isAccepted {
+				if err == nil || !strings.Contains(err.Error(), "out of the allowed window") {
+					t.Fatalf("request is not rejected by the timestamp: %+v, %v", caller, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("request is rejected: %v", err)
+			}
+			if caller.Name != TEST_KEY_NAME || caller.AuthMethod != AUTH_METHOD_SIGNATURE {
+				t.Fatalf("unexpected caller %+v", caller)
+			}
+		})
+	}
+}
+
+func TestSignatureNonceReplay(t *testing.T) {
+	useTestSigningKey(t)
+	now := time.Now()
+
+	steps := []struct {
+		name          string
+		request       *http.Request
+		expectedError string
+	}{
+		{"first request", signedTestRequest(TEST_KEY_NAME, TEST_SIGNING_SECRET, now, "nonce-1"), ""},
+		{"replayed request", signedTestRequest(TEST_KEY_NAME, TEST_SIGNING_SECRET, now, "nonce-1"), "nonce was already used"},
+		{"replay with the other timestamp", signedTestRequest(TEST_KEY_NAME, TEST_SIGNING_SECRET, now.Add(time.Second), "nonce-1"), "nonce was already used"},
+		{"request with the other nonce", signedTestRequest(TEST_KEY_NAME, TEST_SIGNING_SECRET, now, "nonce-2"), ""},
+		// Invalid signature doesn't burn the nonce, so it's still accepted with the valid one.
+		{"invalid signature", signedTestRequest(TEST_KEY_NAME, "other-secret", now, "nonce-3"), "invalid signature"},
+		{"valid signature after the invalid one", signedTestRequest(TEST_KEY_NAME, TEST_SIGNING_SECRET, now, "nonce-3"), ""},
+		{"unknown key", signedTestRequest("unknown", TEST_SIGNING_SECRET, now, "nonce-4"), "unknown signing key"},
+		{"too long nonce", signedTestRequest(TEST_KEY_NAME, TEST_SIGNING_SECRET, now, strings.Repeat("n", MAX_NONCE_LENGTH+1)), "nonce is too long"},
+	}
+
+	for _, step := range steps {
+		_, err := Authenticate(step.request)
+		if step.expectedError == "" {
+			if err != nil {
+				t.Fatalf("%s: request is rejected: %v", step.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), step.expectedError) {
+			t.Fatalf("%s: expected error %q, got %v", step.name, step.expectedError, err)
+		}
+	}
+}
+
+func TestSignatureCoversRequest(t *testing.T) {
+	cases := []struct {
+		name   string
+		change func(r *http.Request)
+	}{
+		{"changed query", func(r *http.Request) { r.URL.RawQuery = "amount=1000&contractor_address=12-127.0.0.1:2000" }},
+		{"changed method", func(r *http.Request) { r.Method = "PUT" }},
+		{"changed body", func(r *http.Request) { r.Body = httptest.NewRequest("POST", "/", strings.NewReader("{}")).Body }},
+		{"changed nonce", func(r *http.Request) { r.Header.Set(SIGNATURE_NONCE_HEADER, "other-nonce") }},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			useTestSigningKey(t)
+			r := signedTestRequest(TEST_KEY_NAME, TEST_SIGNING_SECRET, time.Now(), "nonce")
+			c.change(r)
+			_, err := Authenticate(r)
+			if err == nil || err.Error() != "invalid signature" {
+				t.Fatalf("changed request is not rejected: %v", err)
+			}
+		})
+	}
+}
+
+func TestSignRequest(t *testing.T) {
+	cases := []struct {
+		name   string
+		method string
+		body   string
+		secret string
+		// Expected error of the signature check, empty if the request is accepted.
+		expectedError string
+	}{
+		{"request with body", "POST", TEST_REQUEST_BODY, TEST_SIGNING_SECRET, ""},
+		{"request without body", "GET", "", TEST_SIGNING_SECRET, ""},
+		{"request signed by the other secret", "POST", TEST_REQUEST_BODY, "other-secret", "invalid signature"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			useTestSigningKey(t)
+			r := httptest.NewRequest(c.method, TEST_REQUEST_URI, strings.NewReader(c.body))
+			err := SignRequest(r, TEST_KEY_NAME, c.secret)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			caller, err := signatureCaller(r)
+			if c.expectedError != "" {
+				if err == nil || err.Error() != c.expectedError {
+					t.Fatalf("expected error %q, got %+v, %v", c.expectedError, caller, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("signed request is rejected: %v", err)
+			}
+			if caller.Name != TEST_KEY_NAME || caller.AuthMethod != AUTH_METHOD_SIGNATURE {
+				t.Fatalf("unexpected caller %+v", caller)
+			}
+			// Body is restored after the signing and the check, so the handler reads it as is.
+			body, err := io.ReadAll(r.Body)
+			if err != nil || string(body) != c.body {
+				t.Fatalf("body is not restored: %q, %v", body, err)
+			}
+		})
+	}
+}
+
+func TestSignRequestUsesNewNonce(t *testing.T) {
+	useTestSigningKey(t)
+	for i := 0; i < 2; i++ {
+		r := httptest.NewRequest("POST", TEST_REQUEST_URI, strings.NewReader(TEST_REQUEST_BODY))
+		err := SignRequest(r, TEST_KEY_NAME, TEST_SIGNING_SECRET)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = signatureCaller(r)
+		if err != nil {
+			t.Fatalf("request %d is rejected: %v", i+1, err)
+		}
+	}
+}
@@ -0,0 +1,305 @@
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/logger"
+)
+
+var (
+	MANIFEST_NAME    = "manifest.json"
+	MANIFEST_VERSION = 1
+	// Files of the work directory are placed under this directory of the archive.
+	WORK_DIR_PREFIX = "workdir/"
+	// Files of the work directory, that are not backed up.
+	EXCLUDED_FILES = []string{"process.pid"}
+
+	MAX_MANIFEST_SIZE = int64(64 * 1024 * 1024)
+)
+
+var (
+	ErrInvalidArchive   = errors.New("invalid archive")
+	ErrChecksumMismatch = errors.New("archive is corrupted, checksums don't match the manifest")
+)
+
+type ManifestFile struct {
+	// Path relative to the work directory, "/" separated.
+	Path   string      `json:"path"`
+	Size   int64       `json:"size"`
+	Mode   fs.FileMode `json:"mode"`
+	SHA256 string      `json:"sha256"`
+}
+
+// Manifest is the last entry of the archive, it lists the files with their checksums.
+type Manifest struct {
+	Version   int            `json:"version"`
+	CreatedAt string         `json:"created_at"`
+	WorkDir   string         `json:"work_dir"`
+	Files     []ManifestFile `json:"files"`
+}
+
+func (m *Manifest) size() int64 {
+	var size int64
+	for _, file := range m.Files {
+		size += file.Size
+	}
+	return size
+}
+
+func isExcluded(relativePath string) bool {
+	for _, excluded := range EXCLUDED_FILES {
+		if relativePath == excluded {
+			return true
+		}
+	}
+	return false
+}
+
+// Writes compressed archive of the work directory. If passphrase is not empty, archive is encrypted.
+// Only directories and regular files are archived (fifo files are recreated by the engine).
+func writeArchive(out io.Writer, workDir string, passphrase []byte) (*Manifest, error) {
+	var encryptor *encryptingWriter
+	if len(passphrase) > 0 {
+		var err error
+		encryptor, err = newEncryptingWriter(out, passphrase)
+		if err != nil {
+			return nil, err
+		}
+		out = encryptor
+	}
+	compressor := gzip.NewWriter(out)
+	archive := tar.NewWriter(compressor)
+
+	manifest := &Manifest{
+		Version:   MANIFEST_VERSION,
+		CreatedAt: time.Now().UTC().Format(time.RFC3339),
+		WorkDir:   workDir,
+		Files:     []ManifestFile{},
+	}
+	err := filepath.WalkDir(workDir, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		relativePath, err := filepath.Rel(workDir, filePath)
+		if err != nil {
+			return err
+		}
+		relativePath = filepath.ToSlash(relativePath)
+		if relativePath == "." || isExcluded(relativePath) {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case info.IsDir():
+			return archive.WriteHeader(&tar.Header{
+				Typeflag: tar.TypeDir,
+				Name:     WORK_DIR_PREFIX + relativePath + "/",
+				Mode:     int64(info.Mode().Perm()),
+				ModTime:  info.ModTime(),
+			})
+		case info.Mode().IsRegular():
+			file, err := archiveFile(archive, filePath, relativePath, info)
+			if err != nil {
+				return err
+			}
+			manifest.Files = append(manifest.Files, file)
+			return nil
+		default:
+			logger.Info("Backup: " + relativePath + " is not a regular file, it's skipped")
+			return nil
+		}
+	})
+	if err != nil {
+		return nil, errors.New("can't archive work directory -> " + err.Error())
+	}
+
+	js, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, errors.New("can't marshal manifest -> " + err.Error())
+	}
+	err = archive.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     MANIFEST_NAME,
+		Mode:     0600,
+		Size:     int64(len(js)),
+		ModTime:  time.Now(),
+	})
+	if err == nil {
+		_, err = archive.Write(js)
+	}
+	if err == nil {
+		err = archive.Close()
+	}
+	if err == nil {
+		err = compressor.Close()
+	}
+	if err == nil && encryptor != nil {
+		err = encryptor.Close()
+	}
+	if err != nil {
+		return nil, errors.New("can't write archive -> " + err.Error())
+	}
+	return manifest, nil
+}
+
+func archiveFile(archive *tar.Writer, filePath, relativePath string, info fs.FileInfo) (ManifestFile, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return ManifestFile{}, err
+	}
+	defer file.Close()
+
+	err = archive.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     WORK_DIR_PREFIX + relativePath,
+		Mode:     int64(info.Mode().Perm()),
+		Size:     info.Size(),
+		ModTime:  info.ModTime(),
+	})
+	if err != nil {
+		return ManifestFile{}, err
+	}
+	hash := sha256.New()
+	_, err = io.CopyN(io.MultiWriter(archive, hash), file, info.Size())
+	if err != nil {
+		return ManifestFile{}, errors.New("can't read " + relativePath + " -> " + err.Error())
+	}
+	return ManifestFile{
+		Path:   relativePath,
+		Size:   info.Size(),
+		Mode:   info.Mode().Perm(),
+		SHA256: hex.EncodeToString(hash.Sum(nil)),
+	}, nil
+}
+
+// Extracts the archive into the directory, that must be absent,
+// and verifies the extracted files against the manifest.
+// Returns the manifest and true if the archive was encrypted.
+func extractArchive(in io.Reader, dir string, passphrase []byte) (*Manifest, bool, error) {
+	isEncrypted, in, err := isEncrypted(in)
+	if err != nil {
+		return nil, false, errors.New("can't read archive -> " + err.Error())
+	}
+	if isEncrypted {
+		in, err = newDecryptingReader(in, passphrase)
+		if err != nil {
+			return nil, true, err
+		}
+	}
+	decompressor, err := gzip.NewReader(in)
+	if err != nil {
+		return nil, isEncrypted, wrapArchiveError(err)
+	}
+	archive := tar.NewReader(decompressor)
+
+	err = os.Mkdir(dir, 0700)
+	if err != nil {
+		return nil, isEncrypted, errors.New("can't create directory " + dir + " -> " + err.Error())
+	}
+
+	var manifest *Manifest
+	checksums := make(map[string]string)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, isEncrypted, wrapArchiveError(err)
+		}
+		// Manifest is the last entry.
+		if manifest != nil {
+			return nil, isEncrypted, ErrInvalidArchive
+		}
+
+		if header.Name == MANIFEST_NAME {
+			manifest = &Manifest{}
+			err = json.NewDecoder(io.LimitReader(archive, MAX_MANIFEST_SIZE)).Decode(manifest)
+			if err != nil || manifest.Version != MANIFEST_VERSION {
+				return nil, isEncrypted, ErrInvalidArchive
+			}
+			continue
+		}
+
+		// Entries, that point outside of the directory, are rejected.
+		relativePath := strings.TrimSuffix(strings.TrimPrefix(header.Name, WORK_DIR_PREFIX), "/")
+		if !strings.HasPrefix(header.Name, WORK_DIR_PREFIX) ||
+			path.Clean(relativePath) != relativePath || !filepath.IsLocal(relativePath) {
+			return nil, isEncrypted, ErrInvalidArchive
+		}
+		filePath := filepath.Join(dir, filepath.FromSlash(relativePath))
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(filePath, fs.FileMode(header.Mode).Perm()|0700)
+		case tar.TypeReg:
+			checksums[relativePath], err = extractFile(archive, filePath, fs.FileMode(header.Mode).Perm())
+		default:
+			return nil, isEncrypted, ErrInvalidArchive
+		}
+		if err != nil {
+			return nil, isEncrypted, errors.New("can't extract " + relativePath + " -> " + err.Error())
+		}
+	}
+	if manifest == nil {
+		return nil, isEncrypted, ErrInvalidArchive
+	}
+
+	if len(checksums) != len(manifest.Files) {
+		return nil, isEncrypted, ErrChecksumMismatch
+	}
+	for _, file := range manifest.Files {
+		if checksums[file.Path] != file.SHA256 {
+			return nil, isEncrypted, ErrChecksumMismatch
+		}
+	}
+	return manifest, isEncrypted, nil
+}
+
+func extractFile(archive io.Reader, filePath string, mode fs.FileMode) (string, error) {
+	err := os.MkdirAll(filepath.Dir(filePath), 0700)
+	if err != nil {
+		return "", err
+	}
+	file, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode|0600)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.New()
+	_, err = io.Copy(io.MultiWriter(file, hash), archive)
+	if err == nil {
+		err = file.Sync()
+	}
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", wrapArchiveError(err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// Decryption errors are reported as is, other errors of the reading mean, that the archive is corrupted.
+func wrapArchiveError(err error) error {
+	if err == ErrDecryption || err == ErrInvalidArchive {
+		return err
+	}
+	return errors.New(ErrInvalidArchive.Error() + " -> " + err.Error())
+}
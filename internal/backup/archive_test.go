@@ -0,0 +1,168 @@
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type testEntry struct {
+	header  tar.Header
+	content string
+}
+
+// Writes the archive of the entries.
+// Manifest, that lists the regular files of the work directory, is added, unless the last entry is the manifest.
+func writeTestArchive(t *testing.T, entries []testEntry) []byte {
+	t.Helper()
+	if len(entries) == 0 || entries[len(entries)-1].header.Name != MANIFEST_NAME {
+		files := map[string]string{}
+		for _, entry := range entries {
+			if entry.header.Typeflag == tar.TypeReg && strings.HasPrefix(entry.header.Name, WORK_DIR_PREFIX) {
+				files[strings.TrimPrefix(entry.header.Name, WORK_DIR_PREFIX)] = entry.content
+			}
+		}
+		entries = append(entries, testManifest(t, files))
+	}
+
+	out := &bytes.Buffer{}
+	compressor := gzip.NewWriter(out)
+	archive := tar.NewWriter(compressor)
+	var err error
+	for _, entry := range entries {
+		entry.header.Mode = 0600
+		entry.header.Size = int64(len(entry.content))
+		err = archive.WriteHeader(&entry.header)
+		if err == nil {
+			_, err = archive.Write([]byte(entry.content))
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = archive.Close()
+	if err == nil {
+		err = compressor.Close()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out.Bytes()
+}
+
+func testManifest(t *testing.T, files map[string]string) testEntry {
+	t.Helper()
+	manifest := &Manifest{Version: MANIFEST_VERSION, Files: []ManifestFile{}}
+	for name, content := range files {
+		hash := sha256.Sum256([]byte(content))
+		manifest.Files = append(manifest.Files, ManifestFile{Path: name, SHA256: hex.EncodeToString(hash[:])})
+	}
+	js, err := json.Marshal(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testEntry{tar.Header{Typeflag: tar.TypeReg, Name: MANIFEST_NAME}, string(js)}
+}
+
+func TestArchiveRoundTrip(t *testing.T) {
+	useTestEncryption(t)
+	workDir := t.TempDir()
+	files := map[string]string{
+		"vtcpd.conf":          "{}",
+		"io/storageDB":        "settlement lines",
+		"io/keys/channel.key": "secret",
+		"process.pid":         "42",
+	}
+	for name, content := range files {
+		err := os.MkdirAll(filepath.Join(workDir, filepath.Dir(name)), 0700)
+		if err == nil {
+			err = os.WriteFile(filepath.Join(workDir, name), []byte(content), 0600)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, passphrase := range [][]byte{nil, TEST_PASSPHRASE} {
+		archive := &bytes.Buffer{}
+		written, err := writeArchive(archive, workDir, passphrase)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(written.Files) != len(files)-1 {
+			t.Fatalf("unexpected files of the manifest %+v", written.Files)
+		}
+
+		dir := filepath.Join(t.TempDir(), "extracted")
+		extracted, isEncrypted, err := extractArchive(archive, dir, passphrase)
+		if err != nil || isEncrypted != (passphrase != nil) || len(extracted.Files) != len(written.Files) {
+			t.Fatalf("unexpected extraction %+v, %v, %v", extracted, isEncrypted, err)
+		}
+		for name, content := range files {
+			extractedContent, err := os.ReadFile(filepath.Join(dir, name))
+			if isExcluded(name) {
+				if !os.IsNotExist(err) {
+					t.Fatalf("excluded file %s is extracted", name)
+				}
+				continue
+			}
+			if err != nil || string(extractedContent) != content {
+				t.Fatalf("unexpected content of %s: %q, %v", name, extractedContent, err)
+			}
+		}
+	}
+}
+
+func TestExtractInvalidArchive(t *testing.T) {
+	file := func(name, content string) testEntry {
+		return testEntry{tar.Header{Typeflag: tar.TypeReg, Name: name}, content}
+	}
+	cases := []struct {
+		name        string
+		entries     []testEntry
+		expectedErr error
+	}{
+		{"parent directory", []testEntry{file(WORK_DIR_PREFIX+"../escaped", "x")}, ErrInvalidArchive},
+		{"nested parent directory", []testEntry{file(WORK_DIR_PREFIX+"io/../../escaped", "x")}, ErrInvalidArchive},
+		{"absolute path", []testEntry{file(WORK_DIR_PREFIX+"/escaped", "x")}, ErrInvalidArchive},
+		{"outside of the work directory", []testEntry{file("../escaped", "x")}, ErrInvalidArchive},
+		{"not cleaned path", []testEntry{file(WORK_DIR_PREFIX+"io/./storageDB", "x")}, ErrInvalidArchive},
+		{"symbolic link", []testEntry{
+			{tar.Header{Typeflag: tar.TypeSymlink, Name: WORK_DIR_PREFIX + "link", Linkname: "../../escaped"}, ""},
+		}, ErrInvalidArchive},
+		{"entry after manifest", []testEntry{
+			testManifest(t, nil),
+			file(WORK_DIR_PREFIX+"vtcpd.conf", "{}"),
+		}, ErrInvalidArchive},
+		{"unknown version of manifest", []testEntry{file(MANIFEST_NAME, `{"version": 0}`)}, ErrInvalidArchive},
+		{"wrong checksum", []testEntry{
+			file(WORK_DIR_PREFIX+"vtcpd.conf", "{}"),
+			testManifest(t, map[string]string{"vtcpd.conf": "[]"}),
+		}, ErrChecksumMismatch},
+		{"file absent in manifest", []testEntry{
+			file(WORK_DIR_PREFIX+"vtcpd.conf", "{}"),
+			testManifest(t, nil),
+		}, ErrChecksumMismatch},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			archive := writeTestArchive(t, c.entries)
+			parent := t.TempDir()
+			_, _, err := extractArchive(bytes.NewReader(archive), filepath.Join(parent, "extracted"), nil)
+			if err != c.expectedErr {
+				t.Fatalf("expected %v, got %v", c.expectedErr, err)
+			}
+			_, err = os.Stat(filepath.Join(parent, "escaped"))
+			if !os.IsNotExist(err) {
+				t.Fatal("file is extracted outside of the directory")
+			}
+		})
+	}
+}
@@ -0,0 +1,431 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/audit"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/common"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/conf"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/handler"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/logger"
+)
+
+var (
+	DEFAULT_BACKUP_DIR = "backups"
+	DEFAULT_INTERVAL   = 24 * time.Hour
+	DEFAULT_KEEP       = 7
+
+	ARCHIVE_PREFIX              = "vtcpd-backup-"
+	ARCHIVE_EXTENSION           = ".tar.gz"
+	ENCRYPTED_ARCHIVE_EXTENSION = ".tar.gz.enc"
+	ARCHIVE_TIME_LAYOUT         = "20060102T150405Z"
+
+	// How long the stopped node is waited to exit.
+	NODE_STOP_TIMEOUT = 10 * time.Second
+)
+
+var (
+	ErrNodeIsRunning       = errors.New("node is running, it could be stopped for the time of the operation with --online")
+	ErrNoNodeConfiguration = errors.New("archive doesn't contain node configuration (conf.json)")
+)
+
+// Backups and restores of the process are serialized.
+var lock sync.Mutex
+
+type Options struct {
+	// Running node is stopped for the time of the operation and started again after it.
+	// Otherwise ErrNodeIsRunning is returned, if the node is running.
+	Online bool
+	// Archive is encrypted by the passphrase, if it's not empty.
+	Passphrase []byte
+	// Node is started with the communication (http mode).
+	WithCommunication bool
+}
+
+func IsEnabled() bool {
+	return conf.Params.Backup.Scheduled
+}
+
+func Dir() string {
+	if conf.Params.Backup.Dir == "" {
+		return DEFAULT_BACKUP_DIR
+	}
+	return conf.Params.Backup.Dir
+}
+
+// Reads the passphrase from the file, trailing line break is ignored.
+// Empty path means, that there is no passphrase.
+func ReadPassphrase(passphraseFile string) ([]byte, error) {
+	if passphraseFile == "" {
+		return nil, nil
+	}
+	content, err := os.ReadFile(passphraseFile)
+	if err != nil {
+		return nil, errors.New("can't read passphrase file -> " + err.Error())
+	}
+	passphrase := []byte(strings.TrimRight(string(content), "\r\n"))
+	if len(passphrase) == 0 {
+		return nil, errors.New("passphrase file " + passphraseFile + " is empty")
+	}
+	return passphrase, nil
+}
+
+// Returns name of the archive, that is created at the time.
+func ArchiveName(createdAt time.Time, isEncrypted bool) string {
+	if isEncrypted {
+		return ARCHIVE_PREFIX + createdAt.UTC().Format(ARCHIVE_TIME_LAYOUT) + ENCRYPTED_ARCHIVE_EXTENSION
+	}
+	return ARCHIVE_PREFIX + createdAt.UTC().Format(ARCHIVE_TIME_LAYOUT) + ARCHIVE_EXTENSION
+}
+
+func workDir() string {
+	return filepath.Clean(conf.Params.WorkDir)
+}
+
+// Returns true if the node is running. Absent PID file means, that the node is not running.
+func isNodeRunning(nodeHandler *handler.NodeHandler) (bool, error) {
+	isRunning, err := nodeHandler.CheckNodeRunning()
+	if err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+	return isRunning, nil
+}
+
+func stopNode(nodeHandler *handler.NodeHandler, options Options) error {
+	if options.WithCommunication {
+		err := nodeHandler.StopNodeCommunication()
+		if err != nil {
+			return err
+		}
+	}
+	err := nodeHandler.StopNode()
+	if err != nil {
+		return err
+	}
+	for deadline := time.Now().Add(NODE_STOP_TIMEOUT); time.Now().Before(deadline); {
+		isRunning, err := isNodeRunning(nodeHandler)
+		if err != nil {
+			return err
+		}
+		if !isRunning {
+			logger.Info("Node is stopped for the backup")
+			return nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return errors.New("node is not stopped in " + NODE_STOP_TIMEOUT.String())
+}
+
+func startNode(nodeHandler *handler.NodeHandler, options Options) error {
+	if options.WithCommunication {
+		return nodeHandler.RestoreNodeWithCommunication()
+	}
+	return nodeHandler.RestoreNode()
+}
+
+// Archives the work directory of the node into the file.
+// Archive is written to the temporary file first, so the incomplete archives are never left.
+func Backup(nodeHandler *handler.NodeHandler, archivePath string, options Options) (*common.BackupResponse, error) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	response, err := backup(nodeHandler, archivePath, options)
+	if err != nil {
+		audit.Record(context.Background(), audit.OPERATION_BACKUP, []string{archivePath}, "", handler.SERVER_ERROR)
+		return nil, err
+	}
+	audit.Record(context.Background(), audit.OPERATION_BACKUP, []string{archivePath}, "", handler.OK)
+	return response, nil
+}
+
+func backup(nodeHandler *handler.NodeHandler, archivePath string, options Options) (*common.BackupResponse, error) {
+	_, err := os.Stat(workDir())
+	if err != nil {
+		return nil, errors.New("can't backup node, there is no node folder -> " + err.Error())
+	}
+	isRunning, err := isNodeRunning(nodeHandler)
+	if err != nil {
+		return nil, err
+	}
+	if isRunning && !options.Online {
+		return nil, ErrNodeIsRunning
+	}
+
+	err = os.MkdirAll(filepath.Dir(archivePath), 0700)
+	if err != nil {
+		return nil, errors.New("can't create directory of the archive -> " + err.Error())
+	}
+	file, err := os.CreateTemp(filepath.Dir(archivePath), "."+filepath.Base(archivePath)+".*.tmp")
+	if err != nil {
+		return nil, errors.New("can't create temporary file -> " + err.Error())
+	}
+	defer os.Remove(file.Name())
+
+	if isRunning {
+		err = stopNode(nodeHandler, options)
+		if err != nil {
+			file.Close()
+			return nil, errors.New("can't stop node -> " + err.Error())
+		}
+	}
+	manifest, err := writeArchive(file, workDir(), options.Passphrase)
+	if isRunning {
+		startErr := startNode(nodeHandler, options)
+		if startErr != nil {
+			logger.Error("Can't start node after the backup. Details: " + startErr.Error())
+			if err == nil {
+				err = errors.New("archive is created, but node can't be started -> " + startErr.Error())
+			}
+		}
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), archivePath)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("Work directory is backed up to " + archivePath)
+	return &common.BackupResponse{
+		Archive:     archivePath,
+		CreatedAt:   manifest.CreatedAt,
+		Files:       len(manifest.Files),
+		Size:        manifest.size(),
+		Encrypted:   len(options.Passphrase) > 0,
+		NodeStopped: isRunning,
+	}, nil
+}
+
+// Extracts the archive into the temporary directory near the work directory and verifies it.
+// Returns the directory with the extracted files, caller must remove it.
+func extract(archivePath string, passphrase []byte) (string, *Manifest, bool, error) {
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return "", nil, false, errors.New("can't open archive -> " + err.Error())
+	}
+	defer file.Close()
+
+	dir := workDir() + ".restore-" + time.Now().UTC().Format(ARCHIVE_TIME_LAYOUT)
+	manifest, isEncrypted, err := extractArchive(file, dir, passphrase)
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", nil, isEncrypted, err
+	}
+	_, err = os.Stat(filepath.Join(dir, "conf.json"))
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", nil, isEncrypted, ErrNoNodeConfiguration
+	}
+	return dir, manifest, isEncrypted, nil
+}
+
+// Verifies checksums of the archive without restoring it.
+func Verify(archivePath string, passphrase []byte) (*common.RestoreResponse, error) {
+	dir, manifest, isEncrypted, err := extract(archivePath, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	os.RemoveAll(dir)
+	return &common.RestoreResponse{
+		Archive:   archivePath,
+		CreatedAt: manifest.CreatedAt,
+		WorkDir:   workDir(),
+		Files:     len(manifest.Files),
+		Size:      manifest.size(),
+		Encrypted: isEncrypted,
+	}, nil
+}
+
+// Replaces the work directory of the node by the content of the archive and starts the node.
+// Archive is verified before the node is stopped. Previous work directory is kept near the restored one.
+func Restore(nodeHandler *handler.NodeHandler, archivePath string, options Options) (*common.RestoreResponse, error) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	response, err := restore(nodeHandler, archivePath, options)
+	if err != nil {
+		audit.Record(context.Background(), audit.OPERATION_RESTORE, []string{archivePath}, "", handler.SERVER_ERROR)
+		return nil, err
+	}
+	audit.Record(context.Background(), audit.OPERATION_RESTORE, []string{archivePath}, "", handler.OK)
+	return response, nil
+}
+
+func restore(nodeHandler *handler.NodeHandler, archivePath string, options Options) (*common.RestoreResponse, error) {
+	isRunning := false
+	if _, err := os.Stat(workDir()); err == nil {
+		isRunning, err = isNodeRunning(nodeHandler)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if isRunning && !options.Online {
+		return nil, ErrNodeIsRunning
+	}
+
+	dir, manifest, isEncrypted, err := extract(archivePath, options.Passphrase)
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	if isRunning {
+		err = stopNode(nodeHandler, options)
+		if err != nil {
+			return nil, errors.New("can't stop node -> " + err.Error())
+		}
+	}
+
+	response := &common.RestoreResponse{
+		Archive:   archivePath,
+		CreatedAt: manifest.CreatedAt,
+		WorkDir:   workDir(),
+		Files:     len(manifest.Files),
+		Size:      manifest.size(),
+		Encrypted: isEncrypted,
+	}
+	if _, err := os.Stat(workDir()); err == nil {
+		response.PreviousWorkDir = workDir() + ".before-restore-" + time.Now().UTC().Format(ARCHIVE_TIME_LAYOUT)
+		err = os.Rename(workDir(), response.PreviousWorkDir)
+		if err != nil {
+			return nil, errors.New("can't move work directory -> " + err.Error())
+		}
+	}
+	err = os.Rename(dir, workDir())
+	if err != nil {
+		return nil, errors.New("can't move restored files to the work directory -> " + err.Error())
+	}
+	logger.Info("Work directory is restored from " + archivePath)
+
+	err = startNode(nodeHandler, options)
+	if err != nil {
+		return nil, errors.New("files are restored, but node can't be started -> " + err.Error())
+	}
+	response.NodeStarted = true
+	return response, nil
+}
+
+// Returns names of the archives of the directory, ordered from the oldest.
+func archives(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, errors.New("can't read backups directory -> " + err.Error())
+	}
+	var names []string
+	for _, entry := range entries {
+		if _, ok := archiveTime(entry.Name()); ok && !entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func archiveTime(name string) (time.Time, bool) {
+	if !strings.HasPrefix(name, ARCHIVE_PREFIX) {
+		return time.Time{}, false
+	}
+	name = strings.TrimPrefix(name, ARCHIVE_PREFIX)
+	if strings.HasSuffix(name, ENCRYPTED_ARCHIVE_EXTENSION) {
+		name = strings.TrimSuffix(name, ENCRYPTED_ARCHIVE_EXTENSION)
+	} else if strings.HasSuffix(name, ARCHIVE_EXTENSION) {
+		name = strings.TrimSuffix(name, ARCHIVE_EXTENSION)
+	} else {
+		return time.Time{}, false
+	}
+	createdAt, err := time.Parse(ARCHIVE_TIME_LAYOUT, name)
+	return createdAt, err == nil
+}
+
+// Removes the archives of the directory except the latest ones.
+func prune(dir string, keep int) error {
+	names, err := archives(dir)
+	if err != nil {
+		return err
+	}
+	for len(names) > keep {
+		err = os.Remove(filepath.Join(dir, names[0]))
+		if err != nil {
+			return errors.New("can't remove archive " + names[0] + " -> " + err.Error())
+		}
+		logger.Info("Outdated backup " + names[0] + " is removed")
+		names = names[1:]
+	}
+	return nil
+}
+
+// Starts scheduled backups. Time of the next backup is counted from the latest archive of the directory,
+// so the schedule is kept between the restarts.
+func Start(nodeHandler *handler.NodeHandler) error {
+	dir := Dir()
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return errors.New("can't create backups directory -> " + err.Error())
+	}
+	passphrase, err := ReadPassphrase(conf.Params.Backup.PassphraseFile)
+	if err != nil {
+		return err
+	}
+
+	interval := conf.Params.Backup.Interval
+	if interval <= 0 {
+		interval = DEFAULT_INTERVAL
+	}
+	keep := conf.Params.Backup.Keep
+	if keep <= 0 {
+		keep = DEFAULT_KEEP
+	}
+
+	go func() {
+		var lastAttempt time.Time
+		for {
+			var next time.Time
+			names, err := archives(dir)
+			if err != nil {
+				logger.Error("Can't list backups. Details: " + err.Error())
+			}
+			if len(names) > 0 {
+				latest, _ := archiveTime(names[len(names)-1])
+				next = latest.Add(interval)
+			}
+			// Failed backup is not retried until the next interval.
+			if !lastAttempt.IsZero() && lastAttempt.Add(interval).After(next) {
+				next = lastAttempt.Add(interval)
+			}
+			time.Sleep(time.Until(next))
+
+			lastAttempt = time.Now()
+			archivePath := filepath.Join(dir, ArchiveName(lastAttempt, len(passphrase) > 0))
+			_, err = Backup(nodeHandler, archivePath, Options{
+				Online:            true,
+				Passphrase:        passphrase,
+				WithCommunication: true,
+			})
+			if err != nil {
+				logger.Error("Scheduled backup failed. Details: " + err.Error())
+				continue
+			}
+			err = prune(dir, keep)
+			if err != nil {
+				logger.Error("Can't remove outdated backups. Details: " + err.Error())
+			}
+		}
+	}()
+	logger.Info("Scheduled backups started, interval " + interval.String() + ", archives are kept " + strconv.Itoa(keep))
+	return nil
+}
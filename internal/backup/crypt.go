@@ -0,0 +1,209 @@
+package backup
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+var (
+	// Encrypted archive begins with the magic, KDF iterations count and salt,
+	// then the chunks of the compressed archive follow, each chunk is sealed by AES-256-GCM separately.
+	ENCRYPTION_MAGIC = []byte("VTCPDBK1")
+	KDF_ITERATIONS   = 600000
+	SALT_SIZE        = 16
+	KEY_SIZE         = 32
+	CHUNK_SIZE       = 64 * 1024
+
+	// Iterations count is read from the archive, so it's limited to prevent the endless key derivation.
+	MAX_KDF_ITERATIONS = 10000000
+)
+
+var (
+	ErrPassphraseRequired = errors.New("archive is encrypted, passphrase is required")
+	ErrDecryption         = errors.New("can't decrypt archive, passphrase is wrong or archive is corrupted")
+)
+
+// PBKDF2 with HMAC-SHA256 (RFC 8018).
+func deriveKey(passphrase, salt []byte, iterations int) []byte {
+	return pbkdf2.Key(passphrase, salt, iterations, KEY_SIZE, sha256.New)
+}
+
+func newAEAD(passphrase, salt []byte, iterations int) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(deriveKey(passphrase, salt, iterations))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Nonce is the counter of the chunk and the flag of the last chunk,
+// so the chunks could not be reordered and the archive could not be truncated.
+// Key is unique for each archive (random salt), so the nonces are never reused.
+func chunkNonce(size int, counter uint64, isLast bool) []byte {
+	nonce := make([]byte, size)
+	binary.BigEndian.PutUint64(nonce, counter)
+	if isLast {
+		nonce[size-1] = 1
+	}
+	return nonce
+}
+
+type encryptingWriter struct {
+	out     io.Writer
+	aead    cipher.AEAD
+	header  []byte
+	buffer  []byte
+	counter uint64
+}
+
+func newEncryptingWriter(out io.Writer, passphrase []byte) (*encryptingWriter, error) {
+	salt := make([]byte, SALT_SIZE)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return nil, errors.New("can't generate salt -> " + err.Error())
+	}
+	aead, err := newAEAD(passphrase, salt, KDF_ITERATIONS)
+	if err != nil {
+		return nil, errors.New("can't init cipher -> " + err.Error())
+	}
+
+	header := append([]byte{}, ENCRYPTION_MAGIC...)
+	header = binary.BigEndian.AppendUint32(header, uint32(KDF_ITERATIONS))
+	header = append(header, salt...)
+	_, err = out.Write(header)
+	if err != nil {
+		return nil, err
+	}
+	return &encryptingWriter{out: out, aead: aead, header: header}, nil
+}
+
+func (w *encryptingWriter) Write(data []byte) (int, error) {
+	w.buffer = append(w.buffer, data...)
+	for len(w.buffer) > CHUNK_SIZE {
+		err := w.writeChunk(w.buffer[:CHUNK_SIZE], false)
+		if err != nil {
+			return 0, err
+		}
+		w.buffer = w.buffer[CHUNK_SIZE:]
+	}
+	return len(data), nil
+}
+
+// Writes the last chunk (it could be empty).
+func (w *encryptingWriter) Close() error {
+	return w.writeChunk(w.buffer, true)
+}
+
+func (w *encryptingWriter) writeChunk(chunk []byte, isLast bool) error {
+	sealed := w.aead.Seal(nil, chunkNonce(w.aead.NonceSize(), w.counter, isLast), chunk, w.header)
+	w.counter++
+	_, err := w.out.Write(binary.BigEndian.AppendUint32(nil, uint32(len(sealed))))
+	if err != nil {
+		return err
+	}
+	_, err = w.out.Write(sealed)
+	return err
+}
+
+type decryptingReader struct {
+	in      io.Reader
+	aead    cipher.AEAD
+	header  []byte
+	chunk   []byte
+	counter uint64
+	isLast  bool
+}
+
+// Returns true and the reader of the archive, that begins with the encryption magic.
+func isEncrypted(in io.Reader) (bool, io.Reader, error) {
+	magic := make([]byte, len(ENCRYPTION_MAGIC))
+	n, err := io.ReadFull(in, magic)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return false, nil, err
+	}
+	return bytes.Equal(magic[:n], ENCRYPTION_MAGIC), io.MultiReader(bytes.NewReader(magic[:n]), in), nil
+}
+
+func newDecryptingReader(in io.Reader, passphrase []byte) (*decryptingReader, error) {
+	if len(passphrase) == 0 {
+		return nil, ErrPassphraseRequired
+	}
+	header := make([]byte, len(ENCRYPTION_MAGIC)+4+SALT_SIZE)
+	_, err := io.ReadFull(in, header)
+	if err != nil {
+		return nil, ErrInvalidArchive
+	}
+	iterations := int(binary.BigEndian.Uint32(header[len(ENCRYPTION_MAGIC):]))
+	if iterations <= 0 || iterations > MAX_KDF_ITERATIONS {
+		return nil, ErrInvalidArchive
+	}
+	aead, err := newAEAD(passphrase, header[len(ENCRYPTION_MAGIC)+4:], iterations)
+	if err != nil {
+		return nil, errors.New("can't init cipher -> " + err.Error())
+	}
+	return &decryptingReader{in: in, aead: aead, header: header}, nil
+}
+
+func (r *decryptingReader) Read(data []byte) (int, error) {
+	for len(r.chunk) == 0 {
+		if r.isLast {
+			return 0, io.EOF
+		}
+		err := r.readChunk()
+		if err != nil {
+			return 0, err
+		}
+	}
+	n := copy(data, r.chunk)
+	r.chunk = r.chunk[n:]
+	return n, nil
+}
+
+func (r *decryptingReader) readChunk() error {
+	size := make([]byte, 4)
+	_, err := io.ReadFull(r.in, size)
+	if err != nil {
+		// Archive ends before the last chunk.
+		return ErrInvalidArchive
+	}
+	sealedSize := int(binary.BigEndian.Uint32(size))
+	if sealedSize > CHUNK_SIZE+r.aead.Overhead() {
+		return ErrInvalidArchive
+	}
+	sealed := make([]byte, sealedSize)
+	_, err = io.ReadFull(r.in, sealed)
+	if err != nil {
+		return ErrInvalidArchive
+	}
+
+	// Chunk is authenticated either as the regular or as the last one.
+	for _, isLast := range []bool{false, true} {
+		r.chunk, err = r.aead.Open(nil, chunkNonce(r.aead.NonceSize(), r.counter, isLast), sealed, r.header)
+		if err == nil {
+			r.isLast = isLast
+			r.counter++
+			return r.checkEnd()
+		}
+	}
+	return ErrDecryption
+}
+
+// Nothing could follow the last chunk.
+func (r *decryptingReader) checkEnd() error {
+	if !r.isLast {
+		return nil
+	}
+	n, _ := r.in.Read(make([]byte, 1))
+	if n != 0 {
+		return ErrInvalidArchive
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package backup
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"testing"
+
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/conf/conftest"
+)
+
+var TEST_PASSPHRASE = []byte("correct horse battery staple")
+
+// Small chunks and cheap key derivation, so the archive of the test consists of several chunks.
+func useTestEncryption(t *testing.T) {
+	t.Helper()
+	conftest.Set(t, &CHUNK_SIZE, 16)
+	conftest.Set(t, &KDF_ITERATIONS, 1000)
+}
+
+func encryptTestData(t *testing.T, data []byte) []byte {
+	t.Helper()
+	encrypted := &bytes.Buffer{}
+	w, err := newEncryptingWriter(encrypted, TEST_PASSPHRASE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = w.Write(data)
+	if err == nil {
+		err = w.Close()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return encrypted.Bytes()
+}
+
+func decryptTestData(encrypted, passphrase []byte) ([]byte, error) {
+	r, err := newDecryptingReader(bytes.NewReader(encrypted), passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+// Splits the encrypted archive into the header and the sealed chunks (with their sizes).
+func splitTestChunks(t *testing.T, encrypted []byte) ([]byte, [][]byte) {
+	t.Helper()
+	headerSize := len(ENCRYPTION_MAGIC) + 4 + SALT_SIZE
+	header, rest := encrypted[:headerSize], encrypted[headerSize:]
+	chunks := [][]byte{}
+	for len(rest) > 0 {
+		size := 4 + int(binary.BigEndian.Uint32(rest))
+		chunks = append(chunks, rest[:size])
+		rest = rest[size:]
+	}
+	return header, chunks
+}
+
+func joinTestChunks(header []byte, chunks ...[]byte) []byte {
+	return bytes.Join(append([][]byte{header}, chunks...), nil)
+}
+
+func TestDeriveKey(t *testing.T) {
+	// PBKDF2-HMAC-SHA256 vectors of RFC 7914 (section 11), truncated to the key size.
+	cases := []struct {
+		passphrase string
+		salt       string
+		iterations int
+		expected   string
+	}{
+		{"passwd", "salt", 1, "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc"},
+		{"Password", "NaCl", 80000, "4ddcd8f60b98be21830cee5ef22701f9641a4418d04c0414aeff08876b34ab56"},
+	}
+	for _, c := range cases {
+		key := hex.EncodeToString(deriveKey([]byte(c.passphrase), []byte(c.salt), c.iterations))
+		if key != c.expected {
+			t.Fatalf("expected %s, got %s", c.expected, key)
+		}
+	}
+}
+
+func TestEncryptionRoundTrip(t *testing.T) {
+	useTestEncryption(t)
+	cases := []struct {
+		name   string
+		size   int
+		chunks int
+	}{
+		{"empty data", 0, 1},
+		{"one chunk", 16, 1},
+		{"several chunks", 40, 3},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			data := bytes.Repeat([]byte("x"), c.size)
+			encrypted := encryptTestData(t, data)
+			if bytes.Contains(encrypted, []byte("xxxx")) {
+				t.Fatal("data is not encrypted")
+			}
+			_, chunks := splitTestChunks(t, encrypted)
+			if len(chunks) != c.chunks {
+				t.Fatalf("expected %d chunks, got %d", c.chunks, len(chunks))
+			}
+
+			isEncrypted, in, err := isEncrypted(bytes.NewReader(encrypted))
+			if err != nil || !isEncrypted {
+				t.Fatalf("encrypted data is not detected: %v", err)
+			}
+			r, err := newDecryptingReader(in, TEST_PASSPHRASE)
+			if err != nil {
+				t.Fatal(err)
+			}
+			decrypted, err := io.ReadAll(r)
+			if err != nil || !bytes.Equal(decrypted, data) {
+				t.Fatalf("unexpected decrypted data %q, %v", decrypted, err)
+			}
+		})
+	}
+}
+
+func TestDecryptionOfModifiedArchive(t *testing.T) {
+	useTestEncryption(t)
+	encrypted := encryptTestData(t, bytes.Repeat([]byte("x"), 40))
+	header, chunks := splitTestChunks(t, encrypted)
+
+	invalidIterations := append([]byte{}, header...)
+	binary.BigEndian.PutUint32(invalidIterations[len(ENCRYPTION_MAGIC):], uint32(MAX_KDF_ITERATIONS+1))
+	otherSalt := append([]byte{}, header...)
+	otherSalt[len(otherSalt)-1] ^= 1
+	modifiedChunk := append([]byte{}, chunks[1]...)
+	modifiedChunk[len(modifiedChunk)-1] ^= 1
+
+	cases := []struct {
+		name        string
+		encrypted   []byte
+		passphrase  []byte
+		expectedErr error
+	}{
+		{"without passphrase", encrypted, nil, ErrPassphraseRequired},
+		{"wrong passphrase", encrypted, []byte("wrong"), ErrDecryption},
+		{"truncated header", header[:len(header)-1], TEST_PASSPHRASE, ErrInvalidArchive},
+		{"invalid iterations count", joinTestChunks(invalidIterations, chunks...), TEST_PASSPHRASE, ErrInvalidArchive},
+		{"other salt", joinTestChunks(otherSalt, chunks...), TEST_PASSPHRASE, ErrDecryption},
+		{"without last chunk", joinTestChunks(header, chunks[0], chunks[1]), TEST_PASSPHRASE, ErrInvalidArchive},
+		{"truncated last chunk", encrypted[:len(encrypted)-1], TEST_PASSPHRASE, ErrInvalidArchive},
+		{"reordered chunks", joinTestChunks(header, chunks[1], chunks[0], chunks[2]), TEST_PASSPHRASE, ErrDecryption},
+		{"removed chunk", joinTestChunks(header, chunks[0], chunks[2]), TEST_PASSPHRASE, ErrDecryption},
+		{"duplicated chunk", joinTestChunks(header, chunks[0], chunks[0], chunks[1], chunks[2]), TEST_PASSPHRASE, ErrDecryption},
+		{"modified chunk", joinTestChunks(header, chunks[0], modifiedChunk, chunks[2]), TEST_PASSPHRASE, ErrDecryption},
+		{"data after last chunk", joinTestChunks(header, append(chunks, chunks[2])...), TEST_PASSPHRASE, ErrInvalidArchive},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := decryptTestData(c.encrypted, c.passphrase)
+			if err != c.expectedErr {
+				t.Fatalf("expected %v, got %v", c.expectedErr, err)
+			}
+		})
+	}
+}
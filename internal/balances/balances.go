@@ -0,0 +1,370 @@
+package balances
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/common"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/conf"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/handler"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/logger"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/store"
+)
+
+var (
+	BALANCE_SNAPSHOTS_BUCKET = "balance-snapshots"
+	DEFAULT_INTERVAL         = 5 * time.Minute
+	DEFAULT_RETENTION        = 30 * 24 * time.Hour
+	// Samples are kept in the log per day, so the outdated samples are removed with the whole logs.
+	LOG_NAME_LAYOUT = "2006-01-02"
+
+	DEFAULT_PERIOD = 24 * time.Hour
+	MIN_STEP       = time.Minute
+	MAX_BUCKETS    = 10000
+)
+
+var (
+	ErrTooManyBuckets = errors.New("too many buckets, step should be increased")
+	ErrInvalidPeriod  = errors.New("invalid period, from is after to")
+)
+
+func IsEnabled() bool {
+	return conf.Params.BalanceSnapshots.Enabled
+}
+
+// Starts sampling of the balances of all the equivalents of the node.
+func Start(nodeHandler *handler.NodeHandler) error {
+	bucket, err := store.OpenBucket(BALANCE_SNAPSHOTS_BUCKET)
+	if err != nil {
+		return err
+	}
+
+	interval := conf.Params.BalanceSnapshots.Interval
+	if interval <= 0 {
+		interval = DEFAULT_INTERVAL
+	}
+	retention := conf.Params.BalanceSnapshots.Retention
+	if retention <= 0 {
+		retention = DEFAULT_RETENTION
+	}
+
+	go func() {
+		for {
+			err := sample(context.Background(), nodeHandler, bucket, time.Now())
+			if err != nil {
+				logger.Error("Can't sample balances. Details: " + err.Error())
+			}
+			err = prune(bucket, time.Now().Add(-retention))
+			if err != nil {
+				logger.Error("Can't remove outdated balance samples. Details: " + err.Error())
+			}
+			time.Sleep(interval)
+		}
+	}()
+	logger.Info("Balances sampling started, interval " + interval.String() + ", retention " + retention.String())
+	return nil
+}
+
+// Records total balances and settlement lines balances of each equivalent.
+func sample(ctx context.Context, nodeHandler *handler.NodeHandler, bucket *store.Bucket, now time.Time) error {
+	code, equivalents := nodeHandler.AllSettlementLines(ctx)
+	if code != handler.OK {
+		return errors.New("can't read settlement lines, code " + strconv.Itoa(code))
+	}
+
+	now = now.UTC()
+	var samples []interface{}
+	for _, equivalent := range equivalents {
+		code, totalBalance := nodeHandler.TotalBalance(ctx, equivalent.Eq)
+		if code != handler.OK {
+			return errors.New("can't read total balance of the equivalent " + equivalent.Eq + ", code " + strconv.Itoa(code))
+		}
+		balanceSample := common.BalanceSample{
+			Timestamp:            now.Format(time.RFC3339),
+			UnixTimestamp:        now.Unix(),
+			Equivalent:           equivalent.Eq,
+			TotalBalanceResponse: totalBalance,
+			Contractors:          []common.BalanceSampleContractor{},
+		}
+		for _, settlementLine := range equivalent.SettlementLines {
+			balanceSample.Contractors = append(balanceSample.Contractors, common.BalanceSampleContractor{
+				ContractorID: settlementLine.ID,
+				Contractor:   settlementLine.Contractor,
+				Balance:      settlementLine.Balance,
+			})
+		}
+		samples = append(samples, balanceSample)
+	}
+	if len(samples) == 0 {
+		return nil
+	}
+
+	unlock, err := bucket.Lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	log, err := bucket.OpenLog(now.Format(LOG_NAME_LAYOUT))
+	if err != nil {
+		return err
+	}
+	return log.Append(samples...)
+}
+
+// Removes logs of the days, that are completely before the threshold.
+func prune(bucket *store.Bucket, threshold time.Time) error {
+	unlock, err := bucket.Lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	names, err := bucket.Logs()
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		day, err := time.Parse(LOG_NAME_LAYOUT, name)
+		if err != nil {
+			continue
+		}
+		if day.Add(24 * time.Hour).Before(threshold) {
+			err = bucket.DeleteLog(name)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Fills the absent bounds of the period: to is the current time and from is the DEFAULT_PERIOD before to.
+func Period(from, to time.Time) (time.Time, time.Time, error) {
+	if to.IsZero() {
+		to = time.Now()
+	}
+	if from.IsZero() {
+		from = to.Add(-DEFAULT_PERIOD)
+	}
+	if to.Before(from) {
+		return time.Time{}, time.Time{}, ErrInvalidPeriod
+	}
+	return from, to, nil
+}
+
+// Returns the samples of the equivalent in the range [from, to], ordered by time.
+// Empty equivalent means all the equivalents.
+// Samples are read from the store, so it's available even if the node is not running.
+func Samples(equivalent string, from, to time.Time) ([]common.BalanceSample, error) {
+	bucket, err := store.OpenBucket(BALANCE_SNAPSHOTS_BUCKET)
+	if err != nil {
+		return nil, err
+	}
+	unlock, err := bucket.Lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	names, err := bucket.Logs()
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+
+	samples := []common.BalanceSample{}
+	for _, name := range names {
+		day, err := time.Parse(LOG_NAME_LAYOUT, name)
+		if err != nil || day.Add(24*time.Hour).Before(from) || day.After(to) {
+			continue
+		}
+		log, err := bucket.OpenLog(name)
+		if err != nil {
+			return nil, err
+		}
+		err = log.Read(func(data []byte) error {
+			balanceSample := common.BalanceSample{}
+			err := json.Unmarshal(data, &balanceSample)
+			if err != nil {
+				return errors.New("can't parse balance sample -> " + err.Error())
+			}
+			if equivalent != "" && balanceSample.Equivalent != equivalent {
+				return nil
+			}
+			if balanceSample.UnixTimestamp < from.Unix() || balanceSample.UnixTimestamp > to.Unix() {
+				return nil
+			}
+			samples = append(samples, balanceSample)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.SliceStable(samples, func(i, j int) bool {
+		return samples[i].UnixTimestamp < samples[j].UnixTimestamp
+	})
+	return samples, nil
+}
+
+// Downsamples the samples of the equivalent to the buckets of the step.
+// Buckets are aligned to the step and only the buckets with samples are returned.
+// Balances of the contractors are aggregated only for the contractorIDs.
+func Aggregates(equivalent string, from, to time.Time, step time.Duration, contractorIDs []string) (*common.BalanceAggregatesResponse, error) {
+	if int64(to.Sub(from)/step) > int64(MAX_BUCKETS) {
+		return nil, ErrTooManyBuckets
+	}
+	samples, err := Samples(equivalent, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	isContractorIncluded := func(contractorID string) bool {
+		for _, id := range contractorIDs {
+			if id == contractorID {
+				return true
+			}
+		}
+		return false
+	}
+
+	response := &common.BalanceAggregatesResponse{
+		Equivalent: equivalent,
+		Step:       step.String(),
+		Buckets:    []common.BalanceBucket{},
+	}
+	for begin := 0; begin < len(samples); {
+		bucketFrom := time.Unix(samples[begin].UnixTimestamp, 0).Truncate(step)
+		end := begin
+		for end < len(samples) && samples[end].UnixTimestamp < bucketFrom.Add(step).Unix() {
+			end++
+		}
+		response.Buckets = append(response.Buckets,
+			aggregate(samples[begin:end], bucketFrom, bucketFrom.Add(step), isContractorIncluded))
+		begin = end
+	}
+	response.Count = len(response.Buckets)
+	return response, nil
+}
+
+// Aggregates the samples of each equivalent over the whole range [from, to].
+func Report(equivalent string, from, to time.Time) (*common.BalanceReportResponse, error) {
+	samples, err := Samples(equivalent, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	samplesByEquivalent := make(map[string][]common.BalanceSample)
+	var equivalents []string
+	for _, balanceSample := range samples {
+		if samplesByEquivalent[balanceSample.Equivalent] == nil {
+			equivalents = append(equivalents, balanceSample.Equivalent)
+		}
+		samplesByEquivalent[balanceSample.Equivalent] = append(samplesByEquivalent[balanceSample.Equivalent], balanceSample)
+	}
+	sort.Strings(equivalents)
+
+	response := &common.BalanceReportResponse{
+		From:        from.UTC().Format(time.RFC3339),
+		To:          to.UTC().Format(time.RFC3339),
+		Equivalents: []common.BalanceReportRecord{},
+	}
+	for _, eq := range equivalents {
+		response.Equivalents = append(response.Equivalents, common.BalanceReportRecord{
+			Equivalent: eq,
+			BalanceBucket: aggregate(samplesByEquivalent[eq], from, to, func(string) bool {
+				return true
+			}),
+		})
+	}
+	response.Count = len(response.Equivalents)
+	return response, nil
+}
+
+// Accumulates min, max, average, first and last values of the balance.
+type accumulator struct {
+	count       int64
+	min, max    *big.Int
+	sum         *big.Int
+	first, last *big.Int
+}
+
+func (a *accumulator) add(value string) {
+	amount, isParsed := new(big.Int).SetString(value, 10)
+	if !isParsed {
+		return
+	}
+	if a.count == 0 {
+		a.min, a.max, a.sum, a.first = amount, amount, new(big.Int), amount
+	}
+	if amount.Cmp(a.min) < 0 {
+		a.min = amount
+	}
+	if amount.Cmp(a.max) > 0 {
+		a.max = amount
+	}
+	a.sum.Add(a.sum, amount)
+	a.last = amount
+	a.count++
+}
+
+func (a *accumulator) result() common.BalanceAggregate {
+	if a.count == 0 {
+		return common.BalanceAggregate{}
+	}
+	return common.BalanceAggregate{
+		Min:   a.min.String(),
+		Max:   a.max.String(),
+		Avg:   new(big.Int).Quo(a.sum, big.NewInt(a.count)).String(),
+		First: a.first.String(),
+		Last:  a.last.String(),
+	}
+}
+
+func aggregate(
+	samples []common.BalanceSample, from, to time.Time, isContractorIncluded func(contractorID string) bool,
+) common.BalanceBucket {
+	totalNegativeBalance := &accumulator{}
+	totalPositiveBalance := &accumulator{}
+	contractors := make(map[string]*accumulator)
+	contractorsAddresses := make(map[string]string)
+	var contractorIDs []string
+
+	for _, balanceSample := range samples {
+		totalNegativeBalance.add(balanceSample.TotalNegativeBalance)
+		totalPositiveBalance.add(balanceSample.TotalPositiveBalance)
+		for _, contractor := range balanceSample.Contractors {
+			if !isContractorIncluded(contractor.ContractorID) {
+				continue
+			}
+			if contractors[contractor.ContractorID] == nil {
+				contractors[contractor.ContractorID] = &accumulator{}
+				contractorIDs = append(contractorIDs, contractor.ContractorID)
+			}
+			contractors[contractor.ContractorID].add(contractor.Balance)
+			contractorsAddresses[contractor.ContractorID] = contractor.Contractor
+		}
+	}
+
+	bucket := common.BalanceBucket{
+		From:                 from.UTC().Format(time.RFC3339),
+		To:                   to.UTC().Format(time.RFC3339),
+		Samples:              len(samples),
+		TotalNegativeBalance: totalNegativeBalance.result(),
+		TotalPositiveBalance: totalPositiveBalance.result(),
+	}
+	for _, contractorID := range contractorIDs {
+		bucket.Contractors = append(bucket.Contractors, common.BalanceContractorAggregate{
+			ContractorID: contractorID,
+			Contractor:   contractorsAddresses[contractorID],
+			Balance:      contractors[contractorID].result(),
+		})
+	}
+	return bucket
+}
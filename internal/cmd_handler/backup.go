@@ -0,0 +1,81 @@
+package cmd_handler
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/backup"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/conf"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/handler"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/logger"
+)
+
+// Passphrase of the --passphrase-file flag overrides the one of the settings.
+func readBackupPassphrase() ([]byte, error) {
+	if handler.PassphraseFile != "" {
+		return backup.ReadPassphrase(handler.PassphraseFile)
+	}
+	return backup.ReadPassphrase(conf.Params.Backup.PassphraseFile)
+}
+
+func handleBackup(nodeHandler *handler.NodeHandler) error {
+	passphrase, err := readBackupPassphrase()
+	if err != nil {
+		logger.Error("Bad request: " + err.Error() + " in backup request")
+		fmt.Println("Bad request: " + err.Error())
+		return nil
+	}
+
+	archivePath := handler.Output
+	if archivePath == "" {
+		archivePath = filepath.Join(backup.Dir(), backup.ArchiveName(time.Now(), len(passphrase) > 0))
+	}
+
+	response, err := backup.Backup(nodeHandler, archivePath, backup.Options{
+		Online:     handler.Online,
+		Passphrase: passphrase,
+	})
+	if err != nil {
+		logger.Error("Can't backup node. Details: " + err.Error())
+		return errors.New("Can't backup node. " + err.Error())
+	}
+	printJSONResponse(handler.OK, response)
+	return nil
+}
+
+func handleRestore(nodeHandler *handler.NodeHandler) error {
+	if handler.File == "" {
+		logger.Error("Bad request: file parameter is required in restore request")
+		fmt.Println("Bad request: file parameter is required")
+		return nil
+	}
+	passphrase, err := readBackupPassphrase()
+	if err != nil {
+		logger.Error("Bad request: " + err.Error() + " in restore request")
+		fmt.Println("Bad request: " + err.Error())
+		return nil
+	}
+
+	if handler.DryRun {
+		response, err := backup.Verify(handler.File, passphrase)
+		if err != nil {
+			logger.Error("Backup archive verification failed. Details: " + err.Error())
+			return errors.New("Backup archive verification failed. " + err.Error())
+		}
+		printJSONResponse(handler.OK, response)
+		return nil
+	}
+
+	response, err := backup.Restore(nodeHandler, handler.File, backup.Options{
+		Online:     handler.Online,
+		Passphrase: passphrase,
+	})
+	if err != nil {
+		logger.Error("Can't restore node. Details: " + err.Error())
+		return errors.New("Can't restore node. " + err.Error())
+	}
+	printJSONResponse(handler.OK, response)
+	return nil
+}
@@ -2,7 +2,6 @@ package cmd_handler
 
 import (
 	"fmt"
-	"net/http"
 	"os"
 	"time"
 
@@ -49,6 +48,27 @@ func (h *CommandHandler) HandleCommand(command string) error {
 		return h.nodeHandler.HandleHistory()
 	case "remove-outdated-crypto":
 		return h.nodeHandler.HandleRemoveOutdatedCrypto()
+	case "api-key":
+		return h.nodeHandler.HandleApiKey()
+	case "audit":
+		return h.nodeHandler.HandleAudit()
+	case "pending-payments":
+		return h.nodeHandler.HandlePendingPayments()
+	case "bulk-payment":
+		return h.nodeHandler.HandleBulkPayment()
+	case "sl":
+		return h.nodeHandler.HandleSettlementLinesConfig()
+	case "reconcile":
+		return h.nodeHandler.HandleReconcile()
+	case "report":
+		// Node is not required, the report is built from the local balance samples.
+		return handleReport()
+	case "backup":
+		return handleBackup(h.nodeHandler)
+	case "restore":
+		return handleRestore(h.nodeHandler)
+	case "jobs":
+		return handleJobs()
 	default:
 		logger.Error("Invalid command " + command)
 		fmt.Println("Invalid command")
@@ -73,15 +93,27 @@ func (h *CommandHandler) WaitForNodeResults() {
 }
 
 func (h *CommandHandler) HandleHTTP() error {
-	err := h.nodeHandler.StartNodeForCommunication()
+	// Settings are validated by the router initialization,
+	// so nothing is started with the broken settings.
+	router, err := server.InitNodeHandlerServer(routes.NewRoutesHandler(h.nodeHandler))
+	if err != nil {
+		logger.Error("Can't init HTTP server. Details: " + err.Error())
+		fmt.Println("Can't init HTTP server. Details: " + err.Error())
+		return err
+	}
+	err = h.nodeHandler.StartNodeForCommunication()
 	if err != nil {
 		logger.Error("Node is not running. Details: " + err.Error())
 		fmt.Println("Node is not running. Details: " + err.Error())
 		return err
 	}
-	routesHandler := routes.NewRoutesHandler(h.nodeHandler)
-	router := server.InitNodeHandlerServer(routesHandler)
-	return http.ListenAndServe(conf.Params.HTTP.HTTPInterface(), router)
+	err = server.StartServices(h.nodeHandler)
+	if err != nil {
+		logger.Error("Can't start HTTP server services. Details: " + err.Error())
+		fmt.Println("Can't start HTTP server services. Details: " + err.Error())
+		return err
+	}
+	return server.ListenAndServe(conf.Params.HTTP, router)
 }
 
 func (h *CommandHandler) HandleStartHTTP() error {
@@ -99,13 +131,25 @@ func (h *CommandHandler) HandleStartHTTP() error {
 		fmt.Println("Node already running")
 		os.Exit(0)
 	}
+	// Settings are validated by the router initialization,
+	// so nothing is started with the broken settings.
+	router, err := server.InitNodeHandlerServer(routes.NewRoutesHandler(h.nodeHandler))
+	if err != nil {
+		logger.Error("Can't init HTTP server. Details: " + err.Error())
+		fmt.Println("Can't init HTTP server. Details: " + err.Error())
+		return err
+	}
 	err = h.nodeHandler.RestoreNodeWithCommunication()
 	if err != nil {
 		logger.Error("Can't start. Details: " + err.Error())
 		fmt.Println("Can't start. Details: " + err.Error())
 		return err
 	}
-	routesHandler := routes.NewRoutesHandler(h.nodeHandler)
-	router := server.InitNodeHandlerServer(routesHandler)
-	return http.ListenAndServe(conf.Params.HTTP.HTTPInterface(), router)
+	err = server.StartServices(h.nodeHandler)
+	if err != nil {
+		logger.Error("Can't start HTTP server services. Details: " + err.Error())
+		fmt.Println("Can't start HTTP server services. Details: " + err.Error())
+		return err
+	}
+	return server.ListenAndServe(conf.Params.HTTP, router)
 }
@@ -2,7 +2,6 @@ package cmd_handler
 
 import (
 	"fmt"
-	"net/http"
 	"os"
 	"time"
 
@@ -49,6 +48,27 @@ func (h *CommandHandlerTesting) HandleCommand(command string) error {
 		return h.nodeHandler.HandleHistory()
 	case "remove-outdated-crypto":
 		return h.nodeHandler.HandleRemoveOutdatedCrypto()
+	case "api-key":
+		return h.nodeHandler.HandleApiKey()
+	case "audit":
+		return h.nodeHandler.HandleAudit()
+	case "pending-payments":
+		return h.nodeHandler.HandlePendingPayments()
+	case "bulk-payment":
+		return h.nodeHandler.HandleBulkPayment()
+	case "sl":
+		return h.nodeHandler.HandleSettlementLinesConfig()
+	case "reconcile":
+		return h.nodeHandler.HandleReconcile()
+	case "report":
+		// Node is not required, the report is built from the local balance samples.
+		return handleReport()
+	case "backup":
+		return handleBackup(h.nodeHandler)
+	case "restore":
+		return handleRestore(h.nodeHandler)
+	case "jobs":
+		return handleJobs()
 	default:
 		logger.Error("Invalid command " + command)
 		fmt.Println("Invalid command")
@@ -82,14 +102,25 @@ func (h *CommandHandlerTesting) HandleHTTP() error {
 	go func() {
 		routesHandlerTesting := routes.NewRoutesHandler(h.nodeHandler)
 		routerTesting := server.InitTestNodeHandlerServer(routesHandlerTesting)
-		err = http.ListenAndServe(conf.Params.HTTPTesting.HTTPInterface(), routerTesting)
+		err = server.ListenAndServe(conf.Params.HTTPTesting, routerTesting)
 		if err != nil {
 			os.Exit(1)
 		}
 	}()
+	err = server.StartServices(h.nodeHandler)
+	if err != nil {
+		logger.Error("Can't start HTTP server services. Details: " + err.Error())
+		fmt.Println("Can't start HTTP server services. Details: " + err.Error())
+		return err
+	}
 	routesHandler := routes.NewRoutesHandler(h.nodeHandler)
-	router := server.InitNodeHandlerServer(routesHandler)
-	return http.ListenAndServe(conf.Params.HTTP.HTTPInterface(), router)
+	router, err := server.InitNodeHandlerServer(routesHandler)
+	if err != nil {
+		logger.Error("Can't init HTTP server. Details: " + err.Error())
+		fmt.Println("Can't init HTTP server. Details: " + err.Error())
+		return err
+	}
+	return server.ListenAndServe(conf.Params.HTTP, router)
 }
 
 func (h *CommandHandlerTesting) HandleStartHTTP() error {
@@ -115,12 +146,23 @@ func (h *CommandHandlerTesting) HandleStartHTTP() error {
 	go func() {
 		routesHandlerTesting := routes.NewRoutesHandler(h.nodeHandler)
 		routerTesting := server.InitTestNodeHandlerServer(routesHandlerTesting)
-		err = http.ListenAndServe(conf.Params.HTTPTesting.HTTPInterface(), routerTesting)
+		err = server.ListenAndServe(conf.Params.HTTPTesting, routerTesting)
 		if err != nil {
 			os.Exit(1)
 		}
 	}()
+	err = server.StartServices(h.nodeHandler)
+	if err != nil {
+		logger.Error("Can't start HTTP server services. Details: " + err.Error())
+		fmt.Println("Can't start HTTP server services. Details: " + err.Error())
+		return err
+	}
 	routesHandler := routes.NewRoutesHandler(h.nodeHandler)
-	router := server.InitNodeHandlerServer(routesHandler)
-	return http.ListenAndServe(conf.Params.HTTP.HTTPInterface(), router)
+	router, err := server.InitNodeHandlerServer(routesHandler)
+	if err != nil {
+		logger.Error("Can't init HTTP server. Details: " + err.Error())
+		fmt.Println("Can't init HTTP server. Details: " + err.Error())
+		return err
+	}
+	return server.ListenAndServe(conf.Params.HTTP, router)
 }
@@ -0,0 +1,77 @@
+package cmd_handler
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/common"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/handler"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/jobs"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/logger"
+)
+
+// Node is not required, jobs are executed by the HTTP server and controlled through the store.
+func handleJobs() error {
+	if handler.CommandType == "list" {
+		listJobs()
+		return nil
+	}
+	if handler.CommandType != "get" && handler.CommandType != "cancel" &&
+		handler.CommandType != "pause" && handler.CommandType != "resume" {
+		logger.Error("Invalid jobs command " + handler.CommandType)
+		fmt.Println("Invalid jobs command")
+		return nil
+	}
+
+	if !common.ValidateUUID(handler.UUID) {
+		logger.Error("Bad request: invalid uuid parameter in jobs request")
+		fmt.Println("Bad request: invalid uuid parameter")
+		return nil
+	}
+	jobID := uuid.MustParse(handler.UUID).String()
+
+	var job *jobs.Job
+	var err error
+	switch handler.CommandType {
+	case "get":
+		job, err = jobs.Get(jobID)
+	case "cancel":
+		job, err = jobs.Cancel(context.Background(), jobID)
+	case "pause":
+		job, err = jobs.Pause(context.Background(), jobID)
+	case "resume":
+		job, err = jobs.Resume(context.Background(), jobID)
+	}
+	switch err {
+	case nil:
+		printJSONResponse(handler.OK, job.Response(true))
+	case jobs.ErrJobNotFound:
+		printJSONResponse(handler.NOT_FOUND, common.JobResponse{})
+	case jobs.ErrJobStateConflict:
+		printJSONResponse(handler.CONFLICT, job.Response(true))
+	default:
+		logger.Error("Can't process job " + jobID + ". Details: " + err.Error())
+		printJSONResponse(handler.SERVER_ERROR, common.JobResponse{})
+	}
+	return nil
+}
+
+func listJobs() {
+	allJobs, err := jobs.List()
+	if err != nil {
+		logger.Error("Can't read jobs. Details: " + err.Error())
+		printJSONResponse(handler.SERVER_ERROR, common.JobsResponse{})
+		return
+	}
+
+	response := common.JobsResponse{Jobs: []common.JobResponse{}}
+	for _, job := range allJobs {
+		if handler.HistoryKind != "" && job.Kind != handler.HistoryKind {
+			continue
+		}
+		response.Jobs = append(response.Jobs, job.Response(false))
+	}
+	response.Count = len(response.Jobs)
+	printJSONResponse(handler.OK, response)
+}
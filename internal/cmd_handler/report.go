@@ -0,0 +1,64 @@
+package cmd_handler
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/balances"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/handler"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/logger"
+)
+
+// Reports are built from the data, that is collected by the HTTP server services,
+// so they are handled here and not by the node handler (balances package depends on it).
+func handleReport() error {
+	if handler.CommandType != "balances" {
+		logger.Error("Invalid report command " + handler.CommandType)
+		fmt.Println("Invalid report command")
+		return nil
+	}
+
+	from, err := handler.ParseHistoryExportDate(handler.ReportFrom)
+	if err != nil {
+		logger.Error("Bad request: invalid from parameter in balances report request")
+		fmt.Println("Bad request: invalid from parameter")
+		return nil
+	}
+	to, err := handler.ParseHistoryExportDate(handler.ReportTo)
+	if err != nil {
+		logger.Error("Bad request: invalid to parameter in balances report request")
+		fmt.Println("Bad request: invalid to parameter")
+		return nil
+	}
+	from, to, err = balances.Period(from, to)
+	if err != nil {
+		logger.Error("Bad request: " + err.Error() + " in balances report request")
+		fmt.Println("Bad request: " + err.Error())
+		return nil
+	}
+
+	report, err := balances.Report(handler.Equivalent, from, to)
+	if err != nil {
+		logger.Error("Can't build balances report. Details: " + err.Error())
+		printJSONResponse(handler.ENGINE_UNEXPECTED_ERROR, nil)
+		return nil
+	}
+	printJSONResponse(handler.OK, report)
+	return nil
+}
+
+// Prints the response in the same format as the node handler commands do.
+func printJSONResponse(status int, data interface{}) {
+	js, err := json.Marshal(struct {
+		Status int         `json:"status"`
+		Data   interface{} `json:"data"`
+	}{
+		Status: status,
+		Data:   data,
+	})
+	if err != nil {
+		logger.Error("Can't marshall data. Details are: " + err.Error())
+		return
+	}
+	fmt.Println(string(js))
+}
@@ -1,5 +1,7 @@
 package common
 
+import "encoding/json"
+
 // --- Global conts for command timeouts ---
 var (
 	CHANNEL_RESULT_TIMEOUT          uint16 = 20 // seconds
@@ -142,10 +144,39 @@ type MaxFlowPartialResponse struct {
 	Records []MaxFlowRecord `json:"records"`
 }
 
+type MaxFlowBatchRecord struct {
+	ContractorAddressType string `json:"address_type"`
+	ContractorAddress     string `json:"contractor_address"`
+	MaxAmount             string `json:"max_amount,omitempty"`
+	// Result code of the calculation, is set if the flow was not calculated.
+	Code         int    `json:"code,omitempty"`
+	Cached       bool   `json:"cached"`
+	CalculatedAt string `json:"calculated_at,omitempty"`
+	AgeSeconds   int    `json:"age_seconds"`
+}
+
+type MaxFlowBatchResponse struct {
+	Count   int                  `json:"count"`
+	Records []MaxFlowBatchRecord `json:"records"`
+}
+
 type PaymentResponse struct {
 	TransactionUUID string `json:"transaction_uuid"`
 }
 
+type PaymentQuoteResponse struct {
+	Feasible               bool   `json:"feasible"`
+	Amount                 string `json:"amount"`
+	Equivalent             string `json:"equivalent"`
+	MaxAmount              string `json:"max_amount"`
+	PolicyViolation        string `json:"policy_violation,omitempty"`
+	PolicyViolationMessage string `json:"policy_violation_message,omitempty"`
+	// Are set if the payment was sent after the check.
+	Sent            bool   `json:"sent"`
+	TransactionUUID string `json:"transaction_uuid,omitempty"`
+	Code            int    `json:"code,omitempty"`
+}
+
 type GetTransactionByCommandUUIDResponse struct {
 	Count           int    `json:"count"`
 	TransactionUUID string `json:"transaction_uuid"`
@@ -226,6 +257,170 @@ type AdditionalPaymentHistoryResponse struct {
 	Records []AdditionalPaymentHistoryRecord `json:"records"`
 }
 
+// Record of the history export and of the local history index. It's the same for all the kinds of the history,
+// fields, that are absent in the history of the kind, are empty.
+type HistoryExportRecord struct {
+	Equivalent                string `json:"equivalent"`
+	RecordType                string `json:"record_type"` // "payment" or "trustline"
+	TransactionUUID           string `json:"transaction_uuid"`
+	Timestamp                 string `json:"timestamp"`
+	UnixTimestampMicroseconds string `json:"unix_timestamp_microseconds"`
+	Contractor                string `json:"contractor"`
+	OperationDirection        string `json:"operation_direction"`
+	Amount                    string `json:"amount"`
+	BalanceAfterOperation     string `json:"balance_after_operation"`
+	Payload                   string `json:"payload"`
+}
+
+type HistoryExportResponse struct {
+	Count int    `json:"count"`
+	File  string `json:"file,omitempty"`
+}
+
+type HistoryIndexResponse struct {
+	Count   int                   `json:"count"`
+	Records []HistoryExportRecord `json:"records"`
+	// Cursor of the next page, it's absent on the last page.
+	NextCursor string `json:"next_cursor,omitempty"`
+	// Time of the latest successful synchronization of the index with the engine.
+	SyncedAt string `json:"synced_at,omitempty"`
+}
+
+type HistoryIndexStatusRecord struct {
+	Kind       string `json:"kind"`
+	Equivalent string `json:"equivalent"`
+	Records    int    `json:"records"`
+	// Time of the latest indexed record.
+	HighWaterMark string `json:"high_water_mark,omitempty"`
+}
+
+type HistoryIndexStatusResponse struct {
+	Enabled     bool                       `json:"enabled"`
+	SyncedAt    string                     `json:"synced_at,omitempty"`
+	LastError   string                     `json:"last_error,omitempty"`
+	Equivalents []HistoryIndexStatusRecord `json:"equivalents"`
+}
+
+type BalanceSampleContractor struct {
+	ContractorID string `json:"contractor_id"`
+	Contractor   string `json:"contractor"`
+	Balance      string `json:"balance"`
+}
+
+// Balances of the equivalent at the moment of the sampling.
+type BalanceSample struct {
+	Timestamp     string `json:"timestamp"`
+	UnixTimestamp int64  `json:"unix_timestamp"`
+	Equivalent    string `json:"equivalent"`
+	TotalBalanceResponse
+	Contractors []BalanceSampleContractor `json:"contractors"`
+}
+
+type BalanceSamplesResponse struct {
+	Count   int             `json:"count"`
+	Samples []BalanceSample `json:"samples"`
+}
+
+// All the values are empty, if there were no samples.
+type BalanceAggregate struct {
+	Min   string `json:"min"`
+	Max   string `json:"max"`
+	Avg   string `json:"avg"`
+	First string `json:"first"`
+	Last  string `json:"last"`
+}
+
+type BalanceContractorAggregate struct {
+	ContractorID string           `json:"contractor_id"`
+	Contractor   string           `json:"contractor"`
+	Balance      BalanceAggregate `json:"balance"`
+}
+
+type BalanceBucket struct {
+	From                 string                       `json:"from"`
+	To                   string                       `json:"to"`
+	Samples              int                          `json:"samples"`
+	TotalNegativeBalance BalanceAggregate             `json:"total_negative_balance"`
+	TotalPositiveBalance BalanceAggregate             `json:"total_positive_balance"`
+	Contractors          []BalanceContractorAggregate `json:"contractors,omitempty"`
+}
+
+type BalanceAggregatesResponse struct {
+	Equivalent string          `json:"equivalent"`
+	Step       string          `json:"step"`
+	Count      int             `json:"count"`
+	Buckets    []BalanceBucket `json:"buckets"`
+}
+
+type BalanceReportRecord struct {
+	Equivalent string `json:"equivalent"`
+	BalanceBucket
+}
+
+type BalanceReportResponse struct {
+	From        string                `json:"from"`
+	To          string                `json:"to"`
+	Count       int                   `json:"count"`
+	Equivalents []BalanceReportRecord `json:"equivalents"`
+}
+
+// Mismatch, found by the settlement line reconciliation.
+type ReconciliationDiscrepancy struct {
+	// "payment-balance", "balance" or "audit-number".
+	Kind        string `json:"kind"`
+	Description string `json:"description"`
+	Expected    string `json:"expected"`
+	Actual      string `json:"actual"`
+	// Record, where the discrepancy was found (absent for the settlement line discrepancies).
+	Record *HistoryExportRecord `json:"record,omitempty"`
+	// History records around the discrepancy, ordered by time.
+	Context []HistoryExportRecord `json:"context"`
+}
+
+type ReconciliationRecord struct {
+	Equivalent               string                      `json:"equivalent"`
+	ContractorID             string                      `json:"contractor_id"`
+	Contractor               string                      `json:"contractor"`
+	Balance                  string                      `json:"balance"`
+	AuditNumber              string                      `json:"audit_number"`
+	ExpectedBalance          string                      `json:"expected_balance"`
+	Payments                 int                         `json:"payments"`
+	SettlementLineOperations int                         `json:"settlement_line_operations"`
+	IsReconciled             bool                        `json:"is_reconciled"`
+	Discrepancies            []ReconciliationDiscrepancy `json:"discrepancies"`
+}
+
+type ReconciliationResponse struct {
+	Count int `json:"count"`
+	// Count of the settlement lines with discrepancies.
+	Unreconciled    int                    `json:"unreconciled"`
+	SettlementLines []ReconciliationRecord `json:"settlement_lines"`
+}
+
+type BackupResponse struct {
+	Archive   string `json:"archive"`
+	CreatedAt string `json:"created_at"`
+	Files     int    `json:"files"`
+	// Total size of the files of the work directory.
+	Size      int64 `json:"size"`
+	Encrypted bool  `json:"encrypted"`
+	// Node was running, so it was stopped for the time of the archiving.
+	NodeStopped bool `json:"node_stopped"`
+}
+
+type RestoreResponse struct {
+	Archive string `json:"archive"`
+	// Time of the backup.
+	CreatedAt string `json:"created_at"`
+	WorkDir   string `json:"work_dir"`
+	Files     int    `json:"files"`
+	Size      int64  `json:"size"`
+	Encrypted bool   `json:"encrypted"`
+	// Directory, that the previous content of the work directory was moved to.
+	PreviousWorkDir string `json:"previous_work_dir,omitempty"`
+	NodeStarted     bool   `json:"node_started"`
+}
+
 // --- Global API responses for control
 
 type ControlMsgResponse struct {
@@ -234,3 +429,212 @@ type ControlMsgResponse struct {
 }
 
 type ControlResponse struct{}
+
+// --- API responses for security
+
+type ApiKeyResponse struct {
+	Key  string `json:"key"`
+	Hash string `json:"hash"`
+}
+
+// --- API responses for asynchronous payments
+
+type AsyncPaymentResponse struct {
+	CommandUUID string `json:"command_uuid"`
+	StatusURL   string `json:"status_url"`
+}
+
+type PaymentJobResponse struct {
+	UUID            string `json:"uuid"`
+	State           string `json:"state"`
+	TransactionUUID string `json:"transaction_uuid,omitempty"`
+	Code            int    `json:"code,omitempty"`
+	PolicyViolation string `json:"policy_violation,omitempty"`
+	CreatedAt       string `json:"created_at"`
+	CompletedAt     string `json:"completed_at,omitempty"`
+}
+
+// --- Payment approvals ---
+
+type PendingPaymentResponse struct {
+	UUID                string   `json:"uuid"`
+	State               string   `json:"state"`
+	ContractorAddresses []string `json:"contractor_addresses"`
+	Amount              string   `json:"amount"`
+	Equivalent          string   `json:"equivalent"`
+	Payload             string   `json:"payload,omitempty"`
+	RequestedBy         string   `json:"requested_by"`
+	CreatedAt           string   `json:"created_at"`
+	ExpiresAt           string   `json:"expires_at"`
+	DecidedBy           string   `json:"decided_by,omitempty"`
+	DecidedAt           string   `json:"decided_at,omitempty"`
+	TransactionUUID     string   `json:"transaction_uuid,omitempty"`
+	Code                int      `json:"code,omitempty"`
+	PolicyViolation     string   `json:"policy_violation,omitempty"`
+}
+
+type PendingPaymentsResponse struct {
+	Count    int                      `json:"count"`
+	Payments []PendingPaymentResponse `json:"payments"`
+}
+
+// --- Scheduled payments ---
+
+type ScheduleResponse struct {
+	ID                  string   `json:"id"`
+	Name                string   `json:"name"`
+	Cron                string   `json:"cron"`
+	ContractorAddresses []string `json:"contractor_addresses"`
+	Amount              string   `json:"amount"`
+	Equivalent          string   `json:"equivalent"`
+	PayloadTemplate     string   `json:"payload_template,omitempty"`
+	MissedRuns          string   `json:"missed_runs"`
+	Enabled             bool     `json:"enabled"`
+	Owner               string   `json:"owner"`
+	CreatedAt           string   `json:"created_at"`
+	UpdatedAt           string   `json:"updated_at"`
+	NextRunAt           string   `json:"next_run_at,omitempty"`
+}
+
+type SchedulesResponse struct {
+	Count     int                `json:"count"`
+	Schedules []ScheduleResponse `json:"schedules"`
+}
+
+type ScheduleRunResponse struct {
+	UUID            string `json:"uuid"`
+	ScheduledAt     string `json:"scheduled_at"`
+	State           string `json:"state"`
+	Payload         string `json:"payload,omitempty"`
+	TransactionUUID string `json:"transaction_uuid,omitempty"`
+	Code            int    `json:"code,omitempty"`
+	PolicyViolation string `json:"policy_violation,omitempty"`
+	StartedAt       string `json:"started_at,omitempty"`
+	CompletedAt     string `json:"completed_at,omitempty"`
+}
+
+type ScheduleRunsResponse struct {
+	Count int                   `json:"count"`
+	Runs  []ScheduleRunResponse `json:"runs"`
+}
+
+// --- Bulk payments ---
+
+type BulkPaymentRowResponse struct {
+	Index               int      `json:"index"`
+	State               string   `json:"state"`
+	CommandUUID         string   `json:"command_uuid,omitempty"`
+	ContractorAddresses []string `json:"contractor_addresses"`
+	Amount              string   `json:"amount"`
+	Equivalent          string   `json:"equivalent"`
+	TransactionUUID     string   `json:"transaction_uuid,omitempty"`
+	Code                int      `json:"code,omitempty"`
+	PolicyViolation     string   `json:"policy_violation,omitempty"`
+	Error               string   `json:"error,omitempty"`
+}
+
+type BulkPaymentResponse struct {
+	ID              string                   `json:"id"`
+	State           string                   `json:"state"`
+	Count           int                      `json:"count"`
+	Succeeded       int                      `json:"succeeded"`
+	PendingApproval int                      `json:"pending_approval"`
+	Failed          int                      `json:"failed"`
+	Unknown         int                      `json:"unknown"`
+	CreatedAt       string                   `json:"created_at"`
+	UpdatedAt       string                   `json:"updated_at,omitempty"`
+	Rows            []BulkPaymentRowResponse `json:"rows"`
+}
+
+// --- Background jobs ---
+
+type JobItemResponse struct {
+	Arguments   []string `json:"arguments"`
+	State       string   `json:"state"`
+	Code        int      `json:"code,omitempty"`
+	CompletedAt string   `json:"completed_at,omitempty"`
+}
+
+type JobResponse struct {
+	ID         string            `json:"id"`
+	Kind       string            `json:"kind"`
+	State      string            `json:"state"`
+	Parameters map[string]string `json:"parameters"`
+	// Pause between the items in seconds.
+	Delay       int    `json:"delay"`
+	Owner       string `json:"owner"`
+	Count       int    `json:"count"`
+	Processed   int    `json:"processed"`
+	Succeeded   int    `json:"succeeded"`
+	Failed      int    `json:"failed"`
+	CreatedAt   string `json:"created_at"`
+	UpdatedAt   string `json:"updated_at,omitempty"`
+	StartedAt   string `json:"started_at,omitempty"`
+	CompletedAt string `json:"completed_at,omitempty"`
+	// Items are returned only for the single job.
+	Items []JobItemResponse `json:"items,omitempty"`
+}
+
+type JobsResponse struct {
+	Count int           `json:"count"`
+	Jobs  []JobResponse `json:"jobs"`
+}
+
+type JobCreatedResponse struct {
+	ID        string `json:"id"`
+	StatusURL string `json:"status_url"`
+}
+
+// --- Scheduled maintenance ---
+
+type MaintenanceTaskResponse struct {
+	Task      string `json:"task"`
+	Cron      string `json:"cron"`
+	NextRunAt string `json:"next_run_at,omitempty"`
+	// Planned time of the last run.
+	LastRunAt   string `json:"last_run_at,omitempty"`
+	LastOutcome string `json:"last_outcome,omitempty"`
+	// Reason of the skipped or failed run.
+	LastReason string `json:"last_reason,omitempty"`
+	LastCode   int    `json:"last_code,omitempty"`
+	LastJobID  string `json:"last_job_id,omitempty"`
+	// Actual state of the job, it's absent, if the job is already removed.
+	LastJobState string `json:"last_job_state,omitempty"`
+}
+
+type MaintenanceResponse struct {
+	WindowStart      string                    `json:"window_start,omitempty"`
+	WindowEnd        string                    `json:"window_end,omitempty"`
+	InWindow         bool                      `json:"in_window"`
+	PaymentsInFlight int                       `json:"payments_in_flight"`
+	Tasks            []MaintenanceTaskResponse `json:"tasks"`
+}
+
+// --- Webhooks ---
+
+type WebhookDeliveryResponse struct {
+	ID             string          `json:"id"`
+	Subscription   string          `json:"subscription"`
+	EventID        string          `json:"event_id"`
+	EventType      string          `json:"event_type"`
+	State          string          `json:"state"`
+	Attempts       int             `json:"attempts"`
+	LastStatusCode int             `json:"last_status_code,omitempty"`
+	LastError      string          `json:"last_error,omitempty"`
+	CreatedAt      string          `json:"created_at"`
+	NextAttemptAt  string          `json:"next_attempt_at,omitempty"`
+	CompletedAt    string          `json:"completed_at,omitempty"`
+	Payload        json.RawMessage `json:"payload"`
+}
+
+type WebhookDeliveriesResponse struct {
+	Count      int                       `json:"count"`
+	Deliveries []WebhookDeliveryResponse `json:"deliveries"`
+}
+
+// --- Payment policies ---
+
+type PolicyViolationResponse struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
@@ -3,32 +3,295 @@ package conf
 import (
 	"fmt"
 	"strconv"
+	"time"
 
 	"github.com/spf13/viper"
 )
 
+type TLSSettings struct {
+	CertFile string `mapstructure:"cert_file"`
+	KeyFile  string `mapstructure:"key_file"`
+	// Optional. If set, client certificates are required and verified against this CA.
+	ClientCAFile string `mapstructure:"client_ca_file"`
+}
+
 type HTTPSettings struct {
-	Host string `mapstructure:"host"`
-	Port uint16 `mapstructure:"port"`
+	Host string      `mapstructure:"host"`
+	Port uint16      `mapstructure:"port"`
+	TLS  TLSSettings `mapstructure:"tls"`
+}
+
+// Maps subject (common name) of the verified client certificate to the scopes.
+type ClientCertificateSettings struct {
+	Subject string   `mapstructure:"subject"`
+	Scopes  []string `mapstructure:"scopes"`
+	// Optional. If set, client is allowed to operate only with these equivalents.
+	Equivalents []string `mapstructure:"equivalents"`
+}
+
+// Named API key. Only the hash of the key is stored in the settings.
+type ApiKeySettings struct {
+	Name   string   `mapstructure:"name"`
+	Hash   string   `mapstructure:"hash"`
+	Scopes []string `mapstructure:"scopes"`
+	// Optional. If set, key is allowed to operate only with these equivalents.
+	Equivalents []string `mapstructure:"equivalents"`
+	// Optional. Secret for the HMAC signing of the requests.
+	// Key without hash could be used only for the signed requests.
+	SigningSecret string `mapstructure:"signing_secret"`
+	// Optional. Overrides rate_limits.per_key for this key (by the route groups).
+	RateLimits map[string]RateLimitSettings `mapstructure:"rate_limits"`
 }
 
 type SecuritySettings struct {
-	ApiKey       string   `mapstructure:"api_key"`
+	// Legacy single key, it is granted admin scope.
+	ApiKey  string           `mapstructure:"api_key"`
+	ApiKeys []ApiKeySettings `mapstructure:"api_keys"`
+	// Entries of the addresses lists could be single IPs (v4 or v6) or CIDR ranges.
 	AllowableIPs []string `mapstructure:"allowable_ips"`
+	// Optional. Allowed difference between timestamp of the signed request and the server time (300 by default).
+	SignatureWindowSeconds int      `mapstructure:"signature_window_seconds"`
+	DeniedIPs              []string `mapstructure:"denied_ips"`
+	// Proxies, whose X-Forwarded-For and X-Real-Ip headers are honoured.
+	TrustedProxies     []string                    `mapstructure:"trusted_proxies"`
+	ClientCertificates []ClientCertificateSettings `mapstructure:"client_certificates"`
+}
+
+// Token bucket: Rate tokens per second are added to the bucket of Burst tokens.
+type RateLimitSettings struct {
+	Rate  float64 `mapstructure:"rate"`
+	Burst int     `mapstructure:"burst"`
+}
+
+// Limits by the route groups ("reads", "writes", "payments").
+// Groups, that are absent, are not limited.
+type RateLimitsSettings struct {
+	Enabled bool                         `mapstructure:"enabled"`
+	PerIP   map[string]RateLimitSettings `mapstructure:"per_ip"`
+	PerKey  map[string]RateLimitSettings `mapstructure:"per_key"`
+}
+
+type StorageSettings struct {
+	// Optional. Directory of the local store (idempotency records, jobs, etc.).
+	// "storage" in the current directory is used by default.
+	Dir string `mapstructure:"dir"`
+}
+
+type PaymentsSettings struct {
+	// Optional. How long results of the payments with Idempotency-Key are kept (24h by default).
+	IdempotencyTTL time.Duration `mapstructure:"idempotency_ttl"`
+	// Optional. How long completed asynchronous payments are kept (1h by default).
+	JobsRetention time.Duration `mapstructure:"jobs_retention"`
+	// Optional. Policies, that are checked before the payments are sent to the engine.
+	Policies PaymentPoliciesSettings `mapstructure:"policies"`
+	// Optional. Two-person approval of the payments.
+	Approvals ApprovalsSettings `mapstructure:"approvals"`
+	// Optional. Count of the payments of the bulk, that are executed concurrently (4 by default).
+	BulkConcurrency int `mapstructure:"bulk_concurrency"`
+	// Optional. Maximal count of the payments in one bulk (1000 by default).
+	BulkMaxRows int `mapstructure:"bulk_max_rows"`
+	// Optional. How long bulks are kept for the status requests and resuming (7 days by default).
+	BulkRetention time.Duration `mapstructure:"bulk_retention"`
+}
+
+// Limits of the outgoing payments. Amounts are integers in the units of the equivalent, empty values are not limited.
+// Daily and monthly caps are counted by the calendar days and months in UTC.
+type PaymentLimitsSettings struct {
+	MaxAmount  string `mapstructure:"max_amount"`
+	DailyCap   string `mapstructure:"daily_cap"`
+	MonthlyCap string `mapstructure:"monthly_cap"`
+}
+
+// Limits of the payments of the caller (API key name, TLS certificate subject or "local:<OS user>" for the CLI).
+type CallerPaymentPolicySettings struct {
+	Caller string `mapstructure:"caller"`
+	// Limits by the equivalents.
+	Equivalents map[string]PaymentLimitsSettings `mapstructure:"equivalents"`
+}
+
+type PayloadPolicySettings struct {
+	Required bool `mapstructure:"required"`
+	// Optional. Regular expression, that the payload must match.
+	Pattern   string `mapstructure:"pattern"`
+	MaxLength int    `mapstructure:"max_length"`
+}
+
+type PaymentPoliciesSettings struct {
+	// Limits by the equivalents for all the callers together.
+	Equivalents map[string]PaymentLimitsSettings `mapstructure:"equivalents"`
+	Callers     []CallerPaymentPolicySettings    `mapstructure:"callers"`
+	// Payees addresses in the form "<type>:<address>" (e.g. "ipv4:127.0.0.1:2000") or "<type code>-<address>" ("12-127.0.0.1:2000").
+	// If allowed list is not empty, all addresses of the payee must be present in it.
+	AllowedPayees []string              `mapstructure:"allowed_payees"`
+	DeniedPayees  []string              `mapstructure:"denied_payees"`
+	Payload       PayloadPolicySettings `mapstructure:"payload"`
+}
+
+type ApprovalsSettings struct {
+	// Payments with amount above the threshold of the equivalent must be approved by the second caller.
+	// Equivalents, that are absent, don't require approvals.
+	Thresholds map[string]string `mapstructure:"thresholds"`
+	// Optional. How long payment waits for the approval (24h by default).
+	TTL time.Duration `mapstructure:"ttl"`
+	// Optional. How long decided and expired payments are kept (7 days by default).
+	Retention time.Duration `mapstructure:"retention"`
+}
+
+type WebhookSubscriptionSettings struct {
+	Name string `mapstructure:"name"`
+	URL  string `mapstructure:"url"`
+	// Secret for the HMAC signing of the deliveries.
+	Secret string `mapstructure:"secret"`
+	// Optional. Types of the events to deliver, all events are delivered by default.
+	Events []string `mapstructure:"events"`
+}
+
+type WebhooksSettings struct {
+	Subscriptions []WebhookSubscriptionSettings `mapstructure:"subscriptions"`
+	// Optional. How often the engine is polled for the incoming payments
+	// and the settlement lines changes (10s by default).
+	PollInterval time.Duration `mapstructure:"poll_interval"`
+	// Optional. Timeout of one delivery attempt (10s by default).
+	Timeout time.Duration `mapstructure:"timeout"`
+	// Optional. Deliveries, that failed this number of attempts, are moved to the dead letter file (8 by default).
+	MaxAttempts int `mapstructure:"max_attempts"`
+	// Optional. Delay before the first retry, it is doubled on each next retry (1s by default).
+	InitialBackoff time.Duration `mapstructure:"initial_backoff"`
+	// Optional. Maximal delay between the retries (10m by default).
+	MaxBackoff time.Duration `mapstructure:"max_backoff"`
+	// Optional. "webhooks-dead-letter.log" in the current directory is used by default.
+	DeadLetterFilePath string `mapstructure:"dead_letter_file_path"`
+	// Optional. How long completed deliveries are listed (24h by default).
+	Retention time.Duration `mapstructure:"retention"`
+}
+
+// Scheduled payments of the http mode.
+type SchedulerSettings struct {
+	// Optional. How often the schedules are checked (10s by default).
+	TickInterval time.Duration `mapstructure:"tick_interval"`
+	// Optional. Runs, that are late for more than this period, are treated as missed (1m by default).
+	MisfireGrace time.Duration `mapstructure:"misfire_grace"`
+	// Optional. Maximal count of the latest missed runs, that are executed by the "catch_up" policy (10 by default).
+	MaxCatchUpRuns int `mapstructure:"max_catch_up_runs"`
+	// Optional. Count of the latest runs, that are kept for each schedule (100 by default).
+	HistoryLimit int `mapstructure:"history_limit"`
+}
+
+type HistoryIndexSettings struct {
+	// Optional. Payments and settlement lines history is mirrored into the local index (http mode only).
+	Enabled bool `mapstructure:"enabled"`
+	// Optional. How often the new history records are fetched from the engine (30s by default).
+	SyncInterval time.Duration `mapstructure:"sync_interval"`
+}
+
+type BalanceSnapshotsSettings struct {
+	// Optional. Total and settlement lines balances are sampled periodically (http mode only).
+	Enabled bool `mapstructure:"enabled"`
+	// Optional. How often the balances are sampled (5m by default).
+	Interval time.Duration `mapstructure:"interval"`
+	// Optional. How long the samples are kept (30 days by default).
+	Retention time.Duration `mapstructure:"retention"`
+}
+
+// Background maintenance jobs of the http mode.
+type JobsSettings struct {
+	// Optional. How often the store is checked for the queued, resumed and cancelled jobs (2s by default).
+	PollInterval time.Duration `mapstructure:"poll_interval"`
+	// Optional. How long finished jobs are kept (7 days by default).
+	Retention time.Duration `mapstructure:"retention"`
+}
+
+type MaintenanceTaskSettings struct {
+	// Optional. Cron expression in UTC, task is disabled if it's empty.
+	Cron string `mapstructure:"cron"`
+	// Optional. Database is vacuumed after the removal (remove_outdated_crypto only).
+	Vacuum bool `mapstructure:"vacuum"`
+	// Optional. Delay between the settlement lines (regenerate_keys only, 5s by default).
+	Delay time.Duration `mapstructure:"delay"`
+}
+
+// Scheduled maintenance tasks of the http mode, they are executed as the background jobs.
+type MaintenanceSettings struct {
+	// Optional. Maintenance window "HH:MM" in UTC, tasks are started only inside it.
+	// Window could cross the midnight (e.g. 23:00 - 02:00). Tasks are not restricted, if it's not set.
+	WindowStart string `mapstructure:"window_start"`
+	WindowEnd   string `mapstructure:"window_end"`
+	// Optional. Run, that could not be started for this period (outside the window,
+	// while payments are in flight, etc.), is skipped (15m by default).
+	MisfireGrace time.Duration `mapstructure:"misfire_grace"`
+	// Optional. How often the tasks are checked (30s by default).
+	TickInterval         time.Duration           `mapstructure:"tick_interval"`
+	RemoveOutdatedCrypto MaintenanceTaskSettings `mapstructure:"remove_outdated_crypto"`
+	RegenerateKeys       MaintenanceTaskSettings `mapstructure:"regenerate_keys"`
+}
+
+type BackupSettings struct {
+	// Optional. Directory of the archives ("backups" in the current directory is used by default).
+	Dir string `mapstructure:"dir"`
+	// Optional. File with the passphrase. If it's set, archives are encrypted.
+	PassphraseFile string `mapstructure:"passphrase_file"`
+	// Optional. Work directory is backed up periodically (http mode only).
+	// Node is stopped for the time of the archiving.
+	Scheduled bool `mapstructure:"scheduled"`
+	// Optional. How often the scheduled backups are made (24h by default).
+	Interval time.Duration `mapstructure:"interval"`
+	// Optional. Count of the latest archives, that are kept by the scheduled backups (7 by default).
+	Keep int `mapstructure:"keep"`
+}
+
+type MaxFlowSettings struct {
+	// Optional. Count of the addresses in one max flow command of the batch (20 by default).
+	ChunkSize int `mapstructure:"chunk_size"`
+	// Optional. Count of the max flow commands of the batch, that are executed concurrently (4 by default).
+	Concurrency int `mapstructure:"concurrency"`
+	// Optional. How long the calculated max flows are cached (1m by default).
+	CacheTTL time.Duration `mapstructure:"cache_ttl"`
+}
+
+type AuditSettings struct {
+	// Optional. "audit.log" in the current directory is used by default.
+	FilePath string `mapstructure:"file_path"`
+}
+
+type TracingSettings struct {
+	Enabled      bool    `mapstructure:"enabled"`
+	Exporter     string  `mapstructure:"exporter"`
+	FilePath     string  `mapstructure:"file_path"`
+	OTLPEndpoint string  `mapstructure:"otlp_endpoint"`
+	OTLPInsecure bool    `mapstructure:"otlp_insecure"`
+	ServiceName  string  `mapstructure:"service_name"`
+	SampleRatio  float64 `mapstructure:"sample_ratio"`
 }
 
 type Settings struct {
-	WorkDir     string           `mapstructure:"workdir"`
-	VTCPDPath   string           `mapstructure:"vtcpd_path"`
-	HTTP        HTTPSettings     `mapstructure:"http"`
-	HTTPTesting HTTPSettings     `mapstructure:"http_testing"`
-	Security    SecuritySettings `mapstructure:"security"`
+	WorkDir          string                   `mapstructure:"workdir"`
+	VTCPDPath        string                   `mapstructure:"vtcpd_path"`
+	HTTP             HTTPSettings             `mapstructure:"http"`
+	HTTPTesting      HTTPSettings             `mapstructure:"http_testing"`
+	Security         SecuritySettings         `mapstructure:"security"`
+	RateLimits       RateLimitsSettings       `mapstructure:"rate_limits"`
+	Audit            AuditSettings            `mapstructure:"audit"`
+	Storage          StorageSettings          `mapstructure:"storage"`
+	Payments         PaymentsSettings         `mapstructure:"payments"`
+	Webhooks         WebhooksSettings         `mapstructure:"webhooks"`
+	Scheduler        SchedulerSettings        `mapstructure:"scheduler"`
+	MaxFlow          MaxFlowSettings          `mapstructure:"max_flow"`
+	HistoryIndex     HistoryIndexSettings     `mapstructure:"history_index"`
+	BalanceSnapshots BalanceSnapshotsSettings `mapstructure:"balance_snapshots"`
+	Jobs             JobsSettings             `mapstructure:"jobs"`
+	Maintenance      MaintenanceSettings      `mapstructure:"maintenance"`
+	Backup           BackupSettings           `mapstructure:"backup"`
+	Tracing          TracingSettings          `mapstructure:"tracing"`
 }
 
 func (s HTTPSettings) HTTPInterface() string {
 	return s.Host + ":" + strconv.Itoa(int(s.Port))
 }
 
+func (s TLSSettings) Enabled() bool {
+	return s.CertFile != "" && s.KeyFile != ""
+}
+
 var (
 	Params = Settings{}
 )
@@ -0,0 +1,12 @@
+package conftest
+
+import "testing"
+
+// Sets the setting (e.g. &conf.Params.Audit.FilePath) for the duration of the test.
+// Previous value is restored on the test cleanup.
+func Set[T any](t testing.TB, setting *T, value T) {
+	t.Helper()
+	previous := *setting
+	t.Cleanup(func() { *setting = previous })
+	*setting = value
+}
@@ -0,0 +1,111 @@
+package events
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	EVENT_PAYMENT_COMPLETED       = "payment.completed"
+	EVENT_PAYMENT_FAILED          = "payment.failed"
+	EVENT_PAYMENT_INCOMING        = "payment.incoming"
+	EVENT_SETTLEMENT_LINE_CHANGED = "settlement_line.changed"
+	EVENT_NODE_RESTARTED          = "node.restarted"
+)
+
+// All the event types, that could be published.
+var Types = []string{
+	EVENT_PAYMENT_COMPLETED,
+	EVENT_PAYMENT_FAILED,
+	EVENT_PAYMENT_INCOMING,
+	EVENT_SETTLEMENT_LINE_CHANGED,
+	EVENT_NODE_RESTARTED,
+}
+
+// Event, that was detected by the CLI (payment was done by the node, settlement line was changed, etc.).
+type Event struct {
+	ID   string      `json:"id"`
+	Type string      `json:"type"`
+	Time time.Time   `json:"time"`
+	Data interface{} `json:"data"`
+}
+
+// Data of the payment.completed and payment.failed events.
+type PaymentData struct {
+	CommandUUID         string   `json:"command_uuid"`
+	TransactionUUID     string   `json:"transaction_uuid,omitempty"`
+	ContractorAddresses []string `json:"contractor_addresses"`
+	Amount              string   `json:"amount"`
+	Equivalent          string   `json:"equivalent"`
+	Payload             string   `json:"payload,omitempty"`
+	Code                int      `json:"code"`
+}
+
+// Data of the payment.incoming event.
+type IncomingPaymentData struct {
+	Equivalent                string `json:"equivalent"`
+	TransactionUUID           string `json:"transaction_uuid"`
+	UnixTimestampMicroseconds string `json:"unix_timestamp_microseconds"`
+	Contractor                string `json:"contractor"`
+	Amount                    string `json:"amount"`
+	BalanceAfterOperation     string `json:"balance_after_operation"`
+	Payload                   string `json:"payload"`
+}
+
+// State and limits of the settlement line.
+type SettlementLineState struct {
+	State              string `json:"state"`
+	MaxNegativeBalance string `json:"max_negative_balance"`
+	MaxPositiveBalance string `json:"max_positive_balance"`
+}
+
+// Data of the settlement_line.changed event.
+// Previous is absent for the new settlement lines, Current is absent for the removed ones.
+type SettlementLineData struct {
+	Equivalent   string               `json:"equivalent"`
+	ContractorID string               `json:"contractor_id"`
+	Contractor   string               `json:"contractor"`
+	Previous     *SettlementLineState `json:"previous"`
+	Current      *SettlementLineState `json:"current"`
+}
+
+func IsKnownType(eventType string) bool {
+	for _, knownType := range Types {
+		if knownType == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Listeners are called synchronously by the publisher, so they must not block.
+type Listener func(event Event)
+
+var (
+	listenersLock sync.RWMutex
+	listeners     []Listener
+)
+
+func Subscribe(listener Listener) {
+	listenersLock.Lock()
+	defer listenersLock.Unlock()
+	listeners = append(listeners, listener)
+}
+
+// Notifies all the listeners about the event.
+func Publish(eventType string, data interface{}) {
+	event := Event{
+		ID:   uuid.New().String(),
+		Type: eventType,
+		Time: time.Now().UTC(),
+		Data: data,
+	}
+
+	listenersLock.RLock()
+	defer listenersLock.RUnlock()
+	for _, listener := range listeners {
+		listener(event)
+	}
+}
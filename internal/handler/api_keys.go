@@ -0,0 +1,40 @@
+package handler
+
+import (
+	"fmt"
+
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/auth"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/common"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/logger"
+)
+
+func (handler *NodeHandler) ApiKeys() {
+
+	if CommandType == "generate" {
+		handler.generateApiKey()
+
+	} else {
+		logger.Error("Invalid api-key command " + CommandType)
+		fmt.Println("Invalid api-key command")
+		return
+	}
+}
+
+// Generates new API key and prints it together with it's hash.
+// Only the hash should be placed into security.api_keys of the settings,
+// the key itself is passed to the client and is not stored anywhere.
+func (handler *NodeHandler) generateApiKey() {
+	apiKey, err := auth.GenerateApiKey()
+	if err != nil {
+		logger.Error("Can't generate api-key. Details: " + err.Error())
+		resultJSON := buildJSONResponse(SERVER_ERROR, common.ApiKeyResponse{})
+		fmt.Println(string(resultJSON))
+		return
+	}
+
+	resultJSON := buildJSONResponse(OK, common.ApiKeyResponse{
+		Key:  apiKey,
+		Hash: auth.HashApiKey(apiKey),
+	})
+	fmt.Println(string(resultJSON))
+}
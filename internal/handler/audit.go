@@ -0,0 +1,44 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/audit"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/logger"
+)
+
+func (handler *NodeHandler) Audit() error {
+
+	if CommandType == "verify" {
+		return handler.verifyAuditLog()
+
+	} else {
+		logger.Error("Invalid audit command " + CommandType)
+		fmt.Println("Invalid audit command")
+		return nil
+	}
+}
+
+// Checks the hash chain of the audit log and prints the report.
+// Error is returned if the chain is broken, so the CLI exits with non zero code.
+func (handler *NodeHandler) verifyAuditLog() error {
+	report, err := audit.Verify()
+	if err != nil {
+		logger.Error("Can't verify audit log. Details: " + err.Error())
+		resultJSON := buildJSONResponse(SERVER_ERROR, audit.VerificationReport{})
+		fmt.Println(string(resultJSON))
+		return err
+	}
+
+	if !report.Valid {
+		logger.Error("Audit log is broken. Details: " + report.Error)
+		resultJSON := buildJSONResponse(CONFLICT, report)
+		fmt.Println(string(resultJSON))
+		return errors.New("audit log is broken")
+	}
+
+	resultJSON := buildJSONResponse(OK, report)
+	fmt.Println(string(resultJSON))
+	return nil
+}
@@ -0,0 +1,694 @@
+package handler
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/audit"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/auth"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/common"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/conf"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/logger"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/store"
+)
+
+var (
+	BULK_PAYMENTS_BUCKET = "bulk-payments"
+
+	DEFAULT_BULK_PAYMENTS_CONCURRENCY = 4
+	DEFAULT_BULK_PAYMENTS_MAX_ROWS    = 1000
+	DEFAULT_BULK_PAYMENTS_RETENTION   = time.Hour * 24 * 7
+
+	BULK_PAYMENTS_FORMAT_CSV  = "csv"
+	BULK_PAYMENTS_FORMAT_JSON = "json"
+
+	// Columns of the CSV file. Header is required, columns could go in any order.
+	// Addresses of the one payment are separated by spaces.
+	BULK_PAYMENTS_CSV_COLUMNS = []string{"addresses", "amount", "equivalent", "payload", "transaction_uuid"}
+
+	// Rows are validated, but the payments are not sent.
+	BULK_PAYMENT_STATE_DRY_RUN = "dry_run"
+	// Some rows didn't pass the validation, the payments are not sent.
+	BULK_PAYMENT_STATE_INVALID = "invalid"
+	BULK_PAYMENT_STATE_RUNNING = "running"
+	// All the payments are done (or are waiting for the approval).
+	BULK_PAYMENT_STATE_COMPLETED = "completed"
+	// Some payments are failed or their outcomes are not known, the bulk could be resumed.
+	BULK_PAYMENT_STATE_INCOMPLETE = "incomplete"
+
+	BULK_PAYMENT_ROW_STATE_PENDING          = "pending"
+	BULK_PAYMENT_ROW_STATE_INVALID          = "invalid"
+	BULK_PAYMENT_ROW_STATE_SENT             = "sent"
+	BULK_PAYMENT_ROW_STATE_SUCCEEDED        = "succeeded"
+	BULK_PAYMENT_ROW_STATE_PENDING_APPROVAL = "pending_approval"
+	BULK_PAYMENT_ROW_STATE_FAILED           = "failed"
+	// Engine didn't answer in time, the payment would be looked up on resume.
+	BULK_PAYMENT_ROW_STATE_UNKNOWN = "unknown"
+)
+
+var (
+	ErrBulkPaymentNotFound  = errors.New("bulk payment is not found")
+	ErrBulkPaymentIsRunning = errors.New("bulk payment is already running")
+	ErrBulkPaymentIsInvalid = errors.New("bulk payment contains invalid rows")
+)
+
+// Row of the bulk payment file.
+type BulkPaymentInput struct {
+	// Addresses of the payee in the form "<type>:<address>" (as in the payment command).
+	Addresses       []string `json:"addresses"`
+	Amount          string   `json:"amount"`
+	Equivalent      string   `json:"equivalent"`
+	Payload         string   `json:"payload,omitempty"`
+	TransactionUUID string   `json:"transaction_uuid,omitempty"`
+}
+
+type BulkPaymentRow struct {
+	Index   int             `json:"index"`
+	State   string          `json:"state"`
+	Order   PaymentOrder    `json:"order"`
+	Error   string          `json:"error,omitempty"`
+	SentAt  time.Time       `json:"sent_at"`
+	Outcome *PaymentOutcome `json:"outcome,omitempty"`
+}
+
+// Set of the payments, that are sent together.
+// Command UUID of each payment is allocated before any payment is sent,
+// so the outcomes of the interrupted payments could be looked up on resume.
+type BulkPayment struct {
+	ID                  string            `json:"id"`
+	State               string            `json:"state"`
+	Requester           string            `json:"requester"`
+	RequesterAuthMethod string            `json:"requester_auth_method"`
+	RequesterIP         string            `json:"requester_ip"`
+	CreatedAt           time.Time         `json:"created_at"`
+	UpdatedAt           time.Time         `json:"updated_at"`
+	Rows                []*BulkPaymentRow `json:"rows"`
+}
+
+func (b *BulkPayment) Response() common.BulkPaymentResponse {
+	response := common.BulkPaymentResponse{
+		ID:        b.ID,
+		State:     b.State,
+		Count:     len(b.Rows),
+		CreatedAt: b.CreatedAt.UTC().Format(time.RFC3339),
+		Rows:      []common.BulkPaymentRowResponse{},
+	}
+	if !b.UpdatedAt.IsZero() {
+		response.UpdatedAt = b.UpdatedAt.UTC().Format(time.RFC3339)
+	}
+	for _, row := range b.Rows {
+		rowResponse := common.BulkPaymentRowResponse{
+			Index:               row.Index,
+			State:               row.State,
+			CommandUUID:         row.Order.CommandUUID.String(),
+			ContractorAddresses: row.Order.ContractorAddresses,
+			Amount:              row.Order.Amount,
+			Equivalent:          row.Order.Equivalent,
+			Error:               row.Error,
+		}
+		if row.Order.CommandUUID == uuid.Nil {
+			rowResponse.CommandUUID = ""
+		}
+		if row.Outcome != nil {
+			rowResponse.TransactionUUID = row.Outcome.TransactionUUID
+			rowResponse.Code = row.Outcome.Code
+			if row.Outcome.Violation != nil {
+				rowResponse.PolicyViolation = row.Outcome.Violation.Code
+			}
+		}
+		switch row.State {
+		case BULK_PAYMENT_ROW_STATE_SUCCEEDED:
+			response.Succeeded++
+		case BULK_PAYMENT_ROW_STATE_PENDING_APPROVAL:
+			response.PendingApproval++
+		case BULK_PAYMENT_ROW_STATE_FAILED, BULK_PAYMENT_ROW_STATE_INVALID:
+			response.Failed++
+		case BULK_PAYMENT_ROW_STATE_UNKNOWN, BULK_PAYMENT_ROW_STATE_SENT:
+			response.Unknown++
+		}
+		response.Rows = append(response.Rows, rowResponse)
+	}
+	return response
+}
+
+// Returns equivalents of all the rows.
+func (b *BulkPayment) Equivalents() []string {
+	isPresent := make(map[string]bool)
+	var equivalents []string
+	for _, row := range b.Rows {
+		if !isPresent[row.Order.Equivalent] {
+			isPresent[row.Order.Equivalent] = true
+			equivalents = append(equivalents, row.Order.Equivalent)
+		}
+	}
+	return equivalents
+}
+
+func (b *BulkPayment) isCompleted() bool {
+	for _, row := range b.Rows {
+		if row.State != BULK_PAYMENT_ROW_STATE_SUCCEEDED && row.State != BULK_PAYMENT_ROW_STATE_PENDING_APPROVAL {
+			return false
+		}
+	}
+	return true
+}
+
+// Bulk is executed under the lease of it's document, so the HTTP server and the CLI commands
+// can't send payments of the same bulk concurrently. Lease is released when the process exits,
+// so running bulk without the lease was interrupted by the restart of the process.
+func isBulkPaymentRunning(bucket *store.Bucket, bulkID string) bool {
+	isLeased, err := bucket.IsLeased(bulkID)
+	if err != nil {
+		logger.Error("Can't check lease of the bulk payment " + bulkID + ". Details: " + err.Error())
+		// Bulk is treated as running, so it is not removed.
+		return true
+	}
+	return isLeased
+}
+
+// Reads rows of the bulk payment in the CSV or JSON format.
+func ParseBulkPayments(format string, data io.Reader) ([]BulkPaymentInput, error) {
+	switch format {
+	case BULK_PAYMENTS_FORMAT_JSON:
+		var inputs []BulkPaymentInput
+		err := json.NewDecoder(data).Decode(&inputs)
+		if err != nil {
+			return nil, wrap("invalid JSON list of payments", err)
+		}
+		return inputs, nil
+
+	case BULK_PAYMENTS_FORMAT_CSV:
+		return parseBulkPaymentsCSV(data)
+
+	default:
+		return nil, errors.New("unknown format of payments " + format)
+	}
+}
+
+func parseBulkPaymentsCSV(data io.Reader) ([]BulkPaymentInput, error) {
+	reader := csv.NewReader(data)
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, wrap("invalid CSV list of payments", err)
+	}
+	if len(records) == 0 {
+		return nil, errors.New("CSV list of payments has no header")
+	}
+
+	columns := make(map[string]int)
+	for idx, column := range records[0] {
+		column = strings.ToLower(strings.TrimSpace(column))
+		if !isBulkPaymentsColumn(column) {
+			return nil, errors.New("unknown CSV column " + column)
+		}
+		columns[column] = idx
+	}
+	for _, column := range []string{"addresses", "amount", "equivalent"} {
+		if _, isPresent := columns[column]; !isPresent {
+			return nil, errors.New("CSV column " + column + " is missing")
+		}
+	}
+
+	value := func(record []string, column string) string {
+		idx, isPresent := columns[column]
+		if !isPresent {
+			return ""
+		}
+		return strings.TrimSpace(record[idx])
+	}
+	var inputs []BulkPaymentInput
+	for _, record := range records[1:] {
+		inputs = append(inputs, BulkPaymentInput{
+			Addresses:       strings.Fields(value(record, "addresses")),
+			Amount:          value(record, "amount"),
+			Equivalent:      value(record, "equivalent"),
+			Payload:         value(record, "payload"),
+			TransactionUUID: value(record, "transaction_uuid"),
+		})
+	}
+	return inputs, nil
+}
+
+func isBulkPaymentsColumn(column string) bool {
+	for _, known := range BULK_PAYMENTS_CSV_COLUMNS {
+		if column == known {
+			return true
+		}
+	}
+	return false
+}
+
+func bulkPaymentsMaxRows() int {
+	if conf.Params.Payments.BulkMaxRows > 0 {
+		return conf.Params.Payments.BulkMaxRows
+	}
+	return DEFAULT_BULK_PAYMENTS_MAX_ROWS
+}
+
+func bulkPaymentsConcurrency() int {
+	if conf.Params.Payments.BulkConcurrency > 0 {
+		return conf.Params.Payments.BulkConcurrency
+	}
+	return DEFAULT_BULK_PAYMENTS_CONCURRENCY
+}
+
+// Validates all the rows and builds the bulk on behalf of the caller from the context.
+// If some rows are invalid, the bulk is returned with the per-row errors together with ErrBulkPaymentIsInvalid.
+// Bulk is not persisted.
+func NewBulkPayment(ctx context.Context, inputs []BulkPaymentInput) (*BulkPayment, error) {
+	if len(inputs) == 0 {
+		return nil, errors.New("there are no payments")
+	}
+	if len(inputs) > bulkPaymentsMaxRows() {
+		return nil, errors.New("count of the payments exceeds the limit " + strconv.Itoa(bulkPaymentsMaxRows()))
+	}
+
+	caller := callerOf(ctx)
+	bulkID := uuid.New()
+	bulk := &BulkPayment{
+		ID:                  bulkID.String(),
+		State:               BULK_PAYMENT_STATE_DRY_RUN,
+		Requester:           caller.Name,
+		RequesterAuthMethod: caller.AuthMethod,
+		RequesterIP:         caller.SourceIP,
+		CreatedAt:           time.Now(),
+	}
+
+	usedUUIDs := make(map[uuid.UUID]int)
+	for idx, input := range inputs {
+		row := &BulkPaymentRow{Index: idx, State: BULK_PAYMENT_ROW_STATE_PENDING}
+		bulk.Rows = append(bulk.Rows, row)
+
+		order, err := bulkPaymentOrder(input)
+		row.Order = order
+		if err == nil && order.CommandUUID == uuid.Nil {
+			// UUID is derived from the bulk, so it stays the same on resume.
+			row.Order.CommandUUID = uuid.NewSHA1(bulkID, []byte(strconv.Itoa(idx)))
+		}
+		if err == nil {
+			if firstIdx, isUsed := usedUUIDs[row.Order.CommandUUID]; isUsed {
+				err = errors.New("transaction_uuid is already used in the row " + strconv.Itoa(firstIdx))
+			}
+			usedUUIDs[row.Order.CommandUUID] = idx
+		}
+		if err != nil {
+			row.State = BULK_PAYMENT_ROW_STATE_INVALID
+			row.Error = err.Error()
+			bulk.State = BULK_PAYMENT_STATE_INVALID
+		}
+	}
+	if bulk.State == BULK_PAYMENT_STATE_INVALID {
+		return bulk, ErrBulkPaymentIsInvalid
+	}
+	return bulk, nil
+}
+
+func bulkPaymentOrder(input BulkPaymentInput) (PaymentOrder, error) {
+	order := PaymentOrder{
+		ContractorAddresses: []string{},
+		Amount:              input.Amount,
+		Equivalent:          input.Equivalent,
+		Payload:             input.Payload,
+	}
+	if len(input.Addresses) == 0 {
+		return order, errors.New("there are no addresses")
+	}
+	for _, value := range input.Addresses {
+		addressType, address := common.ValidateAddress(value)
+		if addressType == "" {
+			return order, errors.New("invalid address " + value)
+		}
+		order.ContractorAddresses = append(order.ContractorAddresses, addressType+"-"+address)
+	}
+	if !common.ValidateSettlementLineAmount(input.Amount) {
+		return order, errors.New("invalid amount " + input.Amount)
+	}
+	if !common.ValidateInt(input.Equivalent) {
+		return order, errors.New("invalid equivalent " + input.Equivalent)
+	}
+	if input.TransactionUUID != "" {
+		transactionUUID, err := uuid.Parse(input.TransactionUUID)
+		if err != nil {
+			return order, errors.New("invalid transaction_uuid " + input.TransactionUUID)
+		}
+		order.CommandUUID = transactionUUID
+	}
+	return order, nil
+}
+
+func openBulkPayments() (*store.Bucket, error) {
+	return store.OpenBucket(BULK_PAYMENTS_BUCKET)
+}
+
+// Persists the bulk before it's payments are sent. Outdated bulks are removed.
+func CreateBulkPayment(ctx context.Context, bulk *BulkPayment) error {
+	bulk.State = BULK_PAYMENT_STATE_RUNNING
+	bulk.UpdatedAt = time.Now()
+
+	bucket, err := openBulkPayments()
+	if err != nil {
+		return err
+	}
+	unlock, err := bucket.Lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	removeOutdatedBulkPayments(bucket)
+	err = bucket.Put(bulk.ID, bulk)
+	if err != nil {
+		return err
+	}
+	audit.Record(ctx, audit.OPERATION_BULK_PAYMENT_CREATE, []string{strconv.Itoa(len(bulk.Rows))}, bulk.ID, CREATED)
+	return nil
+}
+
+func removeOutdatedBulkPayments(bucket *store.Bucket) {
+	retention := conf.Params.Payments.BulkRetention
+	if retention <= 0 {
+		retention = DEFAULT_BULK_PAYMENTS_RETENTION
+	}
+	keys, err := bucket.Keys()
+	if err != nil {
+		logger.Error("Can't read bulk payments. Details: " + err.Error())
+		return
+	}
+	for _, key := range keys {
+		bulk := &BulkPayment{}
+		isPresent, err := bucket.Get(key, bulk)
+		if err != nil || !isPresent || isBulkPaymentRunning(bucket, bulk.ID) {
+			continue
+		}
+		if time.Since(bulk.UpdatedAt) > retention {
+			err = bucket.Delete(key)
+			if err != nil {
+				logger.Error("Can't remove bulk payment " + key + ". Details: " + err.Error())
+			}
+		}
+	}
+}
+
+// Returns the bulk payment.
+// Running bulk, that is not executed by any process (its lease is free), was interrupted and is reported as incomplete.
+func GetBulkPayment(bulkID string) (*BulkPayment, error) {
+	bucket, err := openBulkPayments()
+	if err != nil {
+		return nil, err
+	}
+	bulk := &BulkPayment{}
+	isPresent, err := bucket.Get(bulkID, bulk)
+	if err != nil {
+		return nil, err
+	}
+	if !isPresent {
+		return nil, ErrBulkPaymentNotFound
+	}
+	if bulk.State == BULK_PAYMENT_STATE_RUNNING && !isBulkPaymentRunning(bucket, bulk.ID) {
+		bulk.State = BULK_PAYMENT_STATE_INCOMPLETE
+	}
+	return bulk, nil
+}
+
+// Returns bulk payments (from the newest ones) without their rows.
+func BulkPayments() ([]*BulkPayment, error) {
+	bucket, err := openBulkPayments()
+	if err != nil {
+		return nil, err
+	}
+	keys, err := bucket.Keys()
+	if err != nil {
+		return nil, err
+	}
+
+	var bulks []*BulkPayment
+	for _, key := range keys {
+		bulk, err := GetBulkPayment(key)
+		if err != nil {
+			logger.Error("Can't read bulk payment " + key + ". Details: " + err.Error())
+			continue
+		}
+		bulks = append(bulks, bulk)
+	}
+	sort.Slice(bulks, func(i, j int) bool {
+		return bulks[i].CreatedAt.After(bulks[j].CreatedAt)
+	})
+	return bulks, nil
+}
+
+// Sends payments of the persisted bulk and waits for their results.
+// Done payments are skipped, so the bulk could be resumed after the failure:
+// payments with unknown outcome are looked up in the engine, failed ones are sent again.
+// Payments are executed on behalf of the bulk's requester.
+func (handler *NodeHandler) RunBulkPayment(ctx context.Context, bulkID string) (*BulkPayment, error) {
+	bucket, err := openBulkPayments()
+	if err != nil {
+		return nil, err
+	}
+	release, isAcquired, err := bucket.TryLease(bulkID)
+	if err != nil {
+		return nil, err
+	}
+	if !isAcquired {
+		return nil, ErrBulkPaymentIsRunning
+	}
+	defer release()
+
+	bulk := &BulkPayment{}
+	isPresent, err := bucket.Get(bulkID, bulk)
+	if err != nil {
+		return nil, err
+	}
+	if !isPresent {
+		return nil, ErrBulkPaymentNotFound
+	}
+	if bulk.State == BULK_PAYMENT_STATE_INVALID {
+		return bulk, ErrBulkPaymentIsInvalid
+	}
+
+	if bulk.State != BULK_PAYMENT_STATE_RUNNING {
+		bulk.State = BULK_PAYMENT_STATE_RUNNING
+		err = writeBulkPayment(bucket, bulk)
+		if err != nil {
+			return nil, err
+		}
+		audit.Record(ctx, audit.OPERATION_BULK_PAYMENT_RESUME, []string{strconv.Itoa(len(bulk.Rows))}, bulk.ID, OK)
+	}
+
+	requesterCtx := auth.WithCaller(ctx, &auth.Caller{
+		Name:       bulk.Requester,
+		AuthMethod: bulk.RequesterAuthMethod,
+		SourceIP:   bulk.RequesterIP,
+	})
+	var bulkLock sync.Mutex
+	update := func(row *BulkPaymentRow, state BulkPaymentRow) {
+		bulkLock.Lock()
+		defer bulkLock.Unlock()
+		*row = state
+		err := writeBulkPayment(bucket, bulk)
+		if err != nil {
+			logger.Error("Can't write bulk payment " + bulk.ID + ". Details: " + err.Error())
+		}
+	}
+
+	slots := make(chan struct{}, bulkPaymentsConcurrency())
+	var wg sync.WaitGroup
+	for _, row := range bulk.Rows {
+		bulkLock.Lock()
+		state := *row
+		bulkLock.Unlock()
+		if state.State == BULK_PAYMENT_ROW_STATE_SUCCEEDED || state.State == BULK_PAYMENT_ROW_STATE_PENDING_APPROVAL {
+			continue
+		}
+
+		slots <- struct{}{}
+		wg.Add(1)
+		go func(row *BulkPaymentRow, state BulkPaymentRow) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			handler.runBulkPaymentRow(requesterCtx, state, func(state BulkPaymentRow) { update(row, state) })
+		}(row, state)
+	}
+	wg.Wait()
+
+	bulkLock.Lock()
+	defer bulkLock.Unlock()
+	bulk.State = BULK_PAYMENT_STATE_INCOMPLETE
+	if bulk.isCompleted() {
+		bulk.State = BULK_PAYMENT_STATE_COMPLETED
+	}
+	return bulk, writeBulkPayment(bucket, bulk)
+}
+
+// Brings the row to the final state (or to the unknown one), each state change is reported by the callback.
+func (handler *NodeHandler) runBulkPaymentRow(ctx context.Context, row BulkPaymentRow, update func(BulkPaymentRow)) {
+	if row.State == BULK_PAYMENT_ROW_STATE_SENT || row.State == BULK_PAYMENT_ROW_STATE_UNKNOWN {
+		// Payment could be done by the engine, so it must not be sent again until it is known, that it wasn't.
+		code, transactionUUID := handler.FindTransactionByCommandUUID(ctx, row.Order.CommandUUID)
+		if code != OK {
+			row.State = BULK_PAYMENT_ROW_STATE_UNKNOWN
+			update(row)
+			return
+		}
+		if transactionUUID != "" {
+			row.State = BULK_PAYMENT_ROW_STATE_SUCCEEDED
+			row.Outcome = &PaymentOutcome{Code: CREATED, TransactionUUID: transactionUUID}
+			update(row)
+			return
+		}
+		if time.Since(row.SentAt) <= time.Second*time.Duration(2*common.PAYMENT_OPERATION_TIMEOUT) {
+			// Engine may still process the payment.
+			row.State = BULK_PAYMENT_ROW_STATE_UNKNOWN
+			update(row)
+			return
+		}
+	}
+
+	row.Error = ""
+	if RequiresApproval(row.Order) {
+		_, _, err := CreatePendingPayment(ctx, row.Order)
+		if err != nil {
+			logger.Error("Can't request approval of the payment " + row.Order.CommandUUID.String() + ". Details: " + err.Error())
+			row.State = BULK_PAYMENT_ROW_STATE_FAILED
+			row.Error = err.Error()
+			row.Outcome = &PaymentOutcome{Code: SERVER_ERROR}
+			if err == ErrPendingPaymentExists {
+				row.Outcome.Code = CONFLICT
+			}
+		} else {
+			row.State = BULK_PAYMENT_ROW_STATE_PENDING_APPROVAL
+			row.Outcome = &PaymentOutcome{Code: ACCEPTED}
+		}
+		update(row)
+		return
+	}
+
+	// Row is persisted as sent before the payment, so the payment is looked up on resume after the crash.
+	row.State = BULK_PAYMENT_ROW_STATE_SENT
+	row.SentAt = time.Now()
+	row.Outcome = nil
+	update(row)
+
+	outcome := handler.ExecutePayment(ctx, row.Order)
+	row.Outcome = &outcome
+	switch {
+	case outcome.IsSucceeded():
+		row.State = BULK_PAYMENT_ROW_STATE_SUCCEEDED
+	case outcome.IsUnknown():
+		row.State = BULK_PAYMENT_ROW_STATE_UNKNOWN
+	default:
+		row.State = BULK_PAYMENT_ROW_STATE_FAILED
+	}
+	update(row)
+}
+
+func writeBulkPayment(bucket *store.Bucket, bulk *BulkPayment) error {
+	unlock, err := bucket.Lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	bulk.UpdatedAt = time.Now()
+	return bucket.Put(bulk.ID, bulk)
+}
+
+func (handler *NodeHandler) BulkPaymentCommand() {
+	if CommandType == "run" {
+		handler.runBulkPaymentCommand()
+
+	} else if CommandType == "resume" {
+		handler.resumeBulkPaymentCommand()
+
+	} else {
+		logger.Error("Invalid bulk-payment command " + CommandType)
+		fmt.Println("Invalid bulk-payment command")
+		return
+	}
+}
+
+func (handler *NodeHandler) runBulkPaymentCommand() {
+	format := BULK_PAYMENTS_FORMAT_CSV
+	if strings.HasSuffix(strings.ToLower(File), ".json") {
+		format = BULK_PAYMENTS_FORMAT_JSON
+	}
+	file, err := os.Open(File)
+	if err != nil {
+		logger.Error("Bad request: can't open file of the bulk payment. Details: " + err.Error())
+		fmt.Println("Bad request: can't open file " + File)
+		return
+	}
+	defer file.Close()
+
+	inputs, err := ParseBulkPayments(format, file)
+	if err != nil {
+		logger.Error("Bad request: invalid file of the bulk payment. Details: " + err.Error())
+		fmt.Println("Bad request: " + err.Error())
+		return
+	}
+	bulk, err := NewBulkPayment(context.Background(), inputs)
+	if err == ErrBulkPaymentIsInvalid {
+		resultJSON := buildJSONResponse(BAD_REQUEST, bulk.Response())
+		fmt.Println(string(resultJSON))
+		return
+	}
+	if err != nil {
+		logger.Error("Bad request: invalid bulk payment. Details: " + err.Error())
+		fmt.Println("Bad request: " + err.Error())
+		return
+	}
+	if DryRun {
+		resultJSON := buildJSONResponse(OK, bulk.Response())
+		fmt.Println(string(resultJSON))
+		return
+	}
+
+	err = CreateBulkPayment(context.Background(), bulk)
+	if err != nil {
+		logger.Error("Can't persist bulk payment. Details: " + err.Error())
+		resultJSON := buildJSONResponse(SERVER_ERROR, common.BulkPaymentResponse{})
+		fmt.Println(string(resultJSON))
+		return
+	}
+	handler.bulkPaymentResult(bulk.ID)
+}
+
+func (handler *NodeHandler) resumeBulkPaymentCommand() {
+	if !common.ValidateUUID(UUID) {
+		logger.Error("Bad request: invalid uuid parameter in bulk-payment request")
+		fmt.Println("Bad request: invalid uuid parameter")
+		return
+	}
+	handler.bulkPaymentResult(uuid.MustParse(UUID).String())
+}
+
+func (handler *NodeHandler) bulkPaymentResult(bulkID string) {
+	bulk, err := handler.RunBulkPayment(context.Background(), bulkID)
+	if err != nil {
+		logger.Error("Can't run bulk payment " + bulkID + ". Details: " + err.Error())
+		code := SERVER_ERROR
+		switch err {
+		case ErrBulkPaymentNotFound:
+			code = NOT_FOUND
+		case ErrBulkPaymentIsRunning, ErrBulkPaymentIsInvalid:
+			code = CONFLICT
+		}
+		resultJSON := buildJSONResponse(code, common.BulkPaymentResponse{})
+		fmt.Println(string(resultJSON))
+		return
+	}
+	resultJSON := buildJSONResponse(OK, bulk.Response())
+	fmt.Println(string(resultJSON))
+}
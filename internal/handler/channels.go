@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"context"
 	"fmt"
 	"strconv"
 
@@ -77,7 +78,7 @@ func (handler *NodeHandler) initChannel() {
 
 func (handler *NodeHandler) initChannelGetResult(command *Command) {
 
-	err := handler.Node.SendCommand(command)
+	err := handler.Node.SendCommand(context.Background(), command)
 	if err != nil {
 		logger.Error("Can't send command: " + string(command.ToBytes()) + " to node. Details: " + err.Error())
 		resultJSON := buildJSONResponse(COMMAND_TRANSFERRING_ERROR, common.ChannelInitResponse{})
@@ -122,7 +123,7 @@ func (handler *NodeHandler) listChannels() {
 }
 
 func (handler *NodeHandler) listChannelsGetResult(command *Command) {
-	err := handler.Node.SendCommand(command)
+	err := handler.Node.SendCommand(context.Background(), command)
 	if err != nil {
 		logger.Error("Can't send command: " + string(command.ToBytes()) + " to node. Details: " + err.Error())
 		resultJSON := buildJSONResponse(COMMAND_TRANSFERRING_ERROR, common.ChannelListResponse{})
@@ -194,7 +195,7 @@ func (handler *NodeHandler) channelInfo() {
 }
 
 func (handler *NodeHandler) channelInfoGetResult(command *Command) {
-	err := handler.Node.SendCommand(command)
+	err := handler.Node.SendCommand(context.Background(), command)
 	if err != nil {
 		logger.Error("Can't send command: " + string(command.ToBytes()) + " to node. Details: " + err.Error())
 		resultJSON := buildJSONResponse(COMMAND_TRANSFERRING_ERROR, common.ChannelInfoResponse{})
@@ -284,7 +285,7 @@ func (handler *NodeHandler) channelInfoByAddresses() {
 }
 
 func (handler *NodeHandler) channelInfoByAddressesGetResult(command *Command) {
-	err := handler.Node.SendCommand(command)
+	err := handler.Node.SendCommand(context.Background(), command)
 	if err != nil {
 		logger.Error("Can't send command: " + string(command.ToBytes()) + " to node. Details: " + err.Error())
 		resultJSON := buildJSONResponse(COMMAND_TRANSFERRING_ERROR, common.ChannelInfoByAddressResponse{})
@@ -355,7 +356,7 @@ func (handler *NodeHandler) setChannelAddresses() {
 }
 
 func (handler *NodeHandler) setChannelAddressesGetResult(command *Command) {
-	err := handler.Node.SendCommand(command)
+	err := handler.Node.SendCommand(context.Background(), command)
 	if err != nil {
 		logger.Error("Can't send command: " + string(command.ToBytes()) + " to node. Details: " + err.Error())
 		resultJSON := buildJSONResponse(COMMAND_TRANSFERRING_ERROR, common.ChannelResponse{})
@@ -406,7 +407,7 @@ func (handler *NodeHandler) setChannelCryptoKey() {
 }
 
 func (handler *NodeHandler) setChannelCryptoKeyGetResult(command *Command) {
-	err := handler.Node.SendCommand(command)
+	err := handler.Node.SendCommand(context.Background(), command)
 	if err != nil {
 		logger.Error("Can't send command: " + string(command.ToBytes()) + " to node. Details: " + err.Error())
 		resultJSON := buildJSONResponse(COMMAND_TRANSFERRING_ERROR, common.ChannelResponse{})
@@ -446,7 +447,7 @@ func (handler *NodeHandler) regenerateChannelCryptoKey() {
 }
 
 func (handler *NodeHandler) regenerateChannelCryptoKeyGetResult(command *Command) {
-	err := handler.Node.SendCommand(command)
+	err := handler.Node.SendCommand(context.Background(), command)
 	if err != nil {
 		logger.Error("Can't send command: " + string(command.ToBytes()) + " to node. Details: " + err.Error())
 		resultJSON := buildJSONResponse(COMMAND_TRANSFERRING_ERROR, common.ChannelInitResponse{})
@@ -497,7 +498,7 @@ func (handler *NodeHandler) removeChannel() {
 }
 
 func (handler *NodeHandler) removeChannelGetResult(command *Command) {
-	err := handler.Node.SendCommand(command)
+	err := handler.Node.SendCommand(context.Background(), command)
 	if err != nil {
 		logger.Error("Can't send command: " + string(command.ToBytes()) + " to node. Details: " + err.Error())
 		resultJSON := buildJSONResponse(COMMAND_TRANSFERRING_ERROR, common.ChannelResponse{})
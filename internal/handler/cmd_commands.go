@@ -1,9 +1,11 @@
 package handler
 
 import (
+	"context"
 	"errors"
 	"fmt"
 
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/audit"
 	"github.com/vTCP-Foundation/vtcpd-cli/internal/logger"
 )
 
@@ -29,9 +31,11 @@ func (nh *NodeHandler) HandleStart() error {
 func (nh *NodeHandler) HandleStop() error {
 	err := nh.StopNode()
 	if err != nil {
+		audit.Record(context.Background(), audit.OPERATION_STOP, nil, "", SERVER_ERROR)
 		logger.Error("Can't stop node " + err.Error())
 		return errors.New("Can't stop node " + err.Error())
 	}
+	audit.Record(context.Background(), audit.OPERATION_STOP, nil, "", OK)
 	logger.Info("Node stopped")
 	fmt.Println("Stopped")
 	return nil
@@ -87,6 +91,25 @@ func (nh *NodeHandler) HandleHistory() error {
 	return nil
 }
 
+func (nh *NodeHandler) HandleSettlementLinesConfig() error {
+	err := nh.StartNodeForCommunication()
+	if err != nil {
+		logger.Error("Node is not running. Details: " + err.Error())
+		return errors.New("Node is not running. Details: " + err.Error())
+	}
+	return nh.SettlementLinesConfigCommand()
+}
+
+func (nh *NodeHandler) HandleReconcile() error {
+	err := nh.StartNodeForCommunication()
+	if err != nil {
+		logger.Error("Node is not running. Details: " + err.Error())
+		return errors.New("Node is not running. Details: " + err.Error())
+	}
+	nh.reconcile()
+	return nil
+}
+
 func (nh *NodeHandler) HandleRemoveOutdatedCrypto() error {
 	err := nh.StartNodeForCommunication()
 	if err != nil {
@@ -96,3 +119,31 @@ func (nh *NodeHandler) HandleRemoveOutdatedCrypto() error {
 	nh.RemoveOutdatedCryptoDataCommand()
 	return nil
 }
+
+func (nh *NodeHandler) HandleApiKey() error {
+	// Node is not required for the keys management.
+	nh.ApiKeys()
+	return nil
+}
+
+func (nh *NodeHandler) HandleAudit() error {
+	// Node is not required for the audit log verification.
+	return nh.Audit()
+}
+
+func (nh *NodeHandler) HandlePendingPayments() error {
+	nh.PendingPaymentsCommand()
+	return nil
+}
+
+func (nh *NodeHandler) HandleBulkPayment() error {
+	if !DryRun {
+		err := nh.StartNodeForCommunication()
+		if err != nil {
+			logger.Error("Node is not running. Details: " + err.Error())
+			return errors.New("Node is not running. Details: " + err.Error())
+		}
+	}
+	nh.BulkPaymentCommand()
+	return nil
+}
@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"context"
 	"strings"
 
 	"github.com/google/uuid"
@@ -9,6 +10,11 @@ import (
 type Command struct {
 	UUID uuid.UUID
 	Body string
+
+	// Context of the request, on behalf of which the command is sent.
+	// Is set by the Node.SendCommand() and is used for the tracing of
+	// the further command processing stages (FIFO write, result waiting).
+	ctx context.Context
 }
 
 func NewCommand(body ...string) *Command {
@@ -41,6 +47,25 @@ func NewCommandWithUUID(transactionUUID uuid.UUID, body ...string) *Command {
 	}
 }
 
+// Returns name of the command (the first token of the body), e.g. "GET:equivalents".
+func (c *Command) Name() string {
+	name, _, _ := strings.Cut(c.Body, "\t")
+	return name
+}
+
+// Returns arguments of the command (all tokens of the body, except the name).
+func (c *Command) Arguments() []string {
+	tokens := strings.Split(c.Body, "\t")
+	return tokens[1:]
+}
+
+func (c *Command) context() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
 func (c *Command) ToBytes() []byte {
 	command := c.UUID.String()
 	tokens := strings.Split(c.Body, "\t")
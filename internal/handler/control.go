@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"context"
 	"fmt"
 	"strconv"
 
@@ -14,7 +15,7 @@ func (handler *NodeHandler) RemoveOutdatedCryptoDataCommand() {
 	// Command generation
 	command := NewCommand("DELETE:outdated-crypto", "1")
 
-	err := handler.Node.SendCommand(command)
+	err := handler.Node.SendCommand(context.Background(), command)
 	if err != nil {
 		logger.Error("Can't send command: " + string(command.ToBytes()) + " to node. Details: " + err.Error())
 		resultJSON := buildJSONResponse(COMMAND_TRANSFERRING_ERROR, common.ControlResponse{})
@@ -39,3 +40,51 @@ func (handler *NodeHandler) RemoveOutdatedCryptoDataCommand() {
 	resultJSON := buildJSONResponse(result.Code, common.ControlResponse{})
 	fmt.Println(string(resultJSON))
 }
+
+// Removes outdated crypto data of the engine (DELETE:outdated-crypto), vacuum is "0" or "1".
+func (handler *NodeHandler) RemoveOutdatedCryptoData(ctx context.Context, vacuum string) int {
+	command := NewCommand("DELETE:outdated-crypto", vacuum)
+
+	err := handler.Node.SendCommand(ctx, command)
+	if err != nil {
+		logger.Error("Can't send command: " + string(command.ToBytes()) + " to node. Details: " + err.Error())
+		return COMMAND_TRANSFERRING_ERROR
+	}
+
+	result, err := handler.Node.GetResult(command, common.DELETE_CRYPTO_DATA_TIMEOUT)
+	if err != nil {
+		logger.Error("Node is inaccessible during processing command: " +
+			string(command.ToBytes()) + ". Details: " + err.Error())
+		return NODE_IS_INACCESSIBLE
+	}
+
+	if result.Code != OK {
+		logger.Error("Node return wrong command result: " + strconv.Itoa(result.Code) +
+			" on command: " + string(command.ToBytes()))
+	}
+	return result.Code
+}
+
+// Regenerates keys of the settlement line (SET:contractors/trust-line-keys).
+func (handler *NodeHandler) RegenerateSettlementLineKeys(ctx context.Context, contractorID, equivalent string) int {
+	command := NewCommand("SET:contractors/trust-line-keys", contractorID, equivalent)
+
+	err := handler.Node.SendCommand(ctx, command)
+	if err != nil {
+		logger.Error("Can't send command: " + string(command.ToBytes()) + " to node. Details: " + err.Error())
+		return COMMAND_TRANSFERRING_ERROR
+	}
+
+	result, err := handler.Node.GetResult(command, common.CHANNEL_RESULT_TIMEOUT)
+	if err != nil {
+		logger.Error("Node is inaccessible during processing command: " +
+			string(command.ToBytes()) + ". Details: " + err.Error())
+		return NODE_IS_INACCESSIBLE
+	}
+
+	if result.Code != OK {
+		logger.Error("Node return wrong command result: " + strconv.Itoa(result.Code) +
+			" on command: " + string(command.ToBytes()))
+	}
+	return result.Code
+}
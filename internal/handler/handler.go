@@ -20,6 +20,9 @@ var (
 	CREATED                    = 201
 	ACCEPTED                   = 202
 	BAD_REQUEST                = 400
+	PAYMENT_POLICY_VIOLATION   = 403
+	NOT_FOUND                  = 404
+	CONFLICT                   = 409
 	NODE_NOT_FOUND             = 405
 	SERVER_ERROR               = 500
 	NODE_IS_INACCESSIBLE       = 503
@@ -47,6 +50,19 @@ var (
 	MaxNegativeBalance        = ""
 	MaxPositiveBalance        = ""
 	Balance                   = ""
+	UUID                      = ""
+	File                      = ""
+	DryRun                    = false
+	CheckPayment              = false
+	SendIfFeasible            = false
+	HistoryKind               = ""
+	ExportFormat              = ""
+	Output                    = ""
+	ReportFrom                = ""
+	ReportTo                  = ""
+	AutoApprove               = false
+	Online                    = false
+	PassphraseFile            = ""
 )
 
 type NodeHandler struct {
@@ -185,7 +201,7 @@ func (nh *NodeHandler) ensureNodeConfigurationIsPresent() error {
 }
 
 func (nh *NodeHandler) IfNodeWaitForResult() bool {
-	return len(nh.Node.results) > 0
+	return nh.Node.resultChannelsCount() > 0
 }
 
 func (nh *NodeHandler) StopNode() error {
@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"context"
 	"fmt"
 	"strconv"
 
@@ -25,6 +26,9 @@ func (handler *NodeHandler) History() {
 	} else if CommandType == "with-contractor" {
 		handler.contractorOperationsHistory()
 
+	} else if CommandType == "export" {
+		handler.exportHistory()
+
 	} else {
 		logger.Error("Invalid history command " + CommandType)
 		fmt.Println("Invalid history command")
@@ -69,7 +73,7 @@ func (handler *NodeHandler) settlementLinesHistory() {
 }
 
 func (handler *NodeHandler) settlementLinesHistoryResult(command *Command) {
-	err := handler.Node.SendCommand(command)
+	err := handler.Node.SendCommand(context.Background(), command)
 	if err != nil {
 		logger.Error("Can't send command: " + string(command.ToBytes()) + " to node. Details: " + err.Error())
 		resultJSON := buildJSONResponse(COMMAND_TRANSFERRING_ERROR, common.SettlementLineHistoryResponse{})
@@ -197,7 +201,7 @@ func (handler *NodeHandler) paymentsHistory() {
 }
 
 func (handler *NodeHandler) paymentsHistoryResult(command *Command) {
-	err := handler.Node.SendCommand(command)
+	err := handler.Node.SendCommand(context.Background(), command)
 	if err != nil {
 		logger.Error("Can't send command: " + string(command.ToBytes()) + " to node. Details: " + err.Error())
 		resultJSON := buildJSONResponse(COMMAND_TRANSFERRING_ERROR, common.PaymentHistoryResponse{})
@@ -319,7 +323,7 @@ func (handler *NodeHandler) paymentsHistoryAllEquivalents() {
 }
 
 func (handler *NodeHandler) paymentsHistoryAllEquivalentsResult(command *Command) {
-	err := handler.Node.SendCommand(command)
+	err := handler.Node.SendCommand(context.Background(), command)
 	if err != nil {
 		logger.Error("Can't send command: " + string(command.ToBytes()) + " to node. Details: " + err.Error())
 		resultJSON := buildJSONResponse(COMMAND_TRANSFERRING_ERROR, common.PaymentAllEquivalentsHistoryResponse{})
@@ -427,7 +431,7 @@ func (handler *NodeHandler) contractorOperationsHistory() {
 }
 
 func (handler *NodeHandler) contractorOperationsHistoryResult(command *Command) {
-	err := handler.Node.SendCommand(command)
+	err := handler.Node.SendCommand(context.Background(), command)
 	if err != nil {
 		logger.Error("Can't send command: " + string(command.ToBytes()) + " to node. Details: " + err.Error())
 		resultJSON := buildJSONResponse(COMMAND_TRANSFERRING_ERROR, common.ContractorOperationsHistoryResponse{})
@@ -572,7 +576,7 @@ func (handler *NodeHandler) additionalHistory() {
 }
 
 func (handler *NodeHandler) additionalHistoryResult(command *Command) {
-	err := handler.Node.SendCommand(command)
+	err := handler.Node.SendCommand(context.Background(), command)
 	if err != nil {
 		logger.Error("Can't send command: " + string(command.ToBytes()) + " to node. Details: " + err.Error())
 		resultJSON := buildJSONResponse(COMMAND_TRANSFERRING_ERROR, common.AdditionalPaymentHistoryResponse{})
@@ -0,0 +1,528 @@
+package handler
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/common"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/logger"
+)
+
+var (
+	HISTORY_EXPORT_KIND_PAYMENTS         = "payments"
+	HISTORY_EXPORT_KIND_PAYMENTS_ALL     = "payments-all"
+	HISTORY_EXPORT_KIND_SETTLEMENT_LINES = "settlement-lines"
+	HISTORY_EXPORT_KIND_ADDITIONAL       = "additional"
+	HISTORY_EXPORT_KIND_WITH_CONTRACTOR  = "with-contractor"
+
+	HISTORY_EXPORT_FORMAT_CSV    = "csv"
+	HISTORY_EXPORT_FORMAT_NDJSON = "ndjson"
+	HISTORY_EXPORT_FORMAT_LEDGER = "ledger"
+
+	// Count of the records, that are requested from the engine at once.
+	DEFAULT_HISTORY_EXPORT_PAGE_SIZE = 100
+	MAX_HISTORY_EXPORT_PAGE_SIZE     = 1000
+
+	HISTORY_EXPORT_CSV_COLUMNS = []string{
+		"equivalent", "record_type", "transaction_uuid", "timestamp", "unix_timestamp_microseconds",
+		"contractor", "operation_direction", "amount", "balance_after_operation", "payload"}
+
+	historyExportFormatsContentTypes = map[string]string{
+		HISTORY_EXPORT_FORMAT_CSV:    "text/csv; charset=utf-8",
+		HISTORY_EXPORT_FORMAT_NDJSON: "application/x-ndjson",
+		HISTORY_EXPORT_FORMAT_LEDGER: "text/plain; charset=utf-8",
+	}
+)
+
+// Parameters of the history export.
+// Dates and amounts are the inclusive bounds, zero date and empty amount are not bounding.
+type HistoryExportFilter struct {
+	Kind       string
+	Equivalent string
+	// Addresses in the form "<type>-<address>", are used by the "with-contractor" history only.
+	ContractorAddresses []string
+	DateFrom            time.Time
+	DateTo              time.Time
+	AmountFrom          string
+	AmountTo            string
+	PageSize            int
+}
+
+func (f HistoryExportFilter) Validate(format string) error {
+	switch f.Kind {
+	case HISTORY_EXPORT_KIND_PAYMENTS, HISTORY_EXPORT_KIND_PAYMENTS_ALL, HISTORY_EXPORT_KIND_SETTLEMENT_LINES,
+		HISTORY_EXPORT_KIND_ADDITIONAL, HISTORY_EXPORT_KIND_WITH_CONTRACTOR:
+	default:
+		return errors.New("unknown history kind " + f.Kind)
+	}
+	if _, isPresent := historyExportFormatsContentTypes[format]; !isPresent {
+		return errors.New("unknown export format " + format)
+	}
+	// Settlement lines changes are not the transfers, so they could not be written as ledger transactions.
+	if format == HISTORY_EXPORT_FORMAT_LEDGER && f.Kind == HISTORY_EXPORT_KIND_SETTLEMENT_LINES {
+		return errors.New("settlement lines history could not be exported in ledger format")
+	}
+	if f.Kind != HISTORY_EXPORT_KIND_PAYMENTS_ALL && !common.ValidateInt(f.Equivalent) {
+		return errors.New("invalid equivalent")
+	}
+	if f.Kind == HISTORY_EXPORT_KIND_WITH_CONTRACTOR && len(f.ContractorAddresses) == 0 {
+		return errors.New("contractor addresses are required")
+	}
+	for _, contractorAddress := range f.ContractorAddresses {
+		addressType, address, isPresent := strings.Cut(contractorAddress, "-")
+		if !isPresent || !common.ValidateInt(addressType) || address == "" {
+			return errors.New("invalid contractor address " + contractorAddress)
+		}
+	}
+	if f.AmountFrom != "" && !common.ValidateSettlementLineAmount(f.AmountFrom) {
+		return errors.New("invalid amount from")
+	}
+	if f.AmountTo != "" && !common.ValidateSettlementLineAmount(f.AmountTo) {
+		return errors.New("invalid amount to")
+	}
+	if !f.DateFrom.IsZero() && !f.DateTo.IsZero() && f.DateFrom.After(f.DateTo) {
+		return errors.New("date from is after date to")
+	}
+	if f.PageSize < 1 || f.PageSize > MAX_HISTORY_EXPORT_PAGE_SIZE {
+		return errors.New("page size must be from 1 to " + strconv.Itoa(MAX_HISTORY_EXPORT_PAGE_SIZE))
+	}
+	return nil
+}
+
+// Parses the date bound of the export: RFC3339 time or unix timestamp (seconds).
+// Empty value is not bounding.
+func ParseHistoryExportDate(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err == nil {
+		return time.Unix(seconds, 0), nil
+	}
+	date, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, errors.New("invalid date " + value + ", RFC3339 time or unix timestamp is expected")
+	}
+	return date, nil
+}
+
+func HistoryExportContentType(format string) string {
+	return historyExportFormatsContentTypes[format]
+}
+
+// Pages through the history until the records run out.
+// Each page is passed to the onPage (at least one page is always passed, even if it's empty).
+// Returns OK, or the result code of the failed engine command (records, that were already passed, are kept).
+// Error is returned only if the onPage has failed.
+func (handler *NodeHandler) ExportHistory(
+	ctx context.Context, filter HistoryExportFilter, onPage func([]common.HistoryExportRecord) error) (int, error) {
+
+	for offset := 0; ; offset += filter.PageSize {
+		records, recordsCount, code := handler.historyExportPage(ctx, filter, offset)
+		if code != OK {
+			return code, nil
+		}
+		err := onPage(records)
+		if err != nil {
+			return OK, err
+		}
+		if recordsCount < filter.PageSize {
+			return OK, nil
+		}
+	}
+}
+
+// Requests one page of the history and returns it's records, that are passing the filter,
+// and the count of the records, that were returned by the engine.
+func (handler *NodeHandler) historyExportPage(
+	ctx context.Context, filter HistoryExportFilter, offset int) ([]common.HistoryExportRecord, int, int) {
+
+	amountFrom, amountTo := filter.AmountFrom, filter.AmountTo
+	if amountFrom == "" {
+		amountFrom = "null"
+	}
+	if amountTo == "" {
+		amountTo = "null"
+	}
+	offsetToken, countToken := strconv.Itoa(offset), strconv.Itoa(filter.PageSize)
+
+	// Dates are filtered here, not by the engine, because the engine expects them in it's own units.
+	var command *Command
+	switch filter.Kind {
+	case HISTORY_EXPORT_KIND_PAYMENTS:
+		command = NewCommand(
+			"GET:history/payments", offsetToken, countToken, "null", "null",
+			amountFrom, amountTo, "null", "null", filter.Equivalent)
+	case HISTORY_EXPORT_KIND_PAYMENTS_ALL:
+		command = NewCommand(
+			"GET:history/payments/all", offsetToken, countToken, "null", "null", amountFrom, amountTo, "null")
+	case HISTORY_EXPORT_KIND_SETTLEMENT_LINES:
+		command = NewCommand(
+			"GET:history/trust-lines", offsetToken, countToken, "null", "null", filter.Equivalent)
+	case HISTORY_EXPORT_KIND_ADDITIONAL:
+		command = NewCommand(
+			"GET:history/payments/additional", offsetToken, countToken, "null", "null",
+			amountFrom, amountTo, filter.Equivalent)
+	case HISTORY_EXPORT_KIND_WITH_CONTRACTOR:
+		tokens := []string{"GET:history/contractor", offsetToken, countToken, strconv.Itoa(len(filter.ContractorAddresses))}
+		for _, contractorAddress := range filter.ContractorAddresses {
+			addressType, address, _ := strings.Cut(contractorAddress, "-")
+			tokens = append(tokens, addressType, address)
+		}
+		tokens = append(tokens, filter.Equivalent)
+		command = NewCommand(tokens...)
+	}
+
+	err := handler.Node.SendCommand(ctx, command)
+	if err != nil {
+		logger.Error("Can't send command: " + string(command.ToBytes()) + " to node. Details: " + err.Error())
+		return nil, 0, COMMAND_TRANSFERRING_ERROR
+	}
+
+	result, err := handler.Node.GetResult(command, common.HISTORY_RESULT_TIMEOUT)
+	if err != nil {
+		logger.Error("Node is inaccessible during processing command: " +
+			string(command.ToBytes()) + ". Details: " + err.Error())
+		return nil, 0, NODE_IS_INACCESSIBLE
+	}
+
+	if result.Code != OK {
+		logger.Error("Node return wrong command result: " + strconv.Itoa(result.Code) +
+			" on command: " + string(command.ToBytes()))
+		return nil, 0, result.Code
+	}
+
+	records, err := parseHistoryExportRecords(filter, result.Tokens)
+	if err != nil {
+		logger.Error("Node return invalid history on command: " + string(command.ToBytes()) +
+			". Details: " + err.Error())
+		return nil, 0, ENGINE_UNEXPECTED_ERROR
+	}
+	recordsCount := len(records)
+
+	filtered := []common.HistoryExportRecord{}
+	for _, record := range records {
+		isPassed, err := isHistoryExportRecordPassed(filter, record)
+		if err != nil {
+			logger.Error("Node return invalid history record on command: " + string(command.ToBytes()) +
+				". Details: " + err.Error())
+			return nil, 0, ENGINE_UNEXPECTED_ERROR
+		}
+		if isPassed {
+			filtered = append(filtered, record)
+		}
+	}
+	return filtered, recordsCount, OK
+}
+
+// Converts the engine result tokens of the history command to the export records.
+func parseHistoryExportRecords(filter HistoryExportFilter, tokens []string) ([]common.HistoryExportRecord, error) {
+	if len(tokens) == 0 {
+		return nil, errors.New("result tokens are empty")
+	}
+	recordsCount, err := strconv.Atoi(tokens[0])
+	if err != nil {
+		return nil, wrap("invalid records count", err)
+	}
+
+	records := make([]common.HistoryExportRecord, 0, recordsCount)
+	tokenIdx := 1
+	// Returns the next n tokens of the record.
+	next := func(n int) ([]string, error) {
+		if tokenIdx+n > len(tokens) {
+			return nil, errors.New("result tokens are truncated")
+		}
+		recordTokens := tokens[tokenIdx : tokenIdx+n]
+		tokenIdx += n
+		return recordTokens, nil
+	}
+
+	for range recordsCount {
+		record := common.HistoryExportRecord{Equivalent: filter.Equivalent, RecordType: "payment"}
+		switch filter.Kind {
+		case HISTORY_EXPORT_KIND_PAYMENTS:
+			t, err := next(7)
+			if err != nil {
+				return nil, err
+			}
+			record.TransactionUUID, record.UnixTimestampMicroseconds, record.Contractor = t[0], t[1], t[2]
+			record.OperationDirection, record.Amount, record.BalanceAfterOperation, record.Payload = t[3], t[4], t[5], t[6]
+
+		case HISTORY_EXPORT_KIND_PAYMENTS_ALL:
+			t, err := next(8)
+			if err != nil {
+				return nil, err
+			}
+			record.Equivalent, record.TransactionUUID, record.UnixTimestampMicroseconds, record.Contractor = t[0], t[1], t[2], t[3]
+			record.OperationDirection, record.Amount, record.BalanceAfterOperation, record.Payload = t[4], t[5], t[6], t[7]
+
+		case HISTORY_EXPORT_KIND_SETTLEMENT_LINES:
+			t, err := next(5)
+			if err != nil {
+				return nil, err
+			}
+			record.RecordType = "trustline"
+			record.TransactionUUID, record.UnixTimestampMicroseconds, record.Contractor = t[0], t[1], t[2]
+			record.OperationDirection, record.Amount = t[3], t[4]
+
+		case HISTORY_EXPORT_KIND_ADDITIONAL:
+			t, err := next(4)
+			if err != nil {
+				return nil, err
+			}
+			record.TransactionUUID, record.UnixTimestampMicroseconds = t[0], t[1]
+			record.OperationDirection, record.Amount = t[2], t[3]
+
+		case HISTORY_EXPORT_KIND_WITH_CONTRACTOR:
+			recordType, err := next(1)
+			if err != nil {
+				return nil, err
+			}
+			// Records of this history don't contain the contractor, so the requested addresses are used.
+			record.RecordType = recordType[0]
+			record.Contractor = strings.Join(filter.ContractorAddresses, " ")
+			switch record.RecordType {
+			case "payment":
+				t, err := next(6)
+				if err != nil {
+					return nil, err
+				}
+				record.TransactionUUID, record.UnixTimestampMicroseconds, record.OperationDirection = t[0], t[1], t[2]
+				record.Amount, record.BalanceAfterOperation, record.Payload = t[3], t[4], t[5]
+			case "trustline":
+				t, err := next(4)
+				if err != nil {
+					return nil, err
+				}
+				record.TransactionUUID, record.UnixTimestampMicroseconds, record.OperationDirection = t[0], t[1], t[2]
+				record.Amount = t[3]
+			default:
+				return nil, errors.New("unknown record type " + record.RecordType)
+			}
+		}
+
+		microseconds, err := strconv.ParseInt(record.UnixTimestampMicroseconds, 10, 64)
+		if err != nil {
+			return nil, wrap("invalid timestamp of the record "+record.TransactionUUID, err)
+		}
+		record.Timestamp = time.UnixMicro(microseconds).UTC().Format(time.RFC3339)
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// Checks the record against the dates and amounts bounds of the filter.
+func isHistoryExportRecordPassed(filter HistoryExportFilter, record common.HistoryExportRecord) (bool, error) {
+	microseconds, _ := strconv.ParseInt(record.UnixTimestampMicroseconds, 10, 64)
+	timestamp := time.UnixMicro(microseconds)
+	if !filter.DateFrom.IsZero() && timestamp.Before(filter.DateFrom) {
+		return false, nil
+	}
+	if !filter.DateTo.IsZero() && timestamp.After(filter.DateTo) {
+		return false, nil
+	}
+
+	if filter.AmountFrom == "" && filter.AmountTo == "" {
+		return true, nil
+	}
+	amount, isParsed := new(big.Int).SetString(record.Amount, 10)
+	if !isParsed {
+		return false, errors.New("invalid amount " + record.Amount + " of the record " + record.TransactionUUID)
+	}
+	if filter.AmountFrom != "" {
+		amountFrom, _ := new(big.Int).SetString(filter.AmountFrom, 10)
+		if amount.Cmp(amountFrom) < 0 {
+			return false, nil
+		}
+	}
+	if filter.AmountTo != "" {
+		amountTo, _ := new(big.Int).SetString(filter.AmountTo, 10)
+		if amount.Cmp(amountTo) > 0 {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// Writes the exported records in one of the export formats.
+type HistoryWriter struct {
+	format          string
+	out             io.Writer
+	isHeaderWritten bool
+}
+
+func NewHistoryWriter(format string, out io.Writer) *HistoryWriter {
+	return &HistoryWriter{format: format, out: out}
+}
+
+func (hw *HistoryWriter) Write(records []common.HistoryExportRecord) error {
+	switch hw.format {
+	case HISTORY_EXPORT_FORMAT_CSV:
+		return hw.writeCSV(records)
+	case HISTORY_EXPORT_FORMAT_NDJSON:
+		encoder := json.NewEncoder(hw.out)
+		for _, record := range records {
+			err := encoder.Encode(record)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	case HISTORY_EXPORT_FORMAT_LEDGER:
+		for _, record := range records {
+			err := hw.writeLedgerTransaction(record)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return errors.New("unknown export format " + hw.format)
+	}
+}
+
+func (hw *HistoryWriter) writeCSV(records []common.HistoryExportRecord) error {
+	writer := csv.NewWriter(hw.out)
+	if !hw.isHeaderWritten {
+		hw.isHeaderWritten = true
+		err := writer.Write(HISTORY_EXPORT_CSV_COLUMNS)
+		if err != nil {
+			return err
+		}
+	}
+	for _, record := range records {
+		err := writer.Write([]string{
+			record.Equivalent, record.RecordType, record.TransactionUUID, record.Timestamp,
+			record.UnixTimestampMicroseconds, record.Contractor, record.OperationDirection,
+			record.Amount, record.BalanceAfterOperation, record.Payload})
+		if err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// Writes the payment as plain-text double-entry transaction:
+// the amount is moved between the node account and the contractor account of the equivalent.
+// Settlement lines changes are not the transfers, so they are skipped.
+func (hw *HistoryWriter) writeLedgerTransaction(record common.HistoryExportRecord) error {
+	if record.RecordType != "payment" {
+		return nil
+	}
+
+	contractorAccount := "Contractors:" + record.Equivalent
+	if record.Contractor != "" {
+		contractorAccount += ":" + strings.ReplaceAll(record.Contractor, " ", ",")
+	}
+	nodeAccount := "Node:" + record.Equivalent
+	commodity := "\"EQ" + record.Equivalent + "\""
+
+	// Incoming payment increases the node account.
+	debit, credit := contractorAccount, nodeAccount
+	if record.OperationDirection == "incoming" {
+		debit, credit = nodeAccount, contractorAccount
+	}
+
+	var entry strings.Builder
+	fmt.Fprintf(&entry, "%s * Payment %s\n", record.Timestamp[:len("2006-01-02")], record.TransactionUUID)
+	fmt.Fprintf(&entry, "    ; timestamp: %s\n", record.Timestamp)
+	fmt.Fprintf(&entry, "    ; direction: %s\n", record.OperationDirection)
+	if record.Payload != "" {
+		fmt.Fprintf(&entry, "    ; payload: %s\n", strings.ReplaceAll(record.Payload, "\n", " "))
+	}
+	fmt.Fprintf(&entry, "    %s  %s %s\n", debit, record.Amount, commodity)
+	fmt.Fprintf(&entry, "    %s  -%s %s\n\n", credit, record.Amount, commodity)
+	_, err := io.WriteString(hw.out, entry.String())
+	return err
+}
+
+// Exports the history to the file (--output) or to the stdout.
+func (handler *NodeHandler) exportHistory() {
+	pageSize := DEFAULT_HISTORY_EXPORT_PAGE_SIZE
+	if Count != "" {
+		size, err := strconv.Atoi(Count)
+		if err != nil {
+			logger.Error("Bad request: invalid count parameter in history export request")
+			fmt.Println("Bad request: invalid count parameter")
+			return
+		}
+		pageSize = size
+	}
+
+	filter := HistoryExportFilter{
+		Kind:       HistoryKind,
+		Equivalent: Equivalent,
+		AmountFrom: AmountFrom,
+		AmountTo:   AmountTo,
+		PageSize:   pageSize,
+	}
+	for _, contractorAddress := range Addresses {
+		addressType, address := common.ValidateAddress(contractorAddress)
+		if addressType == "" {
+			logger.Error("Bad request: invalid address parameter in history export request")
+			fmt.Println("Bad request: invalid address parameter")
+			return
+		}
+		filter.ContractorAddresses = append(filter.ContractorAddresses, addressType+"-"+address)
+	}
+	var err error
+	filter.DateFrom, err = ParseHistoryExportDate(HistoryFrom)
+	if err != nil {
+		logger.Error("Bad request: invalid history-from parameter in history export request")
+		fmt.Println("Bad request: invalid history-from parameter")
+		return
+	}
+	filter.DateTo, err = ParseHistoryExportDate(HistoryTo)
+	if err != nil {
+		logger.Error("Bad request: invalid history-to parameter in history export request")
+		fmt.Println("Bad request: invalid history-to parameter")
+		return
+	}
+
+	format := ExportFormat
+	if format == "" {
+		format = HISTORY_EXPORT_FORMAT_NDJSON
+	}
+	err = filter.Validate(format)
+	if err != nil {
+		logger.Error("Bad request: " + err.Error() + " in history export request")
+		fmt.Println("Bad request: " + err.Error())
+		return
+	}
+
+	out := os.Stdout
+	if Output != "" {
+		out, err = os.Create(Output)
+		if err != nil {
+			logger.Error("Can't create history export file " + Output + ". Details: " + err.Error())
+			fmt.Println(string(buildJSONResponse(SERVER_ERROR, common.HistoryExportResponse{})))
+			return
+		}
+		defer out.Close()
+	}
+
+	writer := NewHistoryWriter(format, out)
+	exported := 0
+	code, err := handler.ExportHistory(context.Background(), filter, func(records []common.HistoryExportRecord) error {
+		exported += len(records)
+		return writer.Write(records)
+	})
+	if err != nil {
+		logger.Error("Can't write history export. Details: " + err.Error())
+		code = SERVER_ERROR
+	}
+	if code != OK || Output != "" {
+		resultJSON := buildJSONResponse(code, common.HistoryExportResponse{Count: exported, File: Output})
+		fmt.Println(string(resultJSON))
+	}
+}
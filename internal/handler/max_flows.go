@@ -0,0 +1,300 @@
+package handler
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/common"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/conf"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/events"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/logger"
+)
+
+var (
+	DEFAULT_MAX_FLOW_CHUNK_SIZE  = 20
+	DEFAULT_MAX_FLOW_CONCURRENCY = 4
+	DEFAULT_MAX_FLOW_CACHE_TTL   = time.Minute
+
+	// Commands, that are changing the settlement lines. Equivalent is the last argument of each of them.
+	settlementLinesChangingCommands = map[string]bool{
+		"INIT:contractors/trust-line":            true,
+		"SET:contractors/trust-lines":            true,
+		"SET:contractors/trust-lines/reset":      true,
+		"SET:contractors/trust-line-keys":        true,
+		"DELETE:contractors/incoming-trust-line": true,
+		"DELETE:contractors/trust-line":          true,
+	}
+)
+
+// Max flow toward the contractor address.
+// Code is OK if the flow was calculated, otherwise it is the engine result code or one of the CLI codes.
+type MaxFlow struct {
+	// Address in the form "<type>-<address>".
+	Address      string
+	Code         int
+	MaxAmount    string
+	IsCached     bool
+	CalculatedAt time.Time
+}
+
+func (f MaxFlow) Record() common.MaxFlowBatchRecord {
+	addressType, address, _ := strings.Cut(f.Address, "-")
+	record := common.MaxFlowBatchRecord{
+		ContractorAddressType: addressType,
+		ContractorAddress:     address,
+		MaxAmount:             f.MaxAmount,
+		Cached:                f.IsCached,
+	}
+	if f.Code != OK {
+		record.Code = f.Code
+		return record
+	}
+	record.CalculatedAt = f.CalculatedAt.UTC().Format(time.RFC3339)
+	record.AgeSeconds = int(time.Since(f.CalculatedAt).Seconds())
+	return record
+}
+
+type maxFlowsCacheEntry struct {
+	maxAmount    string
+	calculatedAt time.Time
+}
+
+// Max flows of the equivalent.
+// Generation is changed on each invalidation, so the flows, that were calculated before it, are not cached.
+type maxFlowsCacheEquivalent struct {
+	generation int
+	entries    map[string]maxFlowsCacheEntry
+}
+
+// Max flows depend on the whole network state, so any change of the payments or the settlement lines
+// invalidates all the cached flows of the equivalent.
+type maxFlowsCache struct {
+	lock        sync.Mutex
+	equivalents map[string]*maxFlowsCacheEquivalent
+}
+
+var maxFlows = &maxFlowsCache{equivalents: make(map[string]*maxFlowsCacheEquivalent)}
+
+func init() {
+	events.Subscribe(func(event events.Event) {
+		switch data := event.Data.(type) {
+		case events.PaymentData:
+			if event.Type == events.EVENT_PAYMENT_COMPLETED {
+				maxFlows.invalidate(data.Equivalent)
+			}
+		case events.IncomingPaymentData:
+			maxFlows.invalidate(data.Equivalent)
+		case events.SettlementLineData:
+			maxFlows.invalidate(data.Equivalent)
+		}
+	})
+}
+
+func maxFlowsCacheTTL() time.Duration {
+	if conf.Params.MaxFlow.CacheTTL > 0 {
+		return conf.Params.MaxFlow.CacheTTL
+	}
+	return DEFAULT_MAX_FLOW_CACHE_TTL
+}
+
+func (c *maxFlowsCache) equivalent(equivalent string) *maxFlowsCacheEquivalent {
+	cached, isPresent := c.equivalents[equivalent]
+	if !isPresent {
+		cached = &maxFlowsCacheEquivalent{entries: make(map[string]maxFlowsCacheEntry)}
+		c.equivalents[equivalent] = cached
+	}
+	return cached
+}
+
+// Returns the flows, that are cached and are not outdated, and the generation of the equivalent.
+func (c *maxFlowsCache) get(equivalent string, addresses []string) (map[string]maxFlowsCacheEntry, int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	cached := c.equivalent(equivalent)
+	ttl := maxFlowsCacheTTL()
+	entries := make(map[string]maxFlowsCacheEntry)
+	for _, address := range addresses {
+		entry, isPresent := cached.entries[address]
+		if !isPresent {
+			continue
+		}
+		if time.Since(entry.calculatedAt) > ttl {
+			delete(cached.entries, address)
+			continue
+		}
+		entries[address] = entry
+	}
+	return entries, cached.generation
+}
+
+// Caches the flows, if the equivalent was not invalidated since the calculation was started.
+func (c *maxFlowsCache) put(equivalent string, generation int, flows []MaxFlow) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	cached := c.equivalent(equivalent)
+	if cached.generation != generation {
+		return
+	}
+	for _, flow := range flows {
+		if flow.Code != OK {
+			continue
+		}
+		cached.entries[flow.Address] = maxFlowsCacheEntry{maxAmount: flow.MaxAmount, calculatedAt: flow.CalculatedAt}
+	}
+}
+
+func (c *maxFlowsCache) invalidate(equivalent string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	cached := c.equivalent(equivalent)
+	cached.generation++
+	cached.entries = make(map[string]maxFlowsCacheEntry)
+}
+
+// Invalidates cached max flows, when the settlement line was changed by the command.
+func invalidateMaxFlowsOnCommand(command *Command) {
+	if !settlementLinesChangingCommands[command.Name()] {
+		return
+	}
+	arguments := command.Arguments()
+	maxFlows.invalidate(arguments[len(arguments)-1])
+}
+
+// Calculates max flows toward the addresses ("<type>-<address>").
+// Addresses are split into the chunks, that are calculated concurrently by GET:contractors/transactions/max/fully.
+// Flows, that were calculated recently, are taken from the cache (if isCacheUsed is set).
+// This command may execute relatively slow (up to common.MAX_FLOW_FULLY_TIMEOUT for each chunk).
+func (handler *NodeHandler) BatchMaxFlows(ctx context.Context, equivalent string, addresses []string, isCacheUsed bool) []MaxFlow {
+	cached, generation := maxFlows.get(equivalent, addresses)
+	if !isCacheUsed {
+		cached = nil
+	}
+
+	flows := make([]MaxFlow, len(addresses))
+	var missing []int
+	isRequested := make(map[string]bool)
+	for idx, address := range addresses {
+		entry, isPresent := cached[address]
+		if isPresent {
+			flows[idx] = MaxFlow{Address: address, Code: OK, MaxAmount: entry.maxAmount, IsCached: true, CalculatedAt: entry.calculatedAt}
+			continue
+		}
+		if !isRequested[address] {
+			isRequested[address] = true
+			missing = append(missing, idx)
+		}
+	}
+
+	chunkSize := conf.Params.MaxFlow.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = DEFAULT_MAX_FLOW_CHUNK_SIZE
+	}
+	concurrency := conf.Params.MaxFlow.Concurrency
+	if concurrency <= 0 {
+		concurrency = DEFAULT_MAX_FLOW_CONCURRENCY
+	}
+
+	calculated := make(map[string]MaxFlow)
+	var calculatedLock sync.Mutex
+	slots := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for from := 0; from < len(missing); from += chunkSize {
+		var chunk []string
+		for _, idx := range missing[from:min(from+chunkSize, len(missing))] {
+			chunk = append(chunk, addresses[idx])
+		}
+
+		slots <- struct{}{}
+		wg.Add(1)
+		go func(chunk []string) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			chunkFlows := handler.maxFlowsChunk(ctx, equivalent, chunk)
+			maxFlows.put(equivalent, generation, chunkFlows)
+
+			calculatedLock.Lock()
+			defer calculatedLock.Unlock()
+			for _, flow := range chunkFlows {
+				calculated[flow.Address] = flow
+			}
+		}(chunk)
+	}
+	wg.Wait()
+
+	for idx, address := range addresses {
+		if !flows[idx].IsCached {
+			flows[idx] = calculated[address]
+		}
+	}
+	return flows
+}
+
+// Calculates max flows toward the addresses by one command.
+func (handler *NodeHandler) maxFlowsChunk(ctx context.Context, equivalent string, addresses []string) []MaxFlow {
+	failed := func(code int) []MaxFlow {
+		flows := make([]MaxFlow, 0, len(addresses))
+		for _, address := range addresses {
+			flows = append(flows, MaxFlow{Address: address, Code: code})
+		}
+		return flows
+	}
+
+	tokens := []string{"GET:contractors/transactions/max/fully", strconv.Itoa(len(addresses))}
+	for _, contractorAddress := range addresses {
+		addressType, address, _ := strings.Cut(contractorAddress, "-")
+		tokens = append(tokens, addressType, address)
+	}
+	tokens = append(tokens, equivalent)
+	command := NewCommand(tokens...)
+
+	err := handler.Node.SendCommand(ctx, command)
+	if err != nil {
+		logger.Error("Can't send command: " + string(command.ToBytes()) + " to node. Details: " + err.Error())
+		return failed(COMMAND_TRANSFERRING_ERROR)
+	}
+
+	result, err := handler.Node.GetResult(command, common.MAX_FLOW_FULLY_TIMEOUT)
+	if err != nil {
+		logger.Error("Node is inaccessible during processing command: " +
+			string(command.ToBytes()) + ". Details: " + err.Error())
+		return failed(NODE_IS_INACCESSIBLE)
+	}
+	calculatedAt := time.Now()
+
+	if result.Code != OK {
+		logger.Error("Node return wrong command result: " + strconv.Itoa(result.Code) +
+			" on command: " + string(command.ToBytes()))
+		return failed(result.Code)
+	}
+	if len(result.Tokens) == 0 {
+		logger.Error("Node return invalid result tokens size on command: " + string(command.ToBytes()))
+		return failed(ENGINE_UNEXPECTED_ERROR)
+	}
+	contractorsCount, err := strconv.Atoi(result.Tokens[0])
+	if err != nil || len(result.Tokens) < contractorsCount*3+1 {
+		logger.Error("Node return invalid token `count` on command: " + string(command.ToBytes()))
+		return failed(ENGINE_UNEXPECTED_ERROR)
+	}
+
+	amounts := make(map[string]string)
+	for i := range contractorsCount {
+		amounts[result.Tokens[i*3+1]+"-"+result.Tokens[i*3+2]] = result.Tokens[i*3+3]
+	}
+	flows := make([]MaxFlow, 0, len(addresses))
+	for _, address := range addresses {
+		amount, isPresent := amounts[address]
+		if !isPresent {
+			logger.Error("Node return no max flow for the address " + address + " on command: " + string(command.ToBytes()))
+			flows = append(flows, MaxFlow{Address: address, Code: ENGINE_UNEXPECTED_ERROR})
+			continue
+		}
+		flows = append(flows, MaxFlow{Address: address, Code: OK, MaxAmount: amount, CalculatedAt: calculatedAt})
+	}
+	return flows
+}
@@ -2,16 +2,22 @@ package handler
 
 import (
 	"bufio"
+	"context"
 	"errors"
 	"io"
 	"os"
 	"os/exec"
 	"path"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/audit"
 	"github.com/vTCP-Foundation/vtcpd-cli/internal/conf"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/events"
 	"github.com/vTCP-Foundation/vtcpd-cli/internal/logger"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/tracing"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // This internal type is used for controlling internal node's goroutines behaviour.
@@ -28,6 +34,9 @@ type Node struct {
 	// Each result is mapped to it's command by the UUID.
 	// Map contains channels, from which http requests handlers should be waiting for the results.
 	results map[uuid.UUID]chan *Result
+	// Commands are sent concurrently by the http requests handlers and the background jobs,
+	// so the access to the results map must be synchronized.
+	resultsLock sync.Mutex
 
 	commandsGoroutineControlChannel chan *goroutineControlEvent
 	resultsGoroutineControlChannel  chan *goroutineControlEvent
@@ -182,14 +191,22 @@ func (node *Node) beginTransferCommands(
 		select {
 		case command := <-node.commands:
 			{
+				_, span := tracing.StartCommandSpan(
+					command.context(), "Node.WriteFIFO", command.UUID.String(), command.Name())
+
 				_, err := writer.Write(command.ToBytes())
 				if err != nil {
 					node.logError("Can't transfer command to the node, command details: " + string(command.ToBytes()))
-					node.results[command.UUID] <- &Result{Error: err}
+					tracing.RecordError(span, err)
+					channel, isPresent := node.resultChannel(command.UUID)
+					if isPresent {
+						channel <- &Result{Error: err}
+					}
 
 				} else {
 					writer.Flush()
 				}
+				span.End()
 			}
 
 		case event := <-controlEvents:
@@ -272,12 +289,7 @@ func (node *Node) beginReceiveResults(
 		// Results received well.
 		node.logDebug("Received result: " + string(line))
 
-		// Access to the map is safe:
-		// there is only read access used here.
-		// (the channel for the result is already present)
-		//
-		// There is no need for the mutex here.
-		channel, isPresent := node.results[result.UUID]
+		channel, isPresent := node.resultChannel(result.UUID)
 		if isPresent {
 			// Transferring result for further processing.
 			channel <- result
@@ -339,6 +351,7 @@ func (node *Node) BeginMonitorInternalProcessCrashes(
 				commandsGoroutineControlEvents, resultsGoroutineControlEvents, err = node.StartCommunication()
 				if err == nil {
 					node.logInfo("Restarted")
+					events.Publish(events.EVENT_NODE_RESTARTED, nil)
 				} else {
 					node.logError("Can't restart node communication")
 				}
@@ -352,10 +365,17 @@ func (node *Node) BeginMonitorInternalProcessCrashes(
 }
 
 // Sends command to the engine.
-func (node *Node) SendCommand(command *Command) error {
+// Context is used for the tracing of the command processing.
+func (node *Node) SendCommand(ctx context.Context, command *Command) error {
+	command.ctx = ctx
+	trace.SpanFromContext(ctx).SetAttributes(tracing.CommandUUIDKey.String(command.UUID.String()))
+
+	_, span := tracing.StartCommandSpan(ctx, "Node.SendCommand", command.UUID.String(), command.Name())
+	defer span.End()
+
 	// WARN: order is significant.
 	// Channel for the result must be created before sending command to the execution.
-	node.results[command.UUID] = make(chan *Result, 1)
+	node.createResultChannel(command.UUID)
 
 	node.logInfo("Command sent: " + string(command.ToBytes()))
 
@@ -363,7 +383,10 @@ func (node *Node) SendCommand(command *Command) error {
 	case node.commands <- command:
 		return nil
 	case <-time.After(time.Second * 10):
-		return errors.New("can't add command to node commands channel")
+		err := errors.New("can't add command to node commands channel")
+		tracing.RecordError(span, err)
+		node.auditCommand(command, COMMAND_TRANSFERRING_ERROR)
+		return err
 	}
 
 }
@@ -372,37 +395,76 @@ func (node *Node) SendCommand(command *Command) error {
 func (node *Node) WaitCommand(command *Command) {
 	// WARN: order is significant.
 	// Channel for the result must be created before sending command to the execution.
-	node.results[command.UUID] = make(chan *Result, 1)
+	node.createResultChannel(command.UUID)
 
 	node.logInfo("Command wait: " + string(command.ToBytes()))
 }
 
 func (node *Node) GetResult(command *Command, timeoutSeconds uint16) (*Result, error) {
-	channel, isPresent := node.results[command.UUID]
+	_, span := tracing.StartCommandSpan(
+		command.context(), "Node.GetResult", command.UUID.String(), command.Name())
+	defer span.End()
+
+	channel, isPresent := node.resultChannel(command.UUID)
 	if !isPresent {
-		return nil, errors.New("no results channel is present for this UUID")
+		err := errors.New("no results channel is present for this UUID")
+		tracing.RecordError(span, err)
+		return nil, err
 	}
 
 	select {
 	case result := <-channel:
-		// This method would be called from the http requests handler,
-		// each running it's own goroutine.
-		// There is a write access here, so the mutex must be used.
-		delete(node.results, command.UUID)
+		node.removeResultChannel(command.UUID)
 
+		span.SetAttributes(tracing.ResultCodeKey.Int(result.Code))
+		node.auditCommand(command, result.Code)
+		invalidateMaxFlowsOnCommand(command)
 		return result, nil
 
 	case <-time.After(time.Second * time.Duration(timeoutSeconds)):
-		// This method would be called from the http requests handler,
-		// each running it's own goroutine.
-		// There is a write access here, so the mutex must be used.
-		delete(node.results, command.UUID)
+		node.removeResultChannel(command.UUID)
 
-		return nil, errors.New("timeout fired up")
+		err := errors.New("timeout fired up")
+		tracing.RecordError(span, err)
+		node.auditCommand(command, NODE_IS_INACCESSIBLE)
+		return nil, err
 	}
 
 }
 
+func (node *Node) createResultChannel(commandUUID uuid.UUID) {
+	node.resultsLock.Lock()
+	defer node.resultsLock.Unlock()
+	node.results[commandUUID] = make(chan *Result, 1)
+}
+
+func (node *Node) resultChannel(commandUUID uuid.UUID) (chan *Result, bool) {
+	node.resultsLock.Lock()
+	defer node.resultsLock.Unlock()
+	channel, isPresent := node.results[commandUUID]
+	return channel, isPresent
+}
+
+func (node *Node) removeResultChannel(commandUUID uuid.UUID) {
+	node.resultsLock.Lock()
+	defer node.resultsLock.Unlock()
+	delete(node.results, commandUUID)
+}
+
+func (node *Node) resultChannelsCount() int {
+	node.resultsLock.Lock()
+	defer node.resultsLock.Unlock()
+	return len(node.results)
+}
+
+// Writes commands, that are changing the state of the node, to the audit log.
+func (node *Node) auditCommand(command *Command, resultCode int) {
+	if !audit.IsMutatingCommand(command.Name()) {
+		return
+	}
+	audit.Record(command.context(), command.Name(), command.Arguments(), command.UUID.String(), resultCode)
+}
+
 func (node *Node) logError(message string) {
 	logger.Error(node.logHeader() + message)
 }
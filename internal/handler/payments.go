@@ -0,0 +1,312 @@
+package handler
+
+import (
+	"context"
+	"math/big"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/google/uuid"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/common"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/events"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/logger"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/policy"
+)
+
+// Parameters of the payment.
+// Is used by all the payments initiators (HTTP API, background jobs, etc.),
+// so the payments are always sent to the engine in the same way.
+type PaymentOrder struct {
+	// UUID of the engine command. Engine uses it as the transaction identifier for the lookup
+	// by GET:transaction/command-uuid, so the outcome of the payment could be resolved later.
+	CommandUUID uuid.UUID `json:"command_uuid"`
+	// Addresses of the payee in the form "<type>-<address>".
+	ContractorAddresses []string `json:"contractor_addresses"`
+	Amount              string   `json:"amount"`
+	Equivalent          string   `json:"equivalent"`
+	Payload             string   `json:"payload,omitempty"`
+}
+
+// Result of the payment.
+// Code is CREATED if the payment was done, otherwise it is the engine result code
+// or one of the CLI codes (COMMAND_TRANSFERRING_ERROR, NODE_IS_INACCESSIBLE, ENGINE_UNEXPECTED_ERROR,
+// PAYMENT_POLICY_VIOLATION, SERVER_ERROR).
+type PaymentOutcome struct {
+	Code            int               `json:"code"`
+	TransactionUUID string            `json:"transaction_uuid,omitempty"`
+	Violation       *policy.Violation `json:"violation,omitempty"`
+}
+
+func (o PaymentOutcome) IsSucceeded() bool {
+	return o.Code == CREATED
+}
+
+// Returns true if it is not known, whether the payment was done or not
+// (the engine didn't answer in time).
+func (o PaymentOutcome) IsUnknown() bool {
+	return o.Code == NODE_IS_INACCESSIBLE
+}
+
+func (order *PaymentOrder) command() *Command {
+	tokens := []string{"CREATE:contractors/transactions", strconv.Itoa(len(order.ContractorAddresses))}
+	for _, contractorAddress := range order.ContractorAddresses {
+		addressType, address, _ := strings.Cut(contractorAddress, "-")
+		tokens = append(tokens, addressType, address)
+	}
+	tokens = append(tokens, order.Amount, order.Equivalent)
+	if order.Payload != "" {
+		tokens = append(tokens, order.Payload)
+	}
+	return NewCommandWithUUID(order.CommandUUID, tokens...)
+}
+
+// Count of the payments, that are executed by this process right now.
+var paymentsInFlight atomic.Int64
+
+func PaymentsInFlight() int {
+	return int(paymentsInFlight.Load())
+}
+
+// Sends the payment to the engine and waits for it's result.
+// This command may execute relatively slow (up to common.PAYMENT_OPERATION_TIMEOUT).
+func (handler *NodeHandler) ExecutePayment(ctx context.Context, order PaymentOrder) PaymentOutcome {
+	if order.CommandUUID == uuid.Nil {
+		order.CommandUUID = uuid.New()
+	}
+	paymentsInFlight.Add(1)
+	defer paymentsInFlight.Add(-1)
+	outcome := handler.executePayment(ctx, order)
+	publishPaymentEvent(order, outcome)
+	return outcome
+}
+
+// Payments with unknown outcome are not reported, they are resolved later by the initiator.
+func publishPaymentEvent(order PaymentOrder, outcome PaymentOutcome) {
+	if outcome.IsUnknown() {
+		return
+	}
+	eventType := events.EVENT_PAYMENT_FAILED
+	if outcome.IsSucceeded() {
+		eventType = events.EVENT_PAYMENT_COMPLETED
+	}
+	events.Publish(eventType, events.PaymentData{
+		CommandUUID:         order.CommandUUID.String(),
+		TransactionUUID:     outcome.TransactionUUID,
+		ContractorAddresses: order.ContractorAddresses,
+		Amount:              order.Amount,
+		Equivalent:          order.Equivalent,
+		Payload:             order.Payload,
+		Code:                outcome.Code,
+	})
+}
+
+// Payment is sent only if it is allowed by the payment policies.
+func (handler *NodeHandler) executePayment(ctx context.Context, order PaymentOrder) PaymentOutcome {
+	reservation, violation, err := policy.Check(ctx, policy.Payment{
+		CommandUUID:         order.CommandUUID.String(),
+		ContractorAddresses: order.ContractorAddresses,
+		Amount:              order.Amount,
+		Equivalent:          order.Equivalent,
+		Payload:             order.Payload,
+	})
+	if err != nil {
+		logger.Error("Can't check payment policies for the command " + order.CommandUUID.String() + ". Details: " + err.Error())
+		return PaymentOutcome{Code: SERVER_ERROR}
+	}
+	if violation != nil {
+		return PaymentOutcome{Code: PAYMENT_POLICY_VIOLATION, Violation: violation}
+	}
+
+	outcome := handler.sendPayment(ctx, order)
+	// Amount of the payment with unknown outcome is left in the spent amounts.
+	if !outcome.IsSucceeded() && !outcome.IsUnknown() {
+		reservation.Release()
+	}
+	return outcome
+}
+
+func (handler *NodeHandler) sendPayment(ctx context.Context, order PaymentOrder) PaymentOutcome {
+	command := order.command()
+
+	err := handler.Node.SendCommand(ctx, command)
+	if err != nil {
+		logger.Error("Can't send command: " + string(command.ToBytes()) + " to node. Details: " + err.Error())
+		return PaymentOutcome{Code: COMMAND_TRANSFERRING_ERROR}
+	}
+
+	result, err := handler.Node.GetResult(command, common.PAYMENT_OPERATION_TIMEOUT)
+	if err != nil {
+		logger.Error("Node is inaccessible during processing command: " +
+			string(command.ToBytes()) + ". Details: " + err.Error())
+		return PaymentOutcome{Code: NODE_IS_INACCESSIBLE}
+	}
+
+	if result.Code != CREATED && result.Code != ENGINE_NO_EQUIVALENT {
+		logger.Error("Node return wrong command result: " + strconv.Itoa(result.Code) +
+			" on command: " + string(command.ToBytes()))
+		return PaymentOutcome{Code: result.Code}
+	}
+	if result.Code == ENGINE_NO_EQUIVALENT {
+		logger.Info("Node hasn't equivalent for command: " + string(command.ToBytes()))
+		return PaymentOutcome{Code: result.Code}
+	}
+
+	if len(result.Tokens) == 0 {
+		logger.Error("Node return invalid result tokens size on command: " + string(command.ToBytes()))
+		return PaymentOutcome{Code: ENGINE_UNEXPECTED_ERROR}
+	}
+
+	return PaymentOutcome{Code: CREATED, TransactionUUID: result.Tokens[0]}
+}
+
+// Result of the payment pre-flight check.
+// Code is OK if the check was done, otherwise it is the engine result code or one of the CLI codes.
+type PaymentQuote struct {
+	Code int `json:"code"`
+	// Maximal amount, that could be paid to the payee right now.
+	MaxAmount  string            `json:"max_amount"`
+	IsFeasible bool              `json:"is_feasible"`
+	Violation  *policy.Violation `json:"violation,omitempty"`
+}
+
+func (q PaymentQuote) Response(order PaymentOrder) common.PaymentQuoteResponse {
+	response := common.PaymentQuoteResponse{
+		Feasible:   q.IsFeasible,
+		Amount:     order.Amount,
+		Equivalent: order.Equivalent,
+		MaxAmount:  q.MaxAmount,
+	}
+	if q.Violation != nil {
+		response.PolicyViolation = q.Violation.Code
+		response.PolicyViolationMessage = q.Violation.Message
+	}
+	return response
+}
+
+// Checks, whether the payment could be done: it must be allowed by the payment policies
+// and the max flow toward the payee (GET:contractors/transactions/max/fully) must cover the amount.
+// Nothing is reserved, so the payment could still fail if the node state changes before it is sent.
+// This command may execute relatively slow (up to common.MAX_FLOW_FULLY_TIMEOUT).
+func (handler *NodeHandler) QuotePayment(ctx context.Context, order PaymentOrder) PaymentQuote {
+	violation, err := policy.Evaluate(ctx, policy.Payment{
+		CommandUUID:         order.CommandUUID.String(),
+		ContractorAddresses: order.ContractorAddresses,
+		Amount:              order.Amount,
+		Equivalent:          order.Equivalent,
+		Payload:             order.Payload,
+	})
+	if err != nil {
+		logger.Error("Can't check payment policies for the quote. Details: " + err.Error())
+		return PaymentQuote{Code: SERVER_ERROR}
+	}
+
+	code, maxAmount := handler.maxFlowToPayee(ctx, order)
+	if code != OK {
+		return PaymentQuote{Code: code, Violation: violation}
+	}
+
+	quote := PaymentQuote{Code: OK, MaxAmount: maxAmount.String(), Violation: violation}
+	amount, _ := new(big.Int).SetString(order.Amount, 10)
+	quote.IsFeasible = violation == nil && amount != nil && amount.Cmp(maxAmount) <= 0
+	return quote
+}
+
+// Returns max flow toward the payee with the addresses of the order.
+func (handler *NodeHandler) maxFlowToPayee(ctx context.Context, order PaymentOrder) (int, *big.Int) {
+	tokens := []string{"GET:contractors/transactions/max/fully", strconv.Itoa(len(order.ContractorAddresses))}
+	for _, contractorAddress := range order.ContractorAddresses {
+		addressType, address, _ := strings.Cut(contractorAddress, "-")
+		tokens = append(tokens, addressType, address)
+	}
+	tokens = append(tokens, order.Equivalent)
+	command := NewCommand(tokens...)
+
+	err := handler.Node.SendCommand(ctx, command)
+	if err != nil {
+		logger.Error("Can't send command: " + string(command.ToBytes()) + " to node. Details: " + err.Error())
+		return COMMAND_TRANSFERRING_ERROR, nil
+	}
+
+	result, err := handler.Node.GetResult(command, common.MAX_FLOW_FULLY_TIMEOUT)
+	if err != nil {
+		logger.Error("Node is inaccessible during processing command: " +
+			string(command.ToBytes()) + ". Details: " + err.Error())
+		return NODE_IS_INACCESSIBLE, nil
+	}
+
+	if result.Code != OK && result.Code != ENGINE_NO_EQUIVALENT {
+		logger.Error("Node return wrong command result: " + strconv.Itoa(result.Code) +
+			" on command: " + string(command.ToBytes()))
+		return result.Code, nil
+	}
+	if result.Code == ENGINE_NO_EQUIVALENT {
+		logger.Info("Node hasn't equivalent for command: " + string(command.ToBytes()))
+		return result.Code, nil
+	}
+
+	if len(result.Tokens) == 0 {
+		logger.Error("Node return invalid result tokens size on command: " + string(command.ToBytes()))
+		return ENGINE_UNEXPECTED_ERROR, nil
+	}
+	contractorsCount, err := strconv.Atoi(result.Tokens[0])
+	if err != nil || len(result.Tokens) < contractorsCount*3+1 {
+		logger.Error("Node return invalid token `count` on command: " + string(command.ToBytes()))
+		return ENGINE_UNEXPECTED_ERROR, nil
+	}
+
+	// All the addresses belong to the same payee, so the greatest flow is taken.
+	maxAmount := new(big.Int)
+	for i := range contractorsCount {
+		amount, isParsed := new(big.Int).SetString(result.Tokens[i*3+3], 10)
+		if !isParsed {
+			logger.Error("Node return invalid max amount on command: " + string(command.ToBytes()))
+			return ENGINE_UNEXPECTED_ERROR, nil
+		}
+		if amount.Cmp(maxAmount) > 0 {
+			maxAmount = amount
+		}
+	}
+	return OK, maxAmount
+}
+
+// Looks up the transaction, that was created by the command with the UUID.
+// Returns result code of the lookup (OK on success) and the transaction UUID,
+// that is empty if there is no such transaction.
+func (handler *NodeHandler) FindTransactionByCommandUUID(ctx context.Context, commandUUID uuid.UUID) (int, string) {
+	command := NewCommand("GET:transaction/command-uuid", commandUUID.String())
+
+	err := handler.Node.SendCommand(ctx, command)
+	if err != nil {
+		logger.Error("Can't send command: " + string(command.ToBytes()) + ". Details: " + err.Error())
+		return COMMAND_TRANSFERRING_ERROR, ""
+	}
+
+	result, err := handler.Node.GetResult(command, common.COMMAND_UUID_TIMEOUT)
+	if err != nil {
+		logger.Error("Node is inaccessible during processing command: " +
+			string(command.ToBytes()) + ". Details: " + err.Error())
+		return NODE_IS_INACCESSIBLE, ""
+	}
+
+	if result.Code != OK {
+		logger.Error("Node return wrong command result: " + strconv.Itoa(result.Code) +
+			" on command: " + string(command.ToBytes()))
+		return result.Code, ""
+	}
+
+	if len(result.Tokens) == 0 {
+		logger.Error("Node return invalid result tokens size on command: " + string(command.ToBytes()))
+		return ENGINE_UNEXPECTED_ERROR, ""
+	}
+
+	count, err := strconv.Atoi(result.Tokens[0])
+	if err != nil || count > 1 || (count == 1 && len(result.Tokens) < 2) {
+		logger.Error("Node return invalid token `count` on command: " + string(command.ToBytes()))
+		return ENGINE_UNEXPECTED_ERROR, ""
+	}
+	if count == 0 {
+		return OK, ""
+	}
+	return OK, result.Tokens[1]
+}
@@ -0,0 +1,526 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/audit"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/auth"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/common"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/conf"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/logger"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/store"
+)
+
+var (
+	PENDING_PAYMENTS_BUCKET = "pending-payments"
+
+	DEFAULT_PENDING_PAYMENTS_TTL       = time.Hour * 24
+	DEFAULT_PENDING_PAYMENTS_RETENTION = time.Hour * 24 * 7
+
+	// Payment is waiting for the approval.
+	PENDING_PAYMENT_STATE_PENDING = "pending"
+	// Payment was approved and is sent to the engine (or it's outcome is not known yet).
+	PENDING_PAYMENT_STATE_APPROVED = "approved"
+	PENDING_PAYMENT_STATE_EXECUTED = "executed"
+	PENDING_PAYMENT_STATE_FAILED   = "failed"
+	PENDING_PAYMENT_STATE_REJECTED = "rejected"
+	PENDING_PAYMENT_STATE_EXPIRED  = "expired"
+)
+
+var (
+	ErrPendingPaymentNotFound   = errors.New("pending payment is not found")
+	ErrPendingPaymentExists     = errors.New("pending payment with this UUID already exists")
+	ErrPendingPaymentIsDecided  = errors.New("pending payment is already decided or expired")
+	ErrPendingPaymentSelfDecide = errors.New("pending payment can't be decided by it's requester")
+	ErrPendingPaymentDecider    = errors.New("pending payment could be decided only by the API key with approver scope")
+)
+
+// Payment, that waits for the approval of the second caller.
+// Payment is identified by the UUID of the engine command, that is allocated at the request,
+// so the transaction could be looked up by it after the approval.
+type PendingPayment struct {
+	UUID                string          `json:"uuid"`
+	State               string          `json:"state"`
+	Order               PaymentOrder    `json:"order"`
+	Requester           string          `json:"requester"`
+	RequesterAuthMethod string          `json:"requester_auth_method"`
+	RequesterIP         string          `json:"requester_ip"`
+	CreatedAt           time.Time       `json:"created_at"`
+	ExpiresAt           time.Time       `json:"expires_at"`
+	Decider             string          `json:"decider,omitempty"`
+	DecidedAt           time.Time       `json:"decided_at"`
+	Outcome             *PaymentOutcome `json:"outcome,omitempty"`
+}
+
+func (p *PendingPayment) Response() common.PendingPaymentResponse {
+	response := common.PendingPaymentResponse{
+		UUID:                p.UUID,
+		State:               p.State,
+		ContractorAddresses: p.Order.ContractorAddresses,
+		Amount:              p.Order.Amount,
+		Equivalent:          p.Order.Equivalent,
+		Payload:             p.Order.Payload,
+		RequestedBy:         p.Requester,
+		CreatedAt:           p.CreatedAt.UTC().Format(time.RFC3339),
+		ExpiresAt:           p.ExpiresAt.UTC().Format(time.RFC3339),
+		DecidedBy:           p.Decider,
+	}
+	if !p.DecidedAt.IsZero() {
+		response.DecidedAt = p.DecidedAt.UTC().Format(time.RFC3339)
+	}
+	if p.Outcome != nil {
+		response.TransactionUUID = p.Outcome.TransactionUUID
+		response.Code = p.Outcome.Code
+		if p.Outcome.Violation != nil {
+			response.PolicyViolation = p.Outcome.Violation.Code
+		}
+	}
+	return response
+}
+
+func (p *PendingPayment) isCompleted() bool {
+	return p.State != PENDING_PAYMENT_STATE_PENDING && p.State != PENDING_PAYMENT_STATE_APPROVED
+}
+
+// Marks the payment as expired, if it's approval time is over.
+// Returns true if the state was changed.
+func (p *PendingPayment) expireIfNeeded(now time.Time) bool {
+	if p.State != PENDING_PAYMENT_STATE_PENDING || now.Before(p.ExpiresAt) {
+		return false
+	}
+	p.State = PENDING_PAYMENT_STATE_EXPIRED
+	p.DecidedAt = p.ExpiresAt
+	return true
+}
+
+func (p *PendingPayment) complete(outcome PaymentOutcome) {
+	p.Outcome = &outcome
+	if outcome.IsSucceeded() {
+		p.State = PENDING_PAYMENT_STATE_EXECUTED
+	} else {
+		p.State = PENDING_PAYMENT_STATE_FAILED
+	}
+}
+
+func (p *PendingPayment) auditArguments() []string {
+	arguments := []string{p.Order.Equivalent, p.Order.Amount}
+	return append(arguments, p.Order.ContractorAddresses...)
+}
+
+// Approvals, which payments are executed by this process right now.
+// Approved payments, that are absent here, were interrupted by the restart of the process.
+var (
+	runningApprovalsLock sync.Mutex
+	runningApprovals     = make(map[string]bool)
+)
+
+func setApprovalRunning(paymentUUID string, isRunning bool) {
+	runningApprovalsLock.Lock()
+	defer runningApprovalsLock.Unlock()
+	if isRunning {
+		runningApprovals[paymentUUID] = true
+	} else {
+		delete(runningApprovals, paymentUUID)
+	}
+}
+
+func isApprovalRunning(paymentUUID string) bool {
+	runningApprovalsLock.Lock()
+	defer runningApprovalsLock.Unlock()
+	return runningApprovals[paymentUUID]
+}
+
+// Returns true if the payment must be approved before it is sent to the engine.
+func RequiresApproval(order PaymentOrder) bool {
+	threshold, isPresent := conf.Params.Payments.Approvals.Thresholds[order.Equivalent]
+	if !isPresent {
+		return false
+	}
+	thresholdAmount, isParsed := new(big.Int).SetString(threshold, 10)
+	amount, isAmountParsed := new(big.Int).SetString(order.Amount, 10)
+	if !isParsed || !isAmountParsed {
+		// Invalid settings must not let the payments through.
+		logger.Error("Invalid approval threshold " + threshold + " of the equivalent " + order.Equivalent)
+		return true
+	}
+	return amount.Cmp(thresholdAmount) > 0
+}
+
+// Checks approvals settings.
+// Payments are decided only by the API keys (or client certificates) with approver scope,
+// so the thresholds without such keys would leave the payments pending forever.
+func ValidateApprovalsSettings() error {
+	thresholds := conf.Params.Payments.Approvals.Thresholds
+	for equivalent, threshold := range thresholds {
+		if !common.ValidateSettlementLineAmount(threshold) {
+			return errors.New("invalid approval threshold " + threshold + " of the equivalent " + equivalent)
+		}
+	}
+	if len(thresholds) == 0 {
+		return nil
+	}
+
+	security := conf.Params.Security
+	if security.ApiKey == "" && len(security.ApiKeys) == 0 {
+		return errors.New("approval thresholds require API keys, anonymous callers can't decide payments")
+	}
+	if security.ApiKey != "" {
+		// Legacy key is granted admin scope.
+		return nil
+	}
+	for _, key := range security.ApiKeys {
+		if isApproverScopes(key.Scopes) {
+			return nil
+		}
+	}
+	for _, client := range security.ClientCertificates {
+		if isApproverScopes(client.Scopes) {
+			return nil
+		}
+	}
+	return errors.New("approval thresholds require API key or client certificate with approver scope")
+}
+
+func isApproverScopes(scopes []string) bool {
+	return (&auth.Caller{Scopes: scopes}).HasScope(auth.SCOPE_APPROVER)
+}
+
+// Returns true if the caller could decide pending payments.
+// Only the authenticated callers of the HTTP API are allowed,
+// so neither the CLI user nor the anonymous caller (when there are no keys in the settings) could approve the payment.
+func canDecidePendingPayments(caller *auth.Caller) bool {
+	switch caller.AuthMethod {
+	case auth.AUTH_METHOD_API_KEY, auth.AUTH_METHOD_SIGNATURE, auth.AUTH_METHOD_CERTIFICATE:
+		return caller.HasScope(auth.SCOPE_APPROVER)
+	}
+	return false
+}
+
+func callerOf(ctx context.Context) *auth.Caller {
+	caller := auth.CallerFromContext(ctx)
+	if caller == nil {
+		caller = auth.LocalCaller()
+	}
+	return caller
+}
+
+func openPendingPayments() (*store.Bucket, error) {
+	return store.OpenBucket(PENDING_PAYMENTS_BUCKET)
+}
+
+// Persists the payment, that waits for the approval.
+// If the payment with the same UUID is already present and it was requested by the same caller
+// with the same parameters, the present one is returned and the flag is set.
+func CreatePendingPayment(ctx context.Context, order PaymentOrder) (*PendingPayment, bool, error) {
+	caller := callerOf(ctx)
+	if order.CommandUUID == uuid.Nil {
+		order.CommandUUID = uuid.New()
+	}
+
+	bucket, err := openPendingPayments()
+	if err != nil {
+		return nil, false, err
+	}
+	unlock, err := bucket.Lock()
+	if err != nil {
+		return nil, false, err
+	}
+	defer unlock()
+
+	present := &PendingPayment{}
+	isPresent, err := bucket.Get(order.CommandUUID.String(), present)
+	if err != nil {
+		return nil, false, err
+	}
+	if isPresent {
+		if present.Requester != caller.Name || !isSameOrder(present.Order, order) {
+			return nil, false, ErrPendingPaymentExists
+		}
+		return present, true, nil
+	}
+
+	ttl := conf.Params.Payments.Approvals.TTL
+	if ttl <= 0 {
+		ttl = DEFAULT_PENDING_PAYMENTS_TTL
+	}
+	now := time.Now()
+	payment := &PendingPayment{
+		UUID:                order.CommandUUID.String(),
+		State:               PENDING_PAYMENT_STATE_PENDING,
+		Order:               order,
+		Requester:           caller.Name,
+		RequesterAuthMethod: caller.AuthMethod,
+		RequesterIP:         caller.SourceIP,
+		CreatedAt:           now,
+		ExpiresAt:           now.Add(ttl),
+	}
+	err = bucket.Put(payment.UUID, payment)
+	if err != nil {
+		return nil, false, err
+	}
+	audit.Record(ctx, audit.OPERATION_APPROVAL_REQUEST, payment.auditArguments(), payment.UUID, ACCEPTED)
+	return payment, false, nil
+}
+
+func isSameOrder(first, second PaymentOrder) bool {
+	if first.Amount != second.Amount || first.Equivalent != second.Equivalent || first.Payload != second.Payload ||
+		len(first.ContractorAddresses) != len(second.ContractorAddresses) {
+		return false
+	}
+	for i := range first.ContractorAddresses {
+		if first.ContractorAddresses[i] != second.ContractorAddresses[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns pending payments (from the newest ones), optionally filtered by the state.
+// Expired payments are marked, outdated ones are removed.
+func PendingPayments(state string) ([]*PendingPayment, error) {
+	bucket, err := openPendingPayments()
+	if err != nil {
+		return nil, err
+	}
+	unlock, err := bucket.Lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	keys, err := bucket.Keys()
+	if err != nil {
+		return nil, err
+	}
+
+	retention := conf.Params.Payments.Approvals.Retention
+	if retention <= 0 {
+		retention = DEFAULT_PENDING_PAYMENTS_RETENTION
+	}
+	now := time.Now()
+	var payments []*PendingPayment
+	for _, key := range keys {
+		payment := &PendingPayment{}
+		isPresent, err := bucket.Get(key, payment)
+		if err != nil {
+			logger.Error("Can't read pending payment " + key + ". Details: " + err.Error())
+			continue
+		}
+		if !isPresent {
+			continue
+		}
+		if payment.expireIfNeeded(now) {
+			err = bucket.Put(key, payment)
+			if err != nil {
+				logger.Error("Can't write pending payment " + key + ". Details: " + err.Error())
+			}
+		}
+		if payment.isCompleted() && now.Sub(payment.DecidedAt) > retention {
+			err = bucket.Delete(key)
+			if err != nil {
+				logger.Error("Can't remove pending payment " + key + ". Details: " + err.Error())
+			}
+			continue
+		}
+		if state != "" && payment.State != state {
+			continue
+		}
+		payments = append(payments, payment)
+	}
+
+	sort.Slice(payments, func(i, j int) bool {
+		return payments[i].CreatedAt.After(payments[j].CreatedAt)
+	})
+	return payments, nil
+}
+
+// Returns the pending payment.
+// Outcome of the approved payment, which execution was interrupted, is looked up in the engine.
+func (handler *NodeHandler) PendingPayment(ctx context.Context, paymentUUID string) (*PendingPayment, error) {
+	bucket, err := openPendingPayments()
+	if err != nil {
+		return nil, err
+	}
+	payment := &PendingPayment{}
+	isPresent, err := bucket.Get(paymentUUID, payment)
+	if err != nil {
+		return nil, err
+	}
+	if !isPresent {
+		return nil, ErrPendingPaymentNotFound
+	}
+
+	if payment.expireIfNeeded(time.Now()) {
+		return payment, handler.updatePendingPayment(bucket, payment, PENDING_PAYMENT_STATE_PENDING)
+	}
+	if payment.State != PENDING_PAYMENT_STATE_APPROVED || isApprovalRunning(payment.UUID) {
+		return payment, nil
+	}
+
+	code, transactionUUID := handler.FindTransactionByCommandUUID(ctx, payment.Order.CommandUUID)
+	if code != OK {
+		return payment, nil
+	}
+	if transactionUUID != "" {
+		payment.complete(PaymentOutcome{Code: CREATED, TransactionUUID: transactionUUID})
+	} else if time.Since(payment.DecidedAt) > time.Second*time.Duration(2*common.PAYMENT_OPERATION_TIMEOUT) {
+		// Engine has surely finished the payment, so it was not done.
+		payment.complete(PaymentOutcome{Code: NODE_IS_INACCESSIBLE})
+	} else {
+		return payment, nil
+	}
+	return payment, handler.updatePendingPayment(bucket, payment, PENDING_PAYMENT_STATE_APPROVED)
+}
+
+// Writes the payment, if it's stored state is still the expected one.
+func (handler *NodeHandler) updatePendingPayment(bucket *store.Bucket, payment *PendingPayment, expectedState string) error {
+	unlock, err := bucket.Lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	stored := &PendingPayment{}
+	isPresent, err := bucket.Get(payment.UUID, stored)
+	if err != nil {
+		return err
+	}
+	if !isPresent || stored.State != expectedState {
+		*payment = *stored
+		return nil
+	}
+	return bucket.Put(payment.UUID, payment)
+}
+
+// Moves pending payment to the decided state on behalf of the caller from the context.
+func decidePendingPayment(ctx context.Context, paymentUUID, state string) (*PendingPayment, error) {
+	caller := auth.CallerFromContext(ctx)
+	if caller == nil || !canDecidePendingPayments(caller) {
+		return nil, ErrPendingPaymentDecider
+	}
+
+	bucket, err := openPendingPayments()
+	if err != nil {
+		return nil, err
+	}
+	unlock, err := bucket.Lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	payment := &PendingPayment{}
+	isPresent, err := bucket.Get(paymentUUID, payment)
+	if err != nil {
+		return nil, err
+	}
+	if !isPresent {
+		return nil, ErrPendingPaymentNotFound
+	}
+	now := time.Now()
+	if payment.expireIfNeeded(now) {
+		err = bucket.Put(payment.UUID, payment)
+		if err != nil {
+			return nil, err
+		}
+		return payment, ErrPendingPaymentIsDecided
+	}
+	if payment.State != PENDING_PAYMENT_STATE_PENDING {
+		return payment, ErrPendingPaymentIsDecided
+	}
+	if payment.Requester == caller.Name {
+		return payment, ErrPendingPaymentSelfDecide
+	}
+
+	payment.State = state
+	payment.Decider = caller.Name
+	payment.DecidedAt = now
+	// Approved payment must be persisted before it is sent to the engine,
+	// so it could not be approved twice.
+	err = bucket.Put(payment.UUID, payment)
+	if err != nil {
+		return nil, err
+	}
+	return payment, nil
+}
+
+// Approves the pending payment and executes it.
+// Payment is executed on behalf of it's requester, so the requester's payment policies are applied.
+func (handler *NodeHandler) ApprovePendingPayment(ctx context.Context, paymentUUID string) (*PendingPayment, error) {
+	setApprovalRunning(paymentUUID, true)
+	defer setApprovalRunning(paymentUUID, false)
+
+	payment, err := decidePendingPayment(ctx, paymentUUID, PENDING_PAYMENT_STATE_APPROVED)
+	if err != nil {
+		return payment, err
+	}
+	audit.Record(ctx, audit.OPERATION_APPROVAL_APPROVE, payment.auditArguments(), payment.UUID, OK)
+
+	// Payment must not be interrupted if the approver disconnects.
+	requesterCtx := auth.WithCaller(context.WithoutCancel(ctx), &auth.Caller{
+		Name:       payment.Requester,
+		AuthMethod: payment.RequesterAuthMethod,
+		SourceIP:   payment.RequesterIP,
+	})
+	outcome := handler.ExecutePayment(requesterCtx, payment.Order)
+	if outcome.IsUnknown() {
+		// Payment stays approved, it's outcome would be resolved on the next request.
+		return payment, nil
+	}
+
+	payment.complete(outcome)
+	bucket, err := openPendingPayments()
+	if err == nil {
+		err = handler.updatePendingPayment(bucket, payment, PENDING_PAYMENT_STATE_APPROVED)
+	}
+	if err != nil {
+		logger.Error("Can't write pending payment " + payment.UUID + ". Details: " + err.Error())
+	}
+	return payment, nil
+}
+
+// Rejects the pending payment, it would not be sent to the engine.
+func RejectPendingPayment(ctx context.Context, paymentUUID string) (*PendingPayment, error) {
+	payment, err := decidePendingPayment(ctx, paymentUUID, PENDING_PAYMENT_STATE_REJECTED)
+	if err != nil {
+		return payment, err
+	}
+	audit.Record(ctx, audit.OPERATION_APPROVAL_REJECT, payment.auditArguments(), payment.UUID, OK)
+	return payment, nil
+}
+
+// Payments are decided only through the HTTP API (see canDecidePendingPayments).
+func (handler *NodeHandler) PendingPaymentsCommand() {
+	if CommandType == "list" {
+		handler.listPendingPayments()
+
+	} else {
+		logger.Error("Invalid pending-payments command " + CommandType)
+		fmt.Println("Invalid pending-payments command")
+		return
+	}
+}
+
+func (handler *NodeHandler) listPendingPayments() {
+	payments, err := PendingPayments("")
+	if err != nil {
+		logger.Error("Can't read pending payments. Details: " + err.Error())
+		resultJSON := buildJSONResponse(SERVER_ERROR, common.PendingPaymentsResponse{})
+		fmt.Println(string(resultJSON))
+		return
+	}
+
+	response := common.PendingPaymentsResponse{Count: len(payments), Payments: []common.PendingPaymentResponse{}}
+	for _, payment := range payments {
+		response.Payments = append(response.Payments, payment.Response())
+	}
+	resultJSON := buildJSONResponse(OK, response)
+	fmt.Println(string(resultJSON))
+}
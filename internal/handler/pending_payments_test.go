@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/auth"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/conf"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/conf/conftest"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/store/storetest"
+)
+
+func TestValidateApprovalsSettings(t *testing.T) {
+	thresholds := map[string]string{"1": "1000"}
+	cases := []struct {
+		name        string
+		thresholds  map[string]string
+		security    conf.SecuritySettings
+		expectedErr bool
+	}{
+		{"no thresholds and no keys", nil, conf.SecuritySettings{}, false},
+		{"invalid threshold", map[string]string{"1": "-1"}, conf.SecuritySettings{ApiKey: "key"}, true},
+		{"no keys", thresholds, conf.SecuritySettings{}, true},
+		{"only client certificates", thresholds, conf.SecuritySettings{
+			ClientCertificates: []conf.ClientCertificateSettings{{Subject: "approver", Scopes: []string{auth.SCOPE_APPROVER}}},
+		}, true},
+		{"legacy key", thresholds, conf.SecuritySettings{ApiKey: "key"}, false},
+		{"keys without approver scope", thresholds, conf.SecuritySettings{
+			ApiKeys: []conf.ApiKeySettings{{Name: "billing", Scopes: []string{auth.SCOPE_PAYMENTS_CREATE}}},
+		}, true},
+		{"key with approver scope", thresholds, conf.SecuritySettings{
+			ApiKeys: []conf.ApiKeySettings{
+				{Name: "billing", Scopes: []string{auth.SCOPE_PAYMENTS_CREATE}},
+				{Name: "treasurer", Scopes: []string{auth.SCOPE_APPROVER}},
+			},
+		}, false},
+		{"key with admin scope", thresholds, conf.SecuritySettings{
+			ApiKeys: []conf.ApiKeySettings{{Name: "admin", Scopes: []string{auth.SCOPE_ADMIN}}},
+		}, false},
+		{"client certificate with approver scope", thresholds, conf.SecuritySettings{
+			ApiKeys:            []conf.ApiKeySettings{{Name: "billing", Scopes: []string{auth.SCOPE_PAYMENTS_CREATE}}},
+			ClientCertificates: []conf.ClientCertificateSettings{{Subject: "approver", Scopes: []string{auth.SCOPE_APPROVER}}},
+		}, false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			conftest.Set(t, &conf.Params.Payments.Approvals.Thresholds, c.thresholds)
+			conftest.Set(t, &conf.Params.Security, c.security)
+			err := ValidateApprovalsSettings()
+			if (err != nil) != c.expectedErr {
+				t.Fatalf("unexpected error %v", err)
+			}
+		})
+	}
+}
+
+func TestDecidePendingPaymentCaller(t *testing.T) {
+	storetest.UseTempDir(t)
+	conftest.Set(t, &conf.Params.Audit.FilePath, filepath.Join(t.TempDir(), "audit.log"))
+
+	requester := &auth.Caller{Name: "billing", AuthMethod: auth.AUTH_METHOD_API_KEY, Scopes: []string{auth.SCOPE_APPROVER}}
+	payment, _, err := CreatePendingPayment(auth.WithCaller(context.Background(), requester), PaymentOrder{
+		CommandUUID:         uuid.New(),
+		ContractorAddresses: []string{"12-127.0.0.1:2000"},
+		Amount:              "2000",
+		Equivalent:          "1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name        string
+		caller      *auth.Caller
+		expectedErr error
+	}{
+		{"no caller", nil, ErrPendingPaymentDecider},
+		{"CLI user", auth.LocalCaller(), ErrPendingPaymentDecider},
+		{"anonymous caller", &auth.Caller{Name: "anonymous", AuthMethod: auth.AUTH_METHOD_NONE, Scopes: []string{auth.SCOPE_ADMIN}}, ErrPendingPaymentDecider},
+		{"scheduler", &auth.Caller{Name: "treasurer", AuthMethod: auth.AUTH_METHOD_SCHEDULER, Scopes: []string{auth.SCOPE_ADMIN}}, ErrPendingPaymentDecider},
+		{"key without approver scope", &auth.Caller{Name: "dashboard", AuthMethod: auth.AUTH_METHOD_API_KEY, Scopes: []string{auth.SCOPE_READ}}, ErrPendingPaymentDecider},
+		{"requester", requester, ErrPendingPaymentSelfDecide},
+		{"approver", &auth.Caller{Name: "treasurer", AuthMethod: auth.AUTH_METHOD_SIGNATURE, Scopes: []string{auth.SCOPE_APPROVER}}, nil},
+		{"decided payment", &auth.Caller{Name: "cert:approver", AuthMethod: auth.AUTH_METHOD_CERTIFICATE, Scopes: []string{auth.SCOPE_APPROVER}}, ErrPendingPaymentIsDecided},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ctx := context.Background()
+			if c.caller != nil {
+				ctx = auth.WithCaller(ctx, c.caller)
+			}
+			decided, err := RejectPendingPayment(ctx, payment.UUID)
+			if err != c.expectedErr {
+				t.Fatalf("expected %v, got %v", c.expectedErr, err)
+			}
+			if err == nil && (decided.State != PENDING_PAYMENT_STATE_REJECTED || decided.Decider != c.caller.Name) {
+				t.Fatalf("unexpected decision %+v", decided)
+			}
+		})
+	}
+}
@@ -0,0 +1,368 @@
+package handler
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/common"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/logger"
+)
+
+// Engine queries, that are used by the background services of the HTTP server
+// (events watcher, exports, reports, etc.).
+// Each query returns result code (OK on success) and parsed result.
+
+// Optional filters of the payments history. Empty values are not applied.
+type PaymentsHistoryFilter struct {
+	DateFrom      string
+	DateTo        string
+	AmountFrom    string
+	AmountTo      string
+	CommandUUID   string
+	OperationUUID string
+}
+
+func nullIfEmpty(value string) string {
+	if value == "" {
+		return "null"
+	}
+	return value
+}
+
+// Returns one page of the payments history of the equivalent (GET:history/payments).
+func (handler *NodeHandler) PaymentsHistoryPage(
+	ctx context.Context, equivalent string, offset, count int, filter PaymentsHistoryFilter,
+) (int, []common.PaymentHistoryRecord) {
+	command := NewCommand(
+		"GET:history/payments", strconv.Itoa(offset), strconv.Itoa(count),
+		nullIfEmpty(filter.DateFrom), nullIfEmpty(filter.DateTo),
+		nullIfEmpty(filter.AmountFrom), nullIfEmpty(filter.AmountTo),
+		nullIfEmpty(filter.CommandUUID), nullIfEmpty(filter.OperationUUID), equivalent)
+
+	err := handler.Node.SendCommand(ctx, command)
+	if err != nil {
+		logger.Error("Can't send command: " + string(command.ToBytes()) + " to node. Details: " + err.Error())
+		return COMMAND_TRANSFERRING_ERROR, nil
+	}
+
+	result, err := handler.Node.GetResult(command, common.HISTORY_RESULT_TIMEOUT)
+	if err != nil {
+		logger.Error("Node is inaccessible during processing command: " +
+			string(command.ToBytes()) + ". Details: " + err.Error())
+		return NODE_IS_INACCESSIBLE, nil
+	}
+
+	if result.Code != OK {
+		if result.Code == ENGINE_NO_EQUIVALENT {
+			logger.Info("Node hasn't equivalent for command: " + string(command.ToBytes()))
+		} else {
+			logger.Error("Node return wrong command result: " + strconv.Itoa(result.Code) +
+				" on command: " + string(command.ToBytes()))
+		}
+		return result.Code, nil
+	}
+
+	if len(result.Tokens) == 0 {
+		logger.Error("Node return invalid result tokens size on command: " + string(command.ToBytes()))
+		return ENGINE_UNEXPECTED_ERROR, nil
+	}
+
+	recordsCount, err := strconv.Atoi(result.Tokens[0])
+	if err != nil || recordsCount < 0 || len(result.Tokens) < 1+recordsCount*7 {
+		logger.Error("Node return invalid token `count` on command: " + string(command.ToBytes()))
+		return ENGINE_UNEXPECTED_ERROR, nil
+	}
+
+	records := make([]common.PaymentHistoryRecord, 0, recordsCount)
+	for i := range recordsCount {
+		records = append(records, common.PaymentHistoryRecord{
+			TransactionUUID:           result.Tokens[i*7+1],
+			UnixTimestampMicroseconds: result.Tokens[i*7+2],
+			Contractor:                result.Tokens[i*7+3],
+			OperationDirection:        result.Tokens[i*7+4],
+			Amount:                    result.Tokens[i*7+5],
+			BalanceAfterOperation:     result.Tokens[i*7+6],
+			Payload:                   result.Tokens[i*7+7],
+		})
+	}
+	return OK, records
+}
+
+// Returns one page of the settlement lines history of the equivalent (GET:history/trust-lines).
+// Empty dates are not applied.
+func (handler *NodeHandler) SettlementLinesHistoryPage(
+	ctx context.Context, equivalent string, offset, count int, dateFrom, dateTo string,
+) (int, []common.SettlementLineHistoryRecord) {
+	command := NewCommand(
+		"GET:history/trust-lines", strconv.Itoa(offset), strconv.Itoa(count),
+		nullIfEmpty(dateFrom), nullIfEmpty(dateTo), equivalent)
+
+	err := handler.Node.SendCommand(ctx, command)
+	if err != nil {
+		logger.Error("Can't send command: " + string(command.ToBytes()) + " to node. Details: " + err.Error())
+		return COMMAND_TRANSFERRING_ERROR, nil
+	}
+
+	result, err := handler.Node.GetResult(command, common.HISTORY_RESULT_TIMEOUT)
+	if err != nil {
+		logger.Error("Node is inaccessible during processing command: " +
+			string(command.ToBytes()) + ". Details: " + err.Error())
+		return NODE_IS_INACCESSIBLE, nil
+	}
+
+	if result.Code != OK {
+		if result.Code == ENGINE_NO_EQUIVALENT {
+			logger.Info("Node hasn't equivalent for command: " + string(command.ToBytes()))
+		} else {
+			logger.Error("Node return wrong command result: " + strconv.Itoa(result.Code) +
+				" on command: " + string(command.ToBytes()))
+		}
+		return result.Code, nil
+	}
+
+	if len(result.Tokens) == 0 {
+		logger.Error("Node return invalid result tokens size on command: " + string(command.ToBytes()))
+		return ENGINE_UNEXPECTED_ERROR, nil
+	}
+
+	recordsCount, err := strconv.Atoi(result.Tokens[0])
+	if err != nil || recordsCount < 0 || len(result.Tokens) < 1+recordsCount*5 {
+		logger.Error("Node return invalid token `count` on command: " + string(command.ToBytes()))
+		return ENGINE_UNEXPECTED_ERROR, nil
+	}
+
+	records := make([]common.SettlementLineHistoryRecord, 0, recordsCount)
+	for i := range recordsCount {
+		records = append(records, common.SettlementLineHistoryRecord{
+			TransactionUUID:           result.Tokens[i*5+1],
+			UnixTimestampMicroseconds: result.Tokens[i*5+2],
+			Contractor:                result.Tokens[i*5+3],
+			OperationDirection:        result.Tokens[i*5+4],
+			Amount:                    result.Tokens[i*5+5],
+		})
+	}
+	return OK, records
+}
+
+// Returns the settlement line with the contractor (GET:contractors/trust-lines/one/id).
+func (handler *NodeHandler) SettlementLine(
+	ctx context.Context, contractorID, equivalent string) (int, common.SettlementLineDetail) {
+
+	command := NewCommand("GET:contractors/trust-lines/one/id", contractorID, equivalent)
+
+	err := handler.Node.SendCommand(ctx, command)
+	if err != nil {
+		logger.Error("Can't send command: " + string(command.ToBytes()) + " to node. Details: " + err.Error())
+		return COMMAND_TRANSFERRING_ERROR, common.SettlementLineDetail{}
+	}
+
+	result, err := handler.Node.GetResult(command, common.SETTLEMENT_LINE_RESULT_TIMEOUT)
+	if err != nil {
+		logger.Error("Node is inaccessible during processing command: " +
+			string(command.ToBytes()) + ". Details: " + err.Error())
+		return NODE_IS_INACCESSIBLE, common.SettlementLineDetail{}
+	}
+
+	if result.Code != OK {
+		logger.Error("Node return wrong command result: " + strconv.Itoa(result.Code) +
+			" on command: " + string(command.ToBytes()))
+		return result.Code, common.SettlementLineDetail{}
+	}
+
+	if len(result.Tokens) < 8 {
+		logger.Error("Node return invalid result tokens size on command: " + string(command.ToBytes()))
+		return ENGINE_UNEXPECTED_ERROR, common.SettlementLineDetail{}
+	}
+	return OK, common.SettlementLineDetail{
+		ID:                    result.Tokens[0],
+		State:                 result.Tokens[1],
+		OwnKeysPresent:        result.Tokens[2],
+		ContractorKeysPresent: result.Tokens[3],
+		AuditNumber:           result.Tokens[4],
+		MaxNegativeBalance:    result.Tokens[5],
+		MaxPositiveBalance:    result.Tokens[6],
+		Balance:               result.Tokens[7],
+	}
+}
+
+// Returns contractors, the node has channels with (GET:contractors-all).
+func (handler *NodeHandler) Contractors(ctx context.Context) (int, []common.ChannelListItem) {
+	command := NewCommand("GET:contractors-all")
+
+	err := handler.Node.SendCommand(ctx, command)
+	if err != nil {
+		logger.Error("Can't send command: " + string(command.ToBytes()) + " to node. Details: " + err.Error())
+		return COMMAND_TRANSFERRING_ERROR, nil
+	}
+
+	result, err := handler.Node.GetResult(command, common.CHANNEL_RESULT_TIMEOUT)
+	if err != nil {
+		logger.Error("Node is inaccessible during processing command: " +
+			string(command.ToBytes()) + ". Details: " + err.Error())
+		return NODE_IS_INACCESSIBLE, nil
+	}
+
+	if result.Code != OK {
+		logger.Error("Node return wrong command result: " + strconv.Itoa(result.Code) +
+			" on command: " + string(command.ToBytes()))
+		return result.Code, nil
+	}
+
+	if len(result.Tokens) == 0 {
+		logger.Error("Node return invalid result tokens size on command: " + string(command.ToBytes()))
+		return ENGINE_UNEXPECTED_ERROR, nil
+	}
+	channelsCount, err := strconv.Atoi(result.Tokens[0])
+	if err != nil || channelsCount < 0 || len(result.Tokens) < 1+channelsCount*2 {
+		logger.Error("Node return invalid token on command: " + string(command.ToBytes()))
+		return ENGINE_UNEXPECTED_ERROR, nil
+	}
+
+	var contractors []common.ChannelListItem
+	for i := range channelsCount {
+		contractors = append(contractors, common.ChannelListItem{
+			ID:        result.Tokens[i*2+1],
+			Addresses: result.Tokens[i*2+2],
+		})
+	}
+	return OK, contractors
+}
+
+// Returns total balances of the equivalent (GET:stats/balance/total).
+func (handler *NodeHandler) TotalBalance(ctx context.Context, equivalent string) (int, common.TotalBalanceResponse) {
+	command := NewCommand("GET:stats/balance/total", equivalent)
+
+	err := handler.Node.SendCommand(ctx, command)
+	if err != nil {
+		logger.Error("Can't send command: " + string(command.ToBytes()) + " to node. Details: " + err.Error())
+		return COMMAND_TRANSFERRING_ERROR, common.TotalBalanceResponse{}
+	}
+
+	result, err := handler.Node.GetResult(command, common.STATS_RESULT_TIMEOUT)
+	if err != nil {
+		logger.Error("Node is inaccessible during processing command: " +
+			string(command.ToBytes()) + ". Details: " + err.Error())
+		return NODE_IS_INACCESSIBLE, common.TotalBalanceResponse{}
+	}
+
+	if result.Code != OK {
+		logger.Error("Node return wrong command result: " + strconv.Itoa(result.Code) +
+			" on command: " + string(command.ToBytes()))
+		return result.Code, common.TotalBalanceResponse{}
+	}
+
+	if len(result.Tokens) < 4 {
+		logger.Error("Node return invalid result tokens size on command: " + string(command.ToBytes()))
+		return ENGINE_UNEXPECTED_ERROR, common.TotalBalanceResponse{}
+	}
+	return OK, common.TotalBalanceResponse{
+		TotalMaxNegativeBalance: result.Tokens[0],
+		TotalNegativeBalance:    result.Tokens[1],
+		TotalMaxPositiveBalance: result.Tokens[2],
+		TotalPositiveBalance:    result.Tokens[3],
+	}
+}
+
+// Returns equivalents of the node (GET:equivalents).
+func (handler *NodeHandler) Equivalents(ctx context.Context) (int, []string) {
+	command := NewCommand("GET:equivalents")
+
+	err := handler.Node.SendCommand(ctx, command)
+	if err != nil {
+		logger.Error("Can't send command: " + string(command.ToBytes()) + " to node. Details: " + err.Error())
+		return COMMAND_TRANSFERRING_ERROR, nil
+	}
+
+	result, err := handler.Node.GetResult(command, common.SETTLEMENT_LINE_RESULT_TIMEOUT)
+	if err != nil {
+		logger.Error("Node is inaccessible during processing command: " +
+			string(command.ToBytes()) + ". Details: " + err.Error())
+		return NODE_IS_INACCESSIBLE, nil
+	}
+
+	if result.Code != OK {
+		logger.Error("Node return wrong command result: " + strconv.Itoa(result.Code) +
+			" on command: " + string(command.ToBytes()))
+		return result.Code, nil
+	}
+
+	if len(result.Tokens) == 0 {
+		logger.Error("Node return invalid result tokens size on command: " + string(command.ToBytes()))
+		return ENGINE_UNEXPECTED_ERROR, nil
+	}
+
+	equivalentsCount, err := strconv.Atoi(result.Tokens[0])
+	if err != nil || equivalentsCount < 0 || len(result.Tokens) < 1+equivalentsCount {
+		logger.Error("Node return invalid token `count` on command: " + string(command.ToBytes()))
+		return ENGINE_UNEXPECTED_ERROR, nil
+	}
+	return OK, append([]string{}, result.Tokens[1:1+equivalentsCount]...)
+}
+
+// Returns settlement lines of all the equivalents (GET:contractors/trust-lines-all).
+func (handler *NodeHandler) AllSettlementLines(ctx context.Context) (int, []common.EquivalentStatistics) {
+	command := NewCommand(
+		"GET:contractors/trust-lines-all", common.DEFAULT_SETTLEMENT_LINES_OFFSET, common.DFEAULT_SETTLEMENT_LINES_COUNT)
+
+	err := handler.Node.SendCommand(ctx, command)
+	if err != nil {
+		logger.Error("Can't send command: " + string(command.ToBytes()) + " to node. Details: " + err.Error())
+		return COMMAND_TRANSFERRING_ERROR, nil
+	}
+
+	result, err := handler.Node.GetResult(command, common.SETTLEMENT_LINE_RESULT_TIMEOUT)
+	if err != nil {
+		logger.Error("Node is inaccessible during processing command: " +
+			string(command.ToBytes()) + ". Details: " + err.Error())
+		return NODE_IS_INACCESSIBLE, nil
+	}
+
+	if result.Code != OK {
+		logger.Error("Node return wrong command result: " + strconv.Itoa(result.Code) +
+			" on command: " + string(command.ToBytes()))
+		return result.Code, nil
+	}
+
+	if len(result.Tokens) == 0 {
+		logger.Error("Node return invalid result tokens size on command: " + string(command.ToBytes()))
+		return ENGINE_UNEXPECTED_ERROR, nil
+	}
+
+	equivalentsCount, err := strconv.Atoi(result.Tokens[0])
+	if err != nil {
+		logger.Error("Node return invalid token on command: " +
+			string(command.ToBytes()) + ". Details: " + err.Error())
+		return ENGINE_UNEXPECTED_ERROR, nil
+	}
+
+	var equivalents []common.EquivalentStatistics
+	tokenIdx := 1
+	for range equivalentsCount {
+		if len(result.Tokens) < tokenIdx+2 {
+			logger.Error("Node return invalid result tokens size on command: " + string(command.ToBytes()))
+			return ENGINE_UNEXPECTED_ERROR, nil
+		}
+		contractorsCount, err := strconv.Atoi(result.Tokens[tokenIdx+1])
+		if err != nil || contractorsCount < 0 || len(result.Tokens) < tokenIdx+2+contractorsCount*8 {
+			logger.Error("Node return invalid token on command: " + string(command.ToBytes()))
+			return ENGINE_UNEXPECTED_ERROR, nil
+		}
+		statistics := common.EquivalentStatistics{Eq: result.Tokens[tokenIdx], Count: contractorsCount}
+		tokenIdx = tokenIdx + 2
+		for i := range contractorsCount {
+			statistics.SettlementLines = append(statistics.SettlementLines, common.SettlementLineListItem{
+				ID:                    result.Tokens[tokenIdx+i*8],
+				Contractor:            result.Tokens[tokenIdx+i*8+1],
+				State:                 result.Tokens[tokenIdx+i*8+2],
+				OwnKeysPresent:        result.Tokens[tokenIdx+i*8+3],
+				ContractorKeysPresent: result.Tokens[tokenIdx+i*8+4],
+				MaxNegativeBalance:    result.Tokens[tokenIdx+i*8+5],
+				MaxPositiveBalance:    result.Tokens[tokenIdx+i*8+6],
+				Balance:               result.Tokens[tokenIdx+i*8+7],
+			})
+		}
+		tokenIdx = tokenIdx + contractorsCount*8
+		equivalents = append(equivalents, statistics)
+	}
+	return OK, equivalents
+}
@@ -0,0 +1,265 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/common"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/logger"
+)
+
+var (
+	RECONCILIATION_FORMAT_JSON = "json"
+	RECONCILIATION_FORMAT_TEXT = "text"
+
+	// Count of the history records before and after the discrepancy, that are reported with it.
+	DEFAULT_RECONCILIATION_CONTEXT_SIZE = 2
+	MAX_RECONCILIATION_CONTEXT_SIZE     = 20
+
+	DISCREPANCY_KIND_PAYMENT_BALANCE = "payment-balance"
+	DISCREPANCY_KIND_BALANCE         = "balance"
+	DISCREPANCY_KIND_AUDIT_NUMBER    = "audit-number"
+)
+
+// Rebuilds the balances of the settlement lines of the equivalent from the history with each contractor
+// and compares them with the balances and audit numbers, reported by the engine.
+// Empty contractorID means all the settlement lines of the equivalent.
+func (handler *NodeHandler) Reconcile(
+	ctx context.Context, equivalent, contractorID string, contextSize int) (int, common.ReconciliationResponse) {
+
+	code, equivalents := handler.AllSettlementLines(ctx)
+	if code != OK {
+		return code, common.ReconciliationResponse{}
+	}
+
+	var settlementLines []common.SettlementLineListItem
+	for _, equivalentStatistics := range equivalents {
+		if equivalentStatistics.Eq != equivalent {
+			continue
+		}
+		for _, settlementLine := range equivalentStatistics.SettlementLines {
+			if contractorID == "" || settlementLine.ID == contractorID {
+				settlementLines = append(settlementLines, settlementLine)
+			}
+		}
+	}
+	if contractorID != "" && len(settlementLines) == 0 {
+		logger.Info("Node hasn't settlement line with the contractor " + contractorID + " in the equivalent " + equivalent)
+		return NODE_NOT_FOUND, common.ReconciliationResponse{}
+	}
+
+	response := common.ReconciliationResponse{SettlementLines: []common.ReconciliationRecord{}}
+	for _, settlementLine := range settlementLines {
+		code, record := handler.reconcileSettlementLine(ctx, equivalent, settlementLine, contextSize)
+		if code != OK {
+			return code, common.ReconciliationResponse{}
+		}
+		response.SettlementLines = append(response.SettlementLines, record)
+		if !record.IsReconciled {
+			response.Unreconciled++
+		}
+	}
+	response.Count = len(response.SettlementLines)
+	return OK, response
+}
+
+func (handler *NodeHandler) reconcileSettlementLine(
+	ctx context.Context, equivalent string, settlementLine common.SettlementLineListItem,
+	contextSize int) (int, common.ReconciliationRecord) {
+
+	code, detail := handler.SettlementLine(ctx, settlementLine.ID, equivalent)
+	if code != OK {
+		return code, common.ReconciliationRecord{}
+	}
+
+	var records []common.HistoryExportRecord
+	filter := HistoryExportFilter{
+		Kind:                HISTORY_EXPORT_KIND_WITH_CONTRACTOR,
+		Equivalent:          equivalent,
+		ContractorAddresses: strings.Fields(settlementLine.Contractor),
+		PageSize:            MAX_HISTORY_EXPORT_PAGE_SIZE,
+	}
+	code, _ = handler.ExportHistory(ctx, filter, func(page []common.HistoryExportRecord) error {
+		records = append(records, page...)
+		return nil
+	})
+	if code != OK {
+		return code, common.ReconciliationRecord{}
+	}
+
+	// History is returned from the newest records, the balance is rebuilt from the oldest ones.
+	for i, j := 0, len(records)-1; i < j; i, j = i+1, j-1 {
+		records[i], records[j] = records[j], records[i]
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		first, _ := strconv.ParseInt(records[i].UnixTimestampMicroseconds, 10, 64)
+		second, _ := strconv.ParseInt(records[j].UnixTimestampMicroseconds, 10, 64)
+		return first < second
+	})
+
+	reconciliation := common.ReconciliationRecord{
+		Equivalent:    equivalent,
+		ContractorID:  settlementLine.ID,
+		Contractor:    settlementLine.Contractor,
+		Balance:       detail.Balance,
+		AuditNumber:   detail.AuditNumber,
+		Discrepancies: []common.ReconciliationDiscrepancy{},
+	}
+
+	// Payments change the balance, settlement line operations change the limits only.
+	// Each payment is checked against the balance after the previous payment, so the place of the mismatch is found.
+	expectedBalance := new(big.Int)
+	var recordedBalance *big.Int
+	for i, record := range records {
+		if record.RecordType != "payment" {
+			reconciliation.SettlementLineOperations++
+			continue
+		}
+		reconciliation.Payments++
+
+		amount, isAmountParsed := new(big.Int).SetString(record.Amount, 10)
+		balanceAfterOperation, isBalanceParsed := new(big.Int).SetString(record.BalanceAfterOperation, 10)
+		if !isAmountParsed || !isBalanceParsed {
+			logger.Error("Node return invalid amount or balance of the payment " + record.TransactionUUID)
+			return ENGINE_UNEXPECTED_ERROR, common.ReconciliationRecord{}
+		}
+		switch record.OperationDirection {
+		case "incoming":
+		case "outgoing":
+			amount.Neg(amount)
+		default:
+			logger.Error("Node return invalid direction of the payment " + record.TransactionUUID)
+			return ENGINE_UNEXPECTED_ERROR, common.ReconciliationRecord{}
+		}
+
+		previousBalance := expectedBalance
+		if recordedBalance != nil {
+			previousBalance = recordedBalance
+		}
+		expectedBalanceAfterOperation := new(big.Int).Add(previousBalance, amount)
+		if expectedBalanceAfterOperation.Cmp(balanceAfterOperation) != 0 {
+			discrepancyRecord := record
+			reconciliation.Discrepancies = append(reconciliation.Discrepancies, common.ReconciliationDiscrepancy{
+				Kind: DISCREPANCY_KIND_PAYMENT_BALANCE,
+				Description: "balance after the payment doesn't match the balance before it (" +
+					previousBalance.String() + ") and the payment amount",
+				Expected: expectedBalanceAfterOperation.String(),
+				Actual:   balanceAfterOperation.String(),
+				Record:   &discrepancyRecord,
+				Context:  reconciliationContext(records, i, contextSize),
+			})
+		}
+		recordedBalance = balanceAfterOperation
+		expectedBalance.Add(expectedBalance, amount)
+	}
+	reconciliation.ExpectedBalance = expectedBalance.String()
+
+	balance, isParsed := new(big.Int).SetString(detail.Balance, 10)
+	if !isParsed {
+		logger.Error("Node return invalid balance of the settlement line with the contractor " + settlementLine.ID)
+		return ENGINE_UNEXPECTED_ERROR, common.ReconciliationRecord{}
+	}
+	if balance.Cmp(expectedBalance) != 0 {
+		reconciliation.Discrepancies = append(reconciliation.Discrepancies, common.ReconciliationDiscrepancy{
+			Kind:        DISCREPANCY_KIND_BALANCE,
+			Description: "balance of the settlement line doesn't match the sum of the payments",
+			Expected:    expectedBalance.String(),
+			Actual:      balance.String(),
+			Context:     reconciliationContext(records, len(records)-1, contextSize),
+		})
+	}
+
+	// Each payment and each settlement line operation is audited once.
+	operationsCount := strconv.Itoa(len(records))
+	if detail.AuditNumber != operationsCount {
+		reconciliation.Discrepancies = append(reconciliation.Discrepancies, common.ReconciliationDiscrepancy{
+			Kind:        DISCREPANCY_KIND_AUDIT_NUMBER,
+			Description: "audit number of the settlement line doesn't match the count of the operations in the history",
+			Expected:    operationsCount,
+			Actual:      detail.AuditNumber,
+			Context:     reconciliationContext(records, len(records)-1, contextSize),
+		})
+	}
+	reconciliation.IsReconciled = len(reconciliation.Discrepancies) == 0
+	return OK, reconciliation
+}
+
+// Returns the records around the index.
+func reconciliationContext(records []common.HistoryExportRecord, idx, contextSize int) []common.HistoryExportRecord {
+	from := max(idx-contextSize, 0)
+	to := min(idx+contextSize+1, len(records))
+	return append([]common.HistoryExportRecord{}, records[from:to]...)
+}
+
+// Writes human-readable reconciliation report.
+func WriteReconciliationReport(out io.Writer, response common.ReconciliationResponse) error {
+	var report strings.Builder
+	for _, record := range response.SettlementLines {
+		status := "OK"
+		if !record.IsReconciled {
+			status = "discrepancies: " + strconv.Itoa(len(record.Discrepancies))
+		}
+		fmt.Fprintf(&report, "Settlement line with the contractor %s (%s), equivalent %s: %s\n",
+			record.ContractorID, record.Contractor, record.Equivalent, status)
+		fmt.Fprintf(&report, "  balance %s, expected %s; audit number %s; payments %d, settlement line operations %d\n",
+			record.Balance, record.ExpectedBalance, record.AuditNumber, record.Payments, record.SettlementLineOperations)
+
+		for _, discrepancy := range record.Discrepancies {
+			fmt.Fprintf(&report, "  [%s] %s: expected %s, actual %s\n",
+				discrepancy.Kind, discrepancy.Description, discrepancy.Expected, discrepancy.Actual)
+			for _, contextRecord := range discrepancy.Context {
+				marker := " "
+				if discrepancy.Record != nil && *discrepancy.Record == contextRecord {
+					marker = ">"
+				}
+				fmt.Fprintf(&report, "   %s %s  %-9s  %-8s  %s", marker, contextRecord.Timestamp,
+					contextRecord.RecordType, contextRecord.OperationDirection, contextRecord.Amount)
+				if contextRecord.RecordType == "payment" {
+					fmt.Fprintf(&report, "  balance after %s", contextRecord.BalanceAfterOperation)
+				}
+				fmt.Fprintf(&report, "  %s\n", contextRecord.TransactionUUID)
+			}
+		}
+	}
+	fmt.Fprintf(&report, "Settlement lines: %d, with discrepancies: %d\n", response.Count, response.Unreconciled)
+	_, err := io.WriteString(out, report.String())
+	return err
+}
+
+func (handler *NodeHandler) reconcile() {
+	if !common.ValidateInt(Equivalent) {
+		logger.Error("Bad request: invalid equivalent parameter in reconcile request")
+		fmt.Println("Bad request: invalid equivalent parameter")
+		return
+	}
+	if ContractorID != "" && !common.ValidateInt(ContractorID) {
+		logger.Error("Bad request: invalid contractorID parameter in reconcile request")
+		fmt.Println("Bad request: invalid contractorID parameter")
+		return
+	}
+	format := ExportFormat
+	if format == "" {
+		format = RECONCILIATION_FORMAT_JSON
+	}
+	if format != RECONCILIATION_FORMAT_JSON && format != RECONCILIATION_FORMAT_TEXT {
+		logger.Error("Bad request: invalid format parameter in reconcile request")
+		fmt.Println("Bad request: invalid format parameter")
+		return
+	}
+
+	code, response := handler.Reconcile(context.Background(), Equivalent, ContractorID, DEFAULT_RECONCILIATION_CONTEXT_SIZE)
+	if code != OK || format == RECONCILIATION_FORMAT_JSON {
+		fmt.Println(string(buildJSONResponse(code, response)))
+		return
+	}
+	err := WriteReconciliationReport(os.Stdout, response)
+	if err != nil {
+		logger.Error("Can't write reconciliation report. Details: " + err.Error())
+	}
+}
@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"context"
 	"fmt"
 	"strconv"
 
@@ -174,7 +175,7 @@ func (handler *NodeHandler) resetSettlementLine() {
 }
 
 func (handler *NodeHandler) actionSettlementLineGetResult(command *Command) {
-	err := handler.Node.SendCommand(command)
+	err := handler.Node.SendCommand(context.Background(), command)
 	if err != nil {
 		logger.Error("Can't send command: " + string(command.ToBytes()) + " to node. Details: " + err.Error())
 		resultJSON := buildJSONResponse(COMMAND_TRANSFERRING_ERROR, common.ActionResponse{})
@@ -229,7 +230,7 @@ func (handler *NodeHandler) listSettlementLinesPortions() {
 }
 
 func (handler *NodeHandler) listSettlementLinesResult(command *Command) {
-	err := handler.Node.SendCommand(command)
+	err := handler.Node.SendCommand(context.Background(), command)
 	if err != nil {
 		logger.Error("Can't send command: " + string(command.ToBytes()) + " to node. Details: " + err.Error())
 		resultJSON := buildJSONResponse(COMMAND_TRANSFERRING_ERROR, common.SettlementLineListResponse{})
@@ -315,7 +316,7 @@ func (handler *NodeHandler) listContractors() {
 }
 
 func (handler *NodeHandler) listContractorsResult(command *Command) {
-	err := handler.Node.SendCommand(command)
+	err := handler.Node.SendCommand(context.Background(), command)
 	if err != nil {
 		logger.Error("Can't send command: " + string(command.ToBytes()) + " to node. Details: " + err.Error())
 		resultJSON := buildJSONResponse(COMMAND_TRANSFERRING_ERROR, common.ContractorsListResponse{})
@@ -430,7 +431,7 @@ func (handler *NodeHandler) settlementLineByAddresses() {
 }
 
 func (handler *NodeHandler) settlementLineGetResult(command *Command) {
-	err := handler.Node.SendCommand(command)
+	err := handler.Node.SendCommand(context.Background(), command)
 	if err != nil {
 		logger.Error("Can't send command: " + string(command.ToBytes()) + " to node. Details: " + err.Error())
 		resultJSON := buildJSONResponse(COMMAND_TRANSFERRING_ERROR, common.SettlementLineDetailResponse{})
@@ -496,7 +497,7 @@ func (handler *NodeHandler) listEquivalents() {
 }
 
 func (handler *NodeHandler) listEquivalentsGetResult(command *Command) {
-	err := handler.Node.SendCommand(command)
+	err := handler.Node.SendCommand(context.Background(), command)
 	if err != nil {
 		logger.Error("Can't send command: " + string(command.ToBytes()) + " to node. Details: " + err.Error())
 		resultJSON := buildJSONResponse(COMMAND_TRANSFERRING_ERROR, common.EquivalentsListResponse{})
@@ -566,7 +567,7 @@ func (handler *NodeHandler) totalBalance() {
 
 func (handler *NodeHandler) totalBalanceGetResult(command *Command) {
 
-	err := handler.Node.SendCommand(command)
+	err := handler.Node.SendCommand(context.Background(), command)
 	if err != nil {
 		logger.Error("Can't send command: " + string(command.ToBytes()) + " to node. Details: " + err.Error())
 		resultJSON := buildJSONResponse(COMMAND_TRANSFERRING_ERROR, common.TotalBalanceResponse{})
@@ -0,0 +1,453 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/viper"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/common"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/logger"
+)
+
+var (
+	SETTLEMENT_LINE_STATE_PRESENT = "present"
+	SETTLEMENT_LINE_STATE_ABSENT  = "absent"
+
+	// Actions are applied in the order of this list.
+	SETTLEMENT_LINE_ACTION_INIT           = "init"
+	SETTLEMENT_LINE_ACTION_SET            = "set"
+	SETTLEMENT_LINE_ACTION_CLOSE_INCOMING = "close-incoming"
+	SETTLEMENT_LINE_ACTION_DELETE         = "delete"
+	SETTLEMENT_LINE_ACTIONS               = []string{
+		SETTLEMENT_LINE_ACTION_INIT, SETTLEMENT_LINE_ACTION_SET,
+		SETTLEMENT_LINE_ACTION_CLOSE_INCOMING, SETTLEMENT_LINE_ACTION_DELETE}
+
+	SETTLEMENT_LINE_ACTION_STATE_SUCCEEDED = "succeeded"
+	SETTLEMENT_LINE_ACTION_STATE_FAILED    = "failed"
+	// Previous action of the same settlement line failed.
+	SETTLEMENT_LINE_ACTION_STATE_SKIPPED = "skipped"
+
+	SETTLEMENT_LINES_CONFIG_FORMAT_TEXT = "text"
+	SETTLEMENT_LINES_CONFIG_FORMAT_JSON = "json"
+)
+
+// Desired state of the settlement lines with the contractor.
+// Contractor is set by the ID or by the address (in the same form as the --address flag).
+type SettlementLineConfig struct {
+	ContractorID       string   `mapstructure:"contractor_id"`
+	ContractorAddress  string   `mapstructure:"contractor_address"`
+	Equivalents        []string `mapstructure:"equivalents"`
+	MaxPositiveBalance string   `mapstructure:"max_positive_balance"`
+	// Max negative balance (the incoming settlement line) is zeroed out, if it's set.
+	CloseIncoming bool `mapstructure:"close_incoming"`
+	// "present" (default) or "absent".
+	State string `mapstructure:"state"`
+}
+
+type SettlementLinesConfig struct {
+	SettlementLines []SettlementLineConfig `mapstructure:"settlement_lines"`
+}
+
+type SettlementLineAction struct {
+	Action       string `json:"action"`
+	ContractorID string `json:"contractor_id"`
+	Contractor   string `json:"contractor,omitempty"`
+	Equivalent   string `json:"equivalent"`
+	// Current and desired values of the changed limit (set and close-incoming only).
+	From string `json:"from,omitempty"`
+	To   string `json:"to,omitempty"`
+	// Result of the action, it's set on apply.
+	State string `json:"state,omitempty"`
+	Code  int    `json:"code,omitempty"`
+}
+
+type SettlementLinesPlan struct {
+	Actions []*SettlementLineAction `json:"actions"`
+	// Count of the settlement lines of the file, that are already in the desired state.
+	Unchanged int `json:"unchanged"`
+}
+
+// Reads the YAML file of the settlement lines desired state. Unknown keys are not allowed.
+func ReadSettlementLinesConfig(path string) (*SettlementLinesConfig, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+	v.SetConfigType("yaml")
+	err := v.ReadInConfig()
+	if err != nil {
+		return nil, wrap("can't read file "+path, err)
+	}
+
+	config := &SettlementLinesConfig{}
+	err = v.UnmarshalExact(config)
+	if err != nil {
+		return nil, wrap("invalid file "+path, err)
+	}
+	return config, config.Validate()
+}
+
+func (c *SettlementLinesConfig) Validate() error {
+	lines := make(map[string]bool)
+	for idx, line := range c.SettlementLines {
+		position := "settlement line #" + strconv.Itoa(idx+1)
+		if (line.ContractorID == "") == (line.ContractorAddress == "") {
+			return errors.New(position + ": either contractor_id or contractor_address is expected")
+		}
+		if line.ContractorID != "" && !common.ValidateInt(line.ContractorID) {
+			return errors.New(position + ": invalid contractor_id")
+		}
+		if line.ContractorAddress != "" {
+			addressType, _ := common.ValidateAddress(line.ContractorAddress)
+			if addressType == "" {
+				return errors.New(position + ": invalid contractor_address")
+			}
+		}
+		if len(line.Equivalents) == 0 {
+			return errors.New(position + ": equivalents are expected")
+		}
+		switch line.State {
+		case "", SETTLEMENT_LINE_STATE_PRESENT:
+			if !common.ValidateSettlementLineAmount(line.MaxPositiveBalance) {
+				return errors.New(position + ": invalid max_positive_balance")
+			}
+		case SETTLEMENT_LINE_STATE_ABSENT:
+		default:
+			return errors.New(position + ": invalid state " + line.State)
+		}
+
+		for _, equivalent := range line.Equivalents {
+			if !common.ValidateInt(equivalent) {
+				return errors.New(position + ": invalid equivalent " + equivalent)
+			}
+			key := line.ContractorID + line.ContractorAddress + "/" + equivalent
+			if lines[key] {
+				return errors.New(position + ": settlement line in the equivalent " + equivalent + " is listed twice")
+			}
+			lines[key] = true
+		}
+	}
+	return nil
+}
+
+// Compares the desired state with the current settlement lines of the node.
+// Settlement lines, that are not listed in the config, are not changed.
+func (handler *NodeHandler) PlanSettlementLines(
+	ctx context.Context, config *SettlementLinesConfig) (int, *SettlementLinesPlan, error) {
+
+	code, equivalents := handler.AllSettlementLines(ctx)
+	if code != OK {
+		return code, nil, nil
+	}
+	current := make(map[string]common.SettlementLineListItem)
+	for _, equivalentStatistics := range equivalents {
+		for _, settlementLine := range equivalentStatistics.SettlementLines {
+			current[settlementLine.ID+"/"+equivalentStatistics.Eq] = settlementLine
+		}
+	}
+
+	var contractors []common.ChannelListItem
+	for _, line := range config.SettlementLines {
+		if line.ContractorAddress != "" {
+			code, contractors = handler.Contractors(ctx)
+			if code != OK {
+				return code, nil, nil
+			}
+			break
+		}
+	}
+
+	plan := &SettlementLinesPlan{Actions: []*SettlementLineAction{}}
+	for _, line := range config.SettlementLines {
+		contractorID, contractor := line.ContractorID, ""
+		if line.ContractorAddress != "" {
+			contractorID = contractorIDByAddress(contractors, line.ContractorAddress)
+			if contractorID == "" {
+				return OK, nil, errors.New("there is no channel with the contractor " + line.ContractorAddress)
+			}
+			contractor = line.ContractorAddress
+		}
+
+		for _, equivalent := range line.Equivalents {
+			settlementLine, isPresent := current[contractorID+"/"+equivalent]
+			lineContractor := contractor
+			if isPresent {
+				lineContractor = settlementLine.Contractor
+			}
+			newAction := func(action, from, to string) {
+				plan.Actions = append(plan.Actions, &SettlementLineAction{
+					Action:       action,
+					ContractorID: contractorID,
+					Contractor:   lineContractor,
+					Equivalent:   equivalent,
+					From:         from,
+					To:           to,
+				})
+			}
+			actionsCount := len(plan.Actions)
+
+			if line.State == SETTLEMENT_LINE_STATE_ABSENT {
+				if isPresent {
+					if !isZeroAmount(settlementLine.MaxPositiveBalance) {
+						newAction(SETTLEMENT_LINE_ACTION_SET, settlementLine.MaxPositiveBalance, "0")
+					}
+					if !isZeroAmount(settlementLine.MaxNegativeBalance) {
+						newAction(SETTLEMENT_LINE_ACTION_CLOSE_INCOMING, settlementLine.MaxNegativeBalance, "0")
+					}
+					newAction(SETTLEMENT_LINE_ACTION_DELETE, "", "")
+				}
+			} else if !isPresent {
+				newAction(SETTLEMENT_LINE_ACTION_INIT, "", "")
+				if !isZeroAmount(line.MaxPositiveBalance) {
+					newAction(SETTLEMENT_LINE_ACTION_SET, "0", line.MaxPositiveBalance)
+				}
+			} else {
+				if !isEqualAmount(settlementLine.MaxPositiveBalance, line.MaxPositiveBalance) {
+					newAction(SETTLEMENT_LINE_ACTION_SET, settlementLine.MaxPositiveBalance, line.MaxPositiveBalance)
+				}
+				if line.CloseIncoming && !isZeroAmount(settlementLine.MaxNegativeBalance) {
+					newAction(SETTLEMENT_LINE_ACTION_CLOSE_INCOMING, settlementLine.MaxNegativeBalance, "0")
+				}
+			}
+
+			if len(plan.Actions) == actionsCount {
+				plan.Unchanged++
+			}
+		}
+	}
+
+	sort.SliceStable(plan.Actions, func(i, j int) bool {
+		return actionOrder(plan.Actions[i].Action) < actionOrder(plan.Actions[j].Action)
+	})
+	return OK, plan, nil
+}
+
+// Applies the actions of the plan one by one.
+// If the action fails, the next actions of the same settlement line are skipped.
+func (handler *NodeHandler) ApplySettlementLinesPlan(ctx context.Context, plan *SettlementLinesPlan) {
+	failedLines := make(map[string]bool)
+	for _, action := range plan.Actions {
+		key := action.ContractorID + "/" + action.Equivalent
+		if failedLines[key] {
+			action.State = SETTLEMENT_LINE_ACTION_STATE_SKIPPED
+			continue
+		}
+
+		var command *Command
+		switch action.Action {
+		case SETTLEMENT_LINE_ACTION_INIT:
+			command = NewCommand("INIT:contractors/trust-line", action.ContractorID, action.Equivalent)
+		case SETTLEMENT_LINE_ACTION_SET:
+			command = NewCommand("SET:contractors/trust-lines", action.ContractorID, action.To, action.Equivalent)
+		case SETTLEMENT_LINE_ACTION_CLOSE_INCOMING:
+			command = NewCommand("DELETE:contractors/incoming-trust-line", action.ContractorID, action.Equivalent)
+		case SETTLEMENT_LINE_ACTION_DELETE:
+			command = NewCommand("DELETE:contractors/trust-line", action.ContractorID, action.Equivalent)
+		}
+
+		action.Code = handler.settlementLineAction(ctx, command)
+		if action.Code == OK {
+			action.State = SETTLEMENT_LINE_ACTION_STATE_SUCCEEDED
+		} else {
+			action.State = SETTLEMENT_LINE_ACTION_STATE_FAILED
+			failedLines[key] = true
+		}
+	}
+}
+
+func (handler *NodeHandler) settlementLineAction(ctx context.Context, command *Command) int {
+	err := handler.Node.SendCommand(ctx, command)
+	if err != nil {
+		logger.Error("Can't send command: " + string(command.ToBytes()) + " to node. Details: " + err.Error())
+		return COMMAND_TRANSFERRING_ERROR
+	}
+
+	result, err := handler.Node.GetResult(command, common.SETTLEMENT_LINE_RESULT_TIMEOUT)
+	if err != nil {
+		logger.Error("Node is inaccessible during processing command: " +
+			string(command.ToBytes()) + ". Details: " + err.Error())
+		return NODE_IS_INACCESSIBLE
+	}
+
+	if result.Code != OK {
+		logger.Error("Node return wrong command result: " + strconv.Itoa(result.Code) +
+			" on command: " + string(command.ToBytes()))
+	}
+	return result.Code
+}
+
+// Returns ID of the contractor, one of which addresses is the address ("<type>:<address>").
+func contractorIDByAddress(contractors []common.ChannelListItem, value string) string {
+	addressType, address := common.ValidateAddress(value)
+	for _, contractor := range contractors {
+		tokens := strings.Fields(contractor.Addresses)
+		for idx, token := range tokens {
+			if token == address || token == addressType+"-"+address ||
+				(token == addressType && idx+1 < len(tokens) && tokens[idx+1] == address) {
+				return contractor.ID
+			}
+		}
+	}
+	return ""
+}
+
+func actionOrder(action string) int {
+	for idx, a := range SETTLEMENT_LINE_ACTIONS {
+		if a == action {
+			return idx
+		}
+	}
+	return len(SETTLEMENT_LINE_ACTIONS)
+}
+
+func isEqualAmount(first, second string) bool {
+	firstAmount, isFirstParsed := new(big.Int).SetString(first, 10)
+	secondAmount, isSecondParsed := new(big.Int).SetString(second, 10)
+	if !isFirstParsed || !isSecondParsed {
+		return first == second
+	}
+	return firstAmount.Cmp(secondAmount) == 0
+}
+
+func isZeroAmount(amount string) bool {
+	return isEqualAmount(amount, "0")
+}
+
+func (a *SettlementLineAction) String() string {
+	sign := "~"
+	switch a.Action {
+	case SETTLEMENT_LINE_ACTION_INIT:
+		sign = "+"
+	case SETTLEMENT_LINE_ACTION_DELETE:
+		sign = "-"
+	}
+	description := fmt.Sprintf("%s %-14s contractor %s", sign, a.Action, a.ContractorID)
+	if a.Contractor != "" {
+		description += " (" + a.Contractor + ")"
+	}
+	description += ", equivalent " + a.Equivalent
+	switch a.Action {
+	case SETTLEMENT_LINE_ACTION_SET:
+		description += ": max_positive_balance " + a.From + " -> " + a.To
+	case SETTLEMENT_LINE_ACTION_CLOSE_INCOMING:
+		description += ": max_negative_balance " + a.From + " -> " + a.To
+	}
+	return description
+}
+
+// Writes the plan as the human-readable diff.
+func WriteSettlementLinesPlan(out io.Writer, plan *SettlementLinesPlan) error {
+	var report strings.Builder
+	counts := make(map[string]int)
+	for _, action := range plan.Actions {
+		fmt.Fprintln(&report, action.String())
+		counts[action.Action]++
+	}
+	if len(plan.Actions) == 0 {
+		fmt.Fprintf(&report, "No changes, %d settlement lines are in the desired state.\n", plan.Unchanged)
+	} else {
+		fmt.Fprintf(&report, "Plan: %d to init, %d to set, %d to close-incoming, %d to delete; %d unchanged.\n",
+			counts[SETTLEMENT_LINE_ACTION_INIT], counts[SETTLEMENT_LINE_ACTION_SET],
+			counts[SETTLEMENT_LINE_ACTION_CLOSE_INCOMING], counts[SETTLEMENT_LINE_ACTION_DELETE], plan.Unchanged)
+	}
+	_, err := io.WriteString(out, report.String())
+	return err
+}
+
+// Writes the results of the applied actions.
+func WriteSettlementLinesApplyReport(out io.Writer, plan *SettlementLinesPlan) error {
+	var report strings.Builder
+	counts := make(map[string]int)
+	for _, action := range plan.Actions {
+		fmt.Fprintf(&report, "%-9s  %s", action.State, action.String())
+		if action.State == SETTLEMENT_LINE_ACTION_STATE_FAILED {
+			fmt.Fprintf(&report, " (code %d)", action.Code)
+		}
+		fmt.Fprintln(&report)
+		counts[action.State]++
+	}
+	fmt.Fprintf(&report, "Applied: %d succeeded, %d failed, %d skipped.\n",
+		counts[SETTLEMENT_LINE_ACTION_STATE_SUCCEEDED], counts[SETTLEMENT_LINE_ACTION_STATE_FAILED],
+		counts[SETTLEMENT_LINE_ACTION_STATE_SKIPPED])
+	_, err := io.WriteString(out, report.String())
+	return err
+}
+
+func (handler *NodeHandler) SettlementLinesConfigCommand() error {
+	if CommandType != "plan" && CommandType != "apply" {
+		logger.Error("Invalid sl command " + CommandType)
+		fmt.Println("Invalid sl command")
+		return nil
+	}
+	format := ExportFormat
+	if format == "" {
+		format = SETTLEMENT_LINES_CONFIG_FORMAT_TEXT
+	}
+	if format != SETTLEMENT_LINES_CONFIG_FORMAT_TEXT && format != SETTLEMENT_LINES_CONFIG_FORMAT_JSON {
+		logger.Error("Bad request: invalid format parameter in sl request")
+		fmt.Println("Bad request: invalid format parameter")
+		return nil
+	}
+
+	config, err := ReadSettlementLinesConfig(File)
+	if err != nil {
+		logger.Error("Bad request: invalid settlement lines file. Details: " + err.Error())
+		fmt.Println("Bad request: " + err.Error())
+		return nil
+	}
+
+	code, plan, err := handler.PlanSettlementLines(context.Background(), config)
+	if err != nil {
+		logger.Error("Bad request: can't plan settlement lines. Details: " + err.Error())
+		fmt.Println("Bad request: " + err.Error())
+		return nil
+	}
+	if code != OK {
+		fmt.Println(string(buildJSONResponse(code, SettlementLinesPlan{})))
+		return nil
+	}
+
+	if CommandType == "plan" || len(plan.Actions) == 0 {
+		if format == SETTLEMENT_LINES_CONFIG_FORMAT_JSON {
+			fmt.Println(string(buildJSONResponse(OK, plan)))
+			return nil
+		}
+		return WriteSettlementLinesPlan(os.Stdout, plan)
+	}
+
+	// Plan is shown before the confirmation (in JSON format too, so it's written to the stderr).
+	if !AutoApprove {
+		err = WriteSettlementLinesPlan(os.Stderr, plan)
+		if err != nil {
+			return err
+		}
+		fmt.Fprint(os.Stderr, "Apply these actions? Only 'yes' will be accepted: ")
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if strings.TrimSpace(answer) != "yes" {
+			fmt.Fprintln(os.Stderr, "Apply is cancelled.")
+			return nil
+		}
+	}
+
+	handler.ApplySettlementLinesPlan(context.Background(), plan)
+	if format == SETTLEMENT_LINES_CONFIG_FORMAT_JSON {
+		fmt.Println(string(buildJSONResponse(OK, plan)))
+	} else {
+		err = WriteSettlementLinesApplyReport(os.Stdout, plan)
+		if err != nil {
+			return err
+		}
+	}
+	for _, action := range plan.Actions {
+		if action.State != SETTLEMENT_LINE_ACTION_STATE_SUCCEEDED {
+			return errors.New("some settlement lines actions are not applied")
+		}
+	}
+	return nil
+}
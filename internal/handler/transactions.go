@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"context"
 	"fmt"
 	"strconv"
 
@@ -16,6 +17,9 @@ func (handler *NodeHandler) MaxFlow() {
 	} else if CommandType == "partly" {
 		handler.maxFlowPartly()
 
+	} else if CommandType == "batch" {
+		handler.maxFlowBatch()
+
 	} else {
 		logger.Error("Invalid max-flow command " + CommandType)
 		fmt.Println("Invalid max-flow command")
@@ -55,8 +59,42 @@ func (handler *NodeHandler) maxFlowFully() {
 	go handler.maxFlowGetResult(command)
 }
 
+// Calculates max flows toward many contractors by chunks (see BatchMaxFlows).
+func (handler *NodeHandler) maxFlowBatch() {
+	if len(Addresses) == 0 {
+		logger.Error("Bad request: there are no contractor addresses parameters in max-flow request")
+		fmt.Println("Bad request: there are no contractor addresses parameters")
+		return
+	}
+
+	if !common.ValidateInt(Equivalent) {
+		logger.Error("Bad request: invalid equivalent parameter in max-flow request")
+		fmt.Println("Bad request: invalid equivalent parameter")
+		return
+	}
+
+	var addresses []string
+	for idx := range len(Addresses) {
+		addressType, address := common.ValidateAddress(Addresses[idx])
+		if addressType == "" {
+			logger.Error("Bad request: invalid address parameter in max-flow request")
+			fmt.Println("Bad request: invalid address parameter")
+			return
+		}
+		addresses = append(addresses, addressType+"-"+address)
+	}
+
+	flows := handler.BatchMaxFlows(context.Background(), Equivalent, addresses, true)
+	response := common.MaxFlowBatchResponse{Count: len(flows), Records: []common.MaxFlowBatchRecord{}}
+	for _, flow := range flows {
+		response.Records = append(response.Records, flow.Record())
+	}
+	resultJSON := buildJSONResponse(OK, response)
+	fmt.Println(string(resultJSON))
+}
+
 func (handler *NodeHandler) maxFlowGetResult(command *Command) {
-	err := handler.Node.SendCommand(command)
+	err := handler.Node.SendCommand(context.Background(), command)
 	if err != nil {
 		logger.Error("Can't send command: " + string(command.ToBytes()) + " to node. Details: " + err.Error())
 		resultJSON := buildJSONResponse(COMMAND_TRANSFERRING_ERROR, common.MaxFlowResponse{})
@@ -154,7 +192,7 @@ func (handler *NodeHandler) maxFlowPartly() {
 }
 
 func (handler *NodeHandler) maxFlowPartlyGetResult(command *Command) {
-	err := handler.Node.SendCommand(command)
+	err := handler.Node.SendCommand(context.Background(), command)
 	if err != nil {
 		logger.Error("Can't send command: " + string(command.ToBytes()) + " to node. Details: " + err.Error())
 		resultJSON := buildJSONResponse(COMMAND_TRANSFERRING_ERROR, common.MaxFlowPartialResponse{})
@@ -325,7 +363,7 @@ func (handler *NodeHandler) Payment() {
 		return
 	}
 
-	var addresses []string
+	var contractorAddresses []string
 	for idx := range len(Addresses) {
 		addressType, address := common.ValidateAddress(Addresses[idx])
 		if addressType == "" {
@@ -333,59 +371,99 @@ func (handler *NodeHandler) Payment() {
 			fmt.Println("Bad request: invalid address parameter")
 			return
 		}
-		addresses = append(addresses, addressType, address)
+		contractorAddresses = append(contractorAddresses, addressType+"-"+address)
 	}
 
-	addresses = append([]string{strconv.Itoa(len(Addresses))}, addresses...)
-	addresses = append([]string{"CREATE:contractors/transactions"}, addresses...)
-	addresses = append(addresses, []string{Amount, Equivalent}...)
-	if Payload != "" {
-		addresses = append(addresses, []string{Payload}...)
+	order := PaymentOrder{
+		ContractorAddresses: contractorAddresses,
+		Amount:              Amount,
+		Equivalent:          Equivalent,
+		Payload:             Payload,
+	}
+
+	if CheckPayment || SendIfFeasible {
+		handler.paymentQuoteResult(order)
+		return
 	}
-	command := NewCommand(addresses...)
 
-	go handler.paymentResult(command)
+	// Payment is checked by the policies before it is sent,
+	// so it is executed synchronously (the results waiting could not start before it).
+	handler.paymentResult(order)
 }
 
-func (handler *NodeHandler) paymentResult(command *Command) {
-	err := handler.Node.SendCommand(command)
-	if err != nil {
-		logger.Error("Can't send command: " + string(command.ToBytes()) + " to node. Details: " + err.Error())
-		resultJSON := buildJSONResponse(COMMAND_TRANSFERRING_ERROR, common.PaymentResponse{})
+// Checks, whether the payment could be done, and sends it if it is feasible and it was requested.
+func (handler *NodeHandler) paymentQuoteResult(order PaymentOrder) {
+	quote := handler.QuotePayment(context.Background(), order)
+	response := quote.Response(order)
+	if quote.Code != OK {
+		resultJSON := buildJSONResponse(quote.Code, response)
 		fmt.Println(string(resultJSON))
 		return
 	}
+	if !SendIfFeasible || !quote.IsFeasible {
+		resultJSON := buildJSONResponse(OK, response)
+		fmt.Println(string(resultJSON))
+		return
+	}
+
+	if RequiresApproval(order) {
+		requestPaymentApproval(order)
+		return
+	}
+	outcome := handler.ExecutePayment(context.Background(), order)
+	response.Sent = true
+	response.Code = outcome.Code
+	response.TransactionUUID = outcome.TransactionUUID
+	if outcome.Violation != nil {
+		response.PolicyViolation = outcome.Violation.Code
+		response.PolicyViolationMessage = outcome.Violation.Message
+	}
+	status := OK
+	if !outcome.IsSucceeded() {
+		status = outcome.Code
+	}
+	resultJSON := buildJSONResponse(status, response)
+	fmt.Println(string(resultJSON))
+}
 
-	result, err := handler.Node.GetResult(command, common.PAYMENT_OPERATION_TIMEOUT)
+// Payments above the approval threshold are not sent by the CLI too,
+// they wait for the approval of the other caller (see "Payment Approvals").
+func requestPaymentApproval(order PaymentOrder) {
+	payment, _, err := CreatePendingPayment(context.Background(), order)
 	if err != nil {
-		logger.Error("Node is inaccessible during processing command: " +
-			string(command.ToBytes()) + ". Details: " + err.Error())
-		resultJSON := buildJSONResponse(NODE_IS_INACCESSIBLE, common.PaymentResponse{})
+		logger.Error("Can't create pending payment. Details: " + err.Error())
+		code := SERVER_ERROR
+		if err == ErrPendingPaymentExists {
+			code = CONFLICT
+		}
+		resultJSON := buildJSONResponse(code, common.PendingPaymentResponse{})
 		fmt.Println(string(resultJSON))
 		return
 	}
+	resultJSON := buildJSONResponse(ACCEPTED, payment.Response())
+	fmt.Println(string(resultJSON))
+}
 
-	if result.Code != CREATED && result.Code != ENGINE_NO_EQUIVALENT {
-		logger.Error("Node return wrong command result: " + strconv.Itoa(result.Code) +
-			" on command: " + string(command.ToBytes()))
-		resultJSON := buildJSONResponse(result.Code, common.PaymentResponse{})
-		fmt.Println(string(resultJSON))
+func (handler *NodeHandler) paymentResult(order PaymentOrder) {
+	if RequiresApproval(order) {
+		requestPaymentApproval(order)
 		return
 	}
-	if result.Code == ENGINE_NO_EQUIVALENT {
-		logger.Info("Node hasn't equivalent for command: " + string(command.ToBytes()))
-		resultJSON := buildJSONResponse(result.Code, common.PaymentResponse{})
+	outcome := handler.ExecutePayment(context.Background(), order)
+	if outcome.Violation != nil {
+		resultJSON := buildJSONResponse(outcome.Code, common.PolicyViolationResponse{
+			Code:    outcome.Violation.Code,
+			Message: outcome.Violation.Message,
+		})
 		fmt.Println(string(resultJSON))
 		return
 	}
-
-	if len(result.Tokens) == 0 {
-		logger.Error("Node return invalid result tokens size on command: " + string(command.ToBytes()))
-		resultJSON := buildJSONResponse(ENGINE_UNEXPECTED_ERROR, common.PaymentResponse{})
+	if !outcome.IsSucceeded() {
+		resultJSON := buildJSONResponse(outcome.Code, common.PaymentResponse{})
 		fmt.Println(string(resultJSON))
 		return
 	}
 
-	resultJSON := buildJSONResponse(OK, common.PaymentResponse{TransactionUUID: result.Tokens[0]})
+	resultJSON := buildJSONResponse(OK, common.PaymentResponse{TransactionUUID: outcome.TransactionUUID})
 	fmt.Println(string(resultJSON))
 }
@@ -0,0 +1,562 @@
+package historyindex
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/common"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/conf"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/events"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/handler"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/historymark"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/logger"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/store"
+)
+
+var (
+	HISTORY_INDEX_BUCKET  = "history-index"
+	MARKS_KEY             = "marks"
+	DEFAULT_SYNC_INTERVAL = 30 * time.Second
+	SYNC_PAGE_SIZE        = 100
+
+	KIND_PAYMENTS         = "payments"
+	KIND_SETTLEMENT_LINES = "settlement-lines"
+
+	SORT_TIMESTAMP_ASC  = "timestamp"
+	SORT_TIMESTAMP_DESC = "-timestamp"
+	SORT_AMOUNT_ASC     = "amount"
+	SORT_AMOUNT_DESC    = "-amount"
+
+	DEFAULT_QUERY_LIMIT = 100
+	MAX_QUERY_LIMIT     = 1000
+)
+
+var (
+	ErrIndexIsDisabled = errors.New("history index is disabled")
+	ErrInvalidCursor   = errors.New("invalid cursor")
+)
+
+// Indexed history record. ID is assigned in the order of the indexing.
+type record struct {
+	ID   int64  `json:"id"`
+	Kind string `json:"kind"`
+	common.HistoryExportRecord
+
+	timestamp int64
+	amount    *big.Int
+}
+
+// Identity of the record, the same record could be returned by the engine in several syncs.
+func (r *record) key() string {
+	return r.Kind + "/" + r.Equivalent + "/" + r.TransactionUUID + "/" +
+		r.UnixTimestampMicroseconds + "/" + r.OperationDirection + "/" + r.Amount
+}
+
+// Parses timestamp and amount of the record.
+func (r *record) parse() error {
+	timestamp, err := strconv.ParseInt(r.UnixTimestampMicroseconds, 10, 64)
+	if err != nil {
+		return errors.New("invalid timestamp of the transaction " + r.TransactionUUID)
+	}
+	amount, isParsed := new(big.Int).SetString(r.Amount, 10)
+	if !isParsed {
+		return errors.New("invalid amount of the transaction " + r.TransactionUUID)
+	}
+	r.timestamp = timestamp
+	r.amount = amount
+	r.Timestamp = time.UnixMicro(timestamp).UTC().Format(time.RFC3339)
+	return nil
+}
+
+// Local copy of the payments and settlement lines history.
+// Records are appended to the logs of the store and are kept in the memory for the queries.
+type Index struct {
+	nodeHandler *handler.NodeHandler
+	bucket      *store.Bucket
+	logs        map[string]*store.Log
+
+	lock sync.RWMutex
+	// Records by kind and equivalent, in the order of the indexing.
+	records   map[string]map[string][]*record
+	keys      map[string]bool
+	lastID    int64
+	marks     map[string]*historymark.Mark
+	syncedAt  time.Time
+	lastError string
+
+	syncRequests chan struct{}
+}
+
+var index *Index
+
+func IsEnabled() bool {
+	return conf.Params.HistoryIndex.Enabled
+}
+
+// Loads the index from the store and starts the synchronization with the engine.
+func Start(nodeHandler *handler.NodeHandler) error {
+	bucket, err := store.OpenBucket(HISTORY_INDEX_BUCKET)
+	if err != nil {
+		return err
+	}
+	idx := &Index{
+		nodeHandler:  nodeHandler,
+		bucket:       bucket,
+		logs:         make(map[string]*store.Log),
+		records:      make(map[string]map[string][]*record),
+		keys:         make(map[string]bool),
+		marks:        make(map[string]*historymark.Mark),
+		syncRequests: make(chan struct{}, 1),
+	}
+	err = idx.load()
+	if err != nil {
+		return err
+	}
+	index = idx
+
+	// Local payments are indexed right after they are done.
+	events.Subscribe(func(event events.Event) {
+		if event.Type == events.EVENT_PAYMENT_COMPLETED {
+			idx.requestSync()
+		}
+	})
+
+	interval := conf.Params.HistoryIndex.SyncInterval
+	if interval <= 0 {
+		interval = DEFAULT_SYNC_INTERVAL
+	}
+	go func() {
+		for {
+			idx.sync(context.Background())
+			select {
+			case <-time.After(interval):
+			case <-idx.syncRequests:
+			}
+		}
+	}()
+	logger.Info("History indexing started, sync interval " + interval.String())
+	return nil
+}
+
+func (idx *Index) load() error {
+	_, err := idx.bucket.Get(MARKS_KEY, &idx.marks)
+	if err != nil {
+		return err
+	}
+
+	for _, kind := range []string{KIND_PAYMENTS, KIND_SETTLEMENT_LINES} {
+		log, err := idx.bucket.OpenLog(kind)
+		if err != nil {
+			return err
+		}
+		idx.logs[kind] = log
+
+		err = log.Read(func(data []byte) error {
+			r := &record{}
+			err := json.Unmarshal(data, r)
+			if err != nil {
+				return errors.New("can't parse history index record -> " + err.Error())
+			}
+			err = r.parse()
+			if err != nil {
+				return err
+			}
+			idx.add(r)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Adds the record to the memory, records, that are already present, are skipped.
+func (idx *Index) add(r *record) bool {
+	if idx.keys[r.key()] {
+		return false
+	}
+	idx.keys[r.key()] = true
+	if r.ID > idx.lastID {
+		idx.lastID = r.ID
+	}
+	if idx.records[r.Kind] == nil {
+		idx.records[r.Kind] = make(map[string][]*record)
+	}
+	idx.records[r.Kind][r.Equivalent] = append(idx.records[r.Kind][r.Equivalent], r)
+	return true
+}
+
+func (idx *Index) requestSync() {
+	select {
+	case idx.syncRequests <- struct{}{}:
+	default:
+	}
+}
+
+// Fetches the records, that are newer than the marks, for all the equivalents of the node.
+func (idx *Index) sync(ctx context.Context) {
+	code, equivalents := idx.nodeHandler.Equivalents(ctx)
+	if code != handler.OK {
+		idx.setSyncResult("can't read equivalents, code " + strconv.Itoa(code))
+		return
+	}
+
+	for _, equivalent := range equivalents {
+		for _, kind := range []string{KIND_PAYMENTS, KIND_SETTLEMENT_LINES} {
+			err := idx.syncHistory(ctx, kind, equivalent)
+			if err != nil {
+				logger.Error("Can't sync " + kind + " history of the equivalent " + equivalent +
+					" into the index. Details: " + err.Error())
+				idx.setSyncResult(err.Error())
+				return
+			}
+		}
+	}
+	idx.setSyncResult("")
+}
+
+func (idx *Index) setSyncResult(lastError string) {
+	idx.lock.Lock()
+	defer idx.lock.Unlock()
+
+	idx.lastError = lastError
+	if lastError == "" {
+		idx.syncedAt = time.Now()
+	}
+}
+
+// Indexes the records of the history, that are newer than the mark, and advances the mark.
+// Mark is left as is if the history could not be read.
+func (idx *Index) syncHistory(ctx context.Context, kind, equivalent string) error {
+	idx.lock.RLock()
+	current := historymark.Mark{}
+	if m, isPresent := idx.marks[kind+"/"+equivalent]; isPresent {
+		current = m.Copy()
+	}
+	idx.lock.RUnlock()
+	dateFrom := current.DateFrom()
+
+	var newRecords []*record
+	for offset := 0; ; offset += SYNC_PAGE_SIZE {
+		records, err := idx.historyPage(ctx, kind, equivalent, offset, dateFrom)
+		if err != nil {
+			return err
+		}
+
+		pageHasNewRecords := false
+		for _, r := range records {
+			if current.IsNew(r.timestamp, r.key()) {
+				pageHasNewRecords = true
+				newRecords = append(newRecords, r)
+			}
+		}
+		// Records are ordered from the newest ones, so there is no need to read the older pages.
+		if len(records) < SYNC_PAGE_SIZE || !pageHasNewRecords {
+			break
+		}
+	}
+	if len(newRecords) == 0 {
+		return nil
+	}
+	sort.SliceStable(newRecords, func(i, j int) bool {
+		return newRecords[i].timestamp < newRecords[j].timestamp
+	})
+
+	idx.lock.Lock()
+	defer idx.lock.Unlock()
+
+	var appended []interface{}
+	var added []*record
+	for _, r := range newRecords {
+		current.Advance(r.timestamp, r.key())
+		if idx.keys[r.key()] {
+			continue
+		}
+		idx.lastID++
+		r.ID = idx.lastID
+		appended = append(appended, r)
+		added = append(added, r)
+	}
+	// Records are written before the mark, so they are never lost
+	// (they could be fetched again after the crash, but the duplicates are skipped on load).
+	if len(appended) > 0 {
+		err := idx.logs[kind].Append(appended...)
+		if err != nil {
+			idx.lastID -= int64(len(appended))
+			return err
+		}
+		for _, r := range added {
+			idx.add(r)
+		}
+	}
+
+	idx.marks[kind+"/"+equivalent] = &current
+	return idx.bucket.Put(MARKS_KEY, idx.marks)
+}
+
+func (idx *Index) historyPage(ctx context.Context, kind, equivalent string, offset int, dateFrom string) ([]*record, error) {
+	var records []*record
+	switch kind {
+	case KIND_PAYMENTS:
+		code, page := idx.nodeHandler.PaymentsHistoryPage(
+			ctx, equivalent, offset, SYNC_PAGE_SIZE, handler.PaymentsHistoryFilter{DateFrom: dateFrom})
+		if code != handler.OK {
+			return nil, errors.New("can't read payments history, code " + strconv.Itoa(code))
+		}
+		for _, p := range page {
+			records = append(records, &record{Kind: kind, HistoryExportRecord: common.HistoryExportRecord{
+				Equivalent:                equivalent,
+				RecordType:                "payment",
+				TransactionUUID:           p.TransactionUUID,
+				UnixTimestampMicroseconds: p.UnixTimestampMicroseconds,
+				Contractor:                p.Contractor,
+				OperationDirection:        p.OperationDirection,
+				Amount:                    p.Amount,
+				BalanceAfterOperation:     p.BalanceAfterOperation,
+				Payload:                   p.Payload,
+			}})
+		}
+
+	case KIND_SETTLEMENT_LINES:
+		code, page := idx.nodeHandler.SettlementLinesHistoryPage(ctx, equivalent, offset, SYNC_PAGE_SIZE, dateFrom, "")
+		if code != handler.OK {
+			return nil, errors.New("can't read settlement lines history, code " + strconv.Itoa(code))
+		}
+		for _, s := range page {
+			records = append(records, &record{Kind: kind, HistoryExportRecord: common.HistoryExportRecord{
+				Equivalent:                equivalent,
+				RecordType:                "trustline",
+				TransactionUUID:           s.TransactionUUID,
+				UnixTimestampMicroseconds: s.UnixTimestampMicroseconds,
+				Contractor:                s.Contractor,
+				OperationDirection:        s.OperationDirection,
+				Amount:                    s.Amount,
+			}})
+		}
+	}
+
+	for _, r := range records {
+		err := r.parse()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return records, nil
+}
+
+// Filters, sorting and pagination of the index query.
+// Empty values are not applied.
+type Query struct {
+	Contractor string
+	Direction  string
+	AmountFrom *big.Int
+	AmountTo   *big.Int
+	// Substring of the payload (payments only).
+	Payload  string
+	DateFrom time.Time
+	DateTo   time.Time
+	// One of the SORT_* values, SORT_TIMESTAMP_DESC by default.
+	Sort   string
+	Limit  int
+	Cursor string
+}
+
+func IsSortValid(value string) bool {
+	return value == SORT_TIMESTAMP_ASC || value == SORT_TIMESTAMP_DESC ||
+		value == SORT_AMOUNT_ASC || value == SORT_AMOUNT_DESC
+}
+
+// Position of the last returned record in the sorted records.
+type cursor struct {
+	Sort string `json:"sort"`
+	// Sorting value of the record (timestamp or amount).
+	Value string `json:"value"`
+	ID    int64  `json:"id"`
+}
+
+func (c cursor) encode() string {
+	js, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(js)
+}
+
+func decodeCursor(value string) (*cursor, error) {
+	js, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	c := &cursor{}
+	err = json.Unmarshal(js, c)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+func (q *Query) matches(r *record) bool {
+	if q.Contractor != "" && r.Contractor != q.Contractor {
+		return false
+	}
+	if q.Direction != "" && !strings.EqualFold(r.OperationDirection, q.Direction) {
+		return false
+	}
+	if q.AmountFrom != nil && r.amount.Cmp(q.AmountFrom) < 0 {
+		return false
+	}
+	if q.AmountTo != nil && r.amount.Cmp(q.AmountTo) > 0 {
+		return false
+	}
+	if q.Payload != "" && !strings.Contains(r.Payload, q.Payload) {
+		return false
+	}
+	if !q.DateFrom.IsZero() && r.timestamp < q.DateFrom.UnixMicro() {
+		return false
+	}
+	if !q.DateTo.IsZero() && r.timestamp > q.DateTo.UnixMicro() {
+		return false
+	}
+	return true
+}
+
+// Compares records by the sorting value, records with the same value are compared by ID.
+func compareRecords(sortBy string, a, b *record) int {
+	result := 0
+	switch sortBy {
+	case SORT_AMOUNT_ASC, SORT_AMOUNT_DESC:
+		result = a.amount.Cmp(b.amount)
+	default:
+		result = compareInt64(a.timestamp, b.timestamp)
+	}
+	if result == 0 {
+		result = compareInt64(a.ID, b.ID)
+	}
+	if strings.HasPrefix(sortBy, "-") {
+		return -result
+	}
+	return result
+}
+
+func compareInt64(a, b int64) int {
+	if a < b {
+		return -1
+	}
+	if a > b {
+		return 1
+	}
+	return 0
+}
+
+// Returns the page of the indexed records of the kind and the equivalent.
+func Records(kind, equivalent string, query Query) (*common.HistoryIndexResponse, error) {
+	if index == nil {
+		return nil, ErrIndexIsDisabled
+	}
+	if query.Sort == "" {
+		query.Sort = SORT_TIMESTAMP_DESC
+	}
+	if query.Limit <= 0 {
+		query.Limit = DEFAULT_QUERY_LIMIT
+	}
+
+	// Cursor is converted to the record, that is compared as usual.
+	var after *record
+	if query.Cursor != "" {
+		c, err := decodeCursor(query.Cursor)
+		if err != nil || c.Sort != query.Sort {
+			return nil, ErrInvalidCursor
+		}
+		after = &record{ID: c.ID, amount: new(big.Int)}
+		switch query.Sort {
+		case SORT_AMOUNT_ASC, SORT_AMOUNT_DESC:
+			_, isParsed := after.amount.SetString(c.Value, 10)
+			if !isParsed {
+				return nil, ErrInvalidCursor
+			}
+		default:
+			after.timestamp, err = strconv.ParseInt(c.Value, 10, 64)
+			if err != nil {
+				return nil, ErrInvalidCursor
+			}
+		}
+	}
+
+	index.lock.RLock()
+	var matched []*record
+	for _, r := range index.records[kind][equivalent] {
+		if !query.matches(r) {
+			continue
+		}
+		if after != nil && compareRecords(query.Sort, r, after) <= 0 {
+			continue
+		}
+		matched = append(matched, r)
+	}
+	syncedAt := index.syncedAt
+	index.lock.RUnlock()
+
+	sort.Slice(matched, func(i, j int) bool {
+		return compareRecords(query.Sort, matched[i], matched[j]) < 0
+	})
+
+	response := &common.HistoryIndexResponse{Records: []common.HistoryExportRecord{}}
+	if !syncedAt.IsZero() {
+		response.SyncedAt = syncedAt.UTC().Format(time.RFC3339)
+	}
+	for _, r := range matched[:min(query.Limit, len(matched))] {
+		response.Records = append(response.Records, r.HistoryExportRecord)
+	}
+	response.Count = len(response.Records)
+
+	if len(matched) > query.Limit {
+		last := matched[query.Limit-1]
+		c := cursor{Sort: query.Sort, ID: last.ID, Value: strconv.FormatInt(last.timestamp, 10)}
+		if query.Sort == SORT_AMOUNT_ASC || query.Sort == SORT_AMOUNT_DESC {
+			c.Value = last.amount.String()
+		}
+		response.NextCursor = c.encode()
+	}
+	return response, nil
+}
+
+// Returns the state of the synchronization and the counts of the indexed records.
+func Status() common.HistoryIndexStatusResponse {
+	response := common.HistoryIndexStatusResponse{Enabled: index != nil, Equivalents: []common.HistoryIndexStatusRecord{}}
+	if index == nil {
+		return response
+	}
+
+	index.lock.RLock()
+	defer index.lock.RUnlock()
+
+	if !index.syncedAt.IsZero() {
+		response.SyncedAt = index.syncedAt.UTC().Format(time.RFC3339)
+	}
+	response.LastError = index.lastError
+	for key, m := range index.marks {
+		kind, equivalent, _ := strings.Cut(key, "/")
+		status := common.HistoryIndexStatusRecord{
+			Kind:       kind,
+			Equivalent: equivalent,
+			Records:    len(index.records[kind][equivalent]),
+		}
+		if m.UnixTimestampMicroseconds > 0 {
+			status.HighWaterMark = time.UnixMicro(m.UnixTimestampMicroseconds).UTC().Format(time.RFC3339)
+		}
+		response.Equivalents = append(response.Equivalents, status)
+	}
+	sort.Slice(response.Equivalents, func(i, j int) bool {
+		if response.Equivalents[i].Equivalent != response.Equivalents[j].Equivalent {
+			return response.Equivalents[i].Equivalent < response.Equivalents[j].Equivalent
+		}
+		return response.Equivalents[i].Kind < response.Equivalents[j].Kind
+	})
+	return response
+}
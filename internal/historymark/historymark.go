@@ -0,0 +1,50 @@
+package historymark
+
+import (
+	"slices"
+	"strconv"
+	"time"
+)
+
+// Latest processed record of the engine history (high-water mark).
+// History is read from the newest records, so the records, that are newer than the mark, are the new ones.
+type Mark struct {
+	UnixTimestampMicroseconds int64 `json:"unix_timestamp_microseconds"`
+	// Several records could have the same timestamp,
+	// so the keys of the records with the timestamp of the mark are remembered.
+	KeysAtMark []string `json:"records_at_mark"`
+}
+
+// Returns true if the record with the timestamp and the key was not processed yet.
+func (m *Mark) IsNew(timestamp int64, key string) bool {
+	if timestamp != m.UnixTimestampMicroseconds {
+		return timestamp > m.UnixTimestampMicroseconds
+	}
+	return !slices.Contains(m.KeysAtMark, key)
+}
+
+// Moves the mark to the processed record. Records must be processed from the oldest ones.
+func (m *Mark) Advance(timestamp int64, key string) {
+	if timestamp > m.UnixTimestampMicroseconds {
+		m.UnixTimestampMicroseconds = timestamp
+		m.KeysAtMark = nil
+	}
+	m.KeysAtMark = append(m.KeysAtMark, key)
+}
+
+// Returns the copy, that could be advanced without changing the mark.
+func (m *Mark) Copy() Mark {
+	return Mark{
+		UnixTimestampMicroseconds: m.UnixTimestampMicroseconds,
+		KeysAtMark:                slices.Clone(m.KeysAtMark),
+	}
+}
+
+// Returns the date_from filter of the history request, that includes the mark.
+// Engine filters the history by seconds. Empty mark is not filtered.
+func (m *Mark) DateFrom() string {
+	if m.UnixTimestampMicroseconds <= 0 {
+		return ""
+	}
+	return strconv.FormatInt(m.UnixTimestampMicroseconds/int64(time.Second/time.Microsecond), 10)
+}
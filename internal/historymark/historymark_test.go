@@ -0,0 +1,66 @@
+package historymark
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestIsNew(t *testing.T) {
+	mark := Mark{UnixTimestampMicroseconds: 2000000, KeysAtMark: []string{"a", "b"}}
+	cases := []struct {
+		name      string
+		timestamp int64
+		key       string
+		expected  bool
+	}{
+		{"older record", 1999999, "c", false},
+		{"processed record at the mark", 2000000, "a", false},
+		{"other record at the mark", 2000000, "c", true},
+		{"newer record", 2000001, "a", true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if mark.IsNew(c.timestamp, c.key) != c.expected {
+				t.Fatalf("expected %v", c.expected)
+			}
+		})
+	}
+}
+
+func TestAdvance(t *testing.T) {
+	mark := Mark{}
+	for _, r := range []struct {
+		timestamp int64
+		key       string
+	}{{1000000, "a"}, {2000000, "b"}, {2000000, "c"}} {
+		mark.Advance(r.timestamp, r.key)
+	}
+	if mark.UnixTimestampMicroseconds != 2000000 || !slices.Equal(mark.KeysAtMark, []string{"b", "c"}) {
+		t.Fatalf("unexpected mark %+v", mark)
+	}
+	if mark.IsNew(2000000, "b") || mark.IsNew(1000000, "a") || !mark.IsNew(2000000, "d") {
+		t.Fatalf("advanced mark doesn't match processed records %+v", mark)
+	}
+
+	copied := mark.Copy()
+	copied.Advance(2000000, "d")
+	copied.Advance(3000000, "e")
+	if mark.UnixTimestampMicroseconds != 2000000 || !slices.Equal(mark.KeysAtMark, []string{"b", "c"}) {
+		t.Fatalf("mark is changed by it's copy %+v", mark)
+	}
+}
+
+func TestDateFrom(t *testing.T) {
+	cases := []struct {
+		mark     Mark
+		expected string
+	}{
+		{Mark{}, ""},
+		{Mark{UnixTimestampMicroseconds: 1700000000999999}, "1700000000"},
+	}
+	for _, c := range cases {
+		if c.mark.DateFrom() != c.expected {
+			t.Fatalf("expected %q, got %q", c.expected, c.mark.DateFrom())
+		}
+	}
+}
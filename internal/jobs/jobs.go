@@ -0,0 +1,381 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/audit"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/auth"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/common"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/conf"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/handler"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/logger"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/store"
+)
+
+var (
+	JOBS_BUCKET = "jobs"
+
+	DEFAULT_POLL_INTERVAL = 2 * time.Second
+	DEFAULT_RETENTION     = 7 * 24 * time.Hour
+
+	// Job waits for the runner (it's created, resumed or interrupted by the restart).
+	JOB_STATE_QUEUED  = "queued"
+	JOB_STATE_RUNNING = "running"
+	JOB_STATE_PAUSED  = "paused"
+	// Remaining items are not processed.
+	JOB_STATE_CANCELLED = "cancelled"
+	// All the items are processed successfully.
+	JOB_STATE_COMPLETED = "completed"
+	// All the items are processed, but some of them failed.
+	JOB_STATE_FAILED = "failed"
+
+	ITEM_STATE_PENDING   = "pending"
+	ITEM_STATE_SUCCEEDED = "succeeded"
+	ITEM_STATE_FAILED    = "failed"
+)
+
+var (
+	ErrJobNotFound      = errors.New("job is not found")
+	ErrJobStateConflict = errors.New("job is not in the suitable state")
+	ErrUnknownKind      = errors.New("unknown job kind")
+)
+
+type Item struct {
+	Arguments   []string  `json:"arguments"`
+	State       string    `json:"state"`
+	Code        int       `json:"code,omitempty"`
+	CompletedAt time.Time `json:"completed_at"`
+}
+
+// Long-running operation, that is split into the items.
+// Job is persisted after each item, so it's continued from the first pending item after the restart.
+// Items are processed on behalf of the job's owner.
+type Job struct {
+	ID              string            `json:"id"`
+	Kind            string            `json:"kind"`
+	State           string            `json:"state"`
+	Parameters      map[string]string `json:"parameters"`
+	Delay           time.Duration     `json:"delay"`
+	Owner           string            `json:"owner"`
+	OwnerAuthMethod string            `json:"owner_auth_method"`
+	OwnerIP         string            `json:"owner_ip"`
+	CreatedAt       time.Time         `json:"created_at"`
+	UpdatedAt       time.Time         `json:"updated_at"`
+	StartedAt       time.Time         `json:"started_at"`
+	CompletedAt     time.Time         `json:"completed_at"`
+	Items           []*Item           `json:"items"`
+}
+
+func (j *Job) Response(withItems bool) common.JobResponse {
+	response := common.JobResponse{
+		ID:         j.ID,
+		Kind:       j.Kind,
+		State:      j.State,
+		Parameters: j.Parameters,
+		Delay:      int(j.Delay / time.Second),
+		Owner:      j.Owner,
+		Count:      len(j.Items),
+		CreatedAt:  j.CreatedAt.UTC().Format(time.RFC3339),
+	}
+	if response.Parameters == nil {
+		response.Parameters = map[string]string{}
+	}
+	if !j.UpdatedAt.IsZero() {
+		response.UpdatedAt = j.UpdatedAt.UTC().Format(time.RFC3339)
+	}
+	if !j.StartedAt.IsZero() {
+		response.StartedAt = j.StartedAt.UTC().Format(time.RFC3339)
+	}
+	if !j.CompletedAt.IsZero() {
+		response.CompletedAt = j.CompletedAt.UTC().Format(time.RFC3339)
+	}
+	if withItems {
+		response.Items = []common.JobItemResponse{}
+	}
+	for _, item := range j.Items {
+		switch item.State {
+		case ITEM_STATE_SUCCEEDED:
+			response.Succeeded++
+			response.Processed++
+		case ITEM_STATE_FAILED:
+			response.Failed++
+			response.Processed++
+		}
+		if !withItems {
+			continue
+		}
+		itemResponse := common.JobItemResponse{
+			Arguments: item.Arguments,
+			State:     item.State,
+			Code:      item.Code,
+		}
+		if !item.CompletedAt.IsZero() {
+			itemResponse.CompletedAt = item.CompletedAt.UTC().Format(time.RFC3339)
+		}
+		response.Items = append(response.Items, itemResponse)
+	}
+	return response
+}
+
+func (j *Job) IsFinished() bool {
+	return j.State == JOB_STATE_CANCELLED || j.State == JOB_STATE_COMPLETED || j.State == JOB_STATE_FAILED
+}
+
+// Returns index of the first pending item or -1 if all the items are processed.
+func (j *Job) nextItem() int {
+	for i, item := range j.Items {
+		if item.State == ITEM_STATE_PENDING {
+			return i
+		}
+	}
+	return -1
+}
+
+// Sets the final state, if all the items are processed.
+func (j *Job) completeIfProcessed() {
+	if j.nextItem() != -1 {
+		return
+	}
+	j.State = JOB_STATE_COMPLETED
+	for _, item := range j.Items {
+		if item.State == ITEM_STATE_FAILED {
+			j.State = JOB_STATE_FAILED
+		}
+	}
+	j.CompletedAt = time.Now()
+}
+
+func (j *Job) ownerContext(ctx context.Context) context.Context {
+	return auth.WithCaller(ctx, &auth.Caller{
+		Name:       j.Owner,
+		AuthMethod: j.OwnerAuthMethod,
+		SourceIP:   j.OwnerIP,
+	})
+}
+
+func openJobs() (*store.Bucket, error) {
+	return store.OpenBucket(JOBS_BUCKET)
+}
+
+// Creates the job of the kind, it's items are prepared right away, so the invalid parameters
+// and the node errors are reported to the requester. Job is executed by the runner of the http mode.
+// Returns the code of the engine commands (OK if the job is created).
+func Create(
+	ctx context.Context, nodeHandler *handler.NodeHandler, kindName string, parameters map[string]string, delay time.Duration,
+) (int, *Job, error) {
+	kind, isPresent := kinds[kindName]
+	if !isPresent {
+		return handler.BAD_REQUEST, nil, ErrUnknownKind
+	}
+	err := kind.validate(parameters)
+	if err != nil {
+		return handler.BAD_REQUEST, nil, err
+	}
+	code, items := kind.Items(ctx, nodeHandler, parameters)
+	if code != handler.OK {
+		return code, nil, nil
+	}
+
+	job := &Job{
+		ID:         uuid.New().String(),
+		Kind:       kindName,
+		State:      JOB_STATE_QUEUED,
+		Parameters: parameters,
+		Delay:      delay,
+		CreatedAt:  time.Now(),
+		UpdatedAt:  time.Now(),
+		Items:      []*Item{},
+	}
+	if caller := auth.CallerFromContext(ctx); caller != nil {
+		job.Owner = caller.Name
+		job.OwnerAuthMethod = caller.AuthMethod
+		job.OwnerIP = caller.SourceIP
+	}
+	for _, arguments := range items {
+		job.Items = append(job.Items, &Item{Arguments: arguments, State: ITEM_STATE_PENDING})
+	}
+	job.completeIfProcessed()
+
+	bucket, err := openJobs()
+	if err != nil {
+		return handler.SERVER_ERROR, nil, err
+	}
+	unlock, err := bucket.Lock()
+	if err != nil {
+		return handler.SERVER_ERROR, nil, err
+	}
+	defer unlock()
+
+	removeOutdatedJobs(bucket)
+	err = bucket.Put(job.ID, job)
+	if err != nil {
+		return handler.SERVER_ERROR, nil, err
+	}
+	audit.Record(ctx, audit.OPERATION_JOB_CREATE, []string{kindName, strconv.Itoa(len(job.Items))}, job.ID, handler.CREATED)
+	wakeRunner()
+	return handler.OK, job, nil
+}
+
+func removeOutdatedJobs(bucket *store.Bucket) {
+	retention := conf.Params.Jobs.Retention
+	if retention <= 0 {
+		retention = DEFAULT_RETENTION
+	}
+	keys, err := bucket.Keys()
+	if err != nil {
+		logger.Error("Can't read jobs. Details: " + err.Error())
+		return
+	}
+	for _, key := range keys {
+		job := &Job{}
+		isPresent, err := bucket.Get(key, job)
+		if err != nil || !isPresent || !job.IsFinished() {
+			continue
+		}
+		if time.Since(job.UpdatedAt) > retention {
+			err = bucket.Delete(key)
+			if err != nil {
+				logger.Error("Can't remove job " + key + ". Details: " + err.Error())
+			}
+		}
+	}
+}
+
+func Get(jobID string) (*Job, error) {
+	bucket, err := openJobs()
+	if err != nil {
+		return nil, err
+	}
+	job := &Job{}
+	isPresent, err := bucket.Get(jobID, job)
+	if err != nil {
+		return nil, err
+	}
+	if !isPresent {
+		return nil, ErrJobNotFound
+	}
+	return job, nil
+}
+
+// Returns all the jobs from the newest ones.
+func List() ([]*Job, error) {
+	bucket, err := openJobs()
+	if err != nil {
+		return nil, err
+	}
+	keys, err := bucket.Keys()
+	if err != nil {
+		return nil, err
+	}
+
+	var jobs []*Job
+	for _, key := range keys {
+		job, err := Get(key)
+		if err != nil {
+			logger.Error("Can't read job " + key + ". Details: " + err.Error())
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].CreatedAt.After(jobs[j].CreatedAt)
+	})
+	return jobs, nil
+}
+
+// Reads the job, applies the change and writes it back under the lock of the bucket,
+// so the changes of the runner and of the control requests (from the CLI too) are not lost.
+func update(jobID string, change func(job *Job) error) (*Job, error) {
+	bucket, err := openJobs()
+	if err != nil {
+		return nil, err
+	}
+	unlock, err := bucket.Lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	job := &Job{}
+	isPresent, err := bucket.Get(jobID, job)
+	if err != nil {
+		return nil, err
+	}
+	if !isPresent {
+		return nil, ErrJobNotFound
+	}
+	err = change(job)
+	if err != nil {
+		return job, err
+	}
+	job.UpdatedAt = time.Now()
+	return job, bucket.Put(job.ID, job)
+}
+
+// Moves the job from one of the states to the new one.
+// Running job notices the change before it's next item.
+func transition(ctx context.Context, jobID string, from []string, to string, operation string) (*Job, error) {
+	job, err := update(jobID, func(job *Job) error {
+		for _, state := range from {
+			if job.State == state {
+				job.State = to
+				if to == JOB_STATE_CANCELLED {
+					job.CompletedAt = time.Now()
+				}
+				return nil
+			}
+		}
+		return ErrJobStateConflict
+	})
+	code := handler.OK
+	switch err {
+	case nil:
+	case ErrJobNotFound:
+		return nil, err
+	case ErrJobStateConflict:
+		code = handler.CONFLICT
+	default:
+		code = handler.SERVER_ERROR
+	}
+	audit.Record(ctx, operation, nil, jobID, code)
+	if err == nil {
+		wakeRunner()
+	}
+	return job, err
+}
+
+// Stops the job before it's next item, it could be resumed later.
+func Pause(ctx context.Context, jobID string) (*Job, error) {
+	return transition(ctx, jobID, []string{JOB_STATE_QUEUED, JOB_STATE_RUNNING}, JOB_STATE_PAUSED, audit.OPERATION_JOB_PAUSE)
+}
+
+// Queues the paused job again. Failed items of the failed job are processed again.
+func Resume(ctx context.Context, jobID string) (*Job, error) {
+	job, err := update(jobID, func(job *Job) error {
+		if job.State == JOB_STATE_FAILED {
+			for _, item := range job.Items {
+				if item.State == ITEM_STATE_FAILED {
+					item.State = ITEM_STATE_PENDING
+				}
+			}
+			job.State = JOB_STATE_PAUSED
+			job.CompletedAt = time.Time{}
+		}
+		return nil
+	})
+	if err != nil {
+		return job, err
+	}
+	return transition(ctx, jobID, []string{JOB_STATE_PAUSED}, JOB_STATE_QUEUED, audit.OPERATION_JOB_RESUME)
+}
+
+// Stops the job before it's next item, remaining items are not processed.
+func Cancel(ctx context.Context, jobID string) (*Job, error) {
+	return transition(ctx, jobID,
+		[]string{JOB_STATE_QUEUED, JOB_STATE_RUNNING, JOB_STATE_PAUSED}, JOB_STATE_CANCELLED, audit.OPERATION_JOB_CANCEL)
+}
@@ -0,0 +1,86 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"slices"
+
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/handler"
+)
+
+var (
+	// Keys of each settlement line are regenerated (SET:contractors/trust-line-keys).
+	KIND_REGENERATE_KEYS = "regenerate-keys"
+	// Outdated crypto data of the engine is removed (DELETE:outdated-crypto), parameter "vacuum" is "0" or "1".
+	KIND_REMOVE_OUTDATED_CRYPTO = "remove-outdated-crypto"
+)
+
+// Kind of the job defines it's parameters, items and how each item is processed.
+type Kind struct {
+	// Names of the parameters, other parameters are rejected.
+	Parameters []string
+	// Optional. Checks values of the parameters.
+	Validate func(parameters map[string]string) error
+	// Returns arguments of the items and the code of the engine commands (OK if the items are prepared).
+	Items func(ctx context.Context, nodeHandler *handler.NodeHandler, parameters map[string]string) (int, [][]string)
+	// Processes the item, returns the code of the engine command (OK if the item succeeded).
+	Process func(ctx context.Context, nodeHandler *handler.NodeHandler, parameters map[string]string, arguments []string) int
+}
+
+func (k Kind) validate(parameters map[string]string) error {
+	for name := range parameters {
+		if !slices.Contains(k.Parameters, name) {
+			return errors.New("unknown parameter " + name)
+		}
+	}
+	if k.Validate != nil {
+		return k.Validate(parameters)
+	}
+	return nil
+}
+
+var kinds = map[string]Kind{
+	KIND_REGENERATE_KEYS: {
+		Items: func(ctx context.Context, nodeHandler *handler.NodeHandler, parameters map[string]string) (int, [][]string) {
+			code, equivalents := nodeHandler.AllSettlementLines(ctx)
+			if code != handler.OK {
+				return code, nil
+			}
+			var items [][]string
+			for _, equivalent := range equivalents {
+				for _, settlementLine := range equivalent.SettlementLines {
+					items = append(items, []string{settlementLine.ID, equivalent.Eq})
+				}
+			}
+			return handler.OK, items
+		},
+		Process: func(
+			ctx context.Context, nodeHandler *handler.NodeHandler, parameters map[string]string, arguments []string,
+		) int {
+			return nodeHandler.RegenerateSettlementLineKeys(ctx, arguments[0], arguments[1])
+		},
+	},
+
+	KIND_REMOVE_OUTDATED_CRYPTO: {
+		Parameters: []string{"vacuum"},
+		Validate: func(parameters map[string]string) error {
+			vacuum := parameters["vacuum"]
+			if vacuum != "" && vacuum != "0" && vacuum != "1" {
+				return errors.New("invalid vacuum parameter")
+			}
+			return nil
+		},
+		Items: func(ctx context.Context, nodeHandler *handler.NodeHandler, parameters map[string]string) (int, [][]string) {
+			vacuum := parameters["vacuum"]
+			if vacuum == "" {
+				vacuum = "0"
+			}
+			return handler.OK, [][]string{{vacuum}}
+		},
+		Process: func(
+			ctx context.Context, nodeHandler *handler.NodeHandler, parameters map[string]string, arguments []string,
+		) int {
+			return nodeHandler.RemoveOutdatedCryptoData(ctx, arguments[0])
+		},
+	},
+}
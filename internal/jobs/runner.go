@@ -0,0 +1,175 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/conf"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/handler"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/logger"
+)
+
+// Wakes the runner up, when the job is created or it's state is changed by this process.
+// Changes of the other processes (CLI) are noticed by the polling.
+var wake = make(chan struct{}, 1)
+
+func wakeRunner() {
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+}
+
+func pollInterval() time.Duration {
+	if conf.Params.Jobs.PollInterval <= 0 {
+		return DEFAULT_POLL_INTERVAL
+	}
+	return conf.Params.Jobs.PollInterval
+}
+
+// Starts the runner of the jobs. Jobs are executed one by one, from the oldest one.
+// Jobs, that were running before the restart, are queued again (item, that was in progress, is processed again).
+func Start(nodeHandler *handler.NodeHandler) error {
+	jobs, err := List()
+	if err != nil {
+		return err
+	}
+	for _, job := range jobs {
+		if job.State != JOB_STATE_RUNNING {
+			continue
+		}
+		_, err = update(job.ID, func(job *Job) error {
+			if job.State == JOB_STATE_RUNNING {
+				job.State = JOB_STATE_QUEUED
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		logger.Info("Interrupted job " + job.ID + " is queued again")
+	}
+
+	go func() {
+		for {
+			job, err := nextQueuedJob()
+			if err != nil {
+				logger.Error("Can't read jobs. Details: " + err.Error())
+			}
+			if job == nil {
+				select {
+				case <-wake:
+				case <-time.After(pollInterval()):
+				}
+				continue
+			}
+			run(nodeHandler, job.ID)
+		}
+	}()
+	logger.Info("Jobs runner started")
+	return nil
+}
+
+func nextQueuedJob() (*Job, error) {
+	jobs, err := List()
+	if err != nil {
+		return nil, err
+	}
+	for i := len(jobs) - 1; i >= 0; i-- {
+		if jobs[i].State == JOB_STATE_QUEUED {
+			return jobs[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// Processes pending items of the job until it's finished, paused or cancelled.
+func run(nodeHandler *handler.NodeHandler, jobID string) {
+	job, err := update(jobID, func(job *Job) error {
+		if job.State != JOB_STATE_QUEUED {
+			return ErrJobStateConflict
+		}
+		job.State = JOB_STATE_RUNNING
+		if job.StartedAt.IsZero() {
+			job.StartedAt = time.Now()
+		}
+		return nil
+	})
+	if err == ErrJobStateConflict {
+		return
+	}
+	if err != nil {
+		logger.Error("Can't start job " + jobID + ". Details: " + err.Error())
+		return
+	}
+
+	kind, isPresent := kinds[job.Kind]
+	if !isPresent {
+		logger.Error("Job " + jobID + " has unknown kind " + job.Kind)
+		_, err = update(jobID, func(job *Job) error {
+			job.State = JOB_STATE_FAILED
+			job.CompletedAt = time.Now()
+			return nil
+		})
+		if err != nil {
+			logger.Error("Can't write job " + jobID + ". Details: " + err.Error())
+		}
+		return
+	}
+	logger.Info("Job " + jobID + " (" + job.Kind + ") is running")
+
+	ctx := job.ownerContext(context.Background())
+	for {
+		idx := job.nextItem()
+		if idx == -1 {
+			break
+		}
+		code := kind.Process(ctx, nodeHandler, job.Parameters, job.Items[idx].Arguments)
+		job, err = update(jobID, func(job *Job) error {
+			item := job.Items[idx]
+			item.State = ITEM_STATE_SUCCEEDED
+			if code != handler.OK {
+				item.State = ITEM_STATE_FAILED
+			}
+			item.Code = code
+			item.CompletedAt = time.Now()
+			if job.State == JOB_STATE_RUNNING {
+				job.completeIfProcessed()
+			}
+			return nil
+		})
+		if err != nil {
+			logger.Error("Can't write job " + jobID + ". Details: " + err.Error())
+			return
+		}
+		if job.State != JOB_STATE_RUNNING {
+			break
+		}
+
+		job, err = wait(jobID, job.Delay)
+		if err != nil {
+			logger.Error("Can't read job " + jobID + ". Details: " + err.Error())
+			return
+		}
+		if job.State != JOB_STATE_RUNNING {
+			break
+		}
+	}
+	logger.Info("Job " + jobID + " is " + job.State)
+}
+
+// Pauses between the items. Changes of the job's state, that are made by this process, interrupt the pause.
+// Returns the actual job.
+func wait(jobID string, delay time.Duration) (*Job, error) {
+	deadline := time.Now().Add(delay)
+	for {
+		job, err := Get(jobID)
+		if err != nil || job.State != JOB_STATE_RUNNING || !time.Now().Before(deadline) {
+			return job, err
+		}
+		select {
+		case <-wake:
+		case <-time.After(min(time.Until(deadline), pollInterval())):
+		}
+	}
+}
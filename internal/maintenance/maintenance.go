@@ -0,0 +1,350 @@
+package maintenance
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/auth"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/common"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/conf"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/handler"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/jobs"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/logger"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/scheduler"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/store"
+)
+
+var (
+	MAINTENANCE_BUCKET = "maintenance"
+
+	DEFAULT_TICK_INTERVAL = 30 * time.Second
+	DEFAULT_MISFIRE_GRACE = 15 * time.Minute
+	DEFAULT_KEYS_DELAY    = 5 * time.Second
+
+	// Job of the task is created.
+	OUTCOME_STARTED = "started"
+	// Run could not be started in time.
+	OUTCOME_SKIPPED = "skipped"
+	// Job of the task could not be created (e.g. node is inaccessible).
+	OUTCOME_FAILED = "failed"
+
+	REASON_OUTSIDE_WINDOW     = "outside of the maintenance window"
+	REASON_PAYMENTS_IN_FLIGHT = "payments are in flight"
+	REASON_JOB_IS_NOT_DONE    = "previous job of the task is not finished"
+	REASON_MISSED             = "run is missed"
+)
+
+// Task is executed as the background job of the same kind.
+type task struct {
+	Kind       string
+	Cron       string
+	Parameters map[string]string
+	Delay      time.Duration
+}
+
+// Persisted state of the task, so the runs are not repeated or lost after the restart.
+type taskStatus struct {
+	Task string `json:"task"`
+	// Cron, by which the next run was planned. Next run is planned again, if it's changed.
+	Cron        string    `json:"cron"`
+	NextRunAt   time.Time `json:"next_run_at"`
+	LastRunAt   time.Time `json:"last_run_at"`
+	LastOutcome string    `json:"last_outcome"`
+	LastReason  string    `json:"last_reason"`
+	LastCode    int       `json:"last_code"`
+	LastJobID   string    `json:"last_job_id"`
+}
+
+func (s *taskStatus) response() common.MaintenanceTaskResponse {
+	response := common.MaintenanceTaskResponse{
+		Task:        s.Task,
+		Cron:        s.Cron,
+		LastOutcome: s.LastOutcome,
+		LastReason:  s.LastReason,
+		LastCode:    s.LastCode,
+		LastJobID:   s.LastJobID,
+	}
+	if !s.NextRunAt.IsZero() {
+		response.NextRunAt = s.NextRunAt.UTC().Format(time.RFC3339)
+	}
+	if !s.LastRunAt.IsZero() {
+		response.LastRunAt = s.LastRunAt.UTC().Format(time.RFC3339)
+	}
+	if s.LastJobID != "" {
+		job, err := jobs.Get(s.LastJobID)
+		if err == nil {
+			response.LastJobState = job.State
+		}
+	}
+	return response
+}
+
+// Returns the tasks, that are enabled by the settings.
+func tasks() []task {
+	settings := conf.Params.Maintenance
+	var result []task
+	if settings.RemoveOutdatedCrypto.Cron != "" {
+		vacuum := "0"
+		if settings.RemoveOutdatedCrypto.Vacuum {
+			vacuum = "1"
+		}
+		result = append(result, task{
+			Kind:       jobs.KIND_REMOVE_OUTDATED_CRYPTO,
+			Cron:       settings.RemoveOutdatedCrypto.Cron,
+			Parameters: map[string]string{"vacuum": vacuum},
+		})
+	}
+	if settings.RegenerateKeys.Cron != "" {
+		delay := settings.RegenerateKeys.Delay
+		if delay <= 0 {
+			delay = DEFAULT_KEYS_DELAY
+		}
+		result = append(result, task{
+			Kind:  jobs.KIND_REGENERATE_KEYS,
+			Cron:  settings.RegenerateKeys.Cron,
+			Delay: delay,
+		})
+	}
+	return result
+}
+
+func IsEnabled() bool {
+	return len(tasks()) > 0
+}
+
+// Checks settings of the maintenance tasks.
+func ValidateSettings() error {
+	settings := conf.Params.Maintenance
+	if (settings.WindowStart == "") != (settings.WindowEnd == "") {
+		return errors.New("both window_start and window_end are required")
+	}
+	if settings.WindowStart != "" {
+		_, err := parseWindowTime(settings.WindowStart)
+		if err != nil {
+			return errors.New("invalid window_start: " + err.Error())
+		}
+		_, err = parseWindowTime(settings.WindowEnd)
+		if err != nil {
+			return errors.New("invalid window_end: " + err.Error())
+		}
+	}
+	if settings.MisfireGrace < 0 {
+		return errors.New("invalid misfire_grace")
+	}
+	for _, task := range tasks() {
+		_, err := scheduler.NextCronTime(task.Cron, time.Now())
+		if err != nil {
+			return errors.New("invalid cron of the " + task.Kind + " task: " + err.Error())
+		}
+	}
+	return nil
+}
+
+// Returns minutes since the midnight of the "HH:MM" time.
+func parseWindowTime(value string) (int, error) {
+	hours, minutes, isPresent := strings.Cut(value, ":")
+	if !isPresent {
+		return 0, errors.New("time must be in HH:MM format")
+	}
+	h, err := strconv.Atoi(hours)
+	if err != nil || h < 0 || h > 23 {
+		return 0, errors.New("invalid hours")
+	}
+	m, err := strconv.Atoi(minutes)
+	if err != nil || m < 0 || m > 59 {
+		return 0, errors.New("invalid minutes")
+	}
+	return h*60 + m, nil
+}
+
+// Returns true if the time is inside the maintenance window (or the window is not set).
+func isInWindow(t time.Time) bool {
+	settings := conf.Params.Maintenance
+	if settings.WindowStart == "" {
+		return true
+	}
+	start, err := parseWindowTime(settings.WindowStart)
+	if err != nil {
+		return false
+	}
+	end, err := parseWindowTime(settings.WindowEnd)
+	if err != nil {
+		return false
+	}
+	t = t.UTC()
+	minutes := t.Hour()*60 + t.Minute()
+	if start <= end {
+		return minutes >= start && minutes < end
+	}
+	// Window crosses the midnight.
+	return minutes >= start || minutes < end
+}
+
+// Returns status of the enabled tasks.
+func Status() (common.MaintenanceResponse, error) {
+	response := common.MaintenanceResponse{
+		WindowStart:      conf.Params.Maintenance.WindowStart,
+		WindowEnd:        conf.Params.Maintenance.WindowEnd,
+		InWindow:         isInWindow(time.Now()),
+		PaymentsInFlight: handler.PaymentsInFlight(),
+		Tasks:            []common.MaintenanceTaskResponse{},
+	}
+	bucket, err := store.OpenBucket(MAINTENANCE_BUCKET)
+	if err != nil {
+		return response, err
+	}
+	for _, task := range tasks() {
+		status := &taskStatus{}
+		_, err = bucket.Get(task.Kind, status)
+		if err != nil {
+			return response, err
+		}
+		if status.Cron != task.Cron {
+			// Task is not planned by the new cron yet.
+			status = &taskStatus{Task: task.Kind, Cron: task.Cron}
+		}
+		response.Tasks = append(response.Tasks, status.response())
+	}
+	return response, nil
+}
+
+// --- Execution ---
+
+type maintenance struct {
+	nodeHandler *handler.NodeHandler
+	statuses    *store.Bucket
+}
+
+// Starts execution of the maintenance tasks in the background.
+func Start(nodeHandler *handler.NodeHandler) error {
+	statuses, err := store.OpenBucket(MAINTENANCE_BUCKET)
+	if err != nil {
+		return err
+	}
+	m := &maintenance{nodeHandler: nodeHandler, statuses: statuses}
+
+	interval := conf.Params.Maintenance.TickInterval
+	if interval <= 0 {
+		interval = DEFAULT_TICK_INTERVAL
+	}
+	go func() {
+		for {
+			m.tick(time.Now())
+			time.Sleep(interval)
+		}
+	}()
+	logger.Info("Maintenance started, tick interval " + interval.String())
+	return nil
+}
+
+func (m *maintenance) misfireGrace() time.Duration {
+	if conf.Params.Maintenance.MisfireGrace <= 0 {
+		return DEFAULT_MISFIRE_GRACE
+	}
+	return conf.Params.Maintenance.MisfireGrace
+}
+
+func (m *maintenance) tick(now time.Time) {
+	for _, task := range tasks() {
+		m.process(task, now)
+	}
+}
+
+// Starts (or skips) the run of the task, if it's due.
+// Run, that can't be started right now, is retried by the next ticks until the misfire grace is over.
+func (m *maintenance) process(task task, now time.Time) {
+	status := &taskStatus{}
+	_, err := m.statuses.Get(task.Kind, status)
+	if err != nil {
+		logger.Error("Can't read status of the maintenance task " + task.Kind + ". Details: " + err.Error())
+		return
+	}
+	if status.Cron != task.Cron || status.NextRunAt.IsZero() {
+		status.Task = task.Kind
+		status.Cron = task.Cron
+		m.advance(status, now)
+		return
+	}
+	if status.NextRunAt.After(now) {
+		return
+	}
+
+	isLate := now.Sub(status.NextRunAt) > m.misfireGrace()
+	reason := m.obstacle(task, now)
+	if reason != "" && !isLate {
+		return
+	}
+	if reason == "" && isLate {
+		reason = REASON_MISSED
+	}
+
+	status.LastRunAt = status.NextRunAt
+	status.LastCode = 0
+	status.LastJobID = ""
+	if reason != "" {
+		logger.Info("Maintenance task " + task.Kind + " is skipped: " + reason)
+		status.LastOutcome = OUTCOME_SKIPPED
+		status.LastReason = reason
+		m.advance(status, now)
+		return
+	}
+
+	ctx := auth.WithCaller(context.Background(), &auth.Caller{
+		Name:       "maintenance",
+		AuthMethod: auth.AUTH_METHOD_MAINTENANCE,
+		SourceIP:   "maintenance",
+	})
+	code, job, err := jobs.Create(ctx, m.nodeHandler, task.Kind, task.Parameters, task.Delay)
+	status.LastCode = code
+	status.LastReason = ""
+	if code != handler.OK {
+		status.LastOutcome = OUTCOME_FAILED
+		status.LastReason = "node returned code " + strconv.Itoa(code)
+		if err != nil {
+			status.LastReason = err.Error()
+		}
+		logger.Error("Maintenance task " + task.Kind + " failed: " + status.LastReason)
+	} else {
+		status.LastOutcome = OUTCOME_STARTED
+		status.LastJobID = job.ID
+		logger.Info("Maintenance task " + task.Kind + " started job " + job.ID)
+	}
+	m.advance(status, now)
+}
+
+// Returns the reason, why the task can't be started right now, or empty string.
+func (m *maintenance) obstacle(task task, now time.Time) string {
+	if !isInWindow(now) {
+		return REASON_OUTSIDE_WINDOW
+	}
+	if handler.PaymentsInFlight() > 0 {
+		return REASON_PAYMENTS_IN_FLIGHT
+	}
+	allJobs, err := jobs.List()
+	if err != nil {
+		logger.Error("Can't read jobs. Details: " + err.Error())
+		return REASON_JOB_IS_NOT_DONE
+	}
+	for _, job := range allJobs {
+		if job.Kind == task.Kind && !job.IsFinished() {
+			return REASON_JOB_IS_NOT_DONE
+		}
+	}
+	return ""
+}
+
+// Plans the next run of the task after the passed time and saves the status.
+func (m *maintenance) advance(status *taskStatus, now time.Time) {
+	nextRunAt, err := scheduler.NextCronTime(status.Cron, now)
+	if err != nil {
+		logger.Error("Invalid cron of the maintenance task " + status.Task + ". Details: " + err.Error())
+	}
+	status.NextRunAt = nextRunAt
+	err = m.statuses.Put(status.Task, status)
+	if err != nil {
+		logger.Error("Can't write status of the maintenance task " + status.Task + ". Details: " + err.Error())
+	}
+}
@@ -0,0 +1,522 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/audit"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/auth"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/common"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/conf"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/logger"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/store"
+)
+
+var (
+	SPENDING_BUCKET = "spending"
+
+	// Codes of the policies violations.
+	VIOLATION_PAYEE_DENIED         = "payee_denied"
+	VIOLATION_PAYEE_NOT_ALLOWED    = "payee_not_allowed"
+	VIOLATION_PAYLOAD_REQUIRED     = "payload_required"
+	VIOLATION_PAYLOAD_INVALID      = "payload_invalid"
+	VIOLATION_MAX_AMOUNT_EXCEEDED  = "max_amount_exceeded"
+	VIOLATION_DAILY_CAP_EXCEEDED   = "daily_cap_exceeded"
+	VIOLATION_MONTHLY_CAP_EXCEEDED = "monthly_cap_exceeded"
+
+	// Decisions are written to the audit log with the result codes.
+	DECISION_ALLOW             = "allow"
+	DECISION_DENY              = "deny"
+	DECISION_ALLOW_RESULT_CODE = 200
+	DECISION_DENY_RESULT_CODE  = 403
+)
+
+// Outgoing payment, that is checked by the policies.
+type Payment struct {
+	CommandUUID string
+	// Addresses of the payee in the form "<type>-<address>".
+	ContractorAddresses []string
+	Amount              string
+	Equivalent          string
+	Payload             string
+}
+
+type Violation struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type limits struct {
+	maxAmount  *big.Int
+	dailyCap   *big.Int
+	monthlyCap *big.Int
+}
+
+// Parsed settings of the policies.
+type policies struct {
+	equivalents map[string]*limits
+	// Limits by the callers and the equivalents.
+	callers        map[string]map[string]*limits
+	allowedPayees  map[string]bool
+	deniedPayees   map[string]bool
+	payloadPattern *regexp.Regexp
+}
+
+var (
+	loadedPolicies   *policies
+	loadingError     error
+	loadPoliciesOnce sync.Once
+)
+
+func parseAmount(value, name string) (*big.Int, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if !common.ValidateSettlementLineAmount(value) {
+		return nil, errors.New("invalid " + name + " " + value)
+	}
+	amount, _ := new(big.Int).SetString(value, 10)
+	return amount, nil
+}
+
+func parseLimits(settings conf.PaymentLimitsSettings) (*limits, error) {
+	maxAmount, err := parseAmount(settings.MaxAmount, "max_amount")
+	if err != nil {
+		return nil, err
+	}
+	dailyCap, err := parseAmount(settings.DailyCap, "daily_cap")
+	if err != nil {
+		return nil, err
+	}
+	monthlyCap, err := parseAmount(settings.MonthlyCap, "monthly_cap")
+	if err != nil {
+		return nil, err
+	}
+	return &limits{maxAmount: maxAmount, dailyCap: dailyCap, monthlyCap: monthlyCap}, nil
+}
+
+var typedAddressPattern = regexp.MustCompile(`^[0-9]+-.+$`)
+
+// Payees could be set in the CLI form ("ipv4:127.0.0.1:2000") or in the HTTP API form ("12-127.0.0.1:2000").
+func parsePayees(values []string) (map[string]bool, error) {
+	payees := make(map[string]bool)
+	for _, value := range values {
+		if typedAddressPattern.MatchString(value) {
+			payees[value] = true
+			continue
+		}
+		addressType, address := common.ValidateAddress(value)
+		if addressType == "" {
+			return nil, errors.New("invalid payee address " + value)
+		}
+		payees[addressType+"-"+address] = true
+	}
+	return payees, nil
+}
+
+func parsePolicies() (*policies, error) {
+	settings := conf.Params.Payments.Policies
+	p := &policies{
+		equivalents: make(map[string]*limits),
+		callers:     make(map[string]map[string]*limits),
+	}
+
+	for equivalent, limitsSettings := range settings.Equivalents {
+		equivalentLimits, err := parseLimits(limitsSettings)
+		if err != nil {
+			return nil, errors.New("equivalent " + equivalent + ": " + err.Error())
+		}
+		p.equivalents[equivalent] = equivalentLimits
+	}
+
+	for _, callerSettings := range settings.Callers {
+		if callerSettings.Caller == "" {
+			return nil, errors.New("caller policy without caller")
+		}
+		if _, isPresent := p.callers[callerSettings.Caller]; isPresent {
+			return nil, errors.New("duplicated policy of the caller " + callerSettings.Caller)
+		}
+		p.callers[callerSettings.Caller] = make(map[string]*limits)
+		for equivalent, limitsSettings := range callerSettings.Equivalents {
+			callerLimits, err := parseLimits(limitsSettings)
+			if err != nil {
+				return nil, errors.New("caller " + callerSettings.Caller + ", equivalent " + equivalent + ": " + err.Error())
+			}
+			p.callers[callerSettings.Caller][equivalent] = callerLimits
+		}
+	}
+
+	var err error
+	p.allowedPayees, err = parsePayees(settings.AllowedPayees)
+	if err != nil {
+		return nil, err
+	}
+	p.deniedPayees, err = parsePayees(settings.DeniedPayees)
+	if err != nil {
+		return nil, err
+	}
+
+	if settings.Payload.Pattern != "" {
+		p.payloadPattern, err = regexp.Compile(settings.Payload.Pattern)
+		if err != nil {
+			return nil, errors.New("invalid payload pattern -> " + err.Error())
+		}
+	}
+	return p, nil
+}
+
+func loadPolicies() (*policies, error) {
+	loadPoliciesOnce.Do(func() {
+		loadedPolicies, loadingError = parsePolicies()
+	})
+	return loadedPolicies, loadingError
+}
+
+// Checks policies settings.
+func ValidateSettings() error {
+	_, err := loadPolicies()
+	return err
+}
+
+// Returns true if at least one policy is configured.
+func isConfigured() bool {
+	settings := conf.Params.Payments.Policies
+	return len(settings.Equivalents) > 0 || len(settings.Callers) > 0 ||
+		len(settings.AllowedPayees) > 0 || len(settings.DeniedPayees) > 0 ||
+		settings.Payload.Required || settings.Payload.Pattern != "" || settings.Payload.MaxLength > 0
+}
+
+func (p *policies) checkPayees(payment *Payment) *Violation {
+	for _, address := range payment.ContractorAddresses {
+		if p.deniedPayees[address] {
+			return &Violation{Code: VIOLATION_PAYEE_DENIED, Message: "payee address " + address + " is denied"}
+		}
+	}
+	if len(p.allowedPayees) == 0 {
+		return nil
+	}
+	for _, address := range payment.ContractorAddresses {
+		if !p.allowedPayees[address] {
+			return &Violation{Code: VIOLATION_PAYEE_NOT_ALLOWED, Message: "payee address " + address + " is not allowed"}
+		}
+	}
+	return nil
+}
+
+func (p *policies) checkPayload(payment *Payment) *Violation {
+	settings := conf.Params.Payments.Policies.Payload
+	if payment.Payload == "" {
+		if settings.Required {
+			return &Violation{Code: VIOLATION_PAYLOAD_REQUIRED, Message: "payload is required"}
+		}
+		return nil
+	}
+	if settings.MaxLength > 0 && len(payment.Payload) > settings.MaxLength {
+		return &Violation{Code: VIOLATION_PAYLOAD_INVALID, Message: "payload is too long"}
+	}
+	if p.payloadPattern != nil && !p.payloadPattern.MatchString(payment.Payload) {
+		return &Violation{Code: VIOLATION_PAYLOAD_INVALID, Message: "payload doesn't match the required pattern"}
+	}
+	return nil
+}
+
+func checkMaxAmount(l *limits, amount *big.Int, subject string) *Violation {
+	if l == nil || l.maxAmount == nil || amount.Cmp(l.maxAmount) <= 0 {
+		return nil
+	}
+	return &Violation{
+		Code:    VIOLATION_MAX_AMOUNT_EXCEEDED,
+		Message: "amount exceeds max amount " + l.maxAmount.String() + " of the " + subject,
+	}
+}
+
+// Checks the payment and reserves it's amount in the spending counters.
+// Returns violation if the payment is not allowed, error if the policies could not be checked.
+// Reservation must be released if the payment was not done.
+func Check(ctx context.Context, payment Payment) (*Reservation, *Violation, error) {
+	if !isConfigured() {
+		return nil, nil, nil
+	}
+	p, err := loadPolicies()
+	if err != nil {
+		return nil, nil, errors.New("invalid payment policies -> " + err.Error())
+	}
+
+	caller := auth.CallerFromContext(ctx)
+	if caller == nil {
+		caller = auth.LocalCaller()
+	}
+
+	reservation, violation, err := p.check(caller.Name, &payment, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	recordDecision(ctx, &payment, violation)
+	return reservation, violation, nil
+}
+
+// Checks the payment in the same way as Check(), but the amount is not reserved
+// and the decision is not written to the audit log (e.g. for the payment quotes).
+func Evaluate(ctx context.Context, payment Payment) (*Violation, error) {
+	if !isConfigured() {
+		return nil, nil
+	}
+	p, err := loadPolicies()
+	if err != nil {
+		return nil, errors.New("invalid payment policies -> " + err.Error())
+	}
+
+	caller := auth.CallerFromContext(ctx)
+	if caller == nil {
+		caller = auth.LocalCaller()
+	}
+
+	_, violation, err := p.check(caller.Name, &payment, false)
+	return violation, err
+}
+
+func (p *policies) check(callerName string, payment *Payment, isReserved bool) (*Reservation, *Violation, error) {
+	violation := p.checkPayees(payment)
+	if violation != nil {
+		return nil, violation, nil
+	}
+	violation = p.checkPayload(payment)
+	if violation != nil {
+		return nil, violation, nil
+	}
+
+	amount, isParsed := new(big.Int).SetString(payment.Amount, 10)
+	if !isParsed {
+		return nil, nil, errors.New("invalid amount " + payment.Amount)
+	}
+	equivalentLimits := p.equivalents[payment.Equivalent]
+	callerLimits := p.callers[callerName][payment.Equivalent]
+	violation = checkMaxAmount(equivalentLimits, amount, "equivalent")
+	if violation != nil {
+		return nil, violation, nil
+	}
+	violation = checkMaxAmount(callerLimits, amount, "caller")
+	if violation != nil {
+		return nil, violation, nil
+	}
+
+	return reserve(callerName, payment.Equivalent, amount, equivalentLimits, callerLimits, isReserved)
+}
+
+func recordDecision(ctx context.Context, payment *Payment, violation *Violation) {
+	arguments := []string{DECISION_ALLOW, ""}
+	resultCode := DECISION_ALLOW_RESULT_CODE
+	if violation != nil {
+		arguments = []string{DECISION_DENY, violation.Code}
+		resultCode = DECISION_DENY_RESULT_CODE
+		logger.Info("Payment " + payment.CommandUUID + " is rejected by the policy: " + violation.Message)
+	}
+	arguments = append(arguments, payment.Equivalent, payment.Amount)
+	arguments = append(arguments, payment.ContractorAddresses...)
+	audit.Record(ctx, audit.OPERATION_PAYMENT_POLICY, arguments, payment.CommandUUID, resultCode)
+}
+
+// Spent amounts of the period (day or month).
+type spending struct {
+	// Spent amounts by the equivalents.
+	Equivalents map[string]string `json:"equivalents"`
+	// Spent amounts by the callers and the equivalents.
+	Callers map[string]map[string]string `json:"callers"`
+}
+
+func spendingKeys(now time.Time) (string, string) {
+	now = now.UTC()
+	return "daily-" + now.Format("2006-01-02"), "monthly-" + now.Format("2006-01")
+}
+
+func readSpending(bucket *store.Bucket, key string) (*spending, bool, error) {
+	s := &spending{}
+	isPresent, err := bucket.Get(key, s)
+	if err != nil {
+		return nil, false, err
+	}
+	if s.Equivalents == nil {
+		s.Equivalents = make(map[string]string)
+	}
+	if s.Callers == nil {
+		s.Callers = make(map[string]map[string]string)
+	}
+	return s, isPresent, nil
+}
+
+func amountOf(value string) *big.Int {
+	amount, isParsed := new(big.Int).SetString(value, 10)
+	if !isParsed {
+		return new(big.Int)
+	}
+	return amount
+}
+
+// Adds amount (that could be negative) to the spent amounts of the equivalent and the caller.
+func (s *spending) add(callerName, equivalent string, amount *big.Int) {
+	total := new(big.Int).Add(amountOf(s.Equivalents[equivalent]), amount)
+	if total.Sign() < 0 {
+		total.SetInt64(0)
+	}
+	s.Equivalents[equivalent] = total.String()
+
+	if s.Callers[callerName] == nil {
+		s.Callers[callerName] = make(map[string]string)
+	}
+	total = new(big.Int).Add(amountOf(s.Callers[callerName][equivalent]), amount)
+	if total.Sign() < 0 {
+		total.SetInt64(0)
+	}
+	s.Callers[callerName][equivalent] = total.String()
+}
+
+func checkCap(cap *big.Int, spent string, amount *big.Int, code, name, subject string) *Violation {
+	if cap == nil {
+		return nil
+	}
+	if new(big.Int).Add(amountOf(spent), amount).Cmp(cap) <= 0 {
+		return nil
+	}
+	return &Violation{Code: code, Message: "payment exceeds " + name + " " + cap.String() +
+		" of the " + subject + " (already spent " + amountOf(spent).String() + ")"}
+}
+
+// Amount of the allowed payment, that is counted in the daily and monthly spent amounts.
+type Reservation struct {
+	callerName string
+	equivalent string
+	amount     *big.Int
+	dailyKey   string
+	monthlyKey string
+}
+
+// Checks the spending caps and, if isReserved is set, adds the amount to the spending counters.
+func reserve(
+	callerName, equivalent string, amount *big.Int, equivalentLimits, callerLimits *limits, isReserved bool,
+) (*Reservation, *Violation, error) {
+	bucket, err := store.OpenBucket(SPENDING_BUCKET)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Spending is shared with the CLI commands, so the counters are changed under the bucket lock.
+	unlock, err := bucket.Lock()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer unlock()
+
+	dailyKey, monthlyKey := spendingKeys(time.Now())
+	daily, isDailyPresent, err := readSpending(bucket, dailyKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	monthly, isMonthlyPresent, err := readSpending(bucket, monthlyKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	noLimits := &limits{}
+	if equivalentLimits == nil {
+		equivalentLimits = noLimits
+	}
+	if callerLimits == nil {
+		callerLimits = noLimits
+	}
+	for _, violation := range []*Violation{
+		checkCap(equivalentLimits.dailyCap, daily.Equivalents[equivalent], amount,
+			VIOLATION_DAILY_CAP_EXCEEDED, "daily cap", "equivalent"),
+		checkCap(equivalentLimits.monthlyCap, monthly.Equivalents[equivalent], amount,
+			VIOLATION_MONTHLY_CAP_EXCEEDED, "monthly cap", "equivalent"),
+		checkCap(callerLimits.dailyCap, daily.Callers[callerName][equivalent], amount,
+			VIOLATION_DAILY_CAP_EXCEEDED, "daily cap", "caller"),
+		checkCap(callerLimits.monthlyCap, monthly.Callers[callerName][equivalent], amount,
+			VIOLATION_MONTHLY_CAP_EXCEEDED, "monthly cap", "caller"),
+	} {
+		if violation != nil {
+			return nil, violation, nil
+		}
+	}
+	if !isReserved {
+		return nil, nil, nil
+	}
+
+	daily.add(callerName, equivalent, amount)
+	monthly.add(callerName, equivalent, amount)
+	err = bucket.Put(dailyKey, daily)
+	if err == nil {
+		err = bucket.Put(monthlyKey, monthly)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Counters of the previous periods are not needed anymore.
+	if !isDailyPresent || !isMonthlyPresent {
+		removeOutdatedSpending(bucket, dailyKey, monthlyKey)
+	}
+
+	return &Reservation{
+		callerName: callerName,
+		equivalent: equivalent,
+		amount:     amount,
+		dailyKey:   dailyKey,
+		monthlyKey: monthlyKey,
+	}, nil, nil
+}
+
+func removeOutdatedSpending(bucket *store.Bucket, dailyKey, monthlyKey string) {
+	keys, err := bucket.Keys()
+	if err != nil {
+		logger.Error("Can't read spending counters. Details: " + err.Error())
+		return
+	}
+	for _, key := range keys {
+		if key == dailyKey || key == monthlyKey {
+			continue
+		}
+		err = bucket.Delete(key)
+		if err != nil {
+			logger.Error("Can't remove spending counters " + key + ". Details: " + err.Error())
+		}
+	}
+}
+
+// Returns reserved amount back, when the payment was not done.
+func (r *Reservation) Release() {
+	if r == nil {
+		return
+	}
+	bucket, err := store.OpenBucket(SPENDING_BUCKET)
+	if err != nil {
+		logger.Error("Can't release spending reservation. Details: " + err.Error())
+		return
+	}
+	unlock, err := bucket.Lock()
+	if err != nil {
+		logger.Error("Can't release spending reservation. Details: " + err.Error())
+		return
+	}
+	defer unlock()
+
+	negativeAmount := new(big.Int).Neg(r.amount)
+	for _, key := range []string{r.dailyKey, r.monthlyKey} {
+		s, isPresent, err := readSpending(bucket, key)
+		if err != nil {
+			logger.Error("Can't release spending reservation. Details: " + err.Error())
+			continue
+		}
+		// Period is already over.
+		if !isPresent {
+			continue
+		}
+		s.add(r.callerName, r.equivalent, negativeAmount)
+		err = bucket.Put(key, s)
+		if err != nil {
+			logger.Error("Can't release spending reservation. Details: " + err.Error())
+		}
+	}
+}
@@ -0,0 +1,276 @@
+package policy
+
+import (
+	"testing"
+
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/conf"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/conf/conftest"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/store/storetest"
+)
+
+var (
+	TEST_PAYEE       = "12-127.0.0.1:2000"
+	TEST_OTHER_PAYEE = "12-127.0.0.1:2001"
+)
+
+// Applies the settings of the policies and points the spending counters to the temporary store.
+func useTestPolicies(t *testing.T, settings conf.PaymentPoliciesSettings) *policies {
+	t.Helper()
+	storetest.UseTempDir(t)
+	conftest.Set(t, &conf.Params.Payments.Policies, settings)
+
+	p, err := parsePolicies()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func testPayment(amount, payload string, payees ...string) *Payment {
+	if len(payees) == 0 {
+		payees = []string{TEST_PAYEE}
+	}
+	return &Payment{
+		CommandUUID:         "command",
+		ContractorAddresses: payees,
+		Amount:              amount,
+		Equivalent:          "1",
+		Payload:             payload,
+	}
+}
+
+// Payment of the caller, that is checked in order after the previous ones of the same case.
+type checkedPayment struct {
+	caller            string
+	payment           *Payment
+	expectedViolation string
+}
+
+func runChecks(t *testing.T, p *policies, payments []checkedPayment) {
+	t.Helper()
+	for i, c := range payments {
+		reservation, violation, err := p.check(c.caller, c.payment, true)
+		if err != nil {
+			t.Fatalf("payment %d: %v", i, err)
+		}
+		code := ""
+		if violation != nil {
+			code = violation.Code
+		}
+		if code != c.expectedViolation {
+			t.Fatalf("payment %d: expected violation %q, got %+v", i, c.expectedViolation, violation)
+		}
+		if (reservation == nil) != (violation != nil) {
+			t.Fatalf("payment %d: reservation %+v doesn't match violation %+v", i, reservation, violation)
+		}
+	}
+}
+
+func TestCaps(t *testing.T) {
+	cases := []struct {
+		name     string
+		settings conf.PaymentPoliciesSettings
+		payments []checkedPayment
+	}{
+		{
+			name: "max amount of the equivalent",
+			settings: conf.PaymentPoliciesSettings{
+				Equivalents: map[string]conf.PaymentLimitsSettings{"1": {MaxAmount: "100"}},
+			},
+			payments: []checkedPayment{
+				{"alice", testPayment("100", ""), ""},
+				{"alice", testPayment("101", ""), VIOLATION_MAX_AMOUNT_EXCEEDED},
+			},
+		},
+		{
+			name: "limits of the other equivalent are not applied",
+			settings: conf.PaymentPoliciesSettings{
+				Equivalents: map[string]conf.PaymentLimitsSettings{"2": {MaxAmount: "1", DailyCap: "1"}},
+			},
+			payments: []checkedPayment{
+				{"alice", testPayment("1000", ""), ""},
+			},
+		},
+		{
+			name: "daily cap of the equivalent is shared by the callers",
+			settings: conf.PaymentPoliciesSettings{
+				Equivalents: map[string]conf.PaymentLimitsSettings{"1": {DailyCap: "250"}},
+			},
+			payments: []checkedPayment{
+				{"alice", testPayment("100", ""), ""},
+				{"bob", testPayment("100", ""), ""},
+				{"alice", testPayment("51", ""), VIOLATION_DAILY_CAP_EXCEEDED},
+				{"bob", testPayment("50", ""), ""},
+			},
+		},
+		{
+			name: "monthly cap of the equivalent",
+			settings: conf.PaymentPoliciesSettings{
+				Equivalents: map[string]conf.PaymentLimitsSettings{"1": {DailyCap: "1000", MonthlyCap: "150"}},
+			},
+			payments: []checkedPayment{
+				{"alice", testPayment("100", ""), ""},
+				{"alice", testPayment("100", ""), VIOLATION_MONTHLY_CAP_EXCEEDED},
+			},
+		},
+		{
+			name: "limits of the caller",
+			settings: conf.PaymentPoliciesSettings{
+				Callers: []conf.CallerPaymentPolicySettings{{
+					Caller:      "alice",
+					Equivalents: map[string]conf.PaymentLimitsSettings{"1": {MaxAmount: "80", DailyCap: "100"}},
+				}},
+			},
+			payments: []checkedPayment{
+				{"alice", testPayment("81", ""), VIOLATION_MAX_AMOUNT_EXCEEDED},
+				{"alice", testPayment("80", ""), ""},
+				{"alice", testPayment("21", ""), VIOLATION_DAILY_CAP_EXCEEDED},
+				{"bob", testPayment("500", ""), ""},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			p := useTestPolicies(t, c.settings)
+			runChecks(t, p, c.payments)
+		})
+	}
+}
+
+func TestPayeeRules(t *testing.T) {
+	cases := []struct {
+		name     string
+		settings conf.PaymentPoliciesSettings
+		payments []checkedPayment
+	}{
+		{
+			name:     "denied payee in the CLI form",
+			settings: conf.PaymentPoliciesSettings{DeniedPayees: []string{"ipv4:127.0.0.1:2001"}},
+			payments: []checkedPayment{
+				{"alice", testPayment("1", ""), ""},
+				{"alice", testPayment("1", "", TEST_OTHER_PAYEE), VIOLATION_PAYEE_DENIED},
+				{"alice", testPayment("1", "", TEST_PAYEE, TEST_OTHER_PAYEE), VIOLATION_PAYEE_DENIED},
+			},
+		},
+		{
+			name:     "allowed payees in the HTTP API form",
+			settings: conf.PaymentPoliciesSettings{AllowedPayees: []string{TEST_PAYEE}},
+			payments: []checkedPayment{
+				{"alice", testPayment("1", ""), ""},
+				{"alice", testPayment("1", "", TEST_OTHER_PAYEE), VIOLATION_PAYEE_NOT_ALLOWED},
+				// All addresses of the payee must be allowed.
+				{"alice", testPayment("1", "", TEST_PAYEE, TEST_OTHER_PAYEE), VIOLATION_PAYEE_NOT_ALLOWED},
+			},
+		},
+		{
+			name: "denied list takes precedence over the allowed one",
+			settings: conf.PaymentPoliciesSettings{
+				AllowedPayees: []string{TEST_PAYEE},
+				DeniedPayees:  []string{TEST_PAYEE},
+			},
+			payments: []checkedPayment{
+				{"alice", testPayment("1", ""), VIOLATION_PAYEE_DENIED},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			p := useTestPolicies(t, c.settings)
+			runChecks(t, p, c.payments)
+		})
+	}
+}
+
+func TestPayloadRules(t *testing.T) {
+	cases := []struct {
+		name     string
+		settings conf.PayloadPolicySettings
+		payments []checkedPayment
+	}{
+		{
+			name:     "payload is required",
+			settings: conf.PayloadPolicySettings{Required: true},
+			payments: []checkedPayment{
+				{"alice", testPayment("1", ""), VIOLATION_PAYLOAD_REQUIRED},
+				{"alice", testPayment("1", "invoice"), ""},
+			},
+		},
+		{
+			name:     "max length",
+			settings: conf.PayloadPolicySettings{MaxLength: 5},
+			payments: []checkedPayment{
+				{"alice", testPayment("1", ""), ""},
+				{"alice", testPayment("1", "12345"), ""},
+				{"alice", testPayment("1", "123456"), VIOLATION_PAYLOAD_INVALID},
+			},
+		},
+		{
+			name:     "pattern",
+			settings: conf.PayloadPolicySettings{Pattern: `^INV-[0-9]+$`},
+			payments: []checkedPayment{
+				{"alice", testPayment("1", ""), ""},
+				{"alice", testPayment("1", "INV-42"), ""},
+				{"alice", testPayment("1", "invoice 42"), VIOLATION_PAYLOAD_INVALID},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			p := useTestPolicies(t, conf.PaymentPoliciesSettings{Payload: c.settings})
+			runChecks(t, p, c.payments)
+		})
+	}
+}
+
+func TestReservation(t *testing.T) {
+	p := useTestPolicies(t, conf.PaymentPoliciesSettings{
+		Equivalents: map[string]conf.PaymentLimitsSettings{"1": {DailyCap: "100"}},
+	})
+
+	// Evaluation doesn't change the spending counters.
+	_, violation, err := p.check("alice", testPayment("100", ""), false)
+	if err != nil || violation != nil {
+		t.Fatalf("unexpected result of the evaluation: %+v, %v", violation, err)
+	}
+	reservation, violation, err := p.check("alice", testPayment("100", ""), true)
+	if err != nil || violation != nil {
+		t.Fatalf("unexpected result of the check: %+v, %v", violation, err)
+	}
+	runChecks(t, p, []checkedPayment{{"alice", testPayment("1", ""), VIOLATION_DAILY_CAP_EXCEEDED}})
+
+	// Amount of the payment, that was not done, is available again.
+	reservation.Release()
+	runChecks(t, p, []checkedPayment{{"alice", testPayment("100", ""), ""}})
+}
+
+func TestInvalidSettings(t *testing.T) {
+	cases := []struct {
+		name     string
+		settings conf.PaymentPoliciesSettings
+	}{
+		{"invalid amount", conf.PaymentPoliciesSettings{
+			Equivalents: map[string]conf.PaymentLimitsSettings{"1": {DailyCap: "-1"}},
+		}},
+		{"caller without name", conf.PaymentPoliciesSettings{
+			Callers: []conf.CallerPaymentPolicySettings{{}},
+		}},
+		{"duplicated caller", conf.PaymentPoliciesSettings{
+			Callers: []conf.CallerPaymentPolicySettings{{Caller: "alice"}, {Caller: "alice"}},
+		}},
+		{"invalid payee", conf.PaymentPoliciesSettings{DeniedPayees: []string{"127.0.0.1"}}},
+		{"invalid pattern", conf.PaymentPoliciesSettings{Payload: conf.PayloadPolicySettings{Pattern: "("}}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			conftest.Set(t, &conf.Params.Payments.Policies, c.settings)
+			_, err := parsePolicies()
+			if err == nil {
+				t.Fatal("invalid settings are accepted")
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package routes
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/balances"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/common"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/handler"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/logger"
+)
+
+// Returns raw balance samples of the equivalent (see balances package).
+// Optional query parameters: from, to (RFC3339 or unix timestamp, the last day by default).
+func (router *RoutesHandler) BalanceSamples(w http.ResponseWriter, r *http.Request) {
+	url, err := preprocessRequest(r)
+	if err != nil {
+		logger.Error("Bad request: invalid security parameters: " + err.Error())
+		w.WriteHeader(BAD_REQUEST)
+		return
+	}
+
+	equivalent, isParamPresent := mux.Vars(r)["equivalent"]
+	if !isParamPresent || !common.ValidateInt(equivalent) {
+		logger.Error("Bad request: invalid equivalent parameter: " + url)
+		w.WriteHeader(BAD_REQUEST)
+		return
+	}
+
+	from, to, err := parseBalancesPeriod(r)
+	if err != nil {
+		logger.Error("Bad request: " + err.Error() + ": " + url)
+		w.WriteHeader(BAD_REQUEST)
+		return
+	}
+
+	samples, err := balances.Samples(equivalent, from, to)
+	if err != nil {
+		logger.Error("Can't read balance samples. Details: " + err.Error())
+		writeServerError("Balance samples error", w)
+		return
+	}
+	writeHTTPResponse(w, OK, common.BalanceSamplesResponse{
+		Count:   len(samples),
+		Samples: samples,
+	})
+}
+
+// Returns balance samples of the equivalent, downsampled to the buckets of the step (min, max, avg, first and last values).
+// Optional query parameters: from, to, step (duration, 1h by default)
+// and contractor_id (repeated, balances of the settlement lines to be aggregated too).
+func (router *RoutesHandler) BalanceAggregates(w http.ResponseWriter, r *http.Request) {
+	url, err := preprocessRequest(r)
+	if err != nil {
+		logger.Error("Bad request: invalid security parameters: " + err.Error())
+		w.WriteHeader(BAD_REQUEST)
+		return
+	}
+
+	equivalent, isParamPresent := mux.Vars(r)["equivalent"]
+	if !isParamPresent || !common.ValidateInt(equivalent) {
+		logger.Error("Bad request: invalid equivalent parameter: " + url)
+		w.WriteHeader(BAD_REQUEST)
+		return
+	}
+
+	from, to, err := parseBalancesPeriod(r)
+	if err != nil {
+		logger.Error("Bad request: " + err.Error() + ": " + url)
+		w.WriteHeader(BAD_REQUEST)
+		return
+	}
+
+	step := time.Hour
+	if r.URL.Query().Get("step") != "" {
+		step, err = time.ParseDuration(r.URL.Query().Get("step"))
+		if err != nil || step < balances.MIN_STEP {
+			logger.Error("Bad request: invalid step parameter: " + url)
+			w.WriteHeader(BAD_REQUEST)
+			return
+		}
+	}
+
+	contractorIDs := r.URL.Query()["contractor_id"]
+	for _, contractorID := range contractorIDs {
+		if !common.ValidateInt(contractorID) {
+			logger.Error("Bad request: invalid contractor_id parameter: " + url)
+			w.WriteHeader(BAD_REQUEST)
+			return
+		}
+	}
+
+	response, err := balances.Aggregates(equivalent, from, to, step, contractorIDs)
+	switch err {
+	case nil:
+		writeHTTPResponse(w, OK, response)
+	case balances.ErrTooManyBuckets:
+		logger.Error("Bad request: " + err.Error() + ": " + url)
+		w.WriteHeader(BAD_REQUEST)
+	default:
+		logger.Error("Can't aggregate balance samples. Details: " + err.Error())
+		writeServerError("Balance samples error", w)
+	}
+}
+
+// Parses from and to query parameters, the last day is used by default.
+func parseBalancesPeriod(r *http.Request) (time.Time, time.Time, error) {
+	from, err := handler.ParseHistoryExportDate(r.URL.Query().Get("from"))
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	to, err := handler.ParseHistoryExportDate(r.URL.Query().Get("to"))
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	return balances.Period(from, to)
+}
@@ -0,0 +1,212 @@
+package routes
+
+import (
+	"context"
+	"mime"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/auth"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/common"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/handler"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/logger"
+)
+
+var (
+	BULK_PAYMENTS_PATH = "/api/v1/node/bulk-payments/"
+
+	bulkPaymentsFormats = map[string]string{
+		"text/csv":         handler.BULK_PAYMENTS_FORMAT_CSV,
+		"application/json": handler.BULK_PAYMENTS_FORMAT_JSON,
+	}
+)
+
+// Validates payments from the CSV or JSON body and sends them.
+// If any row is invalid, no payment is sent and the per-row errors are returned.
+// With dry_run=true the payments are only validated, with async=true the bulk is processed in background.
+func (router *RoutesHandler) CreateBulkPayment(w http.ResponseWriter, r *http.Request) {
+	url, err := preprocessRequest(r)
+	if err != nil {
+		logger.Error("Bad request: invalid security parameters: " + err.Error())
+		w.WriteHeader(BAD_REQUEST)
+		return
+	}
+
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	format, isPresent := bulkPaymentsFormats[mediaType]
+	if err != nil || !isPresent {
+		logger.Error("Bad request: unsupported content type of the bulk payment: " + url)
+		w.WriteHeader(BAD_REQUEST)
+		return
+	}
+
+	inputs, err := handler.ParseBulkPayments(format, r.Body)
+	if err != nil {
+		logger.Error("Bad request: " + err.Error() + ": " + url)
+		w.WriteHeader(BAD_REQUEST)
+		return
+	}
+	bulk, err := handler.NewBulkPayment(r.Context(), inputs)
+	if err == handler.ErrBulkPaymentIsInvalid {
+		logger.Error("Bad request: bulk payment contains invalid rows: " + url)
+		writeHTTPResponse(w, UNPROCESSABLE_ENTITY, bulk.Response())
+		return
+	}
+	if err != nil {
+		logger.Error("Bad request: " + err.Error() + ": " + url)
+		w.WriteHeader(BAD_REQUEST)
+		return
+	}
+	if !isBulkPaymentPermitted(r, bulk) {
+		writeHTTPResponse(w, BULK_PAYMENT_FORBIDDEN, common.BulkPaymentResponse{})
+		return
+	}
+
+	if r.FormValue("dry_run") == "true" {
+		writeHTTPResponse(w, OK, bulk.Response())
+		return
+	}
+
+	err = handler.CreateBulkPayment(r.Context(), bulk)
+	if err != nil {
+		logger.Error("Can't persist bulk payment. Details: " + err.Error())
+		writeServerError("Bulk payments store error", w)
+		return
+	}
+	router.runBulkPayment(w, r, bulk)
+}
+
+// Returns the bulk payment with the per-row results.
+func (router *RoutesHandler) GetBulkPayment(w http.ResponseWriter, r *http.Request) {
+	url, err := preprocessRequest(r)
+	if err != nil {
+		logger.Error("Bad request: invalid security parameters: " + err.Error())
+		w.WriteHeader(BAD_REQUEST)
+		return
+	}
+
+	bulk, code := requestedBulkPayment(r, url)
+	if bulk == nil {
+		writeBulkPaymentError(w, code)
+		return
+	}
+	writeHTTPResponse(w, OK, bulk.Response())
+}
+
+// Sends payments of the bulk, that are failed or were interrupted.
+// Payments with unknown outcome are looked up in the engine first, so they are not sent twice.
+func (router *RoutesHandler) ResumeBulkPayment(w http.ResponseWriter, r *http.Request) {
+	url, err := preprocessRequest(r)
+	if err != nil {
+		logger.Error("Bad request: invalid security parameters: " + err.Error())
+		w.WriteHeader(BAD_REQUEST)
+		return
+	}
+
+	bulk, code := requestedBulkPayment(r, url)
+	if bulk == nil {
+		writeBulkPaymentError(w, code)
+		return
+	}
+	caller := auth.CallerFromContext(r.Context())
+	if caller != nil && !caller.HasScope(auth.SCOPE_ADMIN) && caller.Name != bulk.Requester {
+		logger.Error("Bulk payment " + bulk.ID + " could be resumed only by it's requester")
+		writeBulkPaymentError(w, BULK_PAYMENT_FORBIDDEN)
+		return
+	}
+	if bulk.State == handler.BULK_PAYMENT_STATE_RUNNING {
+		logger.Error("Bulk payment " + bulk.ID + " is already running")
+		writeBulkPaymentError(w, CONFLICT)
+		return
+	}
+	router.runBulkPayment(w, r, bulk)
+}
+
+func (router *RoutesHandler) runBulkPayment(w http.ResponseWriter, r *http.Request, bulk *handler.BulkPayment) {
+	bulkID := bulk.ID
+	// Payments of the bulk must not be interrupted if the client disconnects.
+	ctx := context.WithoutCancel(r.Context())
+
+	if r.FormValue("async") == "true" {
+		go func() {
+			_, err := router.nodeHandler.RunBulkPayment(ctx, bulkID)
+			if err != nil {
+				logger.Error("Can't run bulk payment " + bulkID + ". Details: " + err.Error())
+			}
+		}()
+		bulk.State = handler.BULK_PAYMENT_STATE_RUNNING
+		w.Header().Set("Location", BULK_PAYMENTS_PATH+bulkID+"/")
+		writeHTTPResponse(w, ACCEPTED, bulk.Response())
+		return
+	}
+
+	// Command processing.
+	// This command may execute relatively slow.
+	bulk, err := router.nodeHandler.RunBulkPayment(ctx, bulkID)
+	if err != nil {
+		logger.Error("Can't run bulk payment " + bulkID + ". Details: " + err.Error())
+		switch err {
+		case handler.ErrBulkPaymentNotFound:
+			writeBulkPaymentError(w, NOT_FOUND)
+		case handler.ErrBulkPaymentIsRunning, handler.ErrBulkPaymentIsInvalid:
+			writeBulkPaymentError(w, CONFLICT)
+		default:
+			writeServerError("Bulk payments store error", w)
+		}
+		return
+	}
+	writeHTTPResponse(w, OK, bulk.Response())
+}
+
+// Restricted callers could operate only with the bulks, that contain their equivalents only.
+func isBulkPaymentPermitted(r *http.Request, bulk *handler.BulkPayment) bool {
+	caller := auth.CallerFromContext(r.Context())
+	if caller == nil {
+		return true
+	}
+	for _, equivalent := range bulk.Equivalents() {
+		if !caller.IsEquivalentAllowed(equivalent) {
+			logger.Error("Caller " + caller.Name + " is not allowed to operate with the equivalent " + equivalent)
+			return false
+		}
+	}
+	return true
+}
+
+// Returns the bulk payment from the path, or nil and the response code.
+func requestedBulkPayment(r *http.Request, url string) (*handler.BulkPayment, int) {
+	bulkID := mux.Vars(r)["id"]
+	if !common.ValidateUUID(bulkID) {
+		logger.Error("Bad request: invalid id parameter: " + url)
+		return nil, BAD_REQUEST
+	}
+	// ID is used as the store key, so it is normalized.
+	bulkID = uuid.MustParse(bulkID).String()
+
+	bulk, err := handler.GetBulkPayment(bulkID)
+	if err == handler.ErrBulkPaymentNotFound {
+		logger.Error("Bulk payment " + bulkID + " is not found")
+		return nil, NOT_FOUND
+	}
+	if err != nil {
+		logger.Error("Can't read bulk payment " + bulkID + ". Details: " + err.Error())
+		return nil, SERVER_ERROR
+	}
+	// Bulks with the equivalents, that are not allowed to the caller, are not disclosed.
+	if !isBulkPaymentPermitted(r, bulk) {
+		return nil, NOT_FOUND
+	}
+	return bulk, OK
+}
+
+func writeBulkPaymentError(w http.ResponseWriter, code int) {
+	switch code {
+	case BAD_REQUEST:
+		w.WriteHeader(BAD_REQUEST)
+	case SERVER_ERROR:
+		writeServerError("Bulk payments store error", w)
+	default:
+		writeHTTPResponse(w, code, common.BulkPaymentResponse{})
+	}
+}
@@ -55,7 +55,7 @@ func (router *RoutesHandler) InitChannel(w http.ResponseWriter, r *http.Request)
 	}
 	command := handler.NewCommand(contractorAddresses...)
 
-	err = router.nodeHandler.Node.SendCommand(command)
+	err = router.nodeHandler.Node.SendCommand(r.Context(), command)
 	if err != nil {
 		logger.Error("Can't send command: " + string(command.ToBytes()) + " to node. Details: " + err.Error())
 		writeHTTPResponse(w, COMMAND_TRANSFERRING_ERROR, common.ChannelInitResponse{})
@@ -98,7 +98,7 @@ func (router *RoutesHandler) ListChannels(w http.ResponseWriter, r *http.Request
 
 	command := handler.NewCommand("GET:contractors-all")
 
-	err = router.nodeHandler.Node.SendCommand(command)
+	err = router.nodeHandler.Node.SendCommand(r.Context(), command)
 	if err != nil {
 		logger.Error("Can't send command: " + string(command.ToBytes()) + " to node. Details: " + err.Error())
 		writeHTTPResponse(w, COMMAND_TRANSFERRING_ERROR, common.ChannelListResponse{})
@@ -172,7 +172,7 @@ func (router *RoutesHandler) ChannelInfo(w http.ResponseWriter, r *http.Request)
 
 	command := handler.NewCommand("GET:channels/one", contractorID)
 
-	err = router.nodeHandler.Node.SendCommand(command)
+	err = router.nodeHandler.Node.SendCommand(r.Context(), command)
 	if err != nil {
 		logger.Error("Can't send command: " + string(command.ToBytes()) + " to node. Details: " + err.Error())
 		writeHTTPResponse(w, COMMAND_TRANSFERRING_ERROR, common.ChannelInfoResponse{})
@@ -259,7 +259,7 @@ func (router *RoutesHandler) ChannelInfoByAddresses(w http.ResponseWriter, r *ht
 	contractorAddresses = append([]string{"GET:channels/one/address"}, contractorAddresses...)
 	command := handler.NewCommand(contractorAddresses...)
 
-	err = router.nodeHandler.Node.SendCommand(command)
+	err = router.nodeHandler.Node.SendCommand(r.Context(), command)
 	if err != nil {
 		logger.Error("Can't send command: " + string(command.ToBytes()) + " to node. Details: " + err.Error())
 		writeHTTPResponse(w, COMMAND_TRANSFERRING_ERROR, common.ChannelInfoByAddressResponse{})
@@ -338,7 +338,7 @@ func (router *RoutesHandler) SetChannelAddresses(w http.ResponseWriter, r *http.
 	addresses = append([]string{"SET:channel/address", contractorID, strconv.Itoa(len(addresses) / 2)}, addresses...)
 	command := handler.NewCommand(addresses...)
 
-	err = router.nodeHandler.Node.SendCommand(command)
+	err = router.nodeHandler.Node.SendCommand(r.Context(), command)
 	if err != nil {
 		logger.Error("Can't send command: " + string(command.ToBytes()) + " to node. Details: " + err.Error())
 		writeHTTPResponse(w, COMMAND_TRANSFERRING_ERROR, common.ChannelResponse{})
@@ -398,7 +398,7 @@ func (router *RoutesHandler) SetChannelCryptoKey(w http.ResponseWriter, r *http.
 
 	// Command generation
 	command := handler.NewCommand(commandParams...)
-	err = router.nodeHandler.Node.SendCommand(command)
+	err = router.nodeHandler.Node.SendCommand(r.Context(), command)
 	if err != nil {
 		logger.Error("Can't send command: " + string(command.ToBytes()) + " to node. Details: " + err.Error())
 		writeHTTPResponse(w, COMMAND_TRANSFERRING_ERROR, common.ChannelResponse{})
@@ -445,7 +445,7 @@ func (router *RoutesHandler) RegenerateChannelCryptoKey(w http.ResponseWriter, r
 	// Command generation
 	command := handler.NewCommand("SET:channel/regenerate-crypto-key", contractorID)
 
-	err = router.nodeHandler.Node.SendCommand(command)
+	err = router.nodeHandler.Node.SendCommand(r.Context(), command)
 	if err != nil {
 		logger.Error("Can't send command: " + string(command.ToBytes()) + " to node. Details: " + err.Error())
 		writeHTTPResponse(w, COMMAND_TRANSFERRING_ERROR, common.ChannelInitResponse{})
@@ -502,7 +502,7 @@ func (router *RoutesHandler) RemoveChannel(w http.ResponseWriter, r *http.Reques
 	// Command generation
 	command := handler.NewCommand("DELETE:channel/contractor-id", contractorID)
 
-	err = router.nodeHandler.Node.SendCommand(command)
+	err = router.nodeHandler.Node.SendCommand(r.Context(), command)
 	if err != nil {
 		logger.Error("Can't send command: " + string(command.ToBytes()) + " to node. Details: " + err.Error())
 		writeHTTPResponse(w, COMMAND_TRANSFERRING_ERROR, common.ChannelResponse{})
@@ -0,0 +1,162 @@
+package routes
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/conf"
+)
+
+// Parsed addresses lists from the security settings.
+// Each entry of the lists could be a single IP (v4 or v6) or a CIDR range.
+type addressesFilters struct {
+	allowed        []*net.IPNet
+	denied         []*net.IPNet
+	trustedProxies []*net.IPNet
+}
+
+var (
+	filtersOnce  sync.Once
+	filters      *addressesFilters
+	filtersError error
+)
+
+// Parses addresses lists from the settings.
+// Lists are parsed only once, settings are not changed during the process lifetime.
+func loadAddressesFilters() (*addressesFilters, error) {
+	filtersOnce.Do(func() {
+		settings := conf.Params.Security
+		result := &addressesFilters{}
+
+		result.allowed, filtersError = parseAddressesRanges(settings.AllowableIPs)
+		if filtersError != nil {
+			filtersError = errors.New("invalid security.allowable_ips -> " + filtersError.Error())
+			return
+		}
+		result.denied, filtersError = parseAddressesRanges(settings.DeniedIPs)
+		if filtersError != nil {
+			filtersError = errors.New("invalid security.denied_ips -> " + filtersError.Error())
+			return
+		}
+		result.trustedProxies, filtersError = parseAddressesRanges(settings.TrustedProxies)
+		if filtersError != nil {
+			filtersError = errors.New("invalid security.trusted_proxies -> " + filtersError.Error())
+			return
+		}
+		filters = result
+	})
+	return filters, filtersError
+}
+
+// Checks addresses lists of the settings.
+// Should be called on the server start, to not to start with broken settings.
+func ValidateAddressesSettings() error {
+	_, err := loadAddressesFilters()
+	return err
+}
+
+func parseAddressesRanges(entries []string) ([]*net.IPNet, error) {
+	var ranges []*net.IPNet
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if strings.Contains(entry, "/") {
+			_, ipRange, err := net.ParseCIDR(entry)
+			if err != nil {
+				return nil, errors.New("invalid CIDR " + entry)
+			}
+			ranges = append(ranges, ipRange)
+			continue
+		}
+
+		ip := net.ParseIP(entry)
+		if ip == nil {
+			return nil, errors.New("invalid IP " + entry)
+		}
+		ranges = append(ranges, singleAddressRange(ip))
+	}
+	return ranges, nil
+}
+
+func singleAddressRange(ip net.IP) *net.IPNet {
+	if ipV4 := ip.To4(); ipV4 != nil {
+		return &net.IPNet{IP: ipV4, Mask: net.CIDRMask(32, 32)}
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}
+}
+
+func containsAddress(ranges []*net.IPNet, ip net.IP) bool {
+	for _, ipRange := range ranges {
+		if ipRange.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Checks requester IP against the deny list and the allowlist.
+// Deny list has priority. Empty allowlist allows all addresses.
+func (f *addressesFilters) isAddressAllowed(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	if containsAddress(f.denied, ip) {
+		return false
+	}
+	if len(f.allowed) == 0 {
+		return true
+	}
+	return containsAddress(f.allowed, ip)
+}
+
+// Returns IP of the requester.
+// Forwarded headers (X-Forwarded-For, X-Real-Ip) are honoured only
+// if the direct peer is one of the trusted proxies. Otherwise the peer address is used,
+// so clients can't spoof their address by the headers.
+func (f *addressesFilters) requesterAddress(r *http.Request) net.IP {
+	remoteIP := parseRemoteAddr(r.RemoteAddr)
+	if remoteIP == nil || !containsAddress(f.trustedProxies, remoteIP) {
+		return remoteIP
+	}
+
+	if xff := r.Header.Get("X-Forwarded-For"); len(xff) > 0 {
+		// Each proxy appends the address of it's peer to the end of the header,
+		// so the header is walked from the end and the first address,
+		// that doesn't belong to the trusted proxies, is the address of the client.
+		addrs := strings.Split(xff, ",")
+		for i := len(addrs) - 1; i >= 0; i-- {
+			ip := net.ParseIP(strings.TrimSpace(addrs[i]))
+			if ip == nil {
+				// Header is malformed, nothing beyond this point could be trusted.
+				return remoteIP
+			}
+			remoteIP = ip
+			if !containsAddress(f.trustedProxies, ip) {
+				return remoteIP
+			}
+		}
+		return remoteIP
+	}
+
+	if xri := r.Header.Get("X-Real-Ip"); len(xri) > 0 {
+		if ip := net.ParseIP(strings.TrimSpace(xri)); ip != nil {
+			return ip
+		}
+	}
+	return remoteIP
+}
+
+// Parses RemoteAddr of the request, that could be "1.2.3.4:5678", "[::1]:5678" or just an address.
+func parseRemoteAddr(remoteAddr string) net.IP {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	// Zone of the IPv6 link-local address is not a part of the address.
+	if zoneIndex := strings.Index(host, "%"); zoneIndex >= 0 {
+		host = host[:zoneIndex]
+	}
+	return net.ParseIP(host)
+}
@@ -0,0 +1,139 @@
+package routes
+
+import (
+	"net"
+	"net/http/httptest"
+	"testing"
+)
+
+func newTestFilters(t *testing.T, allowed, denied, trustedProxies []string) *addressesFilters {
+	t.Helper()
+	var err error
+	result := &addressesFilters{}
+	result.allowed, err = parseAddressesRanges(allowed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result.denied, err = parseAddressesRanges(denied)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result.trustedProxies, err = parseAddressesRanges(trustedProxies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestRequesterAddress(t *testing.T) {
+	filters := newTestFilters(t, nil, nil, []string{"10.0.0.0/8", "2001:db8:ffff::/48"})
+
+	cases := []struct {
+		name       string
+		remoteAddr string
+		headers    map[string]string
+		expected   string
+	}{
+		{"direct peer", "203.0.113.7:5000", nil, "203.0.113.7"},
+		{"spoofed X-Forwarded-For from the untrusted peer", "203.0.113.7:5000",
+			map[string]string{"X-Forwarded-For": "198.51.100.1"}, "203.0.113.7"},
+		{"spoofed X-Real-Ip from the untrusted peer", "203.0.113.7:5000",
+			map[string]string{"X-Real-Ip": "198.51.100.1"}, "203.0.113.7"},
+		{"client behind the trusted proxy", "10.0.0.1:5000",
+			map[string]string{"X-Forwarded-For": "203.0.113.7"}, "203.0.113.7"},
+		{"chain of the trusted proxies", "10.0.0.1:5000",
+			map[string]string{"X-Forwarded-For": "203.0.113.7, 10.0.0.3, 10.0.0.2"}, "203.0.113.7"},
+		{"address, spoofed by the client before the trusted proxies", "10.0.0.1:5000",
+			map[string]string{"X-Forwarded-For": "198.51.100.1, 203.0.113.7, 10.0.0.2"}, "203.0.113.7"},
+		{"header of the trusted proxies only", "10.0.0.1:5000",
+			map[string]string{"X-Forwarded-For": "10.0.0.3, 10.0.0.2"}, "10.0.0.3"},
+		{"malformed header", "10.0.0.1:5000",
+			map[string]string{"X-Forwarded-For": "203.0.113.7, unknown, 10.0.0.2"}, "10.0.0.2"},
+		{"X-Real-Ip from the trusted proxy", "10.0.0.1:5000",
+			map[string]string{"X-Real-Ip": "203.0.113.7"}, "203.0.113.7"},
+		{"X-Forwarded-For has priority over X-Real-Ip", "10.0.0.1:5000",
+			map[string]string{"X-Forwarded-For": "203.0.113.7", "X-Real-Ip": "198.51.100.1"}, "203.0.113.7"},
+		{"IPv4 peer without port", "203.0.113.7", nil, "203.0.113.7"},
+		{"IPv6 peer with port", "[2001:db8::1]:5000", nil, "2001:db8::1"},
+		{"IPv6 peer without port", "2001:db8::1", nil, "2001:db8::1"},
+		{"IPv6 link-local peer with zone", "[fe80::1%eth0]:5000", nil, "fe80::1"},
+		{"IPv6 client behind the trusted IPv6 proxy", "[2001:db8:ffff::1]:5000",
+			map[string]string{"X-Forwarded-For": "2001:db8::7"}, "2001:db8::7"},
+		{"invalid peer address", "unknown", nil, ""},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/api/v1/node/channels/", nil)
+			r.RemoteAddr = c.remoteAddr
+			for name, value := range c.headers {
+				r.Header.Set(name, value)
+			}
+			ip := filters.requesterAddress(r)
+			if c.expected == "" {
+				if ip != nil {
+					t.Fatalf("expected no address, got %s", ip)
+				}
+				return
+			}
+			if !ip.Equal(net.ParseIP(c.expected)) {
+				t.Fatalf("expected %s, got %s", c.expected, ip)
+			}
+		})
+	}
+}
+
+func TestIsAddressAllowed(t *testing.T) {
+	cases := []struct {
+		name      string
+		allowed   []string
+		denied    []string
+		ip        string
+		isAllowed bool
+	}{
+		{"empty lists", nil, nil, "203.0.113.7", true},
+		{"address of the allowlist", []string{"203.0.113.0/24"}, nil, "203.0.113.7", true},
+		{"address out of the allowlist", []string{"203.0.113.0/24"}, nil, "198.51.100.1", false},
+		{"single address of the allowlist", []string{"203.0.113.7"}, nil, "203.0.113.7", true},
+		{"address of the deny list", nil, []string{"203.0.113.0/24"}, "203.0.113.7", false},
+		{"deny list has priority over the allowlist", []string{"203.0.113.0/24"}, []string{"203.0.113.7"}, "203.0.113.7", false},
+		{"other address of the allowed range", []string{"203.0.113.0/24"}, []string{"203.0.113.7"}, "203.0.113.8", true},
+		{"IPv6 address of the allowlist", []string{"2001:db8::/32"}, nil, "2001:db8::1", true},
+		{"IPv6 address of the deny list", []string{"2001:db8::/32"}, []string{"2001:db8::1"}, "2001:db8::1", false},
+		{"IPv4 address against the IPv6 allowlist", []string{"2001:db8::/32"}, nil, "203.0.113.7", false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			filters := newTestFilters(t, c.allowed, c.denied, nil)
+			if filters.isAddressAllowed(net.ParseIP(c.ip)) != c.isAllowed {
+				t.Fatalf("expected allowed %v", c.isAllowed)
+			}
+		})
+	}
+
+	if newTestFilters(t, nil, nil, nil).isAddressAllowed(nil) {
+		t.Fatal("unknown address is allowed")
+	}
+}
+
+func TestParseAddressesRanges(t *testing.T) {
+	cases := []struct {
+		entry   string
+		isValid bool
+	}{
+		{"203.0.113.7", true},
+		{" 203.0.113.0/24 ", true},
+		{"2001:db8::1", true},
+		{"2001:db8::/32", true},
+		{"203.0.113.0/33", false},
+		{"203.0.113", false},
+		{"localhost", false},
+	}
+	for _, c := range cases {
+		_, err := parseAddressesRanges([]string{c.entry})
+		if (err == nil) != c.isValid {
+			t.Fatalf("%q: expected valid %v, got %v", c.entry, c.isValid, err)
+		}
+	}
+}
@@ -1,14 +1,12 @@
 package routes
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"io"
-	"net"
 	"net/http"
-	"strings"
 
-	"github.com/vTCP-Foundation/vtcpd-cli/internal/conf"
 	"github.com/vTCP-Foundation/vtcpd-cli/internal/handler"
 	"github.com/vTCP-Foundation/vtcpd-cli/internal/logger"
 )
@@ -20,6 +18,14 @@ var (
 	CREATED                    = 201
 	ACCEPTED                   = 202
 	BAD_REQUEST                = 400
+	PAYMENT_POLICY_VIOLATION   = 403
+	SELF_APPROVAL_FORBIDDEN    = 403
+	SCHEDULE_FORBIDDEN         = 403
+	BULK_PAYMENT_FORBIDDEN     = 403
+	NOT_FOUND                  = 404
+	CONFLICT                   = 409
+	UNPROCESSABLE_ENTITY       = 422
+	TOO_MANY_REQUESTS          = 429
 	NODE_NOT_FOUND             = 405
 	SERVER_ERROR               = 500
 	NODE_IS_INACCESSIBLE       = 503
@@ -80,52 +86,36 @@ func preprocessRequest(r *http.Request) (string, error) {
 		url = r.Method + ": " + r.URL.String()
 	} else {
 		bodyBytes, _ := io.ReadAll(r.Body)
+		// Body is left for the handlers, that are reading it (e.g. bulk payments).
+		r.Body = io.NopCloser(bytes.NewReader(bodyBytes))
 		url = r.Method + ": " + r.URL.String() + "{ " + string(bodyBytes) + "}"
 	}
 	logger.Info(url)
-	requesterIP := getRealAddr(r)
-	logger.Info("Requester IP: " + requesterIP)
-	if len(conf.Params.Security.AllowableIPs) > 0 {
-		ipIsAllow := false
-		for _, allowableIP := range conf.Params.Security.AllowableIPs {
-			if allowableIP == requesterIP {
-				ipIsAllow = true
-				break
-			}
-		}
-		if !ipIsAllow {
-			return url, errors.New("IP " + requesterIP + " is not allow")
-		}
+	ipFilters, err := loadAddressesFilters()
+	if err != nil {
+		return url, err
 	}
-	apiKey := r.Header.Get("api-key")
-	if conf.Params.Security.ApiKey != "" {
-		if apiKey != conf.Params.Security.ApiKey {
-			return url, errors.New("Invalid api-key " + apiKey)
-		}
+	sender := requesterOf(r)
+	if sender.ip == nil {
+		return url, errors.New("Requester IP can't be determined from " + r.RemoteAddr)
 	}
-	return url, nil
-}
-
-func getRealAddr(r *http.Request) string {
-	remoteIP := ""
-	// the default is the originating ip. but we try to find better options because this is almost
-	// never the right IP
-	if parts := strings.Split(r.RemoteAddr, ":"); len(parts) == 2 {
-		remoteIP = parts[0]
+	logger.Info("Requester IP: " + sender.ip.String())
+	if !ipFilters.isAddressAllowed(sender.ip) {
+		return url, errors.New("IP " + sender.ip.String() + " is not allow")
 	}
-	// If we have a forwarded-for header, take the address from there
-	if xff := strings.Trim(r.Header.Get("X-Forwarded-For"), ","); len(xff) > 0 {
-		addrs := strings.Split(xff, ",")
-		lastFwd := addrs[len(addrs)-1]
-		if ip := net.ParseIP(lastFwd); ip != nil {
-			remoteIP = ip.String()
-		}
-		// parse X-Real-Ip header
-	} else if xri := r.Header.Get("X-Real-Ip"); len(xri) > 0 {
-		if ip := net.ParseIP(xri); ip != nil {
-			remoteIP = ip.String()
-		}
+
+	if sender.authError != nil {
+		return url, sender.authError
 	}
+	caller := sender.caller
+	logger.Info("Requester: " + caller.Name + " (" + caller.AuthMethod + ")")
 
-	return remoteIP
+	scope := requiredScope(r)
+	if !caller.HasScope(scope) {
+		return url, errors.New("Caller " + caller.Name + " has no scope " + scope)
+	}
+	if !isEquivalentPermitted(caller, r) {
+		return url, errors.New("Caller " + caller.Name + " is not allowed to operate with the equivalent")
+	}
+	return url, nil
 }
@@ -53,7 +53,7 @@ func (router *RoutesHandler) SettlementLinesHistory(w http.ResponseWriter, r *ht
 	command := handler.NewCommand(
 		"GET:history/trust-lines", offset, count, dateFromUnixTimestamp, dateToUnixTimestamp, equivalent)
 
-	err = router.nodeHandler.Node.SendCommand(command)
+	err = router.nodeHandler.Node.SendCommand(r.Context(), command)
 	if err != nil {
 		logger.Error("Can't send command: " + string(command.ToBytes()) + " to node. Details: " + err.Error())
 		writeHTTPResponse(w, COMMAND_TRANSFERRING_ERROR, common.SettlementLineHistoryResponse{})
@@ -180,7 +180,7 @@ func (router *RoutesHandler) PaymentsHistory(w http.ResponseWriter, r *http.Requ
 		"GET:history/payments", offset, count, dateFromUnixTimestamp, dateToUnixTimestamp,
 		amountFromUnixTimestamp, amountToUnixTimestamp, commandUUID, operationUUID, equivalent)
 
-	err = router.nodeHandler.Node.SendCommand(command)
+	err = router.nodeHandler.Node.SendCommand(r.Context(), command)
 	if err != nil {
 		logger.Error("Can't send command: " + string(command.ToBytes()) + " to node. Details: " + err.Error())
 		writeHTTPResponse(w, COMMAND_TRANSFERRING_ERROR, common.PaymentHistoryResponse{})
@@ -294,7 +294,7 @@ func (router *RoutesHandler) PaymentsHistoryAllEquivalents(w http.ResponseWriter
 		"GET:history/payments/all", offset, count, dateFromUnixTimestamp, dateToUnixTimestamp,
 		amountFromUnixTimestamp, amountToUnixTimestamp, commandUUID)
 
-	err = router.nodeHandler.Node.SendCommand(command)
+	err = router.nodeHandler.Node.SendCommand(r.Context(), command)
 	if err != nil {
 		logger.Error("Can't send command: " + string(command.ToBytes()) + " to node. Details: " + err.Error())
 		writeHTTPResponse(w, COMMAND_TRANSFERRING_ERROR, common.PaymentAllEquivalentsHistoryResponse{})
@@ -403,7 +403,7 @@ func (router *RoutesHandler) HistoryWithContractor(w http.ResponseWriter, r *htt
 	contractorAddresses = append(contractorAddresses, []string{equivalent}...)
 	command := handler.NewCommand(contractorAddresses...)
 
-	err = router.nodeHandler.Node.SendCommand(command)
+	err = router.nodeHandler.Node.SendCommand(r.Context(), command)
 	if err != nil {
 		logger.Error("Can't send command: " + string(command.ToBytes()) + " to node. Details: " + err.Error())
 		writeHTTPResponse(w, COMMAND_TRANSFERRING_ERROR, common.ContractorOperationsHistoryResponse{})
@@ -535,7 +535,7 @@ func (router *RoutesHandler) PaymentsAdditionalHistory(w http.ResponseWriter, r
 		"GET:history/payments/additional", offset, count, dateFromUnixTimestamp, dateToUnixTimestamp,
 		amountFromUnixTimestamp, amountToUnixTimestamp, equivalent)
 
-	err = router.nodeHandler.Node.SendCommand(command)
+	err = router.nodeHandler.Node.SendCommand(r.Context(), command)
 	if err != nil {
 		logger.Error("Can't send command: " + string(command.ToBytes()) + " to node. Details: " + err.Error())
 		writeHTTPResponse(w, COMMAND_TRANSFERRING_ERROR, common.AdditionalPaymentHistoryResponse{})
@@ -0,0 +1,109 @@
+package routes
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/common"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/handler"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/logger"
+)
+
+// Streams the whole history of the kind in CSV, NDJSON or ledger format.
+// History is requested from the engine page by page, until the records run out.
+func (router *RoutesHandler) ExportHistory(w http.ResponseWriter, r *http.Request) {
+	url, err := preprocessRequest(r)
+	if err != nil {
+		logger.Error("Bad request: invalid security parameters: " + err.Error())
+		w.WriteHeader(BAD_REQUEST)
+		return
+	}
+
+	filter := handler.HistoryExportFilter{
+		Kind:                mux.Vars(r)["kind"],
+		Equivalent:          mux.Vars(r)["equivalent"],
+		ContractorAddresses: r.URL.Query()["contractor_address"],
+		AmountFrom:          r.URL.Query().Get("amount_from"),
+		AmountTo:            r.URL.Query().Get("amount_to"),
+		PageSize:            handler.DEFAULT_HISTORY_EXPORT_PAGE_SIZE,
+	}
+	// History of all the equivalents has it's own route (it's not available for the callers, restricted by equivalents).
+	if filter.Kind == handler.HISTORY_EXPORT_KIND_PAYMENTS_ALL {
+		logger.Error("Bad request: invalid kind parameter: " + url)
+		w.WriteHeader(BAD_REQUEST)
+		return
+	}
+	if filter.Kind == "" {
+		filter.Kind = handler.HISTORY_EXPORT_KIND_PAYMENTS_ALL
+	}
+
+	pageSize := r.URL.Query().Get("page_size")
+	if pageSize != "" {
+		filter.PageSize, err = strconv.Atoi(pageSize)
+		if err != nil {
+			logger.Error("Bad request: invalid page_size parameter: " + url)
+			w.WriteHeader(BAD_REQUEST)
+			return
+		}
+	}
+
+	filter.DateFrom, err = handler.ParseHistoryExportDate(r.URL.Query().Get("date_from"))
+	if err != nil {
+		logger.Error("Bad request: invalid date_from parameter: " + url)
+		w.WriteHeader(BAD_REQUEST)
+		return
+	}
+	filter.DateTo, err = handler.ParseHistoryExportDate(r.URL.Query().Get("date_to"))
+	if err != nil {
+		logger.Error("Bad request: invalid date_to parameter: " + url)
+		w.WriteHeader(BAD_REQUEST)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = handler.HISTORY_EXPORT_FORMAT_NDJSON
+	}
+	err = filter.Validate(format)
+	if err != nil {
+		logger.Error("Bad request: " + err.Error() + ": " + url)
+		w.WriteHeader(BAD_REQUEST)
+		return
+	}
+
+	// Command processing.
+	// This command may execute relatively slow.
+	// Headers are written with the first page, so the failure of the first command could be still reported.
+	writer := handler.NewHistoryWriter(format, w)
+	controller := http.NewResponseController(w)
+	isStarted := false
+	code, err := router.nodeHandler.ExportHistory(r.Context(), filter, func(records []common.HistoryExportRecord) error {
+		if !isStarted {
+			isStarted = true
+			w.Header().Set("Content-Type", handler.HistoryExportContentType(format))
+			w.WriteHeader(OK)
+		}
+		err := writer.Write(records)
+		if err != nil {
+			return err
+		}
+		// Flushing is not supported by some writers, the records are sent with the response end then.
+		_ = controller.Flush()
+		return nil
+	})
+	if err != nil {
+		logger.Error("Can't write history export: " + url + ". Details: " + err.Error())
+		return
+	}
+	if code == OK {
+		return
+	}
+	if !isStarted {
+		writeHTTPResponse(w, code, common.HistoryExportResponse{})
+		return
+	}
+	// Status is already sent, so the response is aborted for the client to notice that it's incomplete.
+	logger.Error("History export is interrupted with the code " + strconv.Itoa(code) + ": " + url)
+	panic(http.ErrAbortHandler)
+}
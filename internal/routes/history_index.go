@@ -0,0 +1,122 @@
+package routes
+
+import (
+	"math/big"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/common"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/handler"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/historyindex"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/logger"
+)
+
+// Queries the local history index (see historyindex package).
+// Optional query parameters: contractor, direction, amount_from, amount_to, payload (substring), date_from, date_to,
+// sort (timestamp, -timestamp, amount, -amount), limit and cursor (next_cursor of the previous page).
+func (router *RoutesHandler) HistoryIndexRecords(w http.ResponseWriter, r *http.Request) {
+	url, err := preprocessRequest(r)
+	if err != nil {
+		logger.Error("Bad request: invalid security parameters: " + err.Error())
+		w.WriteHeader(BAD_REQUEST)
+		return
+	}
+
+	kind := mux.Vars(r)["kind"]
+	if kind != historyindex.KIND_PAYMENTS && kind != historyindex.KIND_SETTLEMENT_LINES {
+		logger.Error("Bad request: invalid kind parameter: " + url)
+		w.WriteHeader(BAD_REQUEST)
+		return
+	}
+
+	equivalent, isParamPresent := mux.Vars(r)["equivalent"]
+	if !isParamPresent || !common.ValidateInt(equivalent) {
+		logger.Error("Bad request: invalid equivalent parameter: " + url)
+		w.WriteHeader(BAD_REQUEST)
+		return
+	}
+
+	query := historyindex.Query{
+		Contractor: r.URL.Query().Get("contractor"),
+		Direction:  r.URL.Query().Get("direction"),
+		Payload:    r.URL.Query().Get("payload"),
+		Sort:       r.URL.Query().Get("sort"),
+		Cursor:     r.URL.Query().Get("cursor"),
+	}
+
+	amountFrom := r.URL.Query().Get("amount_from")
+	if amountFrom != "" {
+		if !common.ValidateSettlementLineAmount(amountFrom) {
+			logger.Error("Bad request: invalid amount_from parameter: " + url)
+			w.WriteHeader(BAD_REQUEST)
+			return
+		}
+		query.AmountFrom, _ = new(big.Int).SetString(amountFrom, 10)
+	}
+
+	amountTo := r.URL.Query().Get("amount_to")
+	if amountTo != "" {
+		if !common.ValidateSettlementLineAmount(amountTo) {
+			logger.Error("Bad request: invalid amount_to parameter: " + url)
+			w.WriteHeader(BAD_REQUEST)
+			return
+		}
+		query.AmountTo, _ = new(big.Int).SetString(amountTo, 10)
+	}
+
+	query.DateFrom, err = handler.ParseHistoryExportDate(r.URL.Query().Get("date_from"))
+	if err != nil {
+		logger.Error("Bad request: invalid date_from parameter: " + url)
+		w.WriteHeader(BAD_REQUEST)
+		return
+	}
+	query.DateTo, err = handler.ParseHistoryExportDate(r.URL.Query().Get("date_to"))
+	if err != nil {
+		logger.Error("Bad request: invalid date_to parameter: " + url)
+		w.WriteHeader(BAD_REQUEST)
+		return
+	}
+
+	if query.Sort != "" && !historyindex.IsSortValid(query.Sort) {
+		logger.Error("Bad request: invalid sort parameter: " + url)
+		w.WriteHeader(BAD_REQUEST)
+		return
+	}
+
+	limit := r.URL.Query().Get("limit")
+	if limit != "" {
+		query.Limit, err = strconv.Atoi(limit)
+		if err != nil || query.Limit < 1 || query.Limit > historyindex.MAX_QUERY_LIMIT {
+			logger.Error("Bad request: invalid limit parameter: " + url)
+			w.WriteHeader(BAD_REQUEST)
+			return
+		}
+	}
+
+	response, err := historyindex.Records(kind, equivalent, query)
+	switch err {
+	case nil:
+		writeHTTPResponse(w, OK, response)
+	case historyindex.ErrIndexIsDisabled:
+		logger.Error("History index is disabled: " + url)
+		writeHTTPResponse(w, NOT_FOUND, common.HistoryIndexResponse{})
+	case historyindex.ErrInvalidCursor:
+		logger.Error("Bad request: invalid cursor parameter: " + url)
+		w.WriteHeader(BAD_REQUEST)
+	default:
+		logger.Error("Can't query history index. Details: " + err.Error())
+		writeServerError("History index error", w)
+	}
+}
+
+// Returns the state of the history index synchronization.
+func (router *RoutesHandler) HistoryIndexStatus(w http.ResponseWriter, r *http.Request) {
+	_, err := preprocessRequest(r)
+	if err != nil {
+		logger.Error("Bad request: invalid security parameters: " + err.Error())
+		w.WriteHeader(BAD_REQUEST)
+		return
+	}
+	writeHTTPResponse(w, OK, historyindex.Status())
+}
@@ -0,0 +1,292 @@
+package routes
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/auth"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/common"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/conf"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/handler"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/logger"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/store"
+)
+
+var (
+	IDEMPOTENCY_KEY_HEADER      = "Idempotency-Key"
+	IDEMPOTENCY_REPLAYED_HEADER = "Idempotent-Replayed"
+	IDEMPOTENCY_KEY_MAX_LENGTH  = 255
+	IDEMPOTENCY_BUCKET          = "idempotency"
+
+	DEFAULT_IDEMPOTENCY_TTL = time.Hour * 24
+
+	// Pending payment (which outcome is unknown) is executed again
+	// only after this period since it's start, so the engine has surely finished the original one.
+	IDEMPOTENCY_RESOLVE_DELAY = time.Second * time.Duration(2*common.PAYMENT_OPERATION_TIMEOUT)
+
+	IDEMPOTENCY_CLEANUP_INTERVAL = time.Hour
+
+	IDEMPOTENCY_STATE_PENDING   = "pending"
+	IDEMPOTENCY_STATE_COMPLETED = "completed"
+)
+
+var (
+	errIdempotencyKeyReused        = errors.New("idempotency key was already used with other request parameters")
+	errIdempotentPaymentUnresolved = errors.New("outcome of the previous payment with this idempotency key is not known yet")
+)
+
+// Persisted state of the payment, that was requested with Idempotency-Key.
+type idempotencyRecord struct {
+	Fingerprint string                 `json:"fingerprint"`
+	Caller      string                 `json:"caller"`
+	State       string                 `json:"state"`
+	Order       handler.PaymentOrder   `json:"order"`
+	Outcome     handler.PaymentOutcome `json:"outcome"`
+	CreatedAt   time.Time              `json:"created_at"`
+	CompletedAt time.Time              `json:"completed_at,omitempty"`
+}
+
+func (r *idempotencyRecord) isExpired(now time.Time) bool {
+	ttl := conf.Params.Payments.IdempotencyTTL
+	if ttl <= 0 {
+		ttl = DEFAULT_IDEMPOTENCY_TTL
+	}
+	if r.State == IDEMPOTENCY_STATE_COMPLETED {
+		return now.Sub(r.CompletedAt) > ttl
+	}
+	return now.Sub(r.CreatedAt) > ttl
+}
+
+// Payments with Idempotency-Key.
+// Records are persisted in the local store, so the results survive restarts of the process.
+// Payments, that are executed by this process right now, are tracked in memory,
+// so duplicates could wait for the original request.
+type idempotentPayments struct {
+	lock          sync.Mutex
+	inFlight      map[string]chan struct{}
+	lastCleanupAt time.Time
+}
+
+var payments = &idempotentPayments{inFlight: make(map[string]chan struct{})}
+
+// Result of the attempt to start processing of the idempotent payment.
+type idempotencyBeginning struct {
+	// Is set if the payment is processed by the other request right now.
+	wait chan struct{}
+	// Is set if the request became the owner of the record and must process (or resolve) it.
+	isOwner bool
+	// Is set if the record was created by one of the previous attempts,
+	// which outcome is unknown, so the payment could be done already.
+	isResumed bool
+	record    *idempotencyRecord
+}
+
+func idempotencyStoreKey(caller, idempotencyKey string) string {
+	hash := sha256.Sum256([]byte(caller + "\n" + idempotencyKey))
+	return hex.EncodeToString(hash[:])
+}
+
+// Returns UUID of the payment command, that is derived from the Idempotency-Key of the caller.
+// It's used when the client doesn't pass transaction_uuid, so the retries are pointing to the same command.
+func idempotentCommandUUID(caller, idempotencyKey string) uuid.UUID {
+	return uuid.NewSHA1(uuid.NameSpaceOID, []byte(idempotencyStoreKey(caller, idempotencyKey)))
+}
+
+// Returns fingerprint of the payment request parameters.
+// UUID of the command is included only if it was passed by the client.
+func paymentFingerprint(order handler.PaymentOrder, transactionUUID string) string {
+	js, _ := json.Marshal([]interface{}{
+		order.Equivalent, order.ContractorAddresses, order.Amount, order.Payload, transactionUUID})
+	hash := sha256.Sum256(js)
+	return hex.EncodeToString(hash[:])
+}
+
+func (p *idempotentPayments) begin(key, fingerprint, caller string, order handler.PaymentOrder) (*idempotencyBeginning, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	wait, isPresent := p.inFlight[key]
+	if isPresent {
+		return &idempotencyBeginning{wait: wait}, nil
+	}
+
+	bucket, err := store.OpenBucket(IDEMPOTENCY_BUCKET)
+	if err != nil {
+		return nil, err
+	}
+	unlock, err := bucket.Lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	now := time.Now()
+	if now.Sub(p.lastCleanupAt) >= IDEMPOTENCY_CLEANUP_INTERVAL {
+		p.lastCleanupAt = now
+		go removeExpiredIdempotencyRecords()
+	}
+
+	record := &idempotencyRecord{}
+	isPresent, err = bucket.Get(key, record)
+	if err != nil {
+		return nil, err
+	}
+	if isPresent && record.isExpired(now) {
+		isPresent = false
+	}
+
+	if isPresent {
+		if record.Fingerprint != fingerprint {
+			return nil, errIdempotencyKeyReused
+		}
+		if record.State == IDEMPOTENCY_STATE_COMPLETED {
+			return &idempotencyBeginning{record: record}, nil
+		}
+		// Payment was started, but it's outcome is unknown
+		// (engine didn't answer in time or the process was restarted).
+		p.inFlight[key] = make(chan struct{})
+		return &idempotencyBeginning{record: record, isOwner: true, isResumed: true}, nil
+	}
+
+	record = &idempotencyRecord{
+		Fingerprint: fingerprint,
+		Caller:      caller,
+		State:       IDEMPOTENCY_STATE_PENDING,
+		Order:       order,
+		CreatedAt:   now,
+	}
+	// Record must be persisted before the payment is sent to the engine,
+	// otherwise the payment could be duplicated after the restart.
+	err = bucket.Put(key, record)
+	if err != nil {
+		return nil, err
+	}
+	p.inFlight[key] = make(chan struct{})
+	return &idempotencyBeginning{record: record, isOwner: true}, nil
+}
+
+// Persists the outcome of the payment and releases the requests, that are waiting for it.
+func (p *idempotentPayments) finish(key string, record *idempotencyRecord, outcome handler.PaymentOutcome) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	err := p.persistOutcome(key, record, outcome)
+	if err != nil {
+		logger.Error("Can't persist idempotency record of command " + record.Order.CommandUUID.String() + ". Details: " + err.Error())
+	}
+
+	close(p.inFlight[key])
+	delete(p.inFlight, key)
+}
+
+func (p *idempotentPayments) persistOutcome(key string, record *idempotencyRecord, outcome handler.PaymentOutcome) error {
+	bucket, err := store.OpenBucket(IDEMPOTENCY_BUCKET)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case outcome.Code == COMMAND_TRANSFERRING_ERROR || outcome.Code == SERVER_ERROR:
+		// Payment was not sent to the engine, so it could be safely requested again.
+		return bucket.Delete(key)
+
+	case outcome.IsUnknown():
+		// Record stays pending, outcome would be resolved on the next retry.
+		return nil
+
+	default:
+		record.State = IDEMPOTENCY_STATE_COMPLETED
+		record.Outcome = outcome
+		record.CompletedAt = time.Now()
+		return bucket.Put(key, record)
+	}
+}
+
+func removeExpiredIdempotencyRecords() {
+	bucket, err := store.OpenBucket(IDEMPOTENCY_BUCKET)
+	if err != nil {
+		logger.Error("Can't open idempotency records. Details: " + err.Error())
+		return
+	}
+	keys, err := bucket.Keys()
+	if err != nil {
+		logger.Error("Can't list idempotency records. Details: " + err.Error())
+		return
+	}
+
+	now := time.Now()
+	for _, key := range keys {
+		record := &idempotencyRecord{}
+		isPresent, err := bucket.Get(key, record)
+		if err != nil || !isPresent || !record.isExpired(now) {
+			continue
+		}
+		err = bucket.Delete(key)
+		if err != nil {
+			logger.Error("Can't remove expired idempotency record. Details: " + err.Error())
+		}
+	}
+}
+
+// Executes the payment only once per Idempotency-Key of the caller.
+// Returns outcome of the payment and true if the outcome was stored by one of the previous requests.
+// Returns error if the key was used for the other payment parameters,
+// or if the outcome of the previous attempt is still unknown.
+func (router *RoutesHandler) executeIdempotentPayment(
+	ctx context.Context, idempotencyKey string, order handler.PaymentOrder, transactionUUID string,
+) (handler.PaymentOutcome, bool, error) {
+
+	callerName := ""
+	if caller := auth.CallerFromContext(ctx); caller != nil {
+		callerName = caller.Name
+	}
+	key := idempotencyStoreKey(callerName, idempotencyKey)
+	fingerprint := paymentFingerprint(order, transactionUUID)
+
+	for {
+		beginning, err := payments.begin(key, fingerprint, callerName, order)
+		if err != nil {
+			return handler.PaymentOutcome{}, false, err
+		}
+
+		if beginning.wait != nil {
+			// Duplicate of the payment, that is processed right now.
+			select {
+			case <-beginning.wait:
+				continue
+			case <-ctx.Done():
+				return handler.PaymentOutcome{}, false, ctx.Err()
+			}
+		}
+
+		record := beginning.record
+		if !beginning.isOwner {
+			return record.Outcome, true, nil
+		}
+
+		if beginning.isResumed {
+			code, transactionUUID := router.nodeHandler.FindTransactionByCommandUUID(ctx, record.Order.CommandUUID)
+			if code == OK && transactionUUID != "" {
+				outcome := handler.PaymentOutcome{Code: CREATED, TransactionUUID: transactionUUID}
+				payments.finish(key, record, outcome)
+				return outcome, true, nil
+			}
+			if code != OK || time.Since(record.CreatedAt) < IDEMPOTENCY_RESOLVE_DELAY {
+				// Engine could still process the original payment.
+				payments.finish(key, record, handler.PaymentOutcome{Code: NODE_IS_INACCESSIBLE})
+				return handler.PaymentOutcome{}, false, errIdempotentPaymentUnresolved
+			}
+			logger.Info("Payment of command " + record.Order.CommandUUID.String() + " was not found, it is executed again")
+		}
+
+		outcome := router.nodeHandler.ExecutePayment(ctx, record.Order)
+		payments.finish(key, record, outcome)
+		return outcome, false, nil
+	}
+}
@@ -0,0 +1,272 @@
+package routes
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/auth"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/handler"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/store"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/store/storetest"
+)
+
+func newTestOrder(amount string) handler.PaymentOrder {
+	return handler.PaymentOrder{
+		CommandUUID:         uuid.New(),
+		ContractorAddresses: []string{"12-127.0.0.1:2000"},
+		Amount:              amount,
+		Equivalent:          "1",
+	}
+}
+
+func TestIdempotentPaymentsBegin(t *testing.T) {
+	order := newTestOrder("100")
+	fingerprint := paymentFingerprint(order, "")
+	otherFingerprint := paymentFingerprint(newTestOrder("200"), "")
+
+	// Stored record of the previous request with the key.
+	type previous struct {
+		state     string
+		outcome   handler.PaymentOutcome
+		createdAt time.Time
+	}
+	cases := []struct {
+		name        string
+		previous    *previous
+		inFlight    bool
+		fingerprint string
+
+		expectedErr       error
+		expectedWait      bool
+		expectedOwner     bool
+		expectedResumed   bool
+		expectedReplayed  bool
+		expectedOutcome   int
+		expectedPersisted string
+	}{
+		{
+			name:              "new key",
+			fingerprint:       fingerprint,
+			expectedOwner:     true,
+			expectedPersisted: IDEMPOTENCY_STATE_PENDING,
+		},
+		{
+			name:              "payment of the key is in flight",
+			previous:          &previous{state: IDEMPOTENCY_STATE_PENDING, createdAt: time.Now()},
+			inFlight:          true,
+			fingerprint:       fingerprint,
+			expectedWait:      true,
+			expectedPersisted: IDEMPOTENCY_STATE_PENDING,
+		},
+		{
+			name: "payment of the key is completed",
+			previous: &previous{
+				state:     IDEMPOTENCY_STATE_COMPLETED,
+				outcome:   handler.PaymentOutcome{Code: CREATED, TransactionUUID: "tx"},
+				createdAt: time.Now(),
+			},
+			fingerprint:       fingerprint,
+			expectedReplayed:  true,
+			expectedOutcome:   CREATED,
+			expectedPersisted: IDEMPOTENCY_STATE_COMPLETED,
+		},
+		{
+			name:              "outcome of the previous attempt is unknown",
+			previous:          &previous{state: IDEMPOTENCY_STATE_PENDING, createdAt: time.Now()},
+			fingerprint:       fingerprint,
+			expectedOwner:     true,
+			expectedResumed:   true,
+			expectedPersisted: IDEMPOTENCY_STATE_PENDING,
+		},
+		{
+			name: "key is reused with the other body",
+			previous: &previous{
+				state:     IDEMPOTENCY_STATE_COMPLETED,
+				outcome:   handler.PaymentOutcome{Code: CREATED, TransactionUUID: "tx"},
+				createdAt: time.Now(),
+			},
+			fingerprint:       otherFingerprint,
+			expectedErr:       errIdempotencyKeyReused,
+			expectedPersisted: IDEMPOTENCY_STATE_COMPLETED,
+		},
+		{
+			name: "key of the expired record is reused with the other body",
+			previous: &previous{
+				state:     IDEMPOTENCY_STATE_COMPLETED,
+				outcome:   handler.PaymentOutcome{Code: CREATED, TransactionUUID: "tx"},
+				createdAt: time.Now().Add(-2 * DEFAULT_IDEMPOTENCY_TTL),
+			},
+			fingerprint:       otherFingerprint,
+			expectedOwner:     true,
+			expectedPersisted: IDEMPOTENCY_STATE_PENDING,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			storetest.UseTempDir(t)
+			bucket, err := store.OpenBucket(IDEMPOTENCY_BUCKET)
+			if err != nil {
+				t.Fatal(err)
+			}
+			p := &idempotentPayments{inFlight: make(map[string]chan struct{}), lastCleanupAt: time.Now()}
+			key := idempotencyStoreKey("caller", "key-1")
+			if c.previous != nil {
+				err = bucket.Put(key, &idempotencyRecord{
+					Fingerprint: fingerprint,
+					Caller:      "caller",
+					State:       c.previous.state,
+					Order:       order,
+					Outcome:     c.previous.outcome,
+					CreatedAt:   c.previous.createdAt,
+					CompletedAt: c.previous.createdAt,
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			if c.inFlight {
+				p.inFlight[key] = make(chan struct{})
+			}
+
+			beginning, err := p.begin(key, c.fingerprint, "caller", order)
+			if err != c.expectedErr {
+				t.Fatalf("expected error %v, got %v", c.expectedErr, err)
+			}
+			if err == nil {
+				if (beginning.wait != nil) != c.expectedWait {
+					t.Fatalf("expected wait %v, got %v", c.expectedWait, beginning.wait != nil)
+				}
+				if beginning.isOwner != c.expectedOwner || beginning.isResumed != c.expectedResumed {
+					t.Fatalf("expected owner %v and resumed %v, got %v and %v",
+						c.expectedOwner, c.expectedResumed, beginning.isOwner, beginning.isResumed)
+				}
+				isReplayed := beginning.wait == nil && !beginning.isOwner
+				if isReplayed != c.expectedReplayed {
+					t.Fatalf("expected replayed %v, got %v", c.expectedReplayed, isReplayed)
+				}
+				if isReplayed && beginning.record.Outcome.Code != c.expectedOutcome {
+					t.Fatalf("expected outcome %d, got %d", c.expectedOutcome, beginning.record.Outcome.Code)
+				}
+				_, isInFlight := p.inFlight[key]
+				if isInFlight != (c.expectedOwner || c.inFlight) {
+					t.Fatalf("unexpected in flight state %v", isInFlight)
+				}
+			}
+
+			record := &idempotencyRecord{}
+			isPresent, err := bucket.Get(key, record)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if isPresent != (c.expectedPersisted != "") || record.State != c.expectedPersisted {
+				t.Fatalf("expected persisted state %q, got %q", c.expectedPersisted, record.State)
+			}
+		})
+	}
+}
+
+func TestIdempotentPaymentsFinish(t *testing.T) {
+	cases := []struct {
+		name              string
+		outcome           handler.PaymentOutcome
+		expectedPersisted string
+	}{
+		{"payment is done", handler.PaymentOutcome{Code: CREATED, TransactionUUID: "tx"}, IDEMPOTENCY_STATE_COMPLETED},
+		{"payment is refused", handler.PaymentOutcome{Code: ENGINE_NO_EQUIVALENT}, IDEMPOTENCY_STATE_COMPLETED},
+		{"outcome is unknown", handler.PaymentOutcome{Code: NODE_IS_INACCESSIBLE}, IDEMPOTENCY_STATE_PENDING},
+		{"payment is not sent to the engine", handler.PaymentOutcome{Code: COMMAND_TRANSFERRING_ERROR}, ""},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			storetest.UseTempDir(t)
+			p := &idempotentPayments{inFlight: make(map[string]chan struct{}), lastCleanupAt: time.Now()}
+			key := idempotencyStoreKey("caller", "key-1")
+			order := newTestOrder("100")
+			beginning, err := p.begin(key, paymentFingerprint(order, ""), "caller", order)
+			if err != nil {
+				t.Fatal(err)
+			}
+			wait := p.inFlight[key]
+
+			p.finish(key, beginning.record, c.outcome)
+
+			select {
+			case <-wait:
+			default:
+				t.Fatal("waiting requests are not released")
+			}
+			if _, isInFlight := p.inFlight[key]; isInFlight {
+				t.Fatal("key stays in flight")
+			}
+			bucket, err := store.OpenBucket(IDEMPOTENCY_BUCKET)
+			if err != nil {
+				t.Fatal(err)
+			}
+			record := &idempotencyRecord{}
+			isPresent, err := bucket.Get(key, record)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if isPresent != (c.expectedPersisted != "") || record.State != c.expectedPersisted {
+				t.Fatalf("expected persisted state %q, got %q", c.expectedPersisted, record.State)
+			}
+			if c.expectedPersisted == IDEMPOTENCY_STATE_COMPLETED && record.Outcome != c.outcome {
+				t.Fatalf("expected outcome %+v, got %+v", c.outcome, record.Outcome)
+			}
+		})
+	}
+}
+
+// Node is not needed: replays are answered from the store without sending the payment again.
+func TestExecuteIdempotentPaymentReplay(t *testing.T) {
+	storetest.UseTempDir(t)
+	router := NewRoutesHandler(nil)
+	ctx := auth.WithCaller(context.Background(), &auth.Caller{Name: "caller"})
+	order := newTestOrder("100")
+	key := idempotencyStoreKey("caller", "key-1")
+
+	// Payment of the key is executed by the other request right now.
+	beginning, err := payments.begin(key, paymentFingerprint(order, ""), "caller", order)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type result struct {
+		outcome    handler.PaymentOutcome
+		isReplayed bool
+		err        error
+	}
+	duplicate := make(chan result)
+	go func() {
+		outcome, isReplayed, err := router.executeIdempotentPayment(ctx, "key-1", newTestOrder("100"), "")
+		duplicate <- result{outcome, isReplayed, err}
+	}()
+
+	select {
+	case <-duplicate:
+		t.Fatal("duplicate doesn't wait for the payment in flight")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	outcome := handler.PaymentOutcome{Code: CREATED, TransactionUUID: "tx"}
+	payments.finish(key, beginning.record, outcome)
+	r := <-duplicate
+	if r.err != nil || !r.isReplayed || r.outcome != outcome {
+		t.Fatalf("unexpected replay of the payment in flight: %+v", r)
+	}
+
+	// Completed payment is replayed to the later requests too.
+	replayed, isReplayed, err := router.executeIdempotentPayment(ctx, "key-1", newTestOrder("100"), "")
+	if err != nil || !isReplayed || replayed != outcome {
+		t.Fatalf("unexpected replay of the completed payment: %+v, %v, %v", replayed, isReplayed, err)
+	}
+
+	// Key is reused with the other body.
+	_, _, err = router.executeIdempotentPayment(ctx, "key-1", newTestOrder("200"), "")
+	if err != errIdempotencyKeyReused {
+		t.Fatalf("expected %v, got %v", errIdempotencyKeyReused, err)
+	}
+}
@@ -0,0 +1,208 @@
+package routes
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/common"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/jobs"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/logger"
+)
+
+var (
+	JOBS_PATH = "/api/v1/jobs/"
+
+	// Seconds between the items of the job, if it's not set by the request.
+	DEFAULT_JOB_DELAY = 5
+)
+
+// Lists background jobs from the newest ones, optionally filtered by the kind and the state.
+func (router *RoutesHandler) ListJobs(w http.ResponseWriter, r *http.Request) {
+	_, err := preprocessRequest(r)
+	if err != nil {
+		logger.Error("Bad request: invalid security parameters: " + err.Error())
+		w.WriteHeader(BAD_REQUEST)
+		return
+	}
+
+	allJobs, err := jobs.List()
+	if err != nil {
+		logger.Error("Can't read jobs. Details: " + err.Error())
+		writeServerError("Jobs store error", w)
+		return
+	}
+
+	kind := r.URL.Query().Get("kind")
+	state := r.URL.Query().Get("state")
+	response := common.JobsResponse{Jobs: []common.JobResponse{}}
+	for _, job := range allJobs {
+		if (kind != "" && job.Kind != kind) || (state != "" && job.State != state) {
+			continue
+		}
+		response.Jobs = append(response.Jobs, job.Response(false))
+	}
+	response.Count = len(response.Jobs)
+	writeHTTPResponse(w, OK, response)
+}
+
+// Creates background job of the kind. Parameters of the kind are passed as the form values.
+func (router *RoutesHandler) CreateJob(w http.ResponseWriter, r *http.Request) {
+	url, err := preprocessRequest(r)
+	if err != nil {
+		logger.Error("Bad request: invalid security parameters: " + err.Error())
+		w.WriteHeader(BAD_REQUEST)
+		return
+	}
+
+	err = r.ParseForm()
+	if err != nil {
+		logger.Error("Bad request: " + err.Error() + ": " + url)
+		w.WriteHeader(BAD_REQUEST)
+		return
+	}
+	parameters := map[string]string{}
+	for name := range r.Form {
+		if name != "kind" && name != "delay" {
+			parameters[name] = r.Form.Get(name)
+		}
+	}
+	router.createJob(w, r, url, r.Form.Get("kind"), parameters)
+}
+
+// Returns background job together with it's items.
+func (router *RoutesHandler) GetJob(w http.ResponseWriter, r *http.Request) {
+	url, err := preprocessRequest(r)
+	if err != nil {
+		logger.Error("Bad request: invalid security parameters: " + err.Error())
+		w.WriteHeader(BAD_REQUEST)
+		return
+	}
+
+	jobID, isValid := requestedJobID(r, url)
+	if !isValid {
+		w.WriteHeader(BAD_REQUEST)
+		return
+	}
+	job, err := jobs.Get(jobID)
+	writeJobResult(w, job, err)
+}
+
+// Cancels background job, remaining items are not processed.
+func (router *RoutesHandler) CancelJob(w http.ResponseWriter, r *http.Request) {
+	url, err := preprocessRequest(r)
+	if err != nil {
+		logger.Error("Bad request: invalid security parameters: " + err.Error())
+		w.WriteHeader(BAD_REQUEST)
+		return
+	}
+
+	jobID, isValid := requestedJobID(r, url)
+	if !isValid {
+		w.WriteHeader(BAD_REQUEST)
+		return
+	}
+	job, err := jobs.Cancel(r.Context(), jobID)
+	writeJobResult(w, job, err)
+}
+
+// Pauses background job before it's next item.
+func (router *RoutesHandler) PauseJob(w http.ResponseWriter, r *http.Request) {
+	url, err := preprocessRequest(r)
+	if err != nil {
+		logger.Error("Bad request: invalid security parameters: " + err.Error())
+		w.WriteHeader(BAD_REQUEST)
+		return
+	}
+
+	jobID, isValid := requestedJobID(r, url)
+	if !isValid {
+		w.WriteHeader(BAD_REQUEST)
+		return
+	}
+	job, err := jobs.Pause(r.Context(), jobID)
+	writeJobResult(w, job, err)
+}
+
+// Resumes paused (or failed) background job.
+func (router *RoutesHandler) ResumeJob(w http.ResponseWriter, r *http.Request) {
+	url, err := preprocessRequest(r)
+	if err != nil {
+		logger.Error("Bad request: invalid security parameters: " + err.Error())
+		w.WriteHeader(BAD_REQUEST)
+		return
+	}
+
+	jobID, isValid := requestedJobID(r, url)
+	if !isValid {
+		w.WriteHeader(BAD_REQUEST)
+		return
+	}
+	job, err := jobs.Resume(r.Context(), jobID)
+	writeJobResult(w, job, err)
+}
+
+// Creates the job and responds with it's status URL.
+// Delay between the items is read from the "delay" parameter (seconds).
+func (router *RoutesHandler) createJob(
+	w http.ResponseWriter, r *http.Request, url string, kind string, parameters map[string]string,
+) {
+	delay := DEFAULT_JOB_DELAY
+	if r.FormValue("delay") != "" {
+		var err error
+		delay, err = strconv.Atoi(r.FormValue("delay"))
+		if err != nil || delay < 0 {
+			logger.Error("Bad request: invalid delay parameter: " + url)
+			w.WriteHeader(BAD_REQUEST)
+			return
+		}
+	}
+
+	code, job, err := jobs.Create(r.Context(), router.nodeHandler, kind, parameters, time.Duration(delay)*time.Second)
+	if code == BAD_REQUEST {
+		logger.Error("Bad request: " + err.Error() + ": " + url)
+		w.WriteHeader(BAD_REQUEST)
+		return
+	}
+	if code == SERVER_ERROR {
+		logger.Error("Can't create job. Details: " + err.Error())
+		writeServerError("Jobs store error", w)
+		return
+	}
+	if code != OK {
+		logger.Error("Can't prepare items of the " + kind + " job. Node returned code " + strconv.Itoa(code))
+		writeHTTPResponse(w, code, common.JobCreatedResponse{})
+		return
+	}
+
+	statusURL := JOBS_PATH + job.ID + "/"
+	w.Header().Set("Location", statusURL)
+	writeHTTPResponse(w, ACCEPTED, common.JobCreatedResponse{ID: job.ID, StatusURL: statusURL})
+}
+
+// Returns the normalized ID of the job from the path.
+func requestedJobID(r *http.Request, url string) (string, bool) {
+	jobID := mux.Vars(r)["id"]
+	if !common.ValidateUUID(jobID) {
+		logger.Error("Bad request: invalid id parameter: " + url)
+		return "", false
+	}
+	// ID is used as the store key, so it is normalized.
+	return uuid.MustParse(jobID).String(), true
+}
+
+func writeJobResult(w http.ResponseWriter, job *jobs.Job, err error) {
+	switch err {
+	case nil:
+		writeHTTPResponse(w, OK, job.Response(true))
+	case jobs.ErrJobNotFound:
+		writeHTTPResponse(w, NOT_FOUND, common.JobResponse{})
+	case jobs.ErrJobStateConflict:
+		writeHTTPResponse(w, CONFLICT, job.Response(true))
+	default:
+		logger.Error("Can't process job. Details: " + err.Error())
+		writeServerError("Jobs store error", w)
+	}
+}
@@ -0,0 +1,26 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/logger"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/maintenance"
+)
+
+// Returns the scheduled maintenance tasks with their next and last runs.
+func (router *RoutesHandler) MaintenanceStatus(w http.ResponseWriter, r *http.Request) {
+	_, err := preprocessRequest(r)
+	if err != nil {
+		logger.Error("Bad request: invalid security parameters: " + err.Error())
+		w.WriteHeader(BAD_REQUEST)
+		return
+	}
+
+	response, err := maintenance.Status()
+	if err != nil {
+		logger.Error("Can't read status of the maintenance tasks. Details: " + err.Error())
+		writeServerError("Maintenance store error", w)
+		return
+	}
+	writeHTTPResponse(w, OK, response)
+}
@@ -0,0 +1,22 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/logger"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/metrics"
+)
+
+var metricsHandler = metrics.Handler()
+
+// Exposes metrics of the CLI in the Prometheus text format.
+func (router *RoutesHandler) Metrics(w http.ResponseWriter, r *http.Request) {
+	_, err := preprocessRequest(r)
+	if err != nil {
+		logger.Error("Bad request: invalid security parameters: " + err.Error())
+		w.WriteHeader(BAD_REQUEST)
+		return
+	}
+
+	metricsHandler.ServeHTTP(w, r)
+}
@@ -37,7 +37,7 @@ func (router *RoutesHandler) SetTestingFlags(w http.ResponseWriter, r *http.Requ
 	if forbiddenNodeAddress == "" {
 		command := handler.NewCommand(
 			"SET:subsystems_controller/flags", flags)
-		err := router.nodeHandler.Node.SendCommand(command)
+		err := router.nodeHandler.Node.SendCommand(r.Context(), command)
 		if err != nil {
 			logger.Error("Can't send command: " + string(command.ToBytes()) + " to node. Details: " + err.Error())
 			writeHTTPResponse(w, NODE_IS_INACCESSIBLE, common.ControlResponse{})
@@ -59,7 +59,7 @@ func (router *RoutesHandler) SetTestingFlags(w http.ResponseWriter, r *http.Requ
 	if forbiddenAmount == "" {
 		command := handler.NewCommand(
 			"SET:subsystems_controller/flags", flags, typeAndAddress[0], typeAndAddress[1])
-		err := router.nodeHandler.Node.SendCommand(command)
+		err := router.nodeHandler.Node.SendCommand(r.Context(), command)
 		if err != nil {
 			logger.Error("Can't send command: " + string(command.ToBytes()) + " to node. Details: " + err.Error())
 			writeHTTPResponse(w, NODE_IS_INACCESSIBLE, common.ControlResponse{})
@@ -74,7 +74,7 @@ func (router *RoutesHandler) SetTestingFlags(w http.ResponseWriter, r *http.Requ
 	command := handler.NewCommand(
 		"SET:subsystems_controller/flags", flags, typeAndAddress[0], typeAndAddress[1])
 
-	err = router.nodeHandler.Node.SendCommand(command)
+	err = router.nodeHandler.Node.SendCommand(r.Context(), command)
 	if err != nil {
 		logger.Error("Can't send command: " + string(command.ToBytes()) + " to node. Details: " + err.Error())
 		writeHTTPResponse(w, NODE_IS_INACCESSIBLE, common.ControlResponse{})
@@ -108,7 +108,7 @@ func (router *RoutesHandler) SetSLInfluenceFlags(w http.ResponseWriter, r *http.
 	command := handler.NewCommand(
 		"SET:subsystems_controller/trust_lines_influence/flags", flags, firstParameter, secondParameter, thirdParameter)
 
-	err = router.nodeHandler.Node.SendCommand(command)
+	err = router.nodeHandler.Node.SendCommand(r.Context(), command)
 	if err != nil {
 		logger.Error("Can't send command: " + string(command.ToBytes()) + " to node. Details: " + err.Error())
 		writeHTTPResponse(w, NODE_IS_INACCESSIBLE, common.ControlResponse{})
@@ -128,7 +128,7 @@ func (router *RoutesHandler) MakeNodeBusy(w http.ResponseWriter, r *http.Request
 
 	command := handler.NewCommand("TEST:make-node-busy", interval)
 
-	err := router.nodeHandler.Node.SendCommand(command)
+	err := router.nodeHandler.Node.SendCommand(r.Context(), command)
 	if err != nil {
 		logger.Error("Can't send command: " + string(command.ToBytes()) + " to node. Details: " + err.Error())
 		writeHTTPResponse(w, NODE_IS_INACCESSIBLE, common.ControlResponse{})
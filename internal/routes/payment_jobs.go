@@ -0,0 +1,329 @@
+package routes
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/auth"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/common"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/conf"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/handler"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/logger"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/store"
+)
+
+var (
+	PAYMENT_JOBS_BUCKET    = "payment-jobs"
+	PAYMENT_JOBS_PATH      = "/api/v1/node/jobs/"
+	DEFAULT_JOBS_RETENTION = time.Hour
+
+	PAYMENT_JOB_STATE_PENDING   = "pending"
+	PAYMENT_JOB_STATE_SUCCEEDED = "succeeded"
+	PAYMENT_JOB_STATE_FAILED    = "failed"
+)
+
+// Persisted state of the asynchronous payment.
+// Job is identified by the UUID of the payment command.
+// It's not a background job of the jobs package: payment is started at once, can't be paused
+// and it's unknown outcome is resolved by the command UUID instead of being sent again.
+type paymentJob struct {
+	UUID            string `json:"uuid"`
+	State           string `json:"state"`
+	Caller          string `json:"caller"`
+	Equivalent      string `json:"equivalent"`
+	TransactionUUID string `json:"transaction_uuid,omitempty"`
+	// Fingerprint of the payment parameters, it's set only for the payments with Idempotency-Key.
+	Fingerprint     string    `json:"fingerprint,omitempty"`
+	Code            int       `json:"code,omitempty"`
+	PolicyViolation string    `json:"policy_violation,omitempty"`
+	CreatedAt       time.Time `json:"created_at"`
+	CompletedAt     time.Time `json:"completed_at,omitempty"`
+}
+
+func (j *paymentJob) response() common.PaymentJobResponse {
+	response := common.PaymentJobResponse{
+		UUID:            j.UUID,
+		State:           j.State,
+		TransactionUUID: j.TransactionUUID,
+		Code:            j.Code,
+		PolicyViolation: j.PolicyViolation,
+		CreatedAt:       j.CreatedAt.UTC().Format(time.RFC3339),
+	}
+	if !j.CompletedAt.IsZero() {
+		response.CompletedAt = j.CompletedAt.UTC().Format(time.RFC3339)
+	}
+	return response
+}
+
+func (j *paymentJob) complete(outcome handler.PaymentOutcome) {
+	j.CompletedAt = time.Now()
+	j.Code = outcome.Code
+	if outcome.IsSucceeded() {
+		j.State = PAYMENT_JOB_STATE_SUCCEEDED
+		j.TransactionUUID = outcome.TransactionUUID
+	} else {
+		j.State = PAYMENT_JOB_STATE_FAILED
+	}
+	if outcome.Violation != nil {
+		j.PolicyViolation = outcome.Violation.Code
+	}
+}
+
+func (j *paymentJob) isExpired(now time.Time) bool {
+	retention := conf.Params.Payments.JobsRetention
+	if retention <= 0 {
+		retention = DEFAULT_JOBS_RETENTION
+	}
+	return j.State != PAYMENT_JOB_STATE_PENDING && now.Sub(j.CompletedAt) > retention
+}
+
+// Jobs, that are executed by this process right now.
+// Pending jobs, that are absent here, were interrupted by the restart of the process.
+type paymentJobs struct {
+	lock          sync.Mutex
+	running       map[string]bool
+	lastCleanupAt time.Time
+}
+
+var runningPaymentJobs = &paymentJobs{running: make(map[string]bool)}
+
+func (p *paymentJobs) isRunning(jobUUID string) bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.running[jobUUID]
+}
+
+func (p *paymentJobs) setRunning(jobUUID string, isRunning bool) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if isRunning {
+		p.running[jobUUID] = true
+	} else {
+		delete(p.running, jobUUID)
+	}
+}
+
+func (p *paymentJobs) cleanupIfNeeded() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	now := time.Now()
+	if now.Sub(p.lastCleanupAt) < IDEMPOTENCY_CLEANUP_INTERVAL {
+		return
+	}
+	p.lastCleanupAt = now
+	go removeExpiredPaymentJobs()
+}
+
+func removeExpiredPaymentJobs() {
+	bucket, err := store.OpenBucket(PAYMENT_JOBS_BUCKET)
+	if err != nil {
+		logger.Error("Can't open payment jobs. Details: " + err.Error())
+		return
+	}
+	keys, err := bucket.Keys()
+	if err != nil {
+		logger.Error("Can't list payment jobs. Details: " + err.Error())
+		return
+	}
+	now := time.Now()
+	for _, key := range keys {
+		job := &paymentJob{}
+		isPresent, err := bucket.Get(key, job)
+		if err != nil || !isPresent || !job.isExpired(now) {
+			continue
+		}
+		err = bucket.Delete(key)
+		if err != nil {
+			logger.Error("Can't remove expired payment job. Details: " + err.Error())
+		}
+	}
+}
+
+// Starts the payment in the background and writes 202 with the job status URL.
+func (router *RoutesHandler) startAsyncPayment(
+	w http.ResponseWriter, r *http.Request, idempotencyKey string, order handler.PaymentOrder, transactionUUID string,
+) {
+	runningPaymentJobs.cleanupIfNeeded()
+
+	callerName := ""
+	if caller := auth.CallerFromContext(r.Context()); caller != nil {
+		callerName = caller.Name
+	}
+	if idempotencyKey != "" && transactionUUID == "" {
+		// Retries are pointing to the same job.
+		order.CommandUUID = idempotentCommandUUID(callerName, idempotencyKey)
+	}
+	job := &paymentJob{
+		UUID:       order.CommandUUID.String(),
+		State:      PAYMENT_JOB_STATE_PENDING,
+		Caller:     callerName,
+		Equivalent: order.Equivalent,
+		CreatedAt:  time.Now(),
+	}
+	if idempotencyKey != "" {
+		job.Fingerprint = paymentFingerprint(order, transactionUUID)
+	}
+
+	bucket, err := store.OpenBucket(PAYMENT_JOBS_BUCKET)
+	var present *paymentJob
+	if err == nil {
+		present, err = createPaymentJob(bucket, job)
+	}
+	if err != nil {
+		logger.Error("Can't persist payment job " + job.UUID + ". Details: " + err.Error())
+		writeServerError("Jobs store error", w)
+		return
+	}
+	if present != nil {
+		if job.Fingerprint == "" {
+			logger.Error("Bad request: payment job " + job.UUID + " already exists")
+			writeHTTPResponse(w, CONFLICT, common.AsyncPaymentResponse{})
+		} else if present.Caller != job.Caller || present.Fingerprint != job.Fingerprint {
+			logger.Error("Bad request: payment job " + job.UUID + " was started with the other parameters")
+			writeHTTPResponse(w, UNPROCESSABLE_ENTITY, common.AsyncPaymentResponse{})
+		} else {
+			w.Header().Set(IDEMPOTENCY_REPLAYED_HEADER, "true")
+			writeAsyncPaymentAccepted(w, present)
+		}
+		return
+	}
+
+	runningPaymentJobs.setRunning(job.UUID, true)
+	// Payment outlives the request, so only request's trace and caller are kept.
+	ctx := context.WithoutCancel(r.Context())
+	go func() {
+		defer runningPaymentJobs.setRunning(job.UUID, false)
+
+		var outcome handler.PaymentOutcome
+		if idempotencyKey == "" {
+			outcome = router.nodeHandler.ExecutePayment(ctx, order)
+		} else {
+			var err error
+			outcome, _, err = router.executeIdempotentPayment(ctx, idempotencyKey, order, transactionUUID)
+			if err != nil {
+				logger.Error("Can't process payment job " + job.UUID + " with Idempotency-Key. Details: " + err.Error())
+				outcome = handler.PaymentOutcome{Code: UNPROCESSABLE_ENTITY}
+				if err == errIdempotentPaymentUnresolved {
+					outcome.Code = CONFLICT
+				}
+			}
+		}
+
+		if outcome.IsUnknown() {
+			// Job stays pending, it's outcome would be resolved on the status request.
+			return
+		}
+		job.complete(outcome)
+		err := bucket.Put(job.UUID, job)
+		if err != nil {
+			logger.Error("Can't persist payment job " + job.UUID + ". Details: " + err.Error())
+		}
+	}()
+
+	writeAsyncPaymentAccepted(w, job)
+}
+
+// Persists the new job.
+// Returns the present job instead, if the job with the same UUID was already started.
+func createPaymentJob(bucket *store.Bucket, job *paymentJob) (*paymentJob, error) {
+	unlock, err := bucket.Lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	present := &paymentJob{}
+	isPresent, err := bucket.Get(job.UUID, present)
+	if err != nil {
+		return nil, err
+	}
+	if isPresent && !present.isExpired(time.Now()) {
+		return present, nil
+	}
+	return nil, bucket.Put(job.UUID, job)
+}
+
+func writeAsyncPaymentAccepted(w http.ResponseWriter, job *paymentJob) {
+	statusURL := PAYMENT_JOBS_PATH + job.UUID + "/"
+	w.Header().Set("Location", statusURL)
+	writeHTTPResponse(w, ACCEPTED, common.AsyncPaymentResponse{CommandUUID: job.UUID, StatusURL: statusURL})
+}
+
+// Returns status of the asynchronous payment.
+func (router *RoutesHandler) GetPaymentJob(w http.ResponseWriter, r *http.Request) {
+	url, err := preprocessRequest(r)
+	if err != nil {
+		logger.Error("Bad request: invalid security parameters: " + err.Error())
+		w.WriteHeader(BAD_REQUEST)
+		return
+	}
+
+	jobUUID := mux.Vars(r)["uuid"]
+	if !common.ValidateUUID(jobUUID) {
+		logger.Error("Bad request: invalid uuid parameter: " + url)
+		w.WriteHeader(BAD_REQUEST)
+		return
+	}
+	// UUID is used as the store key, so it is normalized.
+	jobUUID = uuid.MustParse(jobUUID).String()
+
+	bucket, err := store.OpenBucket(PAYMENT_JOBS_BUCKET)
+	if err != nil {
+		logger.Error("Can't open payment jobs. Details: " + err.Error())
+		writeServerError("Jobs store error", w)
+		return
+	}
+	job := &paymentJob{}
+	isPresent, err := bucket.Get(jobUUID, job)
+	if err != nil {
+		logger.Error("Can't read payment job " + jobUUID + ". Details: " + err.Error())
+		writeServerError("Jobs store error", w)
+		return
+	}
+
+	caller := auth.CallerFromContext(r.Context())
+	if isPresent && caller != nil && !caller.HasScope(auth.SCOPE_ADMIN) &&
+		(job.Caller != caller.Name || !caller.IsEquivalentAllowed(job.Equivalent)) {
+		// Jobs of the other callers are not disclosed.
+		isPresent = false
+	}
+	if !isPresent || job.isExpired(time.Now()) {
+		logger.Error("Payment job " + jobUUID + " is not found")
+		writeHTTPResponse(w, NOT_FOUND, common.PaymentJobResponse{})
+		return
+	}
+
+	if job.State == PAYMENT_JOB_STATE_PENDING && !runningPaymentJobs.isRunning(job.UUID) {
+		router.resolvePaymentJob(r.Context(), bucket, job)
+	}
+	writeHTTPResponse(w, OK, job.response())
+}
+
+// Resolves outcome of the pending job, that is not running anymore
+// (the engine didn't answer in time, or the process was restarted during the payment).
+func (router *RoutesHandler) resolvePaymentJob(ctx context.Context, bucket *store.Bucket, job *paymentJob) {
+	code, transactionUUID := router.nodeHandler.FindTransactionByCommandUUID(ctx, uuid.MustParse(job.UUID))
+	if code != OK {
+		return
+	}
+
+	if transactionUUID != "" {
+		job.complete(handler.PaymentOutcome{Code: CREATED, TransactionUUID: transactionUUID})
+	} else if time.Since(job.CreatedAt) >= IDEMPOTENCY_RESOLVE_DELAY {
+		// Engine has surely finished the payment, so it was not done.
+		job.complete(handler.PaymentOutcome{Code: NODE_IS_INACCESSIBLE})
+	} else {
+		return
+	}
+
+	err := bucket.Put(job.UUID, job)
+	if err != nil {
+		logger.Error("Can't persist payment job " + job.UUID + ". Details: " + err.Error())
+	}
+	logger.Info("Payment job " + job.UUID + " is resolved with code " + strconv.Itoa(job.Code))
+}
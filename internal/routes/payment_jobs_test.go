@@ -0,0 +1,133 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/auth"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/conf/conftest"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/handler"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/store"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/store/storetest"
+)
+
+func startTestAsyncPayment(
+	t *testing.T, caller string, idempotencyKey string, order handler.PaymentOrder,
+) *httptest.ResponseRecorder {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodPost, "/", nil)
+	r = r.WithContext(auth.WithCaller(r.Context(), &auth.Caller{Name: caller}))
+	w := httptest.NewRecorder()
+	NewRoutesHandler(nil).startAsyncPayment(w, r, idempotencyKey, order, "")
+	return w
+}
+
+func TestIdempotentCommandUUID(t *testing.T) {
+	if idempotentCommandUUID("caller", "key-1") != idempotentCommandUUID("caller", "key-1") {
+		t.Fatal("UUID of the same key is changed")
+	}
+	if idempotentCommandUUID("caller", "key-1") == idempotentCommandUUID("other", "key-1") ||
+		idempotentCommandUUID("caller", "key-1") == idempotentCommandUUID("caller", "key-2") {
+		t.Fatal("UUID of the other key is the same")
+	}
+}
+
+func TestStartAsyncPaymentWithIdempotencyKey(t *testing.T) {
+	storetest.UseTempDir(t)
+	conftest.Set(t, &runningPaymentJobs.lastCleanupAt, time.Now())
+
+	// Payment of the key is done already, so the job replays it's outcome without the engine.
+	order := newTestOrder("100")
+	key := idempotencyStoreKey("caller", "key-1")
+	beginning, err := payments.begin(key, paymentFingerprint(order, ""), "caller", order)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payments.finish(key, beginning.record, handler.PaymentOutcome{Code: CREATED, TransactionUUID: "tx"})
+
+	statusURL := PAYMENT_JOBS_PATH + idempotentCommandUUID("caller", "key-1").String() + "/"
+	w := startTestAsyncPayment(t, "caller", "key-1", newTestOrder("100"))
+	if w.Code != ACCEPTED || w.Header().Get("Location") != statusURL || w.Header().Get(IDEMPOTENCY_REPLAYED_HEADER) != "" {
+		t.Fatalf("unexpected response %d, %v", w.Code, w.Header())
+	}
+
+	bucket, err := store.OpenBucket(PAYMENT_JOBS_BUCKET)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job := &paymentJob{}
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(10 * time.Millisecond) {
+		_, err = bucket.Get(idempotentCommandUUID("caller", "key-1").String(), job)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if job.State != PAYMENT_JOB_STATE_PENDING {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("job is not completed")
+		}
+	}
+	if job.State != PAYMENT_JOB_STATE_SUCCEEDED || job.TransactionUUID != "tx" {
+		t.Fatalf("unexpected job %+v", job)
+	}
+
+	// Retry points to the same job, instead of being refused as the duplicated one.
+	w = startTestAsyncPayment(t, "caller", "key-1", newTestOrder("100"))
+	if w.Code != ACCEPTED || w.Header().Get("Location") != statusURL || w.Header().Get(IDEMPOTENCY_REPLAYED_HEADER) != "true" {
+		t.Fatalf("unexpected response of the retry %d, %v", w.Code, w.Header())
+	}
+
+	// Key is reused with the other body.
+	w = startTestAsyncPayment(t, "caller", "key-1", newTestOrder("200"))
+	if w.Code != UNPROCESSABLE_ENTITY {
+		t.Fatalf("expected %d, got %d", UNPROCESSABLE_ENTITY, w.Code)
+	}
+}
+
+func TestStartAsyncPaymentOfPresentJob(t *testing.T) {
+	storetest.UseTempDir(t)
+	conftest.Set(t, &runningPaymentJobs.lastCleanupAt, time.Now())
+	bucket, err := store.OpenBucket(PAYMENT_JOBS_BUCKET)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	order := newTestOrder("100")
+	present := &paymentJob{
+		UUID:      order.CommandUUID.String(),
+		State:     PAYMENT_JOB_STATE_PENDING,
+		Caller:    "caller",
+		CreatedAt: time.Now(),
+	}
+	err = bucket.Put(present.UUID, present)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Payment without Idempotency-Key can't be retried by the same UUID.
+	w := startTestAsyncPayment(t, "caller", "", order)
+	if w.Code != CONFLICT {
+		t.Fatalf("expected %d, got %d", CONFLICT, w.Code)
+	}
+
+	// Expired job doesn't prevent the new job.
+	present.State = PAYMENT_JOB_STATE_FAILED
+	present.CompletedAt = time.Now().Add(-2 * DEFAULT_JOBS_RETENTION)
+	err = bucket.Put(present.UUID, present)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job := &paymentJob{UUID: present.UUID, State: PAYMENT_JOB_STATE_PENDING, Caller: "caller", CreatedAt: time.Now()}
+	replaced, err := createPaymentJob(bucket, job)
+	if err != nil || replaced != nil {
+		t.Fatalf("expired job is not replaced: %+v, %v", replaced, err)
+	}
+	stored := &paymentJob{}
+	_, err = bucket.Get(job.UUID, stored)
+	if err != nil || stored.State != PAYMENT_JOB_STATE_PENDING {
+		t.Fatalf("new job is not persisted: %+v, %v", stored, err)
+	}
+}
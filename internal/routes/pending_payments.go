@@ -0,0 +1,208 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/auth"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/common"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/handler"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/logger"
+)
+
+var (
+	PENDING_PAYMENTS_PATH = "/api/v1/node/pending-payments/"
+)
+
+// Persists the payment, that must be approved, and writes 202 with the pending payment status URL.
+// Repeated request with the same Idempotency-Key returns the same pending payment.
+func (router *RoutesHandler) requestPaymentApproval(
+	w http.ResponseWriter, r *http.Request, url, idempotencyKey string, order handler.PaymentOrder, transactionUUID string,
+) {
+	if idempotencyKey != "" && transactionUUID == "" {
+		// UUID is derived from the key, so the retries are pointing to the same pending payment.
+		callerName := ""
+		if caller := auth.CallerFromContext(r.Context()); caller != nil {
+			callerName = caller.Name
+		}
+		order.CommandUUID = idempotentCommandUUID(callerName, idempotencyKey)
+	}
+
+	payment, isReplayed, err := handler.CreatePendingPayment(r.Context(), order)
+	if err != nil {
+		logger.Error("Can't create pending payment: " + url + ". Details: " + err.Error())
+		if err != handler.ErrPendingPaymentExists {
+			writeServerError("Pending payments store error", w)
+		} else if idempotencyKey != "" {
+			writeHTTPResponse(w, UNPROCESSABLE_ENTITY, common.PendingPaymentResponse{})
+		} else {
+			writeHTTPResponse(w, CONFLICT, common.PendingPaymentResponse{})
+		}
+		return
+	}
+	if isReplayed {
+		w.Header().Set(IDEMPOTENCY_REPLAYED_HEADER, "true")
+	}
+
+	w.Header().Set("Location", PENDING_PAYMENTS_PATH+payment.UUID+"/")
+	writeHTTPResponse(w, ACCEPTED, payment.Response())
+}
+
+// Lists pending payments from the newest ones.
+// Optional query parameter: state (pending, approved, executed, failed, rejected, expired).
+func (router *RoutesHandler) ListPendingPayments(w http.ResponseWriter, r *http.Request) {
+	url, err := preprocessRequest(r)
+	if err != nil {
+		logger.Error("Bad request: invalid security parameters: " + err.Error())
+		w.WriteHeader(BAD_REQUEST)
+		return
+	}
+
+	state := r.URL.Query().Get("state")
+	if state != "" && state != handler.PENDING_PAYMENT_STATE_PENDING &&
+		state != handler.PENDING_PAYMENT_STATE_APPROVED && state != handler.PENDING_PAYMENT_STATE_EXECUTED &&
+		state != handler.PENDING_PAYMENT_STATE_FAILED && state != handler.PENDING_PAYMENT_STATE_REJECTED &&
+		state != handler.PENDING_PAYMENT_STATE_EXPIRED {
+		logger.Error("Bad request: invalid state parameter: " + url)
+		w.WriteHeader(BAD_REQUEST)
+		return
+	}
+
+	payments, err := handler.PendingPayments(state)
+	if err != nil {
+		logger.Error("Can't read pending payments. Details: " + err.Error())
+		writeServerError("Pending payments store error", w)
+		return
+	}
+
+	caller := auth.CallerFromContext(r.Context())
+	response := common.PendingPaymentsResponse{Payments: []common.PendingPaymentResponse{}}
+	for _, payment := range payments {
+		if caller != nil && !caller.IsEquivalentAllowed(payment.Order.Equivalent) {
+			continue
+		}
+		response.Payments = append(response.Payments, payment.Response())
+	}
+	response.Count = len(response.Payments)
+	writeHTTPResponse(w, OK, response)
+}
+
+// Returns the pending payment.
+func (router *RoutesHandler) GetPendingPayment(w http.ResponseWriter, r *http.Request) {
+	url, err := preprocessRequest(r)
+	if err != nil {
+		logger.Error("Bad request: invalid security parameters: " + err.Error())
+		w.WriteHeader(BAD_REQUEST)
+		return
+	}
+
+	paymentUUID, isValid := pendingPaymentUUID(r)
+	if !isValid {
+		logger.Error("Bad request: invalid uuid parameter: " + url)
+		w.WriteHeader(BAD_REQUEST)
+		return
+	}
+
+	payment, err := router.nodeHandler.PendingPayment(r.Context(), paymentUUID)
+	if err == nil && !isPendingPaymentPermitted(r, payment) {
+		err = handler.ErrPendingPaymentNotFound
+	}
+	if err != nil {
+		writePendingPaymentError(w, paymentUUID, err)
+		return
+	}
+	writeHTTPResponse(w, OK, payment.Response())
+}
+
+// Approves the pending payment and executes it.
+// Response contains the outcome of the payment, if it is already known.
+func (router *RoutesHandler) ApprovePendingPayment(w http.ResponseWriter, r *http.Request) {
+	url, err := preprocessRequest(r)
+	if err != nil {
+		logger.Error("Bad request: invalid security parameters: " + err.Error())
+		w.WriteHeader(BAD_REQUEST)
+		return
+	}
+
+	paymentUUID, isValid := pendingPaymentUUID(r)
+	if !isValid {
+		logger.Error("Bad request: invalid uuid parameter: " + url)
+		w.WriteHeader(BAD_REQUEST)
+		return
+	}
+
+	payment, err := router.nodeHandler.PendingPayment(r.Context(), paymentUUID)
+	if err == nil && !isPendingPaymentPermitted(r, payment) {
+		err = handler.ErrPendingPaymentNotFound
+	}
+	if err == nil {
+		// This command may execute relatively slow.
+		payment, err = router.nodeHandler.ApprovePendingPayment(r.Context(), paymentUUID)
+	}
+	if err != nil {
+		writePendingPaymentError(w, paymentUUID, err)
+		return
+	}
+	writeHTTPResponse(w, OK, payment.Response())
+}
+
+// Rejects the pending payment.
+func (router *RoutesHandler) RejectPendingPayment(w http.ResponseWriter, r *http.Request) {
+	url, err := preprocessRequest(r)
+	if err != nil {
+		logger.Error("Bad request: invalid security parameters: " + err.Error())
+		w.WriteHeader(BAD_REQUEST)
+		return
+	}
+
+	paymentUUID, isValid := pendingPaymentUUID(r)
+	if !isValid {
+		logger.Error("Bad request: invalid uuid parameter: " + url)
+		w.WriteHeader(BAD_REQUEST)
+		return
+	}
+
+	payment, err := router.nodeHandler.PendingPayment(r.Context(), paymentUUID)
+	if err == nil && !isPendingPaymentPermitted(r, payment) {
+		err = handler.ErrPendingPaymentNotFound
+	}
+	if err == nil {
+		payment, err = handler.RejectPendingPayment(r.Context(), paymentUUID)
+	}
+	if err != nil {
+		writePendingPaymentError(w, paymentUUID, err)
+		return
+	}
+	writeHTTPResponse(w, OK, payment.Response())
+}
+
+// Returns normalized UUID of the pending payment from the path.
+func pendingPaymentUUID(r *http.Request) (string, bool) {
+	paymentUUID := mux.Vars(r)["uuid"]
+	if !common.ValidateUUID(paymentUUID) {
+		return "", false
+	}
+	// UUID is used as the store key, so it is normalized.
+	return uuid.MustParse(paymentUUID).String(), true
+}
+
+// Payments in the equivalents, that are not allowed to the caller, are not disclosed.
+func isPendingPaymentPermitted(r *http.Request, payment *handler.PendingPayment) bool {
+	caller := auth.CallerFromContext(r.Context())
+	return caller == nil || caller.IsEquivalentAllowed(payment.Order.Equivalent)
+}
+
+func writePendingPaymentError(w http.ResponseWriter, paymentUUID string, err error) {
+	logger.Error("Can't process pending payment " + paymentUUID + ". Details: " + err.Error())
+	switch err {
+	case handler.ErrPendingPaymentNotFound:
+		writeHTTPResponse(w, NOT_FOUND, common.PendingPaymentResponse{})
+	case handler.ErrPendingPaymentIsDecided:
+		writeHTTPResponse(w, CONFLICT, common.PendingPaymentResponse{})
+	case handler.ErrPendingPaymentSelfDecide, handler.ErrPendingPaymentDecider:
+		writeHTTPResponse(w, SELF_APPROVAL_FORBIDDEN, common.PendingPaymentResponse{})
+	default:
+		writeServerError("Pending payments store error", w)
+	}
+}
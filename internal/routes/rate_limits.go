@@ -0,0 +1,240 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/auth"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/conf"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/logger"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/metrics"
+	"golang.org/x/time/rate"
+)
+
+var (
+	// Groups of the routes, that are limited separately.
+	ROUTE_GROUP_READS    = "reads"
+	ROUTE_GROUP_WRITES   = "writes"
+	ROUTE_GROUP_PAYMENTS = "payments"
+
+	// Kinds of the limiters.
+	LIMITER_IP  = "ip"
+	LIMITER_KEY = "key"
+
+	// Buckets, that were not used during this period, are removed.
+	RATE_LIMIT_BUCKET_IDLE_TTL          = time.Minute * 10
+	RATE_LIMIT_BUCKETS_CLEANUP_INTERVAL = time.Minute
+)
+
+var (
+	rateLimitRejectedRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.NAMESPACE,
+		Name:      "rate_limit_rejected_requests_total",
+		Help:      "Count of the requests, rejected by the rate limits.",
+	}, []string{"group", "limiter"})
+
+	rateLimitRate = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metrics.NAMESPACE,
+		Name:      "rate_limit_rate",
+		Help:      "Configured rate (requests per second) of the rate limits. Key is empty for the default limits.",
+	}, []string{"group", "limiter", "key"})
+
+	rateLimitBurst = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metrics.NAMESPACE,
+		Name:      "rate_limit_burst",
+		Help:      "Configured burst of the rate limits. Key is empty for the default limits.",
+	}, []string{"group", "limiter", "key"})
+
+	rateLimitBuckets = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metrics.NAMESPACE,
+		Name:      "rate_limit_buckets",
+		Help:      "Count of the active token buckets (per IP and per key).",
+	})
+)
+
+func init() {
+	metrics.Registry.MustRegister(rateLimitRejectedRequests, rateLimitRate, rateLimitBurst, rateLimitBuckets)
+}
+
+type rateLimitBucket struct {
+	limiter    *rate.Limiter
+	lastUsedAt time.Time
+}
+
+type rateLimiters struct {
+	lock          sync.Mutex
+	buckets       map[string]*rateLimitBucket
+	lastCleanupAt time.Time
+}
+
+var (
+	limiters = &rateLimiters{buckets: make(map[string]*rateLimitBucket)}
+
+	rateLimitsOnce  sync.Once
+	rateLimitsError error
+)
+
+// Returns token bucket for the key, creates it if it is absent.
+func (l *rateLimiters) bucket(key string, settings conf.RateLimitSettings, now time.Time) *rate.Limiter {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if now.Sub(l.lastCleanupAt) >= RATE_LIMIT_BUCKETS_CLEANUP_INTERVAL {
+		l.lastCleanupAt = now
+		for bucketKey, bucket := range l.buckets {
+			if now.Sub(bucket.lastUsedAt) >= RATE_LIMIT_BUCKET_IDLE_TTL {
+				delete(l.buckets, bucketKey)
+			}
+		}
+	}
+
+	bucket, isPresent := l.buckets[key]
+	if !isPresent {
+		bucket = &rateLimitBucket{limiter: rate.NewLimiter(rate.Limit(settings.Rate), settings.Burst)}
+		l.buckets[key] = bucket
+	}
+	bucket.lastUsedAt = now
+	rateLimitBuckets.Set(float64(len(l.buckets)))
+	return bucket.limiter
+}
+
+// Checks rate limits settings and exposes them in the metrics.
+// Should be called on the server start, to not to start with broken settings.
+func ValidateRateLimitsSettings() error {
+	rateLimitsOnce.Do(func() {
+		settings := conf.Params.RateLimits
+		if !settings.Enabled {
+			return
+		}
+
+		rateLimitsError = checkRateLimits(settings.PerIP, LIMITER_IP, "")
+		if rateLimitsError != nil {
+			rateLimitsError = errors.New("invalid rate_limits.per_ip -> " + rateLimitsError.Error())
+			return
+		}
+		rateLimitsError = checkRateLimits(settings.PerKey, LIMITER_KEY, "")
+		if rateLimitsError != nil {
+			rateLimitsError = errors.New("invalid rate_limits.per_key -> " + rateLimitsError.Error())
+			return
+		}
+		for _, key := range conf.Params.Security.ApiKeys {
+			rateLimitsError = checkRateLimits(key.RateLimits, LIMITER_KEY, key.Name)
+			if rateLimitsError != nil {
+				rateLimitsError = errors.New("invalid rate_limits of api key " + key.Name + " -> " + rateLimitsError.Error())
+				return
+			}
+		}
+	})
+	return rateLimitsError
+}
+
+func checkRateLimits(limits map[string]conf.RateLimitSettings, limiter, key string) error {
+	for group, settings := range limits {
+		if group != ROUTE_GROUP_READS && group != ROUTE_GROUP_WRITES && group != ROUTE_GROUP_PAYMENTS {
+			return errors.New("unknown routes group " + group)
+		}
+		if settings.Rate <= 0 || settings.Burst < 1 {
+			return errors.New("rate and burst of the group " + group + " must be positive")
+		}
+		rateLimitRate.WithLabelValues(group, limiter, key).Set(settings.Rate)
+		rateLimitBurst.WithLabelValues(group, limiter, key).Set(float64(settings.Burst))
+	}
+	return nil
+}
+
+func routeGroup(r *http.Request) string {
+	switch requiredScope(r) {
+	case auth.SCOPE_READ:
+		return ROUTE_GROUP_READS
+	case auth.SCOPE_PAYMENTS_CREATE:
+		return ROUTE_GROUP_PAYMENTS
+	default:
+		return ROUTE_GROUP_WRITES
+	}
+}
+
+// Returns limits of the caller for the group.
+// Limits of the API key itself have priority over the default per key limits.
+func callerRateLimit(caller *auth.Caller, group string) (conf.RateLimitSettings, bool) {
+	for _, key := range conf.Params.Security.ApiKeys {
+		isKeyCaller := caller.AuthMethod == auth.AUTH_METHOD_API_KEY || caller.AuthMethod == auth.AUTH_METHOD_SIGNATURE
+		if key.Name == caller.Name && isKeyCaller {
+			settings, isPresent := key.RateLimits[group]
+			if isPresent {
+				return settings, true
+			}
+			break
+		}
+	}
+	settings, isPresent := conf.Params.RateLimits.PerKey[group]
+	return settings, isPresent
+}
+
+// HTTP middleware, that applies token bucket rate limits per client IP and per API key
+// (caller, authenticated by the API key or by the TLS certificate) for the group of the route.
+// Rejected requests get 429 with Retry-After header.
+// Requests with invalid security parameters are not limited by key here,
+// they are rejected later by the routes handlers.
+func RateLimitMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !conf.Params.RateLimits.Enabled {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		now := time.Now()
+		group := routeGroup(r)
+		var reservations []*rate.Reservation
+		var delay time.Duration
+		rejectedBy := ""
+
+		reserve := func(limiter, key string, settings conf.RateLimitSettings) {
+			reservation := limiters.bucket(group+"|"+limiter+"|"+key, settings, now).ReserveN(now, 1)
+			reservationDelay := reservation.DelayFrom(now)
+			reservations = append(reservations, reservation)
+			if reservationDelay > delay {
+				delay = reservationDelay
+				rejectedBy = limiter
+			}
+		}
+
+		sender := requesterOf(r)
+		if sender.ip != nil {
+			settings, isPresent := conf.Params.RateLimits.PerIP[group]
+			if isPresent {
+				reserve(LIMITER_IP, sender.ip.String(), settings)
+			}
+		}
+		if sender.caller != nil {
+			settings, isPresent := callerRateLimit(sender.caller, group)
+			if isPresent {
+				reserve(LIMITER_KEY, sender.caller.Name, settings)
+			}
+		}
+
+		if delay == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Request is rejected, so tokens are returned to all the buckets.
+		for _, reservation := range reservations {
+			reservation.CancelAt(now)
+		}
+		rateLimitRejectedRequests.WithLabelValues(group, rejectedBy).Inc()
+		logger.Error("Too many requests: " + r.Method + " " + r.URL.Path + " is rejected by " + rejectedBy + " rate limit of " + group)
+
+		retryAfter := int(math.Ceil(delay.Seconds()))
+		js, _ := json.Marshal(map[string]string{"error": "rate limit exceeded"})
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		w.WriteHeader(TOO_MANY_REQUESTS)
+		w.Write(js)
+	})
+}
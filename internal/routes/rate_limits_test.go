@@ -0,0 +1,218 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/auth"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/conf"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/conf/conftest"
+)
+
+var (
+	// Rate is low enough to not to refill the buckets during the test.
+	TEST_RATE = 0.001
+
+	TEST_READ_PATH    = "/api/v1/node/channels/"
+	TEST_WRITE_PATH   = "/api/v1/node/contractors/init-channel/"
+	TEST_PAYMENT_PATH = "/api/v1/node/contractors/transactions/1/"
+)
+
+// Applies the rate limits and the API keys with empty token buckets.
+func useTestRateLimits(t *testing.T, settings conf.RateLimitsSettings, keys []conf.ApiKeySettings) {
+	t.Helper()
+	conftest.Set(t, &conf.Params.RateLimits, settings)
+	conftest.Set(t, &conf.Params.Security, conf.SecuritySettings{ApiKeys: keys})
+	conftest.Set(t, &limiters, &rateLimiters{buckets: make(map[string]*rateLimitBucket)})
+}
+
+// Returns router with the routes of each group, that are limited by the middleware the way the server does.
+func newTestRateLimitedRouter(handler http.HandlerFunc) *mux.Router {
+	router := mux.NewRouter()
+	router.HandleFunc(TEST_READ_PATH, handler).Methods("GET")
+	router.HandleFunc(TEST_WRITE_PATH, handler).Methods("POST")
+	router.HandleFunc("/api/v1/node/contractors/transactions/{equivalent}/", handler).Methods("POST")
+	router.Use(RateLimitMiddleware)
+	return router
+}
+
+func sendTestRequest(router http.Handler, method, path, remoteAddr, apiKey string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, nil)
+	r.RemoteAddr = remoteAddr
+	if apiKey != "" {
+		r.Header.Set("api-key", apiKey)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	return w
+}
+
+func testApiKey(name string, limits map[string]conf.RateLimitSettings) conf.ApiKeySettings {
+	return conf.ApiKeySettings{
+		Name:       name,
+		Hash:       auth.HashApiKey(name + "-key"),
+		Scopes:     []string{auth.SCOPE_ADMIN},
+		RateLimits: limits,
+	}
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	useTestRateLimits(t, conf.RateLimitsSettings{
+		Enabled: true,
+		PerIP:   map[string]conf.RateLimitSettings{ROUTE_GROUP_READS: {Rate: TEST_RATE, Burst: 2}},
+		PerKey:  map[string]conf.RateLimitSettings{ROUTE_GROUP_PAYMENTS: {Rate: TEST_RATE, Burst: 1}},
+	}, []conf.ApiKeySettings{
+		testApiKey("alice", map[string]conf.RateLimitSettings{ROUTE_GROUP_PAYMENTS: {Rate: TEST_RATE, Burst: 3}}),
+		testApiKey("bob", nil),
+	})
+	router := newTestRateLimitedRouter(func(w http.ResponseWriter, r *http.Request) {})
+
+	steps := []struct {
+		name       string
+		method     string
+		path       string
+		remoteAddr string
+		apiKey     string
+		isAccepted bool
+	}{
+		{"first read", "GET", TEST_READ_PATH, "203.0.113.1:5000", "bob-key", true},
+		{"second read", "GET", TEST_READ_PATH, "203.0.113.1:5001", "bob-key", true},
+		{"read above the IP limit", "GET", TEST_READ_PATH, "203.0.113.1:5002", "bob-key", false},
+		{"read from the other IP", "GET", TEST_READ_PATH, "203.0.113.2:5000", "bob-key", true},
+		{"write, that is not limited", "POST", TEST_WRITE_PATH, "203.0.113.1:5000", "bob-key", true},
+		{"payment by the default key limit", "POST", TEST_PAYMENT_PATH, "203.0.113.1:5000", "bob-key", true},
+		{"payment above the default key limit", "POST", TEST_PAYMENT_PATH, "203.0.113.2:5000", "bob-key", false},
+		{"first payment by the own limit of the key", "POST", TEST_PAYMENT_PATH, "203.0.113.1:5000", "alice-key", true},
+		{"second payment by the own limit of the key", "POST", TEST_PAYMENT_PATH, "203.0.113.1:5000", "alice-key", true},
+		{"third payment by the own limit of the key", "POST", TEST_PAYMENT_PATH, "203.0.113.1:5000", "alice-key", true},
+		{"payment above the own limit of the key", "POST", TEST_PAYMENT_PATH, "203.0.113.1:5000", "alice-key", false},
+	}
+
+	for _, step := range steps {
+		w := sendTestRequest(router, step.method, step.path, step.remoteAddr, step.apiKey)
+		if step.isAccepted {
+			if w.Code != http.StatusOK {
+				t.Fatalf("%s: request is rejected with %d", step.name, w.Code)
+			}
+			continue
+		}
+
+		if w.Code != TOO_MANY_REQUESTS {
+			t.Fatalf("%s: expected %d, got %d", step.name, TOO_MANY_REQUESTS, w.Code)
+		}
+		var body map[string]string
+		err := json.Unmarshal(w.Body.Bytes(), &body)
+		if err != nil || len(body) != 1 || body["error"] != "rate limit exceeded" {
+			t.Fatalf("%s: unexpected body %s", step.name, w.Body.String())
+		}
+		if w.Header().Get("Content-Type") != "application/json" {
+			t.Fatalf("%s: unexpected content type %s", step.name, w.Header().Get("Content-Type"))
+		}
+		retryAfter, err := strconv.Atoi(w.Header().Get("Retry-After"))
+		if err != nil || retryAfter < 1 {
+			t.Fatalf("%s: unexpected Retry-After %q", step.name, w.Header().Get("Retry-After"))
+		}
+	}
+}
+
+func TestRateLimitRejectionReturnsTokens(t *testing.T) {
+	useTestRateLimits(t, conf.RateLimitsSettings{
+		Enabled: true,
+		PerIP:   map[string]conf.RateLimitSettings{ROUTE_GROUP_PAYMENTS: {Rate: TEST_RATE, Burst: 2}},
+		PerKey:  map[string]conf.RateLimitSettings{ROUTE_GROUP_PAYMENTS: {Rate: TEST_RATE, Burst: 1}},
+	}, []conf.ApiKeySettings{testApiKey("bob", nil)})
+	router := newTestRateLimitedRouter(func(w http.ResponseWriter, r *http.Request) {})
+
+	expectedCodes := []int{http.StatusOK, TOO_MANY_REQUESTS}
+	for i, expectedCode := range expectedCodes {
+		w := sendTestRequest(router, "POST", TEST_PAYMENT_PATH, "203.0.113.1:5000", "bob-key")
+		if w.Code != expectedCode {
+			t.Fatalf("payment %d: expected %d, got %d", i+1, expectedCode, w.Code)
+		}
+	}
+
+	// Payment, rejected by the key limit, doesn't spend the token of the IP.
+	// Requests without the key are limited only by IP (and are rejected later by the handlers).
+	expectedCodes = []int{http.StatusOK, TOO_MANY_REQUESTS}
+	for i, expectedCode := range expectedCodes {
+		w := sendTestRequest(router, "POST", TEST_PAYMENT_PATH, "203.0.113.1:5000", "")
+		if w.Code != expectedCode {
+			t.Fatalf("request without key %d: expected %d, got %d", i+1, expectedCode, w.Code)
+		}
+	}
+}
+
+func TestRateLimitsDisabled(t *testing.T) {
+	useTestRateLimits(t, conf.RateLimitsSettings{
+		Enabled: false,
+		PerIP:   map[string]conf.RateLimitSettings{ROUTE_GROUP_READS: {Rate: TEST_RATE, Burst: 1}},
+	}, nil)
+	router := newTestRateLimitedRouter(func(w http.ResponseWriter, r *http.Request) {})
+
+	for i := 0; i < 3; i++ {
+		w := sendTestRequest(router, "GET", TEST_READ_PATH, "203.0.113.1:5000", "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("request %d is rejected with %d", i+1, w.Code)
+		}
+	}
+}
+
+func TestRouteGroup(t *testing.T) {
+	cases := []struct {
+		method   string
+		path     string
+		expected string
+	}{
+		{"GET", TEST_READ_PATH, ROUTE_GROUP_READS},
+		{"POST", TEST_WRITE_PATH, ROUTE_GROUP_WRITES},
+		{"POST", TEST_PAYMENT_PATH, ROUTE_GROUP_PAYMENTS},
+		// Route is matched by the template, not by the path.
+		{"POST", "/api/v1/node/contractors/transactions/2/", ROUTE_GROUP_PAYMENTS},
+	}
+
+	for _, c := range cases {
+		t.Run(c.method+" "+c.path, func(t *testing.T) {
+			group := ""
+			router := newTestRateLimitedRouter(func(w http.ResponseWriter, r *http.Request) { group = routeGroup(r) })
+			sendTestRequest(router, c.method, c.path, "203.0.113.1:5000", "")
+			if group != c.expected {
+				t.Fatalf("expected group %q, got %q", c.expected, group)
+			}
+		})
+	}
+}
+
+func TestRateLimitBucketsEviction(t *testing.T) {
+	l := &rateLimiters{buckets: make(map[string]*rateLimitBucket)}
+	settings := conf.RateLimitSettings{Rate: TEST_RATE, Burst: 1}
+	now := time.Now()
+
+	idle := l.bucket("idle", settings, now)
+	active := l.bucket("active", settings, now)
+	cleanupAt := now.Add(RATE_LIMIT_BUCKET_IDLE_TTL - RATE_LIMIT_BUCKETS_CLEANUP_INTERVAL/2)
+	if l.bucket("active", settings, cleanupAt) != active || len(l.buckets) != 2 {
+		t.Fatalf("bucket is removed before the idle TTL: %d buckets", len(l.buckets))
+	}
+
+	// Cleanup is done not more often than once per interval, so the expired bucket is kept till the next one.
+	l.bucket("active", settings, now.Add(RATE_LIMIT_BUCKET_IDLE_TTL+RATE_LIMIT_BUCKETS_CLEANUP_INTERVAL/4))
+	if len(l.buckets) != 2 {
+		t.Fatalf("buckets are cleaned up before the interval: %d buckets", len(l.buckets))
+	}
+
+	if l.bucket("active", settings, cleanupAt.Add(RATE_LIMIT_BUCKETS_CLEANUP_INTERVAL)) != active {
+		t.Fatal("used bucket is removed")
+	}
+	if _, isPresent := l.buckets["idle"]; isPresent || len(l.buckets) != 1 {
+		t.Fatalf("idle bucket is not removed: %d buckets", len(l.buckets))
+	}
+	// Removed bucket is created again with the full burst.
+	if l.bucket("idle", settings, cleanupAt.Add(RATE_LIMIT_BUCKETS_CLEANUP_INTERVAL)) == idle {
+		t.Fatal("idle bucket is reused")
+	}
+}
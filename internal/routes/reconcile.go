@@ -0,0 +1,71 @@
+package routes
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/common"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/handler"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/logger"
+)
+
+// Reconciles the settlement lines of the equivalent against the history with the contractors.
+// Optional query parameters: contractor_id (all the settlement lines by default),
+// context (count of the history records around each discrepancy) and format (json or text).
+func (router *RoutesHandler) ReconcileSettlementLines(w http.ResponseWriter, r *http.Request) {
+	url, err := preprocessRequest(r)
+	if err != nil {
+		logger.Error("Bad request: invalid security parameters: " + err.Error())
+		w.WriteHeader(BAD_REQUEST)
+		return
+	}
+
+	equivalent, isParamPresent := mux.Vars(r)["equivalent"]
+	if !isParamPresent || !common.ValidateInt(equivalent) {
+		logger.Error("Bad request: invalid equivalent parameter: " + url)
+		w.WriteHeader(BAD_REQUEST)
+		return
+	}
+
+	contractorID := r.URL.Query().Get("contractor_id")
+	if contractorID != "" && !common.ValidateInt(contractorID) {
+		logger.Error("Bad request: invalid contractor_id parameter: " + url)
+		w.WriteHeader(BAD_REQUEST)
+		return
+	}
+
+	contextSize := handler.DEFAULT_RECONCILIATION_CONTEXT_SIZE
+	if r.URL.Query().Get("context") != "" {
+		contextSize, err = strconv.Atoi(r.URL.Query().Get("context"))
+		if err != nil || contextSize < 0 || contextSize > handler.MAX_RECONCILIATION_CONTEXT_SIZE {
+			logger.Error("Bad request: invalid context parameter: " + url)
+			w.WriteHeader(BAD_REQUEST)
+			return
+		}
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = handler.RECONCILIATION_FORMAT_JSON
+	}
+	if format != handler.RECONCILIATION_FORMAT_JSON && format != handler.RECONCILIATION_FORMAT_TEXT {
+		logger.Error("Bad request: invalid format parameter: " + url)
+		w.WriteHeader(BAD_REQUEST)
+		return
+	}
+
+	// Command processing.
+	// This command may execute relatively slow, the whole history with each contractor is requested.
+	code, response := router.nodeHandler.Reconcile(r.Context(), equivalent, contractorID, contextSize)
+	if code != OK || format == handler.RECONCILIATION_FORMAT_JSON {
+		writeHTTPResponse(w, code, response)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(OK)
+	err = handler.WriteReconciliationReport(w, response)
+	if err != nil {
+		logger.Error("Can't write reconciliation report: " + url + ". Details: " + err.Error())
+	}
+}
@@ -0,0 +1,59 @@
+package routes
+
+import (
+	"context"
+	"net"
+	"net/http"
+
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/auth"
+)
+
+// Identity of the request sender.
+type requester struct {
+	// Nil, if the address can't be determined.
+	ip net.IP
+	// Nil, if the caller is not authenticated (authError is set in this case).
+	caller    *auth.Caller
+	authError error
+}
+
+type requesterContextKey struct{}
+
+func identifyRequester(r *http.Request) *requester {
+	result := &requester{}
+	ipFilters, err := loadAddressesFilters()
+	if err == nil {
+		result.ip = ipFilters.requesterAddress(r)
+	}
+
+	result.caller, result.authError = auth.Authenticate(r)
+	if result.caller != nil && result.ip != nil {
+		result.caller.SourceIP = result.ip.String()
+	}
+	return result
+}
+
+// Returns requester of the request, identified by the RequesterMiddleware,
+// or identifies it, if the middleware was not used (e.g. testing API).
+func requesterOf(r *http.Request) *requester {
+	result, isPresent := r.Context().Value(requesterContextKey{}).(*requester)
+	if isPresent {
+		return result
+	}
+	return identifyRequester(r)
+}
+
+// HTTP middleware, that identifies requester of the request (IP and caller)
+// and puts it into the context of the request.
+// Authenticated caller is available for the further processing (e.g. audit) by auth.CallerFromContext().
+// Requests are not rejected here, security checks are done by the routes handlers.
+func RequesterMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		result := identifyRequester(r)
+		ctx := context.WithValue(r.Context(), requesterContextKey{}, result)
+		if result.caller != nil {
+			ctx = auth.WithCaller(ctx, result.caller)
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -0,0 +1,260 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/auth"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/common"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/logger"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/scheduler"
+)
+
+var (
+	SCHEDULES_PATH = "/api/v1/node/schedules/"
+)
+
+// Lists schedules of the recurring payments.
+func (router *RoutesHandler) ListSchedules(w http.ResponseWriter, r *http.Request) {
+	_, err := preprocessRequest(r)
+	if err != nil {
+		logger.Error("Bad request: invalid security parameters: " + err.Error())
+		w.WriteHeader(BAD_REQUEST)
+		return
+	}
+
+	schedules, err := scheduler.Schedules()
+	if err != nil {
+		logger.Error("Can't read schedules. Details: " + err.Error())
+		writeServerError("Schedules store error", w)
+		return
+	}
+
+	caller := auth.CallerFromContext(r.Context())
+	response := common.SchedulesResponse{Schedules: []common.ScheduleResponse{}}
+	for _, schedule := range schedules {
+		if caller != nil && !caller.IsEquivalentAllowed(schedule.Equivalent) {
+			continue
+		}
+		response.Schedules = append(response.Schedules, schedule.Response())
+	}
+	response.Count = len(response.Schedules)
+	writeHTTPResponse(w, OK, response)
+}
+
+// Creates schedule of the recurring payment.
+func (router *RoutesHandler) CreateSchedule(w http.ResponseWriter, r *http.Request) {
+	url, err := preprocessRequest(r)
+	if err != nil {
+		logger.Error("Bad request: invalid security parameters: " + err.Error())
+		w.WriteHeader(BAD_REQUEST)
+		return
+	}
+
+	definition, err := scheduleDefinition(r)
+	if err != nil {
+		logger.Error("Bad request: " + err.Error() + ": " + url)
+		w.WriteHeader(BAD_REQUEST)
+		return
+	}
+	caller := auth.CallerFromContext(r.Context())
+	if caller != nil && !caller.IsEquivalentAllowed(definition.Equivalent) {
+		logger.Error("Caller " + caller.Name + " is not allowed to operate with the equivalent " + definition.Equivalent)
+		writeHTTPResponse(w, SCHEDULE_FORBIDDEN, common.ScheduleResponse{})
+		return
+	}
+
+	schedule, err := scheduler.CreateSchedule(r.Context(), definition)
+	if err != nil {
+		logger.Error("Can't create schedule. Details: " + err.Error())
+		writeServerError("Schedules store error", w)
+		return
+	}
+	w.Header().Set("Location", SCHEDULES_PATH+schedule.ID+"/")
+	writeHTTPResponse(w, CREATED, schedule.Response())
+}
+
+// Returns schedule of the recurring payment.
+func (router *RoutesHandler) GetSchedule(w http.ResponseWriter, r *http.Request) {
+	url, err := preprocessRequest(r)
+	if err != nil {
+		logger.Error("Bad request: invalid security parameters: " + err.Error())
+		w.WriteHeader(BAD_REQUEST)
+		return
+	}
+
+	schedule, code := requestedSchedule(r, url)
+	if schedule == nil {
+		writeScheduleError(w, code)
+		return
+	}
+	writeHTTPResponse(w, OK, schedule.Response())
+}
+
+// Replaces parameters of the schedule.
+// Only the owner of the schedule (or admin) could change it, because the payments are executed on behalf of the owner.
+func (router *RoutesHandler) UpdateSchedule(w http.ResponseWriter, r *http.Request) {
+	url, err := preprocessRequest(r)
+	if err != nil {
+		logger.Error("Bad request: invalid security parameters: " + err.Error())
+		w.WriteHeader(BAD_REQUEST)
+		return
+	}
+
+	schedule, code := requestedSchedule(r, url)
+	if schedule == nil {
+		writeScheduleError(w, code)
+		return
+	}
+	if !isScheduleOwner(r, schedule) {
+		logger.Error("Schedule " + schedule.ID + " could be changed only by it's owner")
+		writeScheduleError(w, SCHEDULE_FORBIDDEN)
+		return
+	}
+
+	definition, err := scheduleDefinition(r)
+	if err != nil {
+		logger.Error("Bad request: " + err.Error() + ": " + url)
+		w.WriteHeader(BAD_REQUEST)
+		return
+	}
+	caller := auth.CallerFromContext(r.Context())
+	if caller != nil && !caller.IsEquivalentAllowed(definition.Equivalent) {
+		logger.Error("Caller " + caller.Name + " is not allowed to operate with the equivalent " + definition.Equivalent)
+		writeScheduleError(w, SCHEDULE_FORBIDDEN)
+		return
+	}
+
+	schedule, err = scheduler.UpdateSchedule(r.Context(), schedule.ID, definition)
+	if err == scheduler.ErrScheduleNotFound {
+		writeScheduleError(w, NOT_FOUND)
+		return
+	}
+	if err != nil {
+		logger.Error("Can't update schedule. Details: " + err.Error())
+		writeServerError("Schedules store error", w)
+		return
+	}
+	writeHTTPResponse(w, OK, schedule.Response())
+}
+
+// Removes the schedule together with it's runs history.
+func (router *RoutesHandler) DeleteSchedule(w http.ResponseWriter, r *http.Request) {
+	url, err := preprocessRequest(r)
+	if err != nil {
+		logger.Error("Bad request: invalid security parameters: " + err.Error())
+		w.WriteHeader(BAD_REQUEST)
+		return
+	}
+
+	schedule, code := requestedSchedule(r, url)
+	if schedule == nil {
+		writeScheduleError(w, code)
+		return
+	}
+	if !isScheduleOwner(r, schedule) {
+		logger.Error("Schedule " + schedule.ID + " could be removed only by it's owner")
+		writeScheduleError(w, SCHEDULE_FORBIDDEN)
+		return
+	}
+
+	err = scheduler.DeleteSchedule(r.Context(), schedule.ID)
+	if err == scheduler.ErrScheduleNotFound {
+		writeScheduleError(w, NOT_FOUND)
+		return
+	}
+	if err != nil {
+		logger.Error("Can't remove schedule. Details: " + err.Error())
+		writeServerError("Schedules store error", w)
+		return
+	}
+	writeHTTPResponse(w, OK, schedule.Response())
+}
+
+// Returns runs history of the schedule from the newest runs.
+func (router *RoutesHandler) ListScheduleRuns(w http.ResponseWriter, r *http.Request) {
+	url, err := preprocessRequest(r)
+	if err != nil {
+		logger.Error("Bad request: invalid security parameters: " + err.Error())
+		w.WriteHeader(BAD_REQUEST)
+		return
+	}
+
+	schedule, code := requestedSchedule(r, url)
+	if schedule == nil {
+		writeScheduleError(w, code)
+		return
+	}
+
+	runs, err := scheduler.Runs(schedule.ID)
+	if err != nil {
+		logger.Error("Can't read runs of the schedule " + schedule.ID + ". Details: " + err.Error())
+		writeServerError("Schedules store error", w)
+		return
+	}
+	writeHTTPResponse(w, OK, common.ScheduleRunsResponse{Count: len(runs), Runs: runs})
+}
+
+// Reads parameters of the schedule from the request.
+func scheduleDefinition(r *http.Request) (scheduler.Definition, error) {
+	definition := scheduler.Definition{
+		Name:                r.FormValue("name"),
+		Cron:                r.FormValue("cron"),
+		ContractorAddresses: r.URL.Query()["contractor_address"],
+		Amount:              r.FormValue("amount"),
+		Equivalent:          r.FormValue("equivalent"),
+		PayloadTemplate:     r.FormValue("payload_template"),
+		MissedRuns:          r.FormValue("missed_runs"),
+		Enabled:             r.FormValue("enabled") != "false",
+	}
+	if definition.MissedRuns == "" {
+		definition.MissedRuns = scheduler.MISSED_RUNS_SKIP
+	}
+	return definition, definition.Validate()
+}
+
+// Returns the schedule from the path, or nil and the response code.
+// Schedules in the equivalents, that are not allowed to the caller, are not disclosed.
+func requestedSchedule(r *http.Request, url string) (*scheduler.Schedule, int) {
+	scheduleID := mux.Vars(r)["id"]
+	if !common.ValidateUUID(scheduleID) {
+		logger.Error("Bad request: invalid id parameter: " + url)
+		return nil, BAD_REQUEST
+	}
+	// ID is used as the store key, so it is normalized.
+	scheduleID = uuid.MustParse(scheduleID).String()
+
+	schedule, err := scheduler.GetSchedule(scheduleID)
+	if err == scheduler.ErrScheduleNotFound {
+		logger.Error("Schedule " + scheduleID + " is not found")
+		return nil, NOT_FOUND
+	}
+	if err != nil {
+		logger.Error("Can't read schedule " + scheduleID + ". Details: " + err.Error())
+		return nil, SERVER_ERROR
+	}
+
+	caller := auth.CallerFromContext(r.Context())
+	if caller != nil && !caller.IsEquivalentAllowed(schedule.Equivalent) {
+		logger.Error("Schedule " + scheduleID + " is not found")
+		return nil, NOT_FOUND
+	}
+	return schedule, OK
+}
+
+func isScheduleOwner(r *http.Request, schedule *scheduler.Schedule) bool {
+	caller := auth.CallerFromContext(r.Context())
+	return caller == nil || caller.HasScope(auth.SCOPE_ADMIN) || caller.Name == schedule.Owner
+}
+
+func writeScheduleError(w http.ResponseWriter, code int) {
+	switch code {
+	case BAD_REQUEST:
+		w.WriteHeader(BAD_REQUEST)
+	case SERVER_ERROR:
+		writeServerError("Schedules store error", w)
+	default:
+		writeHTTPResponse(w, code, common.ScheduleResponse{})
+	}
+}
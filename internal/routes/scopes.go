@@ -0,0 +1,96 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/auth"
+)
+
+// Scopes, required for the routes, that are changing the state of the node.
+// Key is the method and the path template of the route.
+// GET routes require read scope, all other routes, that are absent here, require admin scope.
+var routesScopes = map[string]string{
+	// Channels
+	"POST /api/v1/node/contractors/init-channel/":                      auth.SCOPE_CHANNELS_WRITE,
+	"PUT /api/v1/node/channels/{contractor_id}/set-addresses/":         auth.SCOPE_CHANNELS_WRITE,
+	"PUT /api/v1/node/channels/{contractor_id}/set-crypto-key/":        auth.SCOPE_CHANNELS_WRITE,
+	"PUT /api/v1/node/channels/{contractor_id}/regenerate-crypto-key/": auth.SCOPE_CHANNELS_WRITE,
+	"DELETE /api/v1/node/channels/{contractor_id}/remove/":             auth.SCOPE_CHANNELS_WRITE,
+
+	// Settlement lines
+	"POST /api/v1/node/contractors/{contractor_id}/init-settlement-line/{equivalent}/":             auth.SCOPE_SETTLEMENT_LINES_WRITE,
+	"PUT /api/v1/node/contractors/{contractor_id}/settlement-lines/{equivalent}/":                  auth.SCOPE_SETTLEMENT_LINES_WRITE,
+	"DELETE /api/v1/node/contractors/{contractor_id}/close-incoming-settlement-line/{equivalent}/": auth.SCOPE_SETTLEMENT_LINES_WRITE,
+	"PUT /api/v1/node/contractors/{contractor_id}/keys-sharing/{equivalent}/":                      auth.SCOPE_SETTLEMENT_LINES_WRITE,
+	"DELETE /api/v1/node/contractors/{contractor_id}/remove-settlement-line/{equivalent}/":         auth.SCOPE_SETTLEMENT_LINES_WRITE,
+	"PUT /api/v1/node/contractors/{contractor_id}/reset-settlement-line/{equivalent}/":             auth.SCOPE_SETTLEMENT_LINES_WRITE,
+
+	// Payments
+	"POST /api/v1/node/contractors/transactions/{equivalent}/":       auth.SCOPE_PAYMENTS_CREATE,
+	"POST /api/v1/node/contractors/transactions/quote/{equivalent}/": auth.SCOPE_PAYMENTS_CREATE,
+
+	// Payment approvals
+	"POST /api/v1/node/pending-payments/{uuid}/approve/": auth.SCOPE_APPROVER,
+	"POST /api/v1/node/pending-payments/{uuid}/reject/":  auth.SCOPE_APPROVER,
+
+	// Scheduled payments
+	"POST /api/v1/node/schedules/":        auth.SCOPE_PAYMENTS_CREATE,
+	"PUT /api/v1/node/schedules/{id}/":    auth.SCOPE_PAYMENTS_CREATE,
+	"DELETE /api/v1/node/schedules/{id}/": auth.SCOPE_PAYMENTS_CREATE,
+
+	// Bulk payments
+	"POST /api/v1/node/bulk-payments/":             auth.SCOPE_PAYMENTS_CREATE,
+	"POST /api/v1/node/bulk-payments/{id}/resume/": auth.SCOPE_PAYMENTS_CREATE,
+
+	// Webhooks
+	"GET /api/v1/webhooks/deliveries/": auth.SCOPE_ADMIN,
+}
+
+// Routes, that are operating with all equivalents at once.
+// Callers, that are restricted by equivalents, are not allowed to use them.
+var crossEquivalentsRoutes = map[string]bool{
+	"GET /api/v1/node/contractors/settlement-lines/equivalents/all/":       true,
+	"GET /api/v1/node/history/transactions/payments-all/{offset}/{count}/": true,
+	"GET /api/v1/node/history/export/payments-all/":                        true,
+	"GET /api/v1/node/history-index/status/":                               true,
+	"POST /api/v1/node/regenerate-all-keys/":                               true,
+	"GET /api/v1/jobs/":                                                    true,
+	"GET /api/v1/jobs/{id}/":                                               true,
+}
+
+func routeKey(r *http.Request) string {
+	template := r.URL.Path
+	if route := mux.CurrentRoute(r); route != nil {
+		if routeTemplate, err := route.GetPathTemplate(); err == nil {
+			template = routeTemplate
+		}
+	}
+	return r.Method + " " + template
+}
+
+func requiredScope(r *http.Request) string {
+	scope, isPresent := routesScopes[routeKey(r)]
+	if isPresent {
+		return scope
+	}
+	if r.Method == "GET" {
+		return auth.SCOPE_READ
+	}
+	return auth.SCOPE_ADMIN
+}
+
+// Checks, that caller is allowed to operate with the equivalent of the request.
+func isEquivalentPermitted(caller *auth.Caller, r *http.Request) bool {
+	if !caller.IsRestrictedByEquivalents() {
+		return true
+	}
+	if crossEquivalentsRoutes[routeKey(r)] {
+		return false
+	}
+	equivalent, isPresent := mux.Vars(r)["equivalent"]
+	if !isPresent {
+		return true
+	}
+	return caller.IsEquivalentAllowed(equivalent)
+}
@@ -37,7 +37,7 @@ func (router *RoutesHandler) InitSettlementLine(w http.ResponseWriter, r *http.R
 	// Command generation
 	command := handler.NewCommand("INIT:contractors/trust-line", contractorID, equivalent)
 
-	err = router.nodeHandler.Node.SendCommand(command)
+	err = router.nodeHandler.Node.SendCommand(r.Context(), command)
 	if err != nil {
 		logger.Error("Can't send command: " + string(command.ToBytes()) + " to node. Details: " + err.Error())
 		writeHTTPResponse(w, COMMAND_TRANSFERRING_ERROR, common.ActionResponse{})
@@ -92,7 +92,7 @@ func (router *RoutesHandler) SetMaxPositiveBalance(w http.ResponseWriter, r *htt
 	command := handler.NewCommand(
 		"SET:contractors/trust-lines", contractorID, amount, equivalent)
 
-	err = router.nodeHandler.Node.SendCommand(command)
+	err = router.nodeHandler.Node.SendCommand(r.Context(), command)
 	if err != nil {
 		logger.Error("Can't send command: " + string(command.ToBytes()) + " to node. Details: " + err.Error())
 		writeHTTPResponse(w, COMMAND_TRANSFERRING_ERROR, common.ActionResponse{})
@@ -140,7 +140,7 @@ func (router *RoutesHandler) ZeroOutMaxNegativeBalance(w http.ResponseWriter, r
 	command := handler.NewCommand(
 		"DELETE:contractors/incoming-trust-line", contractorID, equivalent)
 
-	err = router.nodeHandler.Node.SendCommand(command)
+	err = router.nodeHandler.Node.SendCommand(r.Context(), command)
 	if err != nil {
 		logger.Error("Can't send command: " + string(command.ToBytes()) + " to node. Details: " + err.Error())
 		writeHTTPResponse(w, COMMAND_TRANSFERRING_ERROR, common.ActionResponse{})
@@ -188,7 +188,7 @@ func (router *RoutesHandler) PublicKeysSharing(w http.ResponseWriter, r *http.Re
 	command := handler.NewCommand(
 		"SET:contractors/trust-line-keys", contractorID, equivalent)
 
-	err = router.nodeHandler.Node.SendCommand(command)
+	err = router.nodeHandler.Node.SendCommand(r.Context(), command)
 	if err != nil {
 		logger.Error("Can't send command: " + string(command.ToBytes()) + " to node. Details: " + err.Error())
 		writeHTTPResponse(w, COMMAND_TRANSFERRING_ERROR, common.ActionResponse{})
@@ -236,7 +236,7 @@ func (router *RoutesHandler) RemoveSettlementLine(w http.ResponseWriter, r *http
 	command := handler.NewCommand(
 		"DELETE:contractors/trust-line", contractorID, equivalent)
 
-	err = router.nodeHandler.Node.SendCommand(command)
+	err = router.nodeHandler.Node.SendCommand(r.Context(), command)
 	if err != nil {
 		logger.Error("Can't send command: " + string(command.ToBytes()) + " to node. Details: " + err.Error())
 		writeHTTPResponse(w, COMMAND_TRANSFERRING_ERROR, common.ActionResponse{})
@@ -313,7 +313,7 @@ func (router *RoutesHandler) ResetSettlementLine(w http.ResponseWriter, r *http.
 		"SET:contractors/trust-lines/reset", contractorID, auditNumber,
 		maxNegativeBalance, maxPositiveBalance, balance, equivalent)
 
-	err = router.nodeHandler.Node.SendCommand(command)
+	err = router.nodeHandler.Node.SendCommand(r.Context(), command)
 	if err != nil {
 		logger.Error("Can't send command: " + string(command.ToBytes()) + " to node. Details: " + err.Error())
 		writeHTTPResponse(w, COMMAND_TRANSFERRING_ERROR, common.ActionResponse{})
@@ -353,7 +353,7 @@ func (router *RoutesHandler) ListSettlementLines(w http.ResponseWriter, r *http.
 
 	command := handler.NewCommand("GET:contractors/trust-lines", common.DEFAULT_SETTLEMENT_LINES_OFFSET, common.DFEAULT_SETTLEMENT_LINES_COUNT, equivalent)
 
-	err = router.nodeHandler.Node.SendCommand(command)
+	err = router.nodeHandler.Node.SendCommand(r.Context(), command)
 	if err != nil {
 		logger.Error("Can't send command: " + string(command.ToBytes()) + " to node. Details: " + err.Error())
 		writeHTTPResponse(w, COMMAND_TRANSFERRING_ERROR, common.SettlementLineListResponse{})
@@ -448,7 +448,7 @@ func (router *RoutesHandler) ListSettlementLinesPortions(w http.ResponseWriter,
 
 	command := handler.NewCommand("GET:contractors/trust-lines", offset, count, equivalent)
 
-	err = router.nodeHandler.Node.SendCommand(command)
+	err = router.nodeHandler.Node.SendCommand(r.Context(), command)
 	if err != nil {
 		logger.Error("Can't send command: " + string(command.ToBytes()) + " to node. Details: " + err.Error())
 		writeHTTPResponse(w, COMMAND_TRANSFERRING_ERROR, common.SettlementLineListResponse{})
@@ -522,7 +522,7 @@ func (router *RoutesHandler) ListSettlementLinesAllEquivalents(w http.ResponseWr
 
 	command := handler.NewCommand("GET:contractors/trust-lines-all", common.DEFAULT_SETTLEMENT_LINES_OFFSET, common.DFEAULT_SETTLEMENT_LINES_COUNT)
 
-	err = router.nodeHandler.Node.SendCommand(command)
+	err = router.nodeHandler.Node.SendCommand(r.Context(), command)
 	if err != nil {
 		logger.Error("Can't send command: " + string(command.ToBytes()) + " to node. Details: " + err.Error())
 		writeHTTPResponse(w, COMMAND_TRANSFERRING_ERROR, common.AllEquivalentsResponse{})
@@ -611,7 +611,7 @@ func (router *RoutesHandler) ListContractors(w http.ResponseWriter, r *http.Requ
 
 	command := handler.NewCommand("GET:contractors", equivalent)
 
-	err = router.nodeHandler.Node.SendCommand(command)
+	err = router.nodeHandler.Node.SendCommand(r.Context(), command)
 	if err != nil {
 		logger.Error("Can't send command: " + string(command.ToBytes()) + " to node. Details: " + err.Error())
 		writeHTTPResponse(w, COMMAND_TRANSFERRING_ERROR, common.ContractorsListResponse{})
@@ -693,7 +693,7 @@ func (router *RoutesHandler) GetSettlementLineByID(w http.ResponseWriter, r *htt
 	command := handler.NewCommand(
 		"GET:contractors/trust-lines/one/id", contractorID, equivalent)
 
-	err = router.nodeHandler.Node.SendCommand(command)
+	err = router.nodeHandler.Node.SendCommand(r.Context(), command)
 	if err != nil {
 		logger.Error("Can't send command: " + string(command.ToBytes()) + " to node. Details: " + err.Error())
 		writeHTTPResponse(w, COMMAND_TRANSFERRING_ERROR, common.SettlementLineDetailResponse{})
@@ -784,7 +784,7 @@ func (router *RoutesHandler) GetSettlementLineByAddress(w http.ResponseWriter, r
 	contractorAddresses = append(contractorAddresses, []string{equivalent}...)
 	command := handler.NewCommand(contractorAddresses...)
 
-	err = router.nodeHandler.Node.SendCommand(command)
+	err = router.nodeHandler.Node.SendCommand(r.Context(), command)
 	if err != nil {
 		logger.Error("Can't send command: " + string(command.ToBytes()) + " to node. Details: " + err.Error())
 		writeHTTPResponse(w, COMMAND_TRANSFERRING_ERROR, common.SettlementLineDetailResponse{})
@@ -845,7 +845,7 @@ func (router *RoutesHandler) ListEquivalents(w http.ResponseWriter, r *http.Requ
 
 	command := handler.NewCommand("GET:equivalents")
 
-	err = router.nodeHandler.Node.SendCommand(command)
+	err = router.nodeHandler.Node.SendCommand(r.Context(), command)
 	if err != nil {
 		logger.Error("Can't send command: " + string(command.ToBytes()) + " to node. Details: " + err.Error())
 		writeHTTPResponse(w, COMMAND_TRANSFERRING_ERROR, common.EquivalentsListResponse{})
@@ -910,7 +910,7 @@ func (router *RoutesHandler) TotalBalance(w http.ResponseWriter, r *http.Request
 
 	command := handler.NewCommand("GET:stats/balance/total", equivalent)
 
-	err = router.nodeHandler.Node.SendCommand(command)
+	err = router.nodeHandler.Node.SendCommand(r.Context(), command)
 	if err != nil {
 		logger.Error("Can't send command: " + string(command.ToBytes()) + " to node. Details: " + err.Error())
 		writeHTTPResponse(w, COMMAND_TRANSFERRING_ERROR, common.TotalBalanceResponse{})
@@ -51,7 +51,7 @@ func (router *RoutesHandler) BatchMaxFullyTransaction(w http.ResponseWriter, r *
 	contractorAddresses = append(contractorAddresses, []string{equivalent}...)
 	command := handler.NewCommand(contractorAddresses...)
 
-	err = router.nodeHandler.Node.SendCommand(command)
+	err = router.nodeHandler.Node.SendCommand(r.Context(), command)
 	if err != nil {
 		logger.Error("Can't send command: " + string(command.ToBytes()) + " to node. Details: " + err.Error())
 		writeHTTPResponse(w, COMMAND_TRANSFERRING_ERROR, common.MaxFlowResponse{})
@@ -111,6 +111,49 @@ func (router *RoutesHandler) BatchMaxFullyTransaction(w http.ResponseWriter, r *
 	writeHTTPResponse(w, OK, response)
 }
 
+// Calculates max flows toward many contractors at once.
+// Addresses are calculated by chunks concurrently, recently calculated flows are taken from the cache
+// (unless cache=false is passed).
+func (router *RoutesHandler) BatchMaxFlows(w http.ResponseWriter, r *http.Request) {
+	url, err := preprocessRequest(r)
+	if err != nil {
+		logger.Error("Bad request: invalid security parameters: " + err.Error())
+		w.WriteHeader(BAD_REQUEST)
+		return
+	}
+
+	equivalent, isParamPresent := mux.Vars(r)["equivalent"]
+	if !isParamPresent {
+		logger.Error("Bad request: missing equivalent parameter: " + url)
+		w.WriteHeader(BAD_REQUEST)
+		return
+	}
+
+	contractorAddresses := r.URL.Query()["contractor_address"]
+	if len(contractorAddresses) == 0 {
+		logger.Error("Bad request: there are no contractor_addresses parameters: " + url)
+		w.WriteHeader(BAD_REQUEST)
+		return
+	}
+	for _, contractorAddress := range contractorAddresses {
+		addressType, address, isPresent := strings.Cut(contractorAddress, "-")
+		if !isPresent || !common.ValidateInt(addressType) || address == "" {
+			logger.Error("Bad request: invalid contractor_address parameter: " + url)
+			w.WriteHeader(BAD_REQUEST)
+			return
+		}
+	}
+
+	// Command processing.
+	// This command may execute relatively slow.
+	flows := router.nodeHandler.BatchMaxFlows(r.Context(), equivalent, contractorAddresses, r.FormValue("cache") != "false")
+	response := common.MaxFlowBatchResponse{Count: len(flows), Records: []common.MaxFlowBatchRecord{}}
+	for _, flow := range flows {
+		response.Records = append(response.Records, flow.Record())
+	}
+	writeHTTPResponse(w, OK, response)
+}
+
 func (router *RoutesHandler) CreateTransaction(w http.ResponseWriter, r *http.Request) {
 	url, err := preprocessRequest(r)
 	if err != nil {
@@ -131,12 +174,7 @@ func (router *RoutesHandler) CreateTransaction(w http.ResponseWriter, r *http.Re
 		if key != "contractor_address" {
 			continue
 		}
-
-		for _, value := range values {
-			typeAndAddress := strings.Split(value, "-")
-			contractorAddresses = append(contractorAddresses, typeAndAddress[0])
-			contractorAddresses = append(contractorAddresses, typeAndAddress[1])
-		}
+		contractorAddresses = append(contractorAddresses, values...)
 		break
 	}
 	if len(contractorAddresses) == 0 {
@@ -155,7 +193,7 @@ func (router *RoutesHandler) CreateTransaction(w http.ResponseWriter, r *http.Re
 	payload := r.FormValue("payload")
 
 	transactionUUIDStr := r.FormValue("transaction_uuid")
-	var transactionUUID uuid.UUID
+	transactionUUID := uuid.New()
 	if transactionUUIDStr != "" {
 		transactionUUID, err = uuid.Parse(transactionUUIDStr)
 		if err != nil {
@@ -165,56 +203,155 @@ func (router *RoutesHandler) CreateTransaction(w http.ResponseWriter, r *http.Re
 		}
 	}
 
-	// Command processing.
-	// This command may execute relatively slow.
-	contractorAddresses = append([]string{strconv.Itoa(len(contractorAddresses) / 2)}, contractorAddresses...)
-	contractorAddresses = append([]string{"CREATE:contractors/transactions"}, contractorAddresses...)
-	contractorAddresses = append(contractorAddresses, []string{amount, equivalent}...)
-	if payload != "" {
-		contractorAddresses = append(contractorAddresses, []string{payload}...)
+	idempotencyKey := r.Header.Get(IDEMPOTENCY_KEY_HEADER)
+	if len(idempotencyKey) > IDEMPOTENCY_KEY_MAX_LENGTH {
+		logger.Error("Bad request: invalid Idempotency-Key header: " + url)
+		w.WriteHeader(BAD_REQUEST)
+		return
 	}
 
-	var command *handler.Command
-	if transactionUUIDStr == "" {
-		command = handler.NewCommand(contractorAddresses...)
-	} else {
-		command = handler.NewCommandWithUUID(transactionUUID, contractorAddresses...)
+	order := handler.PaymentOrder{
+		CommandUUID:         transactionUUID,
+		ContractorAddresses: contractorAddresses,
+		Amount:              amount,
+		Equivalent:          equivalent,
+		Payload:             payload,
 	}
 
-	err = router.nodeHandler.Node.SendCommand(command)
+	if handler.RequiresApproval(order) {
+		router.requestPaymentApproval(w, r, url, idempotencyKey, order, transactionUUIDStr)
+		return
+	}
+
+	if r.FormValue("async") == "true" {
+		router.startAsyncPayment(w, r, idempotencyKey, order, transactionUUIDStr)
+		return
+	}
+
+	if idempotencyKey == "" {
+		// Command processing.
+		// This command may execute relatively slow.
+		outcome := router.nodeHandler.ExecutePayment(r.Context(), order)
+		writePaymentOutcome(w, outcome)
+		return
+	}
+
+	outcome, isReplayed, err := router.executeIdempotentPayment(r.Context(), idempotencyKey, order, transactionUUIDStr)
 	if err != nil {
-		logger.Error("Can't send command: " + string(command.ToBytes()) + " to node. Details: " + err.Error())
-		writeHTTPResponse(w, COMMAND_TRANSFERRING_ERROR, common.PaymentResponse{})
+		logger.Error("Can't process payment with Idempotency-Key: " + url + ". Details: " + err.Error())
+		switch err {
+		case errIdempotencyKeyReused:
+			writeHTTPResponse(w, UNPROCESSABLE_ENTITY, common.PaymentResponse{})
+		case errIdempotentPaymentUnresolved:
+			w.Header().Set("Retry-After", strconv.Itoa(int(IDEMPOTENCY_RESOLVE_DELAY.Seconds())))
+			writeHTTPResponse(w, CONFLICT, common.PaymentResponse{})
+		default:
+			writeServerError("Idempotency store error", w)
+		}
 		return
 	}
+	if isReplayed {
+		w.Header().Set(IDEMPOTENCY_REPLAYED_HEADER, "true")
+	}
+	writePaymentOutcome(w, outcome)
+}
 
-	result, err := router.nodeHandler.Node.GetResult(command, common.PAYMENT_OPERATION_TIMEOUT)
+// Checks, whether the payment could be done: it must be allowed by the payment policies
+// and the max flow toward the payee must cover the amount.
+// With send_if_feasible=true the feasible payment is sent right after the check.
+func (router *RoutesHandler) QuoteTransaction(w http.ResponseWriter, r *http.Request) {
+	url, err := preprocessRequest(r)
 	if err != nil {
-		logger.Error("Node is inaccessible during processing command: " +
-			string(command.ToBytes()) + ". Details: " + err.Error())
-		writeHTTPResponse(w, NODE_IS_INACCESSIBLE, common.PaymentResponse{})
+		logger.Error("Bad request: invalid security parameters: " + err.Error())
+		w.WriteHeader(BAD_REQUEST)
 		return
 	}
 
-	if result.Code != CREATED && result.Code != ENGINE_NO_EQUIVALENT {
-		logger.Error("Node return wrong command result: " + strconv.Itoa(result.Code) +
-			" on command: " + string(command.ToBytes()))
-		writeHTTPResponse(w, result.Code, common.PaymentResponse{})
+	equivalent, isParamPresent := mux.Vars(r)["equivalent"]
+	if !isParamPresent {
+		logger.Error("Bad request: missing equivalent parameter: " + url)
+		w.WriteHeader(BAD_REQUEST)
 		return
 	}
-	if result.Code == ENGINE_NO_EQUIVALENT {
-		logger.Info("Node hasn't equivalent for command: " + string(command.ToBytes()))
-		writeHTTPResponse(w, result.Code, common.PaymentResponse{})
+
+	contractorAddresses := r.URL.Query()["contractor_address"]
+	if len(contractorAddresses) == 0 {
+		logger.Error("Bad request: there are no contractor_addresses parameters: " + url)
+		w.WriteHeader(BAD_REQUEST)
 		return
 	}
 
-	if len(result.Tokens) == 0 {
-		logger.Error("Node return invalid result tokens size on command: " + string(command.ToBytes()))
-		writeHTTPResponse(w, ENGINE_UNEXPECTED_ERROR, common.PaymentResponse{})
+	amount := r.FormValue("amount")
+	if !common.ValidateSettlementLineAmount(amount) {
+		logger.Error("Bad request: invalid amount parameter: " + url)
+		w.WriteHeader(BAD_REQUEST)
+		return
+	}
+
+	transactionUUIDStr := r.FormValue("transaction_uuid")
+	transactionUUID := uuid.New()
+	if transactionUUIDStr != "" {
+		transactionUUID, err = uuid.Parse(transactionUUIDStr)
+		if err != nil {
+			logger.Error("Bad request: invalid transaction_uuid parameter: " + url)
+			w.WriteHeader(BAD_REQUEST)
+			return
+		}
+	}
+
+	order := handler.PaymentOrder{
+		CommandUUID:         transactionUUID,
+		ContractorAddresses: contractorAddresses,
+		Amount:              amount,
+		Equivalent:          equivalent,
+		Payload:             r.FormValue("payload"),
+	}
+
+	// Command processing.
+	// This command may execute relatively slow.
+	quote := router.nodeHandler.QuotePayment(r.Context(), order)
+	response := quote.Response(order)
+	if quote.Code != OK {
+		writeHTTPResponse(w, quote.Code, response)
+		return
+	}
+	if r.FormValue("send_if_feasible") != "true" || !quote.IsFeasible {
+		writeHTTPResponse(w, OK, response)
 		return
 	}
 
-	writeHTTPResponse(w, OK, common.PaymentResponse{TransactionUUID: result.Tokens[0]})
+	if handler.RequiresApproval(order) {
+		router.requestPaymentApproval(w, r, url, "", order, transactionUUIDStr)
+		return
+	}
+	outcome := router.nodeHandler.ExecutePayment(r.Context(), order)
+	response.Sent = true
+	response.Code = outcome.Code
+	response.TransactionUUID = outcome.TransactionUUID
+	if outcome.Violation != nil {
+		response.PolicyViolation = outcome.Violation.Code
+		response.PolicyViolationMessage = outcome.Violation.Message
+	}
+	if !outcome.IsSucceeded() {
+		writeHTTPResponse(w, outcome.Code, response)
+		return
+	}
+	writeHTTPResponse(w, OK, response)
+}
+
+func writePaymentOutcome(w http.ResponseWriter, outcome handler.PaymentOutcome) {
+	if outcome.Violation != nil {
+		writeHTTPResponse(w, PAYMENT_POLICY_VIOLATION, common.PolicyViolationResponse{
+			Code:    outcome.Violation.Code,
+			Message: outcome.Violation.Message,
+		})
+		return
+	}
+	if !outcome.IsSucceeded() {
+		writeHTTPResponse(w, outcome.Code, common.PaymentResponse{})
+		return
+	}
+	writeHTTPResponse(w, OK, common.PaymentResponse{TransactionUUID: outcome.TransactionUUID})
 }
 
 func (router *RoutesHandler) GetTransactionByCommandUUID(w http.ResponseWriter, r *http.Request) {
@@ -234,7 +371,7 @@ func (router *RoutesHandler) GetTransactionByCommandUUID(w http.ResponseWriter,
 
 	command := handler.NewCommand("GET:transaction/command-uuid", requestedCommandUUID)
 
-	err = router.nodeHandler.Node.SendCommand(command)
+	err = router.nodeHandler.Node.SendCommand(r.Context(), command)
 	if err != nil {
 		logger.Error("Can't send command: " + string(command.ToBytes()) + ". Details: " + err.Error())
 		writeHTTPResponse(w, COMMAND_TRANSFERRING_ERROR, common.GetTransactionByCommandUUIDResponse{})
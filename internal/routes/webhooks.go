@@ -0,0 +1,36 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/common"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/logger"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/webhooks"
+)
+
+// Lists webhook deliveries from the newest ones.
+// Optional query parameters: state (pending, delivered, dead) and subscription.
+func (router *RoutesHandler) ListWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	url, err := preprocessRequest(r)
+	if err != nil {
+		logger.Error("Bad request: invalid security parameters: " + err.Error())
+		w.WriteHeader(BAD_REQUEST)
+		return
+	}
+
+	state := r.URL.Query().Get("state")
+	if state != "" && state != webhooks.DELIVERY_STATE_PENDING &&
+		state != webhooks.DELIVERY_STATE_DELIVERED && state != webhooks.DELIVERY_STATE_DEAD {
+		logger.Error("Bad request: invalid state parameter: " + url)
+		w.WriteHeader(BAD_REQUEST)
+		return
+	}
+
+	deliveries, err := webhooks.Deliveries(state, r.URL.Query().Get("subscription"))
+	if err != nil {
+		logger.Error("Can't read webhook deliveries. Details: " + err.Error())
+		writeServerError("Webhooks store error", w)
+		return
+	}
+	writeHTTPResponse(w, OK, common.WebhookDeliveriesResponse{Count: len(deliveries), Deliveries: deliveries})
+}
@@ -0,0 +1,169 @@
+package scheduler
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// Aliases of the frequently used expressions.
+	cronAliases = map[string]string{
+		"@hourly":  "0 * * * *",
+		"@daily":   "0 0 * * *",
+		"@weekly":  "0 0 * * 0",
+		"@monthly": "0 0 1 * *",
+		"@yearly":  "0 0 1 1 *",
+	}
+
+	// Next run is searched no further than this period.
+	CRON_SEARCH_LIMIT = 5 * 366 * 24 * time.Hour
+)
+
+// Parsed cron expression: "minute hour day-of-month month day-of-week".
+// Fields support "*", single values, ranges ("1-5"), steps ("*/15", "0-30/10") and lists ("1,15").
+// Day of the week is 0-7 (0 and 7 are Sunday).
+// If both days of the month and of the week are restricted, the day matches any of them (as in cron).
+// As in cron, the field, that begins with "*" (including the steps like "*/2"), is not restricted.
+type cronExpression struct {
+	minutes     []bool
+	hours       []bool
+	daysOfMonth []bool
+	months      []bool
+	daysOfWeek  []bool
+
+	isDayOfMonthRestricted bool
+	isDayOfWeekRestricted  bool
+}
+
+func parseCron(expression string) (*cronExpression, error) {
+	expression = strings.TrimSpace(expression)
+	if alias, isPresent := cronAliases[expression]; isPresent {
+		expression = alias
+	}
+
+	fields := strings.Fields(expression)
+	if len(fields) != 5 {
+		return nil, errors.New("cron expression must contain 5 fields")
+	}
+
+	cron := &cronExpression{}
+	var err error
+	cron.minutes, _, err = parseCronField(fields[0], 0, 59)
+	if err != nil {
+		return nil, errors.New("invalid minutes: " + err.Error())
+	}
+	cron.hours, _, err = parseCronField(fields[1], 0, 23)
+	if err != nil {
+		return nil, errors.New("invalid hours: " + err.Error())
+	}
+	cron.daysOfMonth, cron.isDayOfMonthRestricted, err = parseCronField(fields[2], 1, 31)
+	if err != nil {
+		return nil, errors.New("invalid days of month: " + err.Error())
+	}
+	cron.months, _, err = parseCronField(fields[3], 1, 12)
+	if err != nil {
+		return nil, errors.New("invalid months: " + err.Error())
+	}
+	cron.daysOfWeek, cron.isDayOfWeekRestricted, err = parseCronField(fields[4], 0, 7)
+	if err != nil {
+		return nil, errors.New("invalid days of week: " + err.Error())
+	}
+	// 7 is Sunday too.
+	cron.daysOfWeek[0] = cron.daysOfWeek[0] || cron.daysOfWeek[7]
+	return cron, nil
+}
+
+// Returns matching values of the field (indexed by the value) and false if the field begins with "*".
+func parseCronField(field string, min, max int) ([]bool, bool, error) {
+	values := make([]bool, max+1)
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			step, err = strconv.Atoi(stepPart)
+			if err != nil || step < 1 {
+				return nil, false, errors.New("invalid step " + stepPart)
+			}
+		}
+
+		from, to := min, max
+		if rangePart != "*" {
+			fromPart, toPart, isRange := strings.Cut(rangePart, "-")
+			var err error
+			from, err = strconv.Atoi(fromPart)
+			if err != nil {
+				return nil, false, errors.New("invalid value " + fromPart)
+			}
+			to = from
+			if isRange {
+				to, err = strconv.Atoi(toPart)
+				if err != nil {
+					return nil, false, errors.New("invalid value " + toPart)
+				}
+			} else if hasStep {
+				to = max
+			}
+		}
+		if from < min || to > max || from > to {
+			return nil, false, errors.New("value is out of range " + part)
+		}
+
+		for value := from; value <= to; value += step {
+			values[value] = true
+		}
+	}
+	return values, !strings.HasPrefix(field, "*"), nil
+}
+
+func (c *cronExpression) isDayMatched(t time.Time) bool {
+	dayOfMonth := c.daysOfMonth[t.Day()]
+	dayOfWeek := c.daysOfWeek[int(t.Weekday())]
+	if c.isDayOfMonthRestricted && c.isDayOfWeekRestricted {
+		return dayOfMonth || dayOfWeek
+	}
+	return dayOfMonth && dayOfWeek
+}
+
+// Returns the first time, that matches the expression, strictly after the passed one.
+// Zero time is returned if there is no such time within the search limit (e.g. "0 0 30 2 *").
+func (c *cronExpression) next(after time.Time) time.Time {
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	limit := after.Add(CRON_SEARCH_LIMIT)
+	for t.Before(limit) {
+		if !c.months[int(t.Month())] {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !c.isDayMatched(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !c.hours[t.Hour()] {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if !c.minutes[t.Minute()] {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// Returns the first time, that matches the cron expression, strictly after the passed one.
+// Is used by the other periodic tasks of the CLI (e.g. maintenance).
+func NextCronTime(expression string, after time.Time) (time.Time, error) {
+	cron, err := parseCron(expression)
+	if err != nil {
+		return time.Time{}, err
+	}
+	next := cron.next(after)
+	if next.IsZero() {
+		return next, errors.New("cron expression never matches")
+	}
+	return next, nil
+}
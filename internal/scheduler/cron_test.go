@@ -0,0 +1,101 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func testTime(value string) time.Time {
+	t, err := time.Parse("2006-01-02 15:04", value)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestNextCronTime(t *testing.T) {
+	// 2026-01-01 is Thursday.
+	after := "2026-01-01 10:16"
+	cases := []struct {
+		name       string
+		expression string
+		after      string
+		expected   string
+	}{
+		{"hourly alias", "@hourly", after, "2026-01-01 11:00"},
+		{"daily alias", "@daily", after, "2026-01-02 00:00"},
+		{"weekly alias", "@weekly", after, "2026-01-04 00:00"},
+		{"monthly alias", "@monthly", after, "2026-02-01 00:00"},
+		{"yearly alias", "@yearly", after, "2027-01-01 00:00"},
+		{"every minute", "* * * * *", after, "2026-01-01 10:17"},
+		{"step", "*/15 * * * *", after, "2026-01-01 10:30"},
+		{"step of range", "0-30/10 10 * * *", after, "2026-01-01 10:20"},
+		{"step of range on the next day", "0-30/10 9 * * *", after, "2026-01-02 09:00"},
+		{"step from value", "5/20 * * * *", after, "2026-01-01 10:25"},
+		{"list", "5,45 10 * * *", after, "2026-01-01 10:45"},
+		{"list of days", "0 9 1,15 * *", after, "2026-01-15 09:00"},
+		{"list of ranges", "0 9-10,20-22 * * *", after, "2026-01-01 20:00"},
+		{"step of months", "0 0 1 */3 *", after, "2026-04-01 00:00"},
+		{"working days", "0 9 * * 1-5", after, "2026-01-02 09:00"},
+		{"working days after friday", "0 9 * * 1-5", "2026-01-02 10:00", "2026-01-05 09:00"},
+		{"sunday as 0", "0 9 * * 0", after, "2026-01-04 09:00"},
+		{"sunday as 7", "0 9 * * 7", after, "2026-01-04 09:00"},
+		{"range to 7", "0 9 * * 6-7", after, "2026-01-03 09:00"},
+		// Both days are restricted, so any of them matches.
+		{"day of week before day of month", "0 9 13 * 5", after, "2026-01-02 09:00"},
+		{"day of month before day of week", "0 9 2 * 1", after, "2026-01-02 09:00"},
+		{"range of days of month or day of week", "0 9 1-31/2 * 1", after, "2026-01-03 09:00"},
+		// Field, that begins with "*", is not restricted, so both days must match.
+		{"step of days of month and day of week", "0 9 */2 * 1", after, "2026-01-05 09:00"},
+		{"next step of days of month and day of week", "0 9 */2 * 1", "2026-01-05 09:00", "2026-01-19 09:00"},
+		{"day of month and step of days of week", "0 9 13 * */2", after, "2026-01-13 09:00"},
+		{"last day of february", "0 0 28-29 2 *", after, "2026-02-28 00:00"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			next, err := NextCronTime(c.expression, testTime(c.after))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !next.Equal(testTime(c.expected)) {
+				t.Fatalf("expected %s, got %s", c.expected, next.Format(time.RFC3339))
+			}
+		})
+	}
+}
+
+func TestNextCronTimeNeverMatches(t *testing.T) {
+	_, err := NextCronTime("0 0 30 2 *", testTime("2026-01-01 10:16"))
+	if err == nil {
+		t.Fatal("expression, that never matches, is accepted")
+	}
+}
+
+func TestInvalidCron(t *testing.T) {
+	cases := []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"@every",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * 32 * *",
+		"* * * 0 *",
+		"* * * 13 *",
+		"* * * * 8",
+		"-1 * * * *",
+		"a * * * *",
+		"5-1 * * * *",
+		"1- * * * *",
+		"*/0 * * * *",
+		"*/a * * * *",
+		"1,,2 * * * *",
+	}
+	for _, expression := range cases {
+		_, err := parseCron(expression)
+		if err == nil {
+			t.Fatalf("invalid expression %q is accepted", expression)
+		}
+	}
+}
@@ -0,0 +1,743 @@
+package scheduler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/audit"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/auth"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/common"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/conf"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/handler"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/logger"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/store"
+)
+
+var (
+	SCHEDULES_BUCKET = "schedules"
+	RUNS_BUCKET      = "schedule-runs"
+
+	DEFAULT_TICK_INTERVAL     = 10 * time.Second
+	DEFAULT_MISFIRE_GRACE     = time.Minute
+	DEFAULT_MAX_CATCH_UP_RUNS = 10
+	DEFAULT_HISTORY_LIMIT     = 100
+
+	// Interrupted run (which outcome is unknown) is executed again
+	// only after this period since it's start, so the engine has surely finished the original payment.
+	RUN_RESOLVE_DELAY = time.Second * time.Duration(2*common.PAYMENT_OPERATION_TIMEOUT)
+
+	// Missed runs are not executed, only the runs, that are on time.
+	MISSED_RUNS_SKIP = "skip"
+	// Latest missed runs (up to scheduler.max_catch_up_runs) are executed one by one.
+	MISSED_RUNS_CATCH_UP = "catch_up"
+
+	RUN_STATE_PENDING = "pending"
+	// Payment is above the approval threshold, pending payment with the UUID of the run was created.
+	RUN_STATE_PENDING_APPROVAL = "pending_approval"
+	RUN_STATE_SUCCEEDED        = "succeeded"
+	RUN_STATE_FAILED           = "failed"
+	RUN_STATE_SKIPPED          = "skipped"
+
+	// Due runs of one schedule, that are processed at once.
+	MAX_DUE_RUNS = 10000
+)
+
+var (
+	ErrScheduleNotFound = errors.New("schedule is not found")
+)
+
+// Recurring payment.
+// Schedule is executed on behalf of it's owner, so the payment policies of the owner are applied.
+type Schedule struct {
+	ID                  string    `json:"id"`
+	Name                string    `json:"name"`
+	Cron                string    `json:"cron"`
+	ContractorAddresses []string  `json:"contractor_addresses"`
+	Amount              string    `json:"amount"`
+	Equivalent          string    `json:"equivalent"`
+	PayloadTemplate     string    `json:"payload_template"`
+	MissedRuns          string    `json:"missed_runs"`
+	Enabled             bool      `json:"enabled"`
+	Owner               string    `json:"owner"`
+	CreatedAt           time.Time `json:"created_at"`
+	UpdatedAt           time.Time `json:"updated_at"`
+	// Planned time of the next run, that is not processed yet.
+	NextRunAt time.Time `json:"next_run_at"`
+}
+
+// Parameters of the schedule, that are set by the client.
+type Definition struct {
+	Name                string
+	Cron                string
+	ContractorAddresses []string
+	Amount              string
+	Equivalent          string
+	PayloadTemplate     string
+	MissedRuns          string
+	Enabled             bool
+}
+
+// Values, available in the payload template, e.g. "salary {{.Date}}".
+type payloadValues struct {
+	ScheduleID string
+	Name       string
+	// Planned time of the run in RFC3339.
+	Time string
+	// Planned date of the run (YYYY-MM-DD).
+	Date string
+}
+
+// One planned execution of the schedule.
+// UUID of the run is derived from the schedule and the planned time, and it is used as the command UUID,
+// so the run could not be paid twice.
+type Run struct {
+	UUID            string    `json:"uuid"`
+	ScheduleID      string    `json:"schedule_id"`
+	ScheduledAt     time.Time `json:"scheduled_at"`
+	State           string    `json:"state"`
+	Payload         string    `json:"payload,omitempty"`
+	TransactionUUID string    `json:"transaction_uuid,omitempty"`
+	Code            int       `json:"code,omitempty"`
+	PolicyViolation string    `json:"policy_violation,omitempty"`
+	StartedAt       time.Time `json:"started_at"`
+	CompletedAt     time.Time `json:"completed_at"`
+}
+
+func (s *Schedule) Response() common.ScheduleResponse {
+	response := common.ScheduleResponse{
+		ID:                  s.ID,
+		Name:                s.Name,
+		Cron:                s.Cron,
+		ContractorAddresses: s.ContractorAddresses,
+		Amount:              s.Amount,
+		Equivalent:          s.Equivalent,
+		PayloadTemplate:     s.PayloadTemplate,
+		MissedRuns:          s.MissedRuns,
+		Enabled:             s.Enabled,
+		Owner:               s.Owner,
+		CreatedAt:           s.CreatedAt.UTC().Format(time.RFC3339),
+		UpdatedAt:           s.UpdatedAt.UTC().Format(time.RFC3339),
+	}
+	if s.Enabled && !s.NextRunAt.IsZero() {
+		response.NextRunAt = s.NextRunAt.UTC().Format(time.RFC3339)
+	}
+	return response
+}
+
+func (r *Run) response() common.ScheduleRunResponse {
+	response := common.ScheduleRunResponse{
+		UUID:            r.UUID,
+		ScheduledAt:     r.ScheduledAt.UTC().Format(time.RFC3339),
+		State:           r.State,
+		Payload:         r.Payload,
+		TransactionUUID: r.TransactionUUID,
+		Code:            r.Code,
+		PolicyViolation: r.PolicyViolation,
+	}
+	if !r.StartedAt.IsZero() {
+		response.StartedAt = r.StartedAt.UTC().Format(time.RFC3339)
+	}
+	if !r.CompletedAt.IsZero() {
+		response.CompletedAt = r.CompletedAt.UTC().Format(time.RFC3339)
+	}
+	return response
+}
+
+func (r *Run) isCompleted() bool {
+	return r.State != RUN_STATE_PENDING
+}
+
+func (r *Run) complete(state string) {
+	r.State = state
+	r.CompletedAt = time.Now()
+}
+
+// Checks parameters of the schedule.
+func (d *Definition) Validate() error {
+	if strings.TrimSpace(d.Name) == "" {
+		return errors.New("name is required")
+	}
+	cron, err := parseCron(d.Cron)
+	if err != nil {
+		return errors.New("invalid cron: " + err.Error())
+	}
+	if cron.next(time.Now()).IsZero() {
+		return errors.New("cron never matches")
+	}
+	if len(d.ContractorAddresses) == 0 {
+		return errors.New("contractor addresses are required")
+	}
+	for _, address := range d.ContractorAddresses {
+		addressType, addressValue, isPresent := strings.Cut(address, "-")
+		if !isPresent || !common.ValidateInt(addressType) || addressValue == "" {
+			return errors.New("invalid contractor address " + address)
+		}
+	}
+	if !common.ValidateSettlementLineAmount(d.Amount) {
+		return errors.New("invalid amount")
+	}
+	if !common.ValidateInt(d.Equivalent) {
+		return errors.New("invalid equivalent")
+	}
+	_, err = template.New("payload").Parse(d.PayloadTemplate)
+	if err != nil {
+		return errors.New("invalid payload template: " + err.Error())
+	}
+	if d.MissedRuns != MISSED_RUNS_SKIP && d.MissedRuns != MISSED_RUNS_CATCH_UP {
+		return errors.New("invalid missed runs policy " + d.MissedRuns)
+	}
+	return nil
+}
+
+func (d *Definition) apply(schedule *Schedule, now time.Time) {
+	isTimingChanged := schedule.Cron != d.Cron || (d.Enabled && !schedule.Enabled)
+
+	schedule.Name = d.Name
+	schedule.Cron = d.Cron
+	schedule.ContractorAddresses = d.ContractorAddresses
+	schedule.Amount = d.Amount
+	schedule.Equivalent = d.Equivalent
+	schedule.PayloadTemplate = d.PayloadTemplate
+	schedule.MissedRuns = d.MissedRuns
+	schedule.Enabled = d.Enabled
+	schedule.UpdatedAt = now
+
+	if isTimingChanged || schedule.NextRunAt.IsZero() {
+		// Runs, that were planned before the change (or while the schedule was disabled), are not executed.
+		cron, _ := parseCron(schedule.Cron)
+		schedule.NextRunAt = cron.next(now)
+	}
+}
+
+func runUUID(scheduleID string, scheduledAt time.Time) uuid.UUID {
+	return uuid.NewSHA1(uuid.NameSpaceOID, []byte("schedule/"+scheduleID+"/"+scheduledAt.UTC().Format(time.RFC3339)))
+}
+
+// Runs are stored with the schedule ID prefix, so the runs of the schedule could be listed by the keys.
+func runKey(scheduleID, runUUID string) string {
+	return scheduleID + "_" + runUUID
+}
+
+func payload(schedule *Schedule, scheduledAt time.Time) (string, error) {
+	payloadTemplate, err := template.New("payload").Parse(schedule.PayloadTemplate)
+	if err != nil {
+		return "", err
+	}
+	var buffer bytes.Buffer
+	err = payloadTemplate.Execute(&buffer, payloadValues{
+		ScheduleID: schedule.ID,
+		Name:       schedule.Name,
+		Time:       scheduledAt.UTC().Format(time.RFC3339),
+		Date:       scheduledAt.UTC().Format(time.DateOnly),
+	})
+	return buffer.String(), err
+}
+
+// --- Storage ---
+
+func (s *Schedule) auditArguments() []string {
+	arguments := []string{s.Name, s.Cron, s.Equivalent, s.Amount}
+	return append(arguments, s.ContractorAddresses...)
+}
+
+func CreateSchedule(ctx context.Context, definition Definition) (*Schedule, error) {
+	owner := auth.LocalCaller()
+	if caller := auth.CallerFromContext(ctx); caller != nil {
+		owner = caller
+	}
+
+	bucket, err := store.OpenBucket(SCHEDULES_BUCKET)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	schedule := &Schedule{
+		ID:        uuid.New().String(),
+		Owner:     owner.Name,
+		CreatedAt: now,
+	}
+	definition.apply(schedule, now)
+	err = bucket.Put(schedule.ID, schedule)
+	if err != nil {
+		return nil, err
+	}
+	audit.Record(ctx, audit.OPERATION_SCHEDULE_CREATE, schedule.auditArguments(), schedule.ID, handler.CREATED)
+	return schedule, nil
+}
+
+func UpdateSchedule(ctx context.Context, scheduleID string, definition Definition) (*Schedule, error) {
+	bucket, err := store.OpenBucket(SCHEDULES_BUCKET)
+	if err != nil {
+		return nil, err
+	}
+	unlock, err := bucket.Lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	schedule := &Schedule{}
+	isPresent, err := bucket.Get(scheduleID, schedule)
+	if err != nil {
+		return nil, err
+	}
+	if !isPresent {
+		return nil, ErrScheduleNotFound
+	}
+	definition.apply(schedule, time.Now())
+	err = bucket.Put(schedule.ID, schedule)
+	if err != nil {
+		return nil, err
+	}
+	audit.Record(ctx, audit.OPERATION_SCHEDULE_UPDATE, schedule.auditArguments(), schedule.ID, handler.OK)
+	return schedule, nil
+}
+
+// Removes the schedule and it's runs history.
+func DeleteSchedule(ctx context.Context, scheduleID string) error {
+	bucket, err := store.OpenBucket(SCHEDULES_BUCKET)
+	if err != nil {
+		return err
+	}
+	unlock, err := bucket.Lock()
+	if err != nil {
+		return err
+	}
+	schedule := &Schedule{}
+	isPresent, err := bucket.Get(scheduleID, schedule)
+	if err == nil && isPresent {
+		err = bucket.Delete(scheduleID)
+	}
+	unlock()
+	if err != nil {
+		return err
+	}
+	if !isPresent {
+		return ErrScheduleNotFound
+	}
+	audit.Record(ctx, audit.OPERATION_SCHEDULE_DELETE, schedule.auditArguments(), schedule.ID, handler.OK)
+
+	runKeys, err := scheduleRunKeys(scheduleID)
+	if err != nil {
+		return err
+	}
+	runsBucket, err := store.OpenBucket(RUNS_BUCKET)
+	if err != nil {
+		return err
+	}
+	for _, key := range runKeys {
+		err = runsBucket.Delete(key)
+		if err != nil {
+			logger.Error("Can't remove run " + key + ". Details: " + err.Error())
+		}
+	}
+	return nil
+}
+
+func GetSchedule(scheduleID string) (*Schedule, error) {
+	bucket, err := store.OpenBucket(SCHEDULES_BUCKET)
+	if err != nil {
+		return nil, err
+	}
+	schedule := &Schedule{}
+	isPresent, err := bucket.Get(scheduleID, schedule)
+	if err != nil {
+		return nil, err
+	}
+	if !isPresent {
+		return nil, ErrScheduleNotFound
+	}
+	return schedule, nil
+}
+
+// Returns all schedules ordered by the creation time.
+func Schedules() ([]*Schedule, error) {
+	bucket, err := store.OpenBucket(SCHEDULES_BUCKET)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := bucket.Keys()
+	if err != nil {
+		return nil, err
+	}
+
+	var schedules []*Schedule
+	for _, key := range keys {
+		schedule := &Schedule{}
+		isPresent, err := bucket.Get(key, schedule)
+		if err != nil {
+			logger.Error("Can't read schedule " + key + ". Details: " + err.Error())
+			continue
+		}
+		if isPresent {
+			schedules = append(schedules, schedule)
+		}
+	}
+	sort.Slice(schedules, func(i, j int) bool {
+		return schedules[i].CreatedAt.Before(schedules[j].CreatedAt)
+	})
+	return schedules, nil
+}
+
+func scheduleRunKeys(scheduleID string) ([]string, error) {
+	bucket, err := store.OpenBucket(RUNS_BUCKET)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := bucket.Keys()
+	if err != nil {
+		return nil, err
+	}
+	var runKeys []string
+	for _, key := range keys {
+		if strings.HasPrefix(key, scheduleID+"_") {
+			runKeys = append(runKeys, key)
+		}
+	}
+	return runKeys, nil
+}
+
+// Returns runs history of the schedule from the newest runs.
+func Runs(scheduleID string) ([]common.ScheduleRunResponse, error) {
+	runs, err := scheduleRuns(scheduleID)
+	if err != nil {
+		return nil, err
+	}
+	responses := []common.ScheduleRunResponse{}
+	for _, run := range runs {
+		responses = append(responses, run.response())
+	}
+	return responses, nil
+}
+
+func scheduleRuns(scheduleID string) ([]*Run, error) {
+	keys, err := scheduleRunKeys(scheduleID)
+	if err != nil {
+		return nil, err
+	}
+	bucket, err := store.OpenBucket(RUNS_BUCKET)
+	if err != nil {
+		return nil, err
+	}
+
+	var runs []*Run
+	for _, key := range keys {
+		run := &Run{}
+		isPresent, err := bucket.Get(key, run)
+		if err != nil {
+			logger.Error("Can't read run " + key + ". Details: " + err.Error())
+			continue
+		}
+		if isPresent {
+			runs = append(runs, run)
+		}
+	}
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].ScheduledAt.After(runs[j].ScheduledAt)
+	})
+	return runs, nil
+}
+
+// --- Execution ---
+
+type scheduler struct {
+	nodeHandler *handler.NodeHandler
+	schedules   *store.Bucket
+	runs        *store.Bucket
+	// Schedules are processed by one goroutine, lock guards against the concurrent ticks.
+	lock sync.Mutex
+}
+
+// Starts execution of the schedules in the background.
+func Start(nodeHandler *handler.NodeHandler) error {
+	schedules, err := store.OpenBucket(SCHEDULES_BUCKET)
+	if err != nil {
+		return err
+	}
+	runs, err := store.OpenBucket(RUNS_BUCKET)
+	if err != nil {
+		return err
+	}
+	s := &scheduler{nodeHandler: nodeHandler, schedules: schedules, runs: runs}
+
+	interval := conf.Params.Scheduler.TickInterval
+	if interval <= 0 {
+		interval = DEFAULT_TICK_INTERVAL
+	}
+	go func() {
+		for {
+			s.tick(time.Now())
+			time.Sleep(interval)
+		}
+	}()
+	logger.Info("Scheduler started, tick interval " + interval.String())
+	return nil
+}
+
+// Checks settings of the scheduler.
+func ValidateSettings() error {
+	if conf.Params.Scheduler.MaxCatchUpRuns < 0 {
+		return errors.New("invalid scheduler max_catch_up_runs")
+	}
+	if conf.Params.Scheduler.HistoryLimit < 0 {
+		return errors.New("invalid scheduler history_limit")
+	}
+	return nil
+}
+
+func (s *scheduler) tick(now time.Time) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	schedules, err := Schedules()
+	if err != nil {
+		logger.Error("Can't read schedules. Details: " + err.Error())
+		return
+	}
+	for _, schedule := range schedules {
+		if !schedule.Enabled || schedule.NextRunAt.IsZero() || schedule.NextRunAt.After(now) {
+			continue
+		}
+		s.processDueRuns(schedule, now)
+	}
+}
+
+// Executes (or skips) the runs of the schedule, that are due, and moves the schedule to the next run.
+func (s *scheduler) processDueRuns(schedule *Schedule, now time.Time) {
+	cron, err := parseCron(schedule.Cron)
+	if err != nil {
+		logger.Error("Invalid cron of the schedule " + schedule.ID + ". Details: " + err.Error())
+		return
+	}
+
+	var dueRuns []time.Time
+	for t := schedule.NextRunAt; !t.IsZero() && !t.After(now) && len(dueRuns) < MAX_DUE_RUNS; t = cron.next(t) {
+		dueRuns = append(dueRuns, t)
+	}
+
+	grace := conf.Params.Scheduler.MisfireGrace
+	if grace <= 0 {
+		grace = DEFAULT_MISFIRE_GRACE
+	}
+	maxCatchUpRuns := conf.Params.Scheduler.MaxCatchUpRuns
+	if maxCatchUpRuns == 0 {
+		maxCatchUpRuns = DEFAULT_MAX_CATCH_UP_RUNS
+	}
+	historyLimit := s.historyLimit()
+
+	missedRuns := 0
+	for _, scheduledAt := range dueRuns {
+		if now.Sub(scheduledAt) > grace {
+			missedRuns++
+		}
+	}
+
+	var lastProcessedRun time.Time
+	for i, scheduledAt := range dueRuns {
+		isMissed := i < missedRuns
+		mustBeExecuted := !isMissed ||
+			(schedule.MissedRuns == MISSED_RUNS_CATCH_UP && missedRuns-i <= maxCatchUpRuns)
+
+		if mustBeExecuted {
+			if !s.execute(schedule, scheduledAt) {
+				// Outcome is not known yet, run would be processed on the next tick.
+				break
+			}
+		} else if missedRuns-i <= historyLimit {
+			// Only the latest skipped runs are recorded, older ones would be removed from the history anyway.
+			s.skip(schedule, scheduledAt)
+		}
+		lastProcessedRun = scheduledAt
+	}
+
+	if !lastProcessedRun.IsZero() {
+		s.advance(schedule, cron.next(lastProcessedRun))
+		s.removeOldRuns(schedule.ID)
+	}
+}
+
+func (s *scheduler) historyLimit() int {
+	if conf.Params.Scheduler.HistoryLimit == 0 {
+		return DEFAULT_HISTORY_LIMIT
+	}
+	return conf.Params.Scheduler.HistoryLimit
+}
+
+// Moves the schedule to the next run, if it was not changed since the processing was started.
+func (s *scheduler) advance(schedule *Schedule, nextRunAt time.Time) {
+	unlock, err := s.schedules.Lock()
+	if err != nil {
+		logger.Error("Can't lock schedules. Details: " + err.Error())
+		return
+	}
+	defer unlock()
+
+	stored := &Schedule{}
+	isPresent, err := s.schedules.Get(schedule.ID, stored)
+	if err != nil {
+		logger.Error("Can't read schedule " + schedule.ID + ". Details: " + err.Error())
+		return
+	}
+	if !isPresent || !stored.NextRunAt.Equal(schedule.NextRunAt) {
+		// Schedule was removed or changed, it's next run is already set.
+		return
+	}
+	stored.NextRunAt = nextRunAt
+	err = s.schedules.Put(stored.ID, stored)
+	if err != nil {
+		logger.Error("Can't write schedule " + schedule.ID + ". Details: " + err.Error())
+	}
+}
+
+func (s *scheduler) skip(schedule *Schedule, scheduledAt time.Time) {
+	run := &Run{
+		UUID:        runUUID(schedule.ID, scheduledAt).String(),
+		ScheduleID:  schedule.ID,
+		ScheduledAt: scheduledAt,
+	}
+	key := runKey(schedule.ID, run.UUID)
+	isPresent, err := s.runs.Get(key, &Run{})
+	if err != nil || isPresent {
+		// Run was already processed (it is never replaced by the skipped one).
+		return
+	}
+	run.complete(RUN_STATE_SKIPPED)
+	s.save(run)
+}
+
+// Executes the run. Returns false if the outcome of the payment is not known yet.
+func (s *scheduler) execute(schedule *Schedule, scheduledAt time.Time) bool {
+	commandUUID := runUUID(schedule.ID, scheduledAt)
+	key := runKey(schedule.ID, commandUUID.String())
+
+	ctx := auth.WithCaller(context.Background(), &auth.Caller{
+		Name:       schedule.Owner,
+		AuthMethod: auth.AUTH_METHOD_SCHEDULER,
+		SourceIP:   "scheduler",
+	})
+
+	run := &Run{}
+	isPresent, err := s.runs.Get(key, run)
+	if err != nil {
+		logger.Error("Can't read run " + key + ". Details: " + err.Error())
+		return false
+	}
+	if isPresent {
+		if run.isCompleted() {
+			return true
+		}
+		if !s.resolve(ctx, run) {
+			return false
+		}
+		if run.isCompleted() {
+			s.save(run)
+			return true
+		}
+	} else {
+		run = &Run{UUID: commandUUID.String(), ScheduleID: schedule.ID, ScheduledAt: scheduledAt}
+		run.Payload, err = payload(schedule, scheduledAt)
+		if err != nil {
+			logger.Error("Can't build payload of the run " + key + ". Details: " + err.Error())
+			run.complete(RUN_STATE_FAILED)
+			s.save(run)
+			return true
+		}
+	}
+	run.State = RUN_STATE_PENDING
+	run.StartedAt = time.Now()
+	// Run must be persisted before the payment is sent to the engine,
+	// otherwise the payment could be duplicated after the restart.
+	if !s.save(run) {
+		return false
+	}
+
+	order := handler.PaymentOrder{
+		CommandUUID:         commandUUID,
+		ContractorAddresses: schedule.ContractorAddresses,
+		Amount:              schedule.Amount,
+		Equivalent:          schedule.Equivalent,
+		Payload:             run.Payload,
+	}
+	if handler.RequiresApproval(order) {
+		_, _, err = handler.CreatePendingPayment(ctx, order)
+		if err != nil {
+			logger.Error("Can't create pending payment of the run " + key + ". Details: " + err.Error())
+			run.complete(RUN_STATE_FAILED)
+		} else {
+			run.complete(RUN_STATE_PENDING_APPROVAL)
+		}
+		s.save(run)
+		return true
+	}
+
+	outcome := s.nodeHandler.ExecutePayment(ctx, order)
+	if outcome.IsUnknown() {
+		return false
+	}
+	run.Code = outcome.Code
+	run.TransactionUUID = outcome.TransactionUUID
+	if outcome.Violation != nil {
+		run.PolicyViolation = outcome.Violation.Code
+	}
+	if outcome.IsSucceeded() {
+		run.complete(RUN_STATE_SUCCEEDED)
+	} else {
+		run.complete(RUN_STATE_FAILED)
+	}
+	s.save(run)
+	logger.Info("Run " + key + " is completed with state " + run.State)
+	return true
+}
+
+// Resolves the run, which payment was started, but it's outcome is unknown
+// (the engine didn't answer in time or the process was restarted).
+// Returns true if the run is resolved or must be executed again.
+func (s *scheduler) resolve(ctx context.Context, run *Run) bool {
+	code, transactionUUID := s.nodeHandler.FindTransactionByCommandUUID(ctx, uuid.MustParse(run.UUID))
+	if code != handler.OK {
+		return false
+	}
+	if transactionUUID != "" {
+		run.Code = handler.CREATED
+		run.TransactionUUID = transactionUUID
+		run.complete(RUN_STATE_SUCCEEDED)
+		return true
+	}
+	// Engine could still process the original payment.
+	return time.Since(run.StartedAt) >= RUN_RESOLVE_DELAY
+}
+
+func (s *scheduler) save(run *Run) bool {
+	err := s.runs.Put(runKey(run.ScheduleID, run.UUID), run)
+	if err != nil {
+		logger.Error("Can't write run " + run.UUID + " of the schedule " + run.ScheduleID + ". Details: " + err.Error())
+		return false
+	}
+	return true
+}
+
+// Keeps only the latest runs of the schedule.
+func (s *scheduler) removeOldRuns(scheduleID string) {
+	runs, err := scheduleRuns(scheduleID)
+	if err != nil {
+		logger.Error("Can't read runs of the schedule " + scheduleID + ". Details: " + err.Error())
+		return
+	}
+	limit := s.historyLimit()
+	for i := limit; i < len(runs); i++ {
+		if !runs[i].isCompleted() {
+			continue
+		}
+		err = s.runs.Delete(runKey(scheduleID, runs[i].UUID))
+		if err != nil {
+			logger.Error("Can't remove run " + runs[i].UUID + ". Details: " + err.Error())
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package scheduler
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/conf"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/conf/conftest"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/store"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/store/storetest"
+)
+
+// Returns the scheduler with the temporary store.
+// Payments of the test schedules are above the approval threshold,
+// so the executed runs become pending payments and the engine is not needed.
+func newTestScheduler(t *testing.T) *scheduler {
+	t.Helper()
+	storetest.UseTempDir(t)
+	conftest.Set(t, &conf.Params.Audit.FilePath, filepath.Join(t.TempDir(), "audit.log"))
+	conftest.Set(t, &conf.Params.Payments.Approvals.Thresholds, map[string]string{"1": "10"})
+	conftest.Set(t, &conf.Params.Scheduler, conf.SchedulerSettings{})
+
+	schedules, err := store.OpenBucket(SCHEDULES_BUCKET)
+	if err != nil {
+		t.Fatal(err)
+	}
+	runs, err := store.OpenBucket(RUNS_BUCKET)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &scheduler{schedules: schedules, runs: runs}
+}
+
+func countRunStates(t *testing.T, scheduleID string) map[string]int {
+	t.Helper()
+	runs, err := scheduleRuns(scheduleID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	states := make(map[string]int)
+	for _, run := range runs {
+		states[run.State]++
+	}
+	return states
+}
+
+func TestProcessDueRuns(t *testing.T) {
+	base := testTime("2026-01-01 10:00")
+	// Runs of 10:00-13:00 are missed, the run of 14:00 is on time.
+	now := base.Add(4*time.Hour + 30*time.Second)
+
+	cases := []struct {
+		name           string
+		missedRuns     string
+		maxCatchUpRuns int
+		historyLimit   int
+		expected       map[string]int
+	}{
+		{"skip", MISSED_RUNS_SKIP, 0, 0, map[string]int{RUN_STATE_SKIPPED: 4, RUN_STATE_PENDING_APPROVAL: 1}},
+		{"catch up", MISSED_RUNS_CATCH_UP, 0, 0, map[string]int{RUN_STATE_PENDING_APPROVAL: 5}},
+		{"catch up of the latest runs", MISSED_RUNS_CATCH_UP, 2, 0, map[string]int{RUN_STATE_SKIPPED: 2, RUN_STATE_PENDING_APPROVAL: 3}},
+		{"skip with the short history", MISSED_RUNS_SKIP, 0, 2, map[string]int{RUN_STATE_SKIPPED: 1, RUN_STATE_PENDING_APPROVAL: 1}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			s := newTestScheduler(t)
+			conf.Params.Scheduler.MaxCatchUpRuns = c.maxCatchUpRuns
+			conf.Params.Scheduler.HistoryLimit = c.historyLimit
+
+			schedule := &Schedule{
+				ID:                  "test",
+				Cron:                "@hourly",
+				ContractorAddresses: []string{"12-127.0.0.1:2000"},
+				Amount:              "100",
+				Equivalent:          "1",
+				MissedRuns:          c.missedRuns,
+				Enabled:             true,
+				Owner:               "owner",
+				NextRunAt:           base,
+			}
+			err := s.schedules.Put(schedule.ID, schedule)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			s.processDueRuns(schedule, now)
+			states := countRunStates(t, schedule.ID)
+			if len(states) != len(c.expected) {
+				t.Fatalf("expected runs %v, got %v", c.expected, states)
+			}
+			for state, count := range c.expected {
+				if states[state] != count {
+					t.Fatalf("expected runs %v, got %v", c.expected, states)
+				}
+			}
+
+			stored, err := GetSchedule(schedule.ID)
+			if err != nil || !stored.NextRunAt.Equal(base.Add(5*time.Hour)) {
+				t.Fatalf("unexpected next run of the schedule %+v, %v", stored, err)
+			}
+
+			// Processed runs are not processed again.
+			s.processDueRuns(stored, now)
+			if again := countRunStates(t, schedule.ID); fmt.Sprint(again) != fmt.Sprint(states) {
+				t.Fatalf("runs are changed by the repeated processing %v", again)
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package server
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+	"github.com/gorilla/mux"
+	"github.com/swaggest/swgui/v5emb"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/logger"
+)
+
+var (
+	OPENAPI_SPEC_PATH = "/api/v1/openapi.json"
+	SWAGGER_UI_PATH   = "/api/v1/docs/"
+)
+
+// OpenAPI specification of the main API.
+// Must be updated on each change of the routes in InitNodeHandlerServer(),
+// otherwise the contract test fails (see verifyOpenAPIContract()).
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+func loadOpenAPISpec() (*openapi3.T, error) {
+	loader := openapi3.NewLoader()
+	spec, err := loader.LoadFromData(openAPISpec)
+	if err != nil {
+		return nil, errors.New("can't parse OpenAPI specification -> " + err.Error())
+	}
+
+	err = spec.Validate(context.Background())
+	if err != nil {
+		return nil, errors.New("OpenAPI specification is invalid -> " + err.Error())
+	}
+	return spec, nil
+}
+
+// Registers routes of the API documentation:
+// OpenAPI specification itself and Swagger UI, that is embedded into the binary.
+func initDocumentationRoutes(router *mux.Router) {
+	router.HandleFunc(OPENAPI_SPEC_PATH, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(openAPISpec)
+	}).Methods("GET")
+
+	router.PathPrefix(SWAGGER_UI_PATH).Handler(
+		v5emb.New("vTCP CLI API", OPENAPI_SPEC_PATH, SWAGGER_UI_PATH))
+}
+
+// Checks, that each route of the router is described in the specification
+// and each operation of the specification is served by the router.
+// Swagger UI routes are not checked.
+func verifyOpenAPIContract(router *mux.Router, spec *openapi3.T) error {
+	served := make(map[string]bool)
+	err := router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		template, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			// Routes without methods (e.g. Swagger UI static files) are not a part of the API.
+			return nil
+		}
+		for _, method := range methods {
+			served[strings.ToUpper(method)+" "+template] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	documented := make(map[string]bool)
+	for path, pathItem := range spec.Paths.Map() {
+		for method := range pathItem.Operations() {
+			documented[strings.ToUpper(method)+" "+path] = true
+		}
+	}
+
+	var divergences []string
+	for operation := range served {
+		if !documented[operation] {
+			divergences = append(divergences, "not documented: "+operation)
+		}
+	}
+	for operation := range documented {
+		if !served[operation] {
+			divergences = append(divergences, "not served: "+operation)
+		}
+	}
+	if len(divergences) > 0 {
+		sort.Strings(divergences)
+		return errors.New("routes and OpenAPI specification diverge: " + strings.Join(divergences, "; "))
+	}
+	return nil
+}
+
+// HTTP middleware, that validates incoming requests against the OpenAPI specification.
+// Security parameters are not checked here, they are checked by the routes handlers.
+func newRequestValidationMiddleware(spec *openapi3.T) (mux.MiddlewareFunc, error) {
+	specRouter, err := gorillamux.NewRouter(spec)
+	if err != nil {
+		return nil, errors.New("can't build OpenAPI router -> " + err.Error())
+	}
+
+	options := &openapi3filter.Options{
+		AuthenticationFunc: openapi3filter.NoopAuthenticationFunc,
+		MultiError:         true,
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route, pathParams, err := specRouter.FindRoute(r)
+			if err != nil {
+				if errors.Is(err, routers.ErrPathNotFound) || errors.Is(err, routers.ErrMethodNotAllowed) {
+					// Not an API route (e.g. Swagger UI), nothing to validate.
+					next.ServeHTTP(w, r)
+					return
+				}
+				writeValidationError(w, err)
+				return
+			}
+
+			err = openapi3filter.ValidateRequest(r.Context(), &openapi3filter.RequestValidationInput{
+				Request:    r,
+				PathParams: pathParams,
+				Route:      route,
+				Options:    options,
+			})
+			if err != nil {
+				writeValidationError(w, err)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}, nil
+}
+
+func writeValidationError(w http.ResponseWriter, err error) {
+	logger.Error("Bad request: request doesn't match OpenAPI specification: " + err.Error())
+
+	js, _ := json.Marshal(map[string]string{"error": err.Error()})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	w.Write(js)
+}
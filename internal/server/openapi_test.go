@@ -0,0 +1,59 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/gorilla/mux"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/routes"
+)
+
+// Router of the main API is registered on http.DefaultServeMux, so it's built only once.
+func buildRouter(t *testing.T) *mux.Router {
+	t.Helper()
+	router, err := InitNodeHandlerServer(routes.NewRoutesHandler(nil))
+	if err != nil {
+		t.Fatalf("can't build router: %v", err)
+	}
+	return router
+}
+
+func TestOpenAPIContract(t *testing.T) {
+	router := buildRouter(t)
+	spec, err := loadOpenAPISpec()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("routes match specification", func(t *testing.T) {
+		err := verifyOpenAPIContract(router, spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("undocumented route is reported", func(t *testing.T) {
+		extended := mux.NewRouter()
+		extended.HandleFunc("/api/v1/undocumented/", func(http.ResponseWriter, *http.Request) {}).Methods("GET")
+		err := verifyOpenAPIContract(extended, spec)
+		if err == nil || !strings.Contains(err.Error(), "not documented: GET /api/v1/undocumented/") {
+			t.Fatalf("undocumented route is not reported: %v", err)
+		}
+	})
+
+	t.Run("unserved operation is reported", func(t *testing.T) {
+		extended, err := loadOpenAPISpec()
+		if err != nil {
+			t.Fatal(err)
+		}
+		extended.Paths.Set("/api/v1/unserved/", &openapi3.PathItem{
+			Get: &openapi3.Operation{Responses: openapi3.NewResponses()},
+		})
+		err = verifyOpenAPIContract(router, extended)
+		if err == nil || !strings.Contains(err.Error(), "not served: GET /api/v1/unserved/") {
+			t.Fatalf("unserved operation is not reported: %v", err)
+		}
+	})
+}
@@ -1,17 +1,76 @@
 package server
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/gorilla/mux"
 	"github.com/vTCP-Foundation/vtcpd-cli/internal/conf"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/handler"
 	"github.com/vTCP-Foundation/vtcpd-cli/internal/logger"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/maintenance"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/policy"
 	"github.com/vTCP-Foundation/vtcpd-cli/internal/routes"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/scheduler"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/tracing"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/webhooks"
 )
 
-func InitNodeHandlerServer(r *routes.RoutesHandler) *mux.Router {
+func InitNodeHandlerServer(r *routes.RoutesHandler) (*mux.Router, error) {
+	err := routes.ValidateAddressesSettings()
+	if err != nil {
+		return nil, err
+	}
+
+	err = routes.ValidateRateLimitsSettings()
+	if err != nil {
+		return nil, err
+	}
+
+	err = policy.ValidateSettings()
+	if err != nil {
+		return nil, errors.New("invalid payment policies -> " + err.Error())
+	}
+
+	err = handler.ValidateApprovalsSettings()
+	if err != nil {
+		return nil, errors.New("invalid payment approvals -> " + err.Error())
+	}
+
+	err = scheduler.ValidateSettings()
+	if err != nil {
+		return nil, errors.New("invalid scheduler settings -> " + err.Error())
+	}
+
+	err = maintenance.ValidateSettings()
+	if err != nil {
+		return nil, errors.New("invalid maintenance settings -> " + err.Error())
+	}
+
+	err = webhooks.ValidateSettings()
+	if err != nil {
+		return nil, errors.New("invalid webhooks settings -> " + err.Error())
+	}
+
+	spec, err := loadOpenAPISpec()
+	if err != nil {
+		return nil, err
+	}
+
+	validationMiddleware, err := newRequestValidationMiddleware(spec)
+	if err != nil {
+		return nil, err
+	}
 
 	router := mux.NewRouter()
+	router.Use(tracing.HTTPMiddleware)
+	router.Use(routes.RequesterMiddleware)
+	// Rate limits are applied before any other processing of the request.
+	router.Use(routes.RateLimitMiddleware)
+	router.Use(validationMiddleware)
+
+	// Documentation
+	initDocumentationRoutes(router)
 
 	// Equivalents
 	router.HandleFunc("/api/v1/node/equivalents/", r.ListEquivalents).Methods("GET")
@@ -33,6 +92,7 @@ func InitNodeHandlerServer(r *routes.RoutesHandler) *mux.Router {
 	router.HandleFunc("/api/v1/node/contractors/settlement-lines/{equivalent}/", r.ListSettlementLines).Methods("GET")
 	router.HandleFunc("/api/v1/node/contractors/settlement-lines/{offset}/{count}/{equivalent}/", r.ListSettlementLinesPortions).Methods("GET")
 	router.HandleFunc("/api/v1/node/contractors/settlement-lines/equivalents/all/", r.ListSettlementLinesAllEquivalents).Methods("GET")
+	router.HandleFunc("/api/v1/node/contractors/settlement-lines/reconcile/{equivalent}/", r.ReconcileSettlementLines).Methods("GET")
 	router.HandleFunc("/api/v1/node/contractors/settlement-line-by-id/{equivalent}/", r.GetSettlementLineByID).Methods("GET")
 	router.HandleFunc("/api/v1/node/contractors/settlement-line-by-address/{equivalent}/", r.GetSettlementLineByAddress).Methods("GET")
 	router.HandleFunc("/api/v1/node/contractors/{contractor_id}/init-settlement-line/{equivalent}/", r.InitSettlementLine).Methods("POST")
@@ -45,7 +105,29 @@ func InitNodeHandlerServer(r *routes.RoutesHandler) *mux.Router {
 	// Contractors / Transactions
 	router.HandleFunc("/api/v1/node/contractors/transactions/{equivalent}/", r.CreateTransaction).Methods("POST")
 	router.HandleFunc("/api/v1/node/contractors/transactions/max/{equivalent}/", r.BatchMaxFullyTransaction).Methods("GET")
+	router.HandleFunc("/api/v1/node/contractors/transactions/max-batch/{equivalent}/", r.BatchMaxFlows).Methods("GET")
+	router.HandleFunc("/api/v1/node/contractors/transactions/quote/{equivalent}/", r.QuoteTransaction).Methods("POST")
 	router.HandleFunc("/api/v1/node/transactions/{command_uuid}/", r.GetTransactionByCommandUUID).Methods("GET")
+	router.HandleFunc("/api/v1/node/jobs/{uuid}/", r.GetPaymentJob).Methods("GET")
+
+	// Payment approvals
+	router.HandleFunc("/api/v1/node/pending-payments/", r.ListPendingPayments).Methods("GET")
+	router.HandleFunc("/api/v1/node/pending-payments/{uuid}/", r.GetPendingPayment).Methods("GET")
+	router.HandleFunc("/api/v1/node/pending-payments/{uuid}/approve/", r.ApprovePendingPayment).Methods("POST")
+	router.HandleFunc("/api/v1/node/pending-payments/{uuid}/reject/", r.RejectPendingPayment).Methods("POST")
+
+	// Scheduled payments
+	router.HandleFunc("/api/v1/node/schedules/", r.ListSchedules).Methods("GET")
+	router.HandleFunc("/api/v1/node/schedules/", r.CreateSchedule).Methods("POST")
+	router.HandleFunc("/api/v1/node/schedules/{id}/", r.GetSchedule).Methods("GET")
+	router.HandleFunc("/api/v1/node/schedules/{id}/", r.UpdateSchedule).Methods("PUT")
+	router.HandleFunc("/api/v1/node/schedules/{id}/", r.DeleteSchedule).Methods("DELETE")
+	router.HandleFunc("/api/v1/node/schedules/{id}/runs/", r.ListScheduleRuns).Methods("GET")
+
+	// Bulk payments
+	router.HandleFunc("/api/v1/node/bulk-payments/", r.CreateBulkPayment).Methods("POST")
+	router.HandleFunc("/api/v1/node/bulk-payments/{id}/", r.GetBulkPayment).Methods("GET")
+	router.HandleFunc("/api/v1/node/bulk-payments/{id}/resume/", r.ResumeBulkPayment).Methods("POST")
 
 	// Stats
 	router.HandleFunc("/api/v1/node/stats/total-balance/{equivalent}/", r.TotalBalance).Methods("GET")
@@ -56,15 +138,42 @@ func InitNodeHandlerServer(r *routes.RoutesHandler) *mux.Router {
 	router.HandleFunc("/api/v1/node/history/transactions/payments/additional/{offset}/{count}/{equivalent}/", r.PaymentsAdditionalHistory).Methods("GET")
 	router.HandleFunc("/api/v1/node/history/transactions/settlement-lines/{offset}/{count}/{equivalent}/", r.SettlementLinesHistory).Methods("GET")
 	router.HandleFunc("/api/v1/node/history/contractors/{offset}/{count}/{equivalent}/", r.HistoryWithContractor).Methods("GET")
+	router.HandleFunc("/api/v1/node/history/export/payments-all/", r.ExportHistory).Methods("GET")
+	router.HandleFunc("/api/v1/node/history/export/{kind}/{equivalent}/", r.ExportHistory).Methods("GET")
+	router.HandleFunc("/api/v1/node/history-index/status/", r.HistoryIndexStatus).Methods("GET")
+	router.HandleFunc("/api/v1/node/history-index/{kind}/{equivalent}/", r.HistoryIndexRecords).Methods("GET")
+	router.HandleFunc("/api/v1/node/balances/samples/{equivalent}/", r.BalanceSamples).Methods("GET")
+	router.HandleFunc("/api/v1/node/balances/aggregates/{equivalent}/", r.BalanceAggregates).Methods("GET")
 
 	// Optimization
 	router.HandleFunc("/api/v1/node/remove-outdated-crypto/", r.RemoveOutdatedCryptoData).Methods("DELETE")
 	router.HandleFunc("/api/v1/node/regenerate-all-keys/", r.RegenerateAllKeys).Methods("POST")
+	router.HandleFunc("/api/v1/node/maintenance/", r.MaintenanceStatus).Methods("GET")
+
+	// Jobs
+	router.HandleFunc("/api/v1/jobs/", r.ListJobs).Methods("GET")
+	router.HandleFunc("/api/v1/jobs/", r.CreateJob).Methods("POST")
+	router.HandleFunc("/api/v1/jobs/{id}/", r.GetJob).Methods("GET")
+	router.HandleFunc("/api/v1/jobs/{id}/", r.CancelJob).Methods("DELETE")
+	router.HandleFunc("/api/v1/jobs/{id}/pause/", r.PauseJob).Methods("POST")
+	router.HandleFunc("/api/v1/jobs/{id}/resume/", r.ResumeJob).Methods("POST")
 
 	// Control
 	router.HandleFunc("/api/v1/ctrl/stop/", r.StopEverything).Methods("POST")
 
+	// Metrics
+	router.HandleFunc("/api/v1/metrics/", r.Metrics).Methods("GET")
+
+	// Webhooks
+	router.HandleFunc("/api/v1/webhooks/deliveries/", r.ListWebhookDeliveries).Methods("GET")
+
+	// Contract is enforced by the tests (openapi_test.go), here the divergence is only reported.
+	err = verifyOpenAPIContract(router, spec)
+	if err != nil {
+		logger.Error("OpenAPI specification is outdated: " + err.Error())
+	}
+
 	http.Handle("/", router)
 	logger.Info("Requests accepting started on " + conf.Params.HTTP.HTTPInterface())
-	return router
+	return router, nil
 }
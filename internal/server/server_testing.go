@@ -5,11 +5,13 @@ import (
 	"github.com/vTCP-Foundation/vtcpd-cli/internal/conf"
 	"github.com/vTCP-Foundation/vtcpd-cli/internal/logger"
 	"github.com/vTCP-Foundation/vtcpd-cli/internal/routes"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/tracing"
 )
 
 func InitTestNodeHandlerServer(r *routes.RoutesHandler) *mux.Router {
 
 	router := mux.NewRouter()
+	router.Use(tracing.HTTPMiddleware)
 
 	router.HandleFunc("/api/v1/node/subsystems-controller/{flags}/", r.SetTestingFlags).Methods("PUT")
 	router.HandleFunc("/api/v1/node/settlement-lines-influence/{flags}/", r.SetSLInfluenceFlags).Methods("PUT")
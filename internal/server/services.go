@@ -0,0 +1,57 @@
+package server
+
+import (
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/backup"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/balances"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/handler"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/historyindex"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/jobs"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/maintenance"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/scheduler"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/watcher"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/webhooks"
+)
+
+// Starts background services of the HTTP server.
+// Node communication must be already started.
+func StartServices(nodeHandler *handler.NodeHandler) error {
+	if webhooks.IsEnabled() {
+		err := webhooks.Start()
+		if err != nil {
+			return err
+		}
+		err = watcher.Start(nodeHandler)
+		if err != nil {
+			return err
+		}
+	}
+	if historyindex.IsEnabled() {
+		err := historyindex.Start(nodeHandler)
+		if err != nil {
+			return err
+		}
+	}
+	if balances.IsEnabled() {
+		err := balances.Start(nodeHandler)
+		if err != nil {
+			return err
+		}
+	}
+	if backup.IsEnabled() {
+		err := backup.Start(nodeHandler)
+		if err != nil {
+			return err
+		}
+	}
+	err := jobs.Start(nodeHandler)
+	if err != nil {
+		return err
+	}
+	if maintenance.IsEnabled() {
+		err = maintenance.Start(nodeHandler)
+		if err != nil {
+			return err
+		}
+	}
+	return scheduler.Start(nodeHandler)
+}
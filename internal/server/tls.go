@@ -0,0 +1,146 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/conf"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/logger"
+)
+
+var (
+	// Minimal interval between checks of the certificates files for modifications.
+	CERTIFICATES_RELOAD_CHECK_INTERVAL = time.Second * 10
+)
+
+// Starts HTTP server on the interface from the settings.
+// If TLS certificate and key are set - HTTPS is used,
+// if client CA is set too - client certificates are required and verified (mutual TLS).
+func ListenAndServe(settings conf.HTTPSettings, handler http.Handler) error {
+	if !settings.TLS.Enabled() {
+		return http.ListenAndServe(settings.HTTPInterface(), handler)
+	}
+
+	reloader, err := newCertificatesReloader(settings.TLS)
+	if err != nil {
+		return err
+	}
+
+	server := &http.Server{
+		Addr:    settings.HTTPInterface(),
+		Handler: handler,
+		TLSConfig: &tls.Config{
+			MinVersion:         tls.VersionTLS12,
+			GetConfigForClient: reloader.getConfigForClient,
+		},
+	}
+
+	logger.Info("TLS is enabled on " + settings.HTTPInterface())
+	// Certificates are provided by the TLS config, so files are not passed here.
+	return server.ListenAndServeTLS("", "")
+}
+
+// Keeps server certificate and client CA pool and reloads them,
+// when the files are changed (e.g. on certificates rotation).
+type certificatesReloader struct {
+	settings conf.TLSSettings
+
+	lock        sync.Mutex
+	config      *tls.Config
+	modTimes    map[string]time.Time
+	lastCheckAt time.Time
+}
+
+func newCertificatesReloader(settings conf.TLSSettings) (*certificatesReloader, error) {
+	reloader := &certificatesReloader{
+		settings: settings,
+		modTimes: make(map[string]time.Time),
+	}
+	err := reloader.reload()
+	if err != nil {
+		return nil, err
+	}
+	return reloader, nil
+}
+
+func (c *certificatesReloader) files() []string {
+	files := []string{c.settings.CertFile, c.settings.KeyFile}
+	if c.settings.ClientCAFile != "" {
+		files = append(files, c.settings.ClientCAFile)
+	}
+	return files
+}
+
+func (c *certificatesReloader) reload() error {
+	certificate, err := tls.LoadX509KeyPair(c.settings.CertFile, c.settings.KeyFile)
+	if err != nil {
+		return errors.New("can't load TLS certificate -> " + err.Error())
+	}
+
+	config := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{certificate},
+	}
+
+	if c.settings.ClientCAFile != "" {
+		caContent, err := os.ReadFile(c.settings.ClientCAFile)
+		if err != nil {
+			return errors.New("can't read client CA file -> " + err.Error())
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caContent) {
+			return errors.New("client CA file doesn't contain valid certificates")
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	for _, file := range c.files() {
+		info, err := os.Stat(file)
+		if err != nil {
+			return errors.New("can't stat " + file + " -> " + err.Error())
+		}
+		c.modTimes[file] = info.ModTime()
+	}
+
+	c.config = config
+	return nil
+}
+
+func (c *certificatesReloader) isChanged() bool {
+	for _, file := range c.files() {
+		info, err := os.Stat(file)
+		if err != nil {
+			// File could be temporary absent during the rotation.
+			return false
+		}
+		if !info.ModTime().Equal(c.modTimes[file]) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *certificatesReloader) getConfigForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if time.Since(c.lastCheckAt) >= CERTIFICATES_RELOAD_CHECK_INTERVAL {
+		c.lastCheckAt = time.Now()
+		if c.isChanged() {
+			err := c.reload()
+			if err != nil {
+				// Previous certificates are used until the new ones would be loaded well.
+				logger.Error("Can't reload TLS certificates. Details: " + err.Error())
+			} else {
+				logger.Info("TLS certificates reloaded")
+			}
+		}
+	}
+	return c.config, nil
+}
@@ -0,0 +1,334 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"syscall"
+
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/conf"
+)
+
+var (
+	// Directory of the store, if it is not set in the settings.
+	DEFAULT_STORAGE_DIR = "storage"
+
+	DOCUMENT_EXTENSION = ".json"
+	LOG_EXTENSION      = ".jsonl"
+	LOCK_FILE_NAME     = ".lock"
+	LEASE_EXTENSION    = ".lease"
+
+	// Maximal size of the one record of the log.
+	LOG_MAX_RECORD_SIZE = 1024 * 1024
+)
+
+var keyPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// Directory of JSON documents (one file per key) inside the storage directory.
+// Documents are written atomically (temporary file + rename), so readers never see partial documents.
+// Store is shared between the HTTP server and the CLI commands, processes are synchronized by Lock().
+type Bucket struct {
+	dir string
+}
+
+func storageDir() string {
+	if conf.Params.Storage.Dir == "" {
+		return DEFAULT_STORAGE_DIR
+	}
+	return conf.Params.Storage.Dir
+}
+
+// Opens bucket with the name, creates it's directory if it is absent.
+func OpenBucket(name string) (*Bucket, error) {
+	if !keyPattern.MatchString(name) {
+		return nil, errors.New("invalid bucket name " + name)
+	}
+	dir := filepath.Join(storageDir(), name)
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return nil, errors.New("can't create bucket directory " + dir + " -> " + err.Error())
+	}
+	return &Bucket{dir: dir}, nil
+}
+
+func (b *Bucket) path(key string) (string, error) {
+	if !keyPattern.MatchString(key) || strings.HasPrefix(key, ".") {
+		return "", errors.New("invalid key " + key)
+	}
+	return filepath.Join(b.dir, key+DOCUMENT_EXTENSION), nil
+}
+
+// Writes document under the key.
+func (b *Bucket) Put(key string, value interface{}) error {
+	path, err := b.path(key)
+	if err != nil {
+		return err
+	}
+	js, err := json.Marshal(value)
+	if err != nil {
+		return errors.New("can't marshal document " + key + " -> " + err.Error())
+	}
+
+	file, err := os.CreateTemp(b.dir, "."+key+".*.tmp")
+	if err != nil {
+		return errors.New("can't create temporary file -> " + err.Error())
+	}
+	_, err = file.Write(js)
+	if err == nil {
+		err = file.Sync()
+	}
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return errors.New("can't write document " + key + " -> " + err.Error())
+	}
+
+	err = os.Rename(file.Name(), path)
+	if err != nil {
+		os.Remove(file.Name())
+		return errors.New("can't write document " + key + " -> " + err.Error())
+	}
+	return nil
+}
+
+// Reads document under the key into the value.
+// Returns false if there is no such document.
+func (b *Bucket) Get(key string, value interface{}) (bool, error) {
+	path, err := b.path(key)
+	if err != nil {
+		return false, err
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, errors.New("can't read document " + key + " -> " + err.Error())
+	}
+	err = json.Unmarshal(content, value)
+	if err != nil {
+		return false, errors.New("can't parse document " + key + " -> " + err.Error())
+	}
+	return true, nil
+}
+
+// Removes document under the key together with it's lease file. Absent document is not an error.
+func (b *Bucket) Delete(key string) error {
+	path, err := b.path(key)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(path)
+	if err != nil && !os.IsNotExist(err) {
+		return errors.New("can't remove document " + key + " -> " + err.Error())
+	}
+	err = os.Remove(b.leasePath(key))
+	if err != nil && !os.IsNotExist(err) {
+		return errors.New("can't remove lease of the document " + key + " -> " + err.Error())
+	}
+	return nil
+}
+
+// Returns keys of all the documents of the bucket.
+func (b *Bucket) Keys() ([]string, error) {
+	entries, err := os.ReadDir(b.dir)
+	if err != nil {
+		return nil, errors.New("can't read bucket directory " + b.dir + " -> " + err.Error())
+	}
+	var keys []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") || !strings.HasSuffix(name, DOCUMENT_EXTENSION) {
+			continue
+		}
+		keys = append(keys, strings.TrimSuffix(name, DOCUMENT_EXTENSION))
+	}
+	return keys, nil
+}
+
+// Acquires exclusive lock of the bucket, that is shared between the processes.
+// Should be used for the read-modify-write sequences. Returned function releases the lock.
+func (b *Bucket) Lock() (func(), error) {
+	file, err := os.OpenFile(filepath.Join(b.dir, LOCK_FILE_NAME), os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, errors.New("can't open lock file -> " + err.Error())
+	}
+	err = syscall.Flock(int(file.Fd()), syscall.LOCK_EX)
+	if err != nil {
+		file.Close()
+		return nil, errors.New("can't lock bucket -> " + err.Error())
+	}
+	return func() {
+		syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+		file.Close()
+	}, nil
+}
+
+func (b *Bucket) leasePath(key string) string {
+	return filepath.Join(b.dir, "."+key+LEASE_EXTENSION)
+}
+
+// Acquires exclusive lease of the key without waiting, the lease is shared between the processes.
+// Unlike Lock(), it's held for the long operations on the document (e.g. processing of it's items),
+// so the other processes don't wait for it. Lease is released by the returned function or when the process exits.
+// Returns false if the lease is held by someone else (including the other call of this process).
+func (b *Bucket) TryLease(key string) (func(), bool, error) {
+	_, err := b.path(key)
+	if err != nil {
+		return nil, false, err
+	}
+	file, err := os.OpenFile(b.leasePath(key), os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, false, errors.New("can't open lease file -> " + err.Error())
+	}
+	err = syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if err == syscall.EWOULDBLOCK {
+		file.Close()
+		return nil, false, nil
+	}
+	if err != nil {
+		file.Close()
+		return nil, false, errors.New("can't lease document " + key + " -> " + err.Error())
+	}
+	return func() {
+		syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+		file.Close()
+	}, true, nil
+}
+
+// Returns true if the lease of the key is held right now.
+func (b *Bucket) IsLeased(key string) (bool, error) {
+	release, isAcquired, err := b.TryLease(key)
+	if err != nil {
+		return false, err
+	}
+	if isAcquired {
+		release()
+	}
+	return !isAcquired, nil
+}
+
+// Append-only file of JSON records (one record per line) inside the bucket.
+// It's used for the data, that is too large to be kept as the single document.
+type Log struct {
+	path string
+}
+
+// Opens log with the name, the file is created on the first append.
+// Partially written last line (e.g. after the crash) is cut off.
+func (b *Bucket) OpenLog(name string) (*Log, error) {
+	if !keyPattern.MatchString(name) || strings.HasPrefix(name, ".") {
+		return nil, errors.New("invalid log name " + name)
+	}
+	log := &Log{path: filepath.Join(b.dir, name+LOG_EXTENSION)}
+
+	content, err := os.ReadFile(log.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return log, nil
+		}
+		return nil, errors.New("can't read log " + name + " -> " + err.Error())
+	}
+	if len(content) > 0 && content[len(content)-1] != '\n' {
+		err = os.Truncate(log.path, int64(bytes.LastIndexByte(content, '\n')+1))
+		if err != nil {
+			return nil, errors.New("can't cut off partial record of the log " + name + " -> " + err.Error())
+		}
+	}
+	return log, nil
+}
+
+// Appends records to the end of the log.
+func (l *Log) Append(records ...interface{}) error {
+	var buffer bytes.Buffer
+	for _, record := range records {
+		js, err := json.Marshal(record)
+		if err != nil {
+			return errors.New("can't marshal log record -> " + err.Error())
+		}
+		buffer.Write(js)
+		buffer.WriteByte('\n')
+	}
+
+	file, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return errors.New("can't open log -> " + err.Error())
+	}
+	_, err = file.Write(buffer.Bytes())
+	if err == nil {
+		err = file.Sync()
+	}
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return errors.New("can't append to log -> " + err.Error())
+	}
+	return nil
+}
+
+// Reads all the records of the log, each record is unmarshalled by the decode.
+func (l *Log) Read(decode func(record []byte) error) error {
+	file, err := os.Open(l.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return errors.New("can't open log -> " + err.Error())
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), LOG_MAX_RECORD_SIZE)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		err = decode(scanner.Bytes())
+		if err != nil {
+			return err
+		}
+	}
+	if scanner.Err() != nil {
+		return errors.New("can't read log -> " + scanner.Err().Error())
+	}
+	return nil
+}
+
+// Returns names of all the logs of the bucket.
+func (b *Bucket) Logs() ([]string, error) {
+	entries, err := os.ReadDir(b.dir)
+	if err != nil {
+		return nil, errors.New("can't read bucket directory " + b.dir + " -> " + err.Error())
+	}
+	var names []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") || !strings.HasSuffix(name, LOG_EXTENSION) {
+			continue
+		}
+		names = append(names, strings.TrimSuffix(name, LOG_EXTENSION))
+	}
+	return names, nil
+}
+
+// Removes the log. Absent log is not an error.
+func (b *Bucket) DeleteLog(name string) error {
+	if !keyPattern.MatchString(name) || strings.HasPrefix(name, ".") {
+		return errors.New("invalid log name " + name)
+	}
+	err := os.Remove(filepath.Join(b.dir, name+LOG_EXTENSION))
+	if err != nil && !os.IsNotExist(err) {
+		return errors.New("can't remove log " + name + " -> " + err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/store/storetest"
+)
+
+func openTestBucket(t *testing.T) *Bucket {
+	t.Helper()
+	storetest.UseTempDir(t)
+	bucket, err := OpenBucket("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bucket
+}
+
+func TestLease(t *testing.T) {
+	bucket := openTestBucket(t)
+	err := bucket.Put("document", map[string]string{"state": "running"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	release, isAcquired, err := bucket.TryLease("document")
+	if err != nil || !isAcquired {
+		t.Fatalf("lease is not acquired: %v", err)
+	}
+
+	// Lease is held by the file lock, so the other opening of the lease file (e.g. by the other process) can't take it.
+	_, isAcquired, err = bucket.TryLease("document")
+	if err != nil || isAcquired {
+		t.Fatalf("lease is acquired twice: %v", err)
+	}
+	isLeased, err := bucket.IsLeased("document")
+	if err != nil || !isLeased {
+		t.Fatalf("held lease is not reported: %v", err)
+	}
+	// Leases of the other keys are independent.
+	otherRelease, isAcquired, err := bucket.TryLease("other")
+	if err != nil || !isAcquired {
+		t.Fatalf("lease of the other key is not acquired: %v", err)
+	}
+	otherRelease()
+
+	release()
+	isLeased, err = bucket.IsLeased("document")
+	if err != nil || isLeased {
+		t.Fatalf("released lease is reported as held: %v", err)
+	}
+	release, isAcquired, err = bucket.TryLease("document")
+	if err != nil || !isAcquired {
+		t.Fatalf("released lease is not acquired again: %v", err)
+	}
+	release()
+
+	// Lease file is not a document of the bucket and is removed together with the document.
+	keys, err := bucket.Keys()
+	if err != nil || len(keys) != 1 || keys[0] != "document" {
+		t.Fatalf("unexpected keys %v, %v", keys, err)
+	}
+	err = bucket.Delete("document")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, isAcquired, err = bucket.TryLease("../document")
+	if err == nil || isAcquired {
+		t.Fatal("lease of the invalid key is acquired")
+	}
+}
@@ -0,0 +1,18 @@
+package storetest
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/conf"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/conf/conftest"
+)
+
+// Points the store to the temporary directory of the test, so tests don't share documents.
+// Returns the directory of the store.
+func UseTempDir(t testing.TB) string {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), "storage")
+	conftest.Set(t, &conf.Params.Storage.Dir, dir)
+	return dir
+}
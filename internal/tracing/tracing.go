@@ -0,0 +1,201 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/conf"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/logger"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	instrumentationName = "github.com/vTCP-Foundation/vtcpd-cli"
+
+	// Attribute keys, that are specific for the vTCP engine communication.
+	CommandUUIDKey = attribute.Key("vtcpd.command.uuid")
+	CommandNameKey = attribute.Key("vtcpd.command.name")
+	ResultCodeKey  = attribute.Key("vtcpd.result.code")
+)
+
+var (
+	// Exporters, that could be set in the tracing.exporter setting.
+	EXPORTER_STDOUT = "stdout"
+	EXPORTER_FILE   = "file"
+	EXPORTER_OTLP   = "otlp"
+
+	DEFAULT_SERVICE_NAME = "vtcpd-cli"
+)
+
+// Initialises global tracer provider according to the tracing settings.
+// If tracing is disabled, the default no-op provider is kept and all spans are dropped.
+//
+// Returns shutdown function, that must be called before the process exit
+// to flush the spans that are still buffered.
+func Init() (func(context.Context) error, error) {
+	settings := conf.Params.Tracing
+	if !settings.Enabled {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, file, err := newExporter(settings)
+	if err != nil {
+		return nil, err
+	}
+
+	serviceName := settings.ServiceName
+	if serviceName == "" {
+		serviceName = DEFAULT_SERVICE_NAME
+	}
+
+	sampler := sdktrace.ParentBased(sdktrace.AlwaysSample())
+	if settings.SampleRatio > 0 && settings.SampleRatio < 1 {
+		sampler = sdktrace.ParentBased(sdktrace.TraceIDRatioBased(settings.SampleRatio))
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sampler),
+		sdktrace.WithResource(resource.NewWithAttributes(
+			semconv.SchemaURL,
+			semconv.ServiceName(serviceName),
+		)),
+	)
+
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{}))
+
+	logger.Info("Tracing initialised with exporter " + settings.Exporter)
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if file != nil {
+			// File is closed after the provider has flushed the buffered spans to it.
+			closeErr := file.Close()
+			if err == nil && closeErr != nil {
+				err = errors.New("can't close traces file -> " + closeErr.Error())
+			}
+		}
+		return err
+	}, nil
+}
+
+// Returns exporter and the traces file, that is written by it (if any).
+func newExporter(settings conf.TracingSettings) (sdktrace.SpanExporter, *os.File, error) {
+	switch settings.Exporter {
+	case "", EXPORTER_STDOUT:
+		// Stdout is reserved for the results of the CLI commands, so the spans are written to the stderr.
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(os.Stderr))
+		return exporter, nil, err
+
+	case EXPORTER_FILE:
+		if settings.FilePath == "" {
+			return nil, nil, errors.New("tracing.file_path must be set for the file exporter")
+		}
+		file, err := os.OpenFile(settings.FilePath, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
+		if err != nil {
+			return nil, nil, errors.New("can't open traces file -> " + err.Error())
+		}
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(file))
+		if err != nil {
+			file.Close()
+			return nil, nil, err
+		}
+		return exporter, file, nil
+
+	case EXPORTER_OTLP:
+		options := []otlptracehttp.Option{}
+		if settings.OTLPEndpoint != "" {
+			options = append(options, otlptracehttp.WithEndpoint(settings.OTLPEndpoint))
+		}
+		if settings.OTLPInsecure {
+			options = append(options, otlptracehttp.WithInsecure())
+		}
+		exporter, err := otlptracehttp.New(context.Background(), options...)
+		return exporter, nil, err
+
+	default:
+		return nil, nil, errors.New("unknown tracing exporter " + settings.Exporter)
+	}
+}
+
+// Returns tracer, that must be used for all spans of the CLI.
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Starts span for the command, that is transferred to the engine.
+// Command UUID and command name are attached to the span.
+func StartCommandSpan(ctx context.Context, spanName, commandUUID, commandName string) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, spanName,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			CommandUUIDKey.String(commandUUID),
+			CommandNameKey.String(commandName)))
+}
+
+// Marks span as failed with the error provided.
+func RecordError(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// HTTP middleware, that creates server span for each API route.
+// W3C trace context is taken from the incoming request headers (if present).
+func HTTPMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+
+		routeName := r.URL.Path
+		if route := mux.CurrentRoute(r); route != nil {
+			if template, err := route.GetPathTemplate(); err == nil {
+				routeName = template
+			}
+		}
+
+		ctx, span := Tracer().Start(ctx, r.Method+" "+routeName,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(r.Method),
+				semconv.HTTPRoute(routeName),
+				semconv.URLPath(r.URL.Path)))
+		defer span.End()
+
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+
+		span.SetAttributes(semconv.HTTPResponseStatusCode(recorder.status))
+		if recorder.status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, "HTTP status "+strconv.Itoa(recorder.status))
+		}
+	})
+}
+
+// Wraps response writer to be able to report response status code in the span.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(statusCode int) {
+	r.status = statusCode
+	r.ResponseWriter.WriteHeader(statusCode)
+}
+
+// Allows http.ResponseController to reach the original writer (e.g. for flushing of the streamed responses).
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
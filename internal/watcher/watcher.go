@@ -0,0 +1,219 @@
+package watcher
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/common"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/conf"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/events"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/handler"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/historymark"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/logger"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/store"
+)
+
+var (
+	WATCHER_BUCKET        = "events-watcher"
+	HISTORY_MARKS_KEY     = "history-marks"
+	SETTLEMENT_LINES_KEY  = "settlement-lines"
+	DEFAULT_POLL_INTERVAL = 10 * time.Second
+	HISTORY_PAGE_SIZE     = 100
+
+	INCOMING_OPERATION_DIRECTION = "incoming"
+)
+
+type settlementLineSnapshot struct {
+	Contractor string                     `json:"contractor"`
+	State      events.SettlementLineState `json:"state"`
+}
+
+// Polls the engine and publishes events, that could not be caught in the moment they happen:
+// incoming payments and changes of the settlement lines.
+// Progress is persisted, so the changes, that happened while the CLI was down, are reported too.
+type Watcher struct {
+	nodeHandler *handler.NodeHandler
+	bucket      *store.Bucket
+}
+
+func Start(nodeHandler *handler.NodeHandler) error {
+	bucket, err := store.OpenBucket(WATCHER_BUCKET)
+	if err != nil {
+		return err
+	}
+	watcher := &Watcher{nodeHandler: nodeHandler, bucket: bucket}
+
+	interval := conf.Params.Webhooks.PollInterval
+	if interval <= 0 {
+		interval = DEFAULT_POLL_INTERVAL
+	}
+	go func() {
+		for {
+			watcher.poll(context.Background())
+			time.Sleep(interval)
+		}
+	}()
+	logger.Info("Events watching started, poll interval " + interval.String())
+	return nil
+}
+
+func (w *Watcher) poll(ctx context.Context) {
+	w.pollPayments(ctx)
+	w.pollSettlementLines(ctx)
+}
+
+func (w *Watcher) pollPayments(ctx context.Context) {
+	code, equivalents := w.nodeHandler.Equivalents(ctx)
+	if code != handler.OK {
+		return
+	}
+
+	marks := make(map[string]*historymark.Mark)
+	_, err := w.bucket.Get(HISTORY_MARKS_KEY, &marks)
+	if err != nil {
+		logger.Error("Can't read payments history marks. Details: " + err.Error())
+		return
+	}
+
+	for _, equivalent := range equivalents {
+		mark, isPresent := marks[equivalent]
+		if !isPresent {
+			// History, that was recorded before the watching of the equivalent was started, is not reported.
+			mark = &historymark.Mark{UnixTimestampMicroseconds: time.Now().UnixMicro()}
+			marks[equivalent] = mark
+		}
+		w.processPaymentsHistory(ctx, equivalent, mark)
+	}
+
+	err = w.bucket.Put(HISTORY_MARKS_KEY, marks)
+	if err != nil {
+		logger.Error("Can't write payments history marks. Details: " + err.Error())
+	}
+}
+
+// Publishes incoming payments of the equivalent, that are newer than the mark, and advances the mark.
+// Mark is left as is if the history could not be read.
+func (w *Watcher) processPaymentsHistory(ctx context.Context, equivalent string, mark *historymark.Mark) {
+	filter := handler.PaymentsHistoryFilter{
+		DateFrom: mark.DateFrom(),
+	}
+
+	var newRecords []common.PaymentHistoryRecord
+	timestamps := make(map[string]int64)
+	for offset := 0; ; offset += HISTORY_PAGE_SIZE {
+		code, records := w.nodeHandler.PaymentsHistoryPage(ctx, equivalent, offset, HISTORY_PAGE_SIZE, filter)
+		if code != handler.OK {
+			return
+		}
+
+		pageHasNewRecords := false
+		for i := range records {
+			timestamp, err := strconv.ParseInt(records[i].UnixTimestampMicroseconds, 10, 64)
+			if err != nil {
+				logger.Error("Invalid timestamp of the transaction " + records[i].TransactionUUID)
+				continue
+			}
+			if mark.IsNew(timestamp, records[i].TransactionUUID) {
+				pageHasNewRecords = true
+				newRecords = append(newRecords, records[i])
+				timestamps[records[i].TransactionUUID] = timestamp
+			}
+		}
+		// Records are ordered from the newest ones, so there is no need to read the older pages.
+		if len(records) < HISTORY_PAGE_SIZE || !pageHasNewRecords {
+			break
+		}
+	}
+
+	sort.SliceStable(newRecords, func(i, j int) bool {
+		return timestamps[newRecords[i].TransactionUUID] < timestamps[newRecords[j].TransactionUUID]
+	})
+	for i := range newRecords {
+		mark.Advance(timestamps[newRecords[i].TransactionUUID], newRecords[i].TransactionUUID)
+		if strings.ToLower(newRecords[i].OperationDirection) != INCOMING_OPERATION_DIRECTION {
+			continue
+		}
+		events.Publish(events.EVENT_PAYMENT_INCOMING, events.IncomingPaymentData{
+			Equivalent:                equivalent,
+			TransactionUUID:           newRecords[i].TransactionUUID,
+			UnixTimestampMicroseconds: newRecords[i].UnixTimestampMicroseconds,
+			Contractor:                newRecords[i].Contractor,
+			Amount:                    newRecords[i].Amount,
+			BalanceAfterOperation:     newRecords[i].BalanceAfterOperation,
+			Payload:                   newRecords[i].Payload,
+		})
+	}
+}
+
+func (w *Watcher) pollSettlementLines(ctx context.Context) {
+	code, equivalents := w.nodeHandler.AllSettlementLines(ctx)
+	if code != handler.OK {
+		return
+	}
+
+	current := make(map[string]settlementLineSnapshot)
+	for _, equivalent := range equivalents {
+		for _, settlementLine := range equivalent.SettlementLines {
+			current[equivalent.Eq+"/"+settlementLine.ID] = settlementLineSnapshot{
+				Contractor: settlementLine.Contractor,
+				State: events.SettlementLineState{
+					State:              settlementLine.State,
+					MaxNegativeBalance: settlementLine.MaxNegativeBalance,
+					MaxPositiveBalance: settlementLine.MaxPositiveBalance,
+				},
+			}
+		}
+	}
+
+	previous := make(map[string]settlementLineSnapshot)
+	isPresent, err := w.bucket.Get(SETTLEMENT_LINES_KEY, &previous)
+	if err != nil {
+		logger.Error("Can't read settlement lines snapshot. Details: " + err.Error())
+		return
+	}
+	// The first snapshot is only remembered.
+	if isPresent {
+		publishSettlementLinesChanges(previous, current)
+	}
+
+	err = w.bucket.Put(SETTLEMENT_LINES_KEY, current)
+	if err != nil {
+		logger.Error("Can't write settlement lines snapshot. Details: " + err.Error())
+	}
+}
+
+func publishSettlementLinesChanges(previous, current map[string]settlementLineSnapshot) {
+	var keys []string
+	for key := range previous {
+		keys = append(keys, key)
+	}
+	for key := range current {
+		if _, isPresent := previous[key]; !isPresent {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		previousSnapshot, wasPresent := previous[key]
+		currentSnapshot, isPresent := current[key]
+		if wasPresent && isPresent && previousSnapshot.State == currentSnapshot.State {
+			continue
+		}
+
+		equivalent, contractorID, _ := strings.Cut(key, "/")
+		data := events.SettlementLineData{Equivalent: equivalent, ContractorID: contractorID}
+		if wasPresent {
+			data.Contractor = previousSnapshot.Contractor
+			data.Previous = &previousSnapshot.State
+		}
+		if isPresent {
+			data.Contractor = currentSnapshot.Contractor
+			data.Current = &currentSnapshot.State
+		}
+		events.Publish(events.EVENT_SETTLEMENT_LINE_CHANGED, data)
+	}
+}
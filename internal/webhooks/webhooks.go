@@ -0,0 +1,429 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/common"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/conf"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/events"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/logger"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/metrics"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/store"
+)
+
+var (
+	DELIVERIES_BUCKET = "webhook-deliveries"
+
+	DEFAULT_TIMEOUT               = 10 * time.Second
+	DEFAULT_MAX_ATTEMPTS          = 8
+	DEFAULT_INITIAL_BACKOFF       = time.Second
+	DEFAULT_MAX_BACKOFF           = 10 * time.Minute
+	DEFAULT_DEAD_LETTER_FILE_PATH = "webhooks-dead-letter.log"
+	DEFAULT_RETENTION             = 24 * time.Hour
+	CLEANUP_INTERVAL              = time.Hour
+
+	DELIVERY_STATE_PENDING   = "pending"
+	DELIVERY_STATE_DELIVERED = "delivered"
+	DELIVERY_STATE_DEAD      = "dead"
+
+	// Headers of the delivery request.
+	// Signature is hex encoded HMAC-SHA256 of "<timestamp>.<body>" with the secret of the subscription.
+	HEADER_DELIVERY  = "X-Webhook-Delivery"
+	HEADER_EVENT     = "X-Webhook-Event"
+	HEADER_TIMESTAMP = "X-Webhook-Timestamp"
+	HEADER_SIGNATURE = "X-Webhook-Signature"
+)
+
+// Delivery of one event to one subscription.
+// Payload is kept as it was sent first time, so all the attempts deliver the same body.
+type Delivery struct {
+	ID             string          `json:"id"`
+	Subscription   string          `json:"subscription"`
+	EventID        string          `json:"event_id"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	State          string          `json:"state"`
+	Attempts       int             `json:"attempts"`
+	LastStatusCode int             `json:"last_status_code,omitempty"`
+	LastError      string          `json:"last_error,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+	CompletedAt    time.Time       `json:"completed_at"`
+}
+
+func (d *Delivery) response() common.WebhookDeliveryResponse {
+	response := common.WebhookDeliveryResponse{
+		ID:             d.ID,
+		Subscription:   d.Subscription,
+		EventID:        d.EventID,
+		EventType:      d.EventType,
+		State:          d.State,
+		Attempts:       d.Attempts,
+		LastStatusCode: d.LastStatusCode,
+		LastError:      d.LastError,
+		CreatedAt:      d.CreatedAt.UTC().Format(time.RFC3339),
+		Payload:        d.Payload,
+	}
+	if d.State == DELIVERY_STATE_PENDING {
+		response.NextAttemptAt = d.NextAttemptAt.UTC().Format(time.RFC3339)
+	} else {
+		response.CompletedAt = d.CompletedAt.UTC().Format(time.RFC3339)
+	}
+	return response
+}
+
+var deliveriesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: metrics.NAMESPACE,
+	Name:      "webhook_delivery_attempts_total",
+	Help:      "Attempts of the webhook deliveries by the subscriptions and the results.",
+}, []string{"subscription", "result"})
+
+func init() {
+	metrics.Registry.MustRegister(deliveriesTotal)
+}
+
+type dispatcher struct {
+	bucket        *store.Bucket
+	client        *http.Client
+	subscriptions map[string]conf.WebhookSubscriptionSettings
+
+	deadLetterLock sync.Mutex
+}
+
+var active *dispatcher
+
+// Checks subscriptions settings.
+func ValidateSettings() error {
+	names := make(map[string]bool)
+	for _, subscription := range conf.Params.Webhooks.Subscriptions {
+		if subscription.Name == "" {
+			return errors.New("webhook subscription without name")
+		}
+		if names[subscription.Name] {
+			return errors.New("duplicated webhook subscription " + subscription.Name)
+		}
+		names[subscription.Name] = true
+
+		subscriptionURL, err := url.Parse(subscription.URL)
+		if err != nil || (subscriptionURL.Scheme != "http" && subscriptionURL.Scheme != "https") || subscriptionURL.Host == "" {
+			return errors.New("invalid url of the webhook subscription " + subscription.Name)
+		}
+		if subscription.Secret == "" {
+			return errors.New("webhook subscription " + subscription.Name + " has no secret")
+		}
+		for _, eventType := range subscription.Events {
+			if !events.IsKnownType(eventType) {
+				return errors.New("unknown event " + eventType + " in the webhook subscription " + subscription.Name)
+			}
+		}
+	}
+	if conf.Params.Webhooks.MaxAttempts < 0 {
+		return errors.New("invalid webhooks max_attempts")
+	}
+	return nil
+}
+
+// Returns true if there is at least one subscription.
+func IsEnabled() bool {
+	return len(conf.Params.Webhooks.Subscriptions) > 0
+}
+
+// Starts delivering of the published events to the subscriptions.
+// Pending deliveries, that were interrupted by the restart of the process, are resumed.
+func Start() error {
+	err := ValidateSettings()
+	if err != nil {
+		return err
+	}
+	bucket, err := store.OpenBucket(DELIVERIES_BUCKET)
+	if err != nil {
+		return err
+	}
+
+	timeout := conf.Params.Webhooks.Timeout
+	if timeout <= 0 {
+		timeout = DEFAULT_TIMEOUT
+	}
+	d := &dispatcher{
+		bucket:        bucket,
+		client:        &http.Client{Timeout: timeout},
+		subscriptions: make(map[string]conf.WebhookSubscriptionSettings),
+	}
+	for _, subscription := range conf.Params.Webhooks.Subscriptions {
+		d.subscriptions[subscription.Name] = subscription
+	}
+	active = d
+
+	deliveries, err := d.deliveries()
+	if err != nil {
+		return err
+	}
+	for _, delivery := range deliveries {
+		if delivery.State == DELIVERY_STATE_PENDING {
+			go d.deliver(delivery)
+		}
+	}
+
+	events.Subscribe(d.enqueue)
+	go d.removeExpiredDeliveries()
+	logger.Info("Webhooks started, subscriptions: " + strconv.Itoa(len(d.subscriptions)))
+	return nil
+}
+
+func isSubscribed(subscription conf.WebhookSubscriptionSettings, eventType string) bool {
+	if len(subscription.Events) == 0 {
+		return true
+	}
+	for _, subscribedType := range subscription.Events {
+		if subscribedType == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *dispatcher) enqueue(event events.Event) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		logger.Error("Can't marshal event " + event.Type + ". Details: " + err.Error())
+		return
+	}
+
+	for name, subscription := range d.subscriptions {
+		if !isSubscribed(subscription, event.Type) {
+			continue
+		}
+		delivery := &Delivery{
+			ID:            uuid.New().String(),
+			Subscription:  name,
+			EventID:       event.ID,
+			EventType:     event.Type,
+			Payload:       payload,
+			State:         DELIVERY_STATE_PENDING,
+			CreatedAt:     time.Now(),
+			NextAttemptAt: time.Now(),
+		}
+		d.save(delivery)
+		go d.deliver(delivery)
+	}
+}
+
+func (d *dispatcher) save(delivery *Delivery) {
+	err := d.bucket.Put(delivery.ID, delivery)
+	if err != nil {
+		logger.Error("Can't save webhook delivery " + delivery.ID + ". Details: " + err.Error())
+	}
+}
+
+// Delay before the next attempt: initial backoff is doubled after each failed attempt.
+func backoff(attempts int) time.Duration {
+	delay := conf.Params.Webhooks.InitialBackoff
+	if delay <= 0 {
+		delay = DEFAULT_INITIAL_BACKOFF
+	}
+	maxDelay := conf.Params.Webhooks.MaxBackoff
+	if maxDelay <= 0 {
+		maxDelay = DEFAULT_MAX_BACKOFF
+	}
+	for i := 1; i < attempts && delay < maxDelay; i++ {
+		delay = delay * 2
+	}
+	return min(delay, maxDelay)
+}
+
+func (d *dispatcher) deliver(delivery *Delivery) {
+	maxAttempts := conf.Params.Webhooks.MaxAttempts
+	if maxAttempts == 0 {
+		maxAttempts = DEFAULT_MAX_ATTEMPTS
+	}
+
+	subscription, isPresent := d.subscriptions[delivery.Subscription]
+	if !isPresent {
+		delivery.LastError = "subscription is absent in the settings"
+		d.moveToDeadLetter(delivery)
+		return
+	}
+
+	for {
+		time.Sleep(time.Until(delivery.NextAttemptAt))
+
+		statusCode, err := d.send(subscription, delivery)
+		delivery.Attempts++
+		delivery.LastStatusCode = statusCode
+		if err == nil {
+			deliveriesTotal.WithLabelValues(delivery.Subscription, "delivered").Inc()
+			delivery.LastError = ""
+			delivery.State = DELIVERY_STATE_DELIVERED
+			delivery.CompletedAt = time.Now()
+			d.save(delivery)
+			return
+		}
+
+		deliveriesTotal.WithLabelValues(delivery.Subscription, "failed").Inc()
+		delivery.LastError = err.Error()
+		logger.Error("Webhook delivery " + delivery.ID + " to " + delivery.Subscription +
+			" failed, attempt " + strconv.Itoa(delivery.Attempts) + ". Details: " + err.Error())
+		if delivery.Attempts >= maxAttempts {
+			d.moveToDeadLetter(delivery)
+			return
+		}
+		delivery.NextAttemptAt = time.Now().Add(backoff(delivery.Attempts))
+		d.save(delivery)
+	}
+}
+
+func sign(secret, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Sends delivery once. Any response except 2xx is treated as a failure.
+func (d *dispatcher) send(subscription conf.WebhookSubscriptionSettings, delivery *Delivery) (int, error) {
+	request, err := http.NewRequestWithContext(
+		context.Background(), "POST", subscription.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(HEADER_DELIVERY, delivery.ID)
+	request.Header.Set(HEADER_EVENT, delivery.EventType)
+	request.Header.Set(HEADER_TIMESTAMP, timestamp)
+	request.Header.Set(HEADER_SIGNATURE, sign(subscription.Secret, timestamp, delivery.Payload))
+
+	response, err := d.client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(response.Body, 64*1024))
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return response.StatusCode, errors.New("unexpected response status " + response.Status)
+	}
+	return response.StatusCode, nil
+}
+
+// Appends delivery to the dead letter file (one JSON document per line).
+func (d *dispatcher) moveToDeadLetter(delivery *Delivery) {
+	delivery.State = DELIVERY_STATE_DEAD
+	delivery.CompletedAt = time.Now()
+	d.save(delivery)
+
+	filePath := conf.Params.Webhooks.DeadLetterFilePath
+	if filePath == "" {
+		filePath = DEFAULT_DEAD_LETTER_FILE_PATH
+	}
+	line, err := json.Marshal(delivery)
+	if err != nil {
+		logger.Error("Can't marshal webhook delivery " + delivery.ID + ". Details: " + err.Error())
+		return
+	}
+
+	d.deadLetterLock.Lock()
+	defer d.deadLetterLock.Unlock()
+	file, err := os.OpenFile(filePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		logger.Error("Can't open webhooks dead letter file. Details: " + err.Error())
+		return
+	}
+	defer file.Close()
+	_, err = file.Write(append(line, '\n'))
+	if err != nil {
+		logger.Error("Can't write webhooks dead letter file. Details: " + err.Error())
+		return
+	}
+	logger.Error("Webhook delivery " + delivery.ID + " was moved to the dead letter file")
+}
+
+func (d *dispatcher) deliveries() ([]*Delivery, error) {
+	keys, err := d.bucket.Keys()
+	if err != nil {
+		return nil, err
+	}
+	var deliveries []*Delivery
+	for _, key := range keys {
+		delivery := &Delivery{}
+		isPresent, err := d.bucket.Get(key, delivery)
+		if err != nil {
+			logger.Error("Can't read webhook delivery " + key + ". Details: " + err.Error())
+			continue
+		}
+		if isPresent {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	return deliveries, nil
+}
+
+func (d *dispatcher) removeExpiredDeliveries() {
+	retention := conf.Params.Webhooks.Retention
+	if retention <= 0 {
+		retention = DEFAULT_RETENTION
+	}
+	for {
+		deliveries, err := d.deliveries()
+		if err != nil {
+			logger.Error("Can't read webhook deliveries. Details: " + err.Error())
+		}
+		for _, delivery := range deliveries {
+			if delivery.State != DELIVERY_STATE_PENDING && time.Since(delivery.CompletedAt) > retention {
+				err = d.bucket.Delete(delivery.ID)
+				if err != nil {
+					logger.Error("Can't remove webhook delivery " + delivery.ID + ". Details: " + err.Error())
+				}
+			}
+		}
+		time.Sleep(CLEANUP_INTERVAL)
+	}
+}
+
+// Returns deliveries (from the newest ones), optionally filtered by the state and the subscription.
+func Deliveries(state, subscription string) ([]common.WebhookDeliveryResponse, error) {
+	d := active
+	if d == nil {
+		bucket, err := store.OpenBucket(DELIVERIES_BUCKET)
+		if err != nil {
+			return nil, err
+		}
+		d = &dispatcher{bucket: bucket}
+	}
+
+	deliveries, err := d.deliveries()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(deliveries, func(i, j int) bool {
+		return deliveries[i].CreatedAt.After(deliveries[j].CreatedAt)
+	})
+
+	responses := []common.WebhookDeliveryResponse{}
+	for _, delivery := range deliveries {
+		if state != "" && delivery.State != state {
+			continue
+		}
+		if subscription != "" && delivery.Subscription != subscription {
+			continue
+		}
+		responses = append(responses, delivery.response())
+	}
+	return responses, nil
+}
@@ -0,0 +1,270 @@
+package webhooks
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/conf"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/conf/conftest"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/store"
+	"github.com/vTCP-Foundation/vtcpd-cli/internal/store/storetest"
+)
+
+var TEST_SECRET = "test-secret"
+
+// Received request of the test subscriber.
+type receivedRequest struct {
+	header     http.Header
+	body       []byte
+	receivedAt time.Time
+}
+
+// Subscriber, that responds with the statuses in order (the last one is repeated).
+type subscriber struct {
+	server   *httptest.Server
+	statuses []int
+
+	lock     sync.Mutex
+	requests []receivedRequest
+}
+
+func newSubscriber(t *testing.T, statuses ...int) *subscriber {
+	t.Helper()
+	s := &subscriber{statuses: statuses}
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		s.lock.Lock()
+		s.requests = append(s.requests, receivedRequest{header: r.Header.Clone(), body: body, receivedAt: time.Now()})
+		status := s.statuses[min(len(s.requests), len(s.statuses))-1]
+		s.lock.Unlock()
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(s.server.Close)
+	return s
+}
+
+func (s *subscriber) received() []receivedRequest {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]receivedRequest{}, s.requests...)
+}
+
+// Configures short backoff, temporary store and dead letter file, and returns dispatcher of the subscriber.
+func newTestDispatcher(t *testing.T, s *subscriber) *dispatcher {
+	t.Helper()
+	storetest.UseTempDir(t)
+	conftest.Set(t, &conf.Params.Webhooks, conf.WebhooksSettings{
+		MaxAttempts:        3,
+		InitialBackoff:     20 * time.Millisecond,
+		MaxBackoff:         time.Second,
+		DeadLetterFilePath: filepath.Join(t.TempDir(), "dead-letter.log"),
+	})
+
+	bucket, err := store.OpenBucket(DELIVERIES_BUCKET)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &dispatcher{
+		bucket: bucket,
+		client: &http.Client{Timeout: time.Second},
+		subscriptions: map[string]conf.WebhookSubscriptionSettings{
+			"test": {URL: s.server.URL, Secret: TEST_SECRET},
+		},
+	}
+}
+
+func newTestDelivery() *Delivery {
+	now := time.Now()
+	return &Delivery{
+		ID:            "2f1b0a0e-5b1c-4a6e-9d0a-7c3f4e2b1a00",
+		Subscription:  "test",
+		EventID:       "event-1",
+		EventType:     "payment.completed",
+		Payload:       json.RawMessage(`{"type":"payment.completed","amount":"100"}`),
+		State:         DELIVERY_STATE_PENDING,
+		CreatedAt:     now,
+		NextAttemptAt: now,
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	conftest.Set(t, &conf.Params.Webhooks.InitialBackoff, time.Second)
+	conftest.Set(t, &conf.Params.Webhooks.MaxBackoff, 5*time.Second)
+
+	cases := []struct {
+		attempts int
+		expected time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{4, 5 * time.Second},
+		{10, 5 * time.Second},
+	}
+	for _, c := range cases {
+		t.Run(strconv.Itoa(c.attempts), func(t *testing.T) {
+			delay := backoff(c.attempts)
+			if delay != c.expected {
+				t.Fatalf("expected %v, got %v", c.expected, delay)
+			}
+		})
+	}
+}
+
+func TestSignatureHeader(t *testing.T) {
+	s := newSubscriber(t, http.StatusOK)
+	d := newTestDispatcher(t, s)
+	delivery := newTestDelivery()
+
+	statusCode, err := d.send(d.subscriptions["test"], delivery)
+	if err != nil || statusCode != http.StatusOK {
+		t.Fatalf("unexpected result of the send: %d, %v", statusCode, err)
+	}
+
+	requests := s.received()
+	if len(requests) != 1 {
+		t.Fatalf("expected 1 request, got %d", len(requests))
+	}
+	request := requests[0]
+	if string(request.body) != string(delivery.Payload) {
+		t.Fatalf("unexpected body %s", request.body)
+	}
+	if request.header.Get(HEADER_DELIVERY) != delivery.ID {
+		t.Fatalf("unexpected delivery header %q", request.header.Get(HEADER_DELIVERY))
+	}
+	if request.header.Get(HEADER_EVENT) != delivery.EventType {
+		t.Fatalf("unexpected event header %q", request.header.Get(HEADER_EVENT))
+	}
+
+	timestamp := request.header.Get(HEADER_TIMESTAMP)
+	sentAt, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || time.Since(time.Unix(sentAt, 0)) > time.Minute {
+		t.Fatalf("unexpected timestamp header %q", timestamp)
+	}
+
+	// Signature is verified the same way, as the subscriber is expected to do it.
+	mac := hmac.New(sha256.New, []byte(TEST_SECRET))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(request.body)
+	expected := hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(request.header.Get(HEADER_SIGNATURE)), []byte(expected)) {
+		t.Fatalf("unexpected signature header %q, expected %q", request.header.Get(HEADER_SIGNATURE), expected)
+	}
+
+	mac = hmac.New(sha256.New, []byte("other-secret"))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(request.body)
+	if request.header.Get(HEADER_SIGNATURE) == hex.EncodeToString(mac.Sum(nil)) {
+		t.Fatal("signature does not depend on the secret")
+	}
+}
+
+func TestRetryWithBackoff(t *testing.T) {
+	s := newSubscriber(t, http.StatusInternalServerError, http.StatusServiceUnavailable, http.StatusOK)
+	d := newTestDispatcher(t, s)
+	delivery := newTestDelivery()
+
+	d.deliver(delivery)
+
+	if delivery.State != DELIVERY_STATE_DELIVERED {
+		t.Fatalf("expected state %s, got %s (%s)", DELIVERY_STATE_DELIVERED, delivery.State, delivery.LastError)
+	}
+	if delivery.Attempts != 3 || delivery.LastStatusCode != http.StatusOK || delivery.LastError != "" {
+		t.Fatalf("unexpected delivery %+v", delivery)
+	}
+
+	requests := s.received()
+	if len(requests) != 3 {
+		t.Fatalf("expected 3 requests, got %d", len(requests))
+	}
+	for i := 1; i < len(requests); i++ {
+		interval := requests[i].receivedAt.Sub(requests[i-1].receivedAt)
+		if interval < backoff(i) {
+			t.Fatalf("attempt %d is sent after %v, expected at least %v", i+1, interval, backoff(i))
+		}
+		if string(requests[i].body) != string(requests[0].body) {
+			t.Fatalf("body of the attempt %d differs from the first one", i+1)
+		}
+	}
+
+	saved := &Delivery{}
+	isPresent, err := d.bucket.Get(delivery.ID, saved)
+	if err != nil || !isPresent || saved.State != DELIVERY_STATE_DELIVERED {
+		t.Fatalf("delivered state is not saved: %+v, %v", saved, err)
+	}
+	if _, err := os.Stat(conf.Params.Webhooks.DeadLetterFilePath); !os.IsNotExist(err) {
+		t.Fatal("delivered delivery is written to the dead letter file")
+	}
+}
+
+func TestDeadLetterAfterFinalAttempt(t *testing.T) {
+	s := newSubscriber(t, http.StatusInternalServerError)
+	d := newTestDispatcher(t, s)
+	delivery := newTestDelivery()
+
+	d.deliver(delivery)
+
+	if delivery.State != DELIVERY_STATE_DEAD {
+		t.Fatalf("expected state %s, got %s", DELIVERY_STATE_DEAD, delivery.State)
+	}
+	if delivery.Attempts != conf.Params.Webhooks.MaxAttempts {
+		t.Fatalf("expected %d attempts, got %d", conf.Params.Webhooks.MaxAttempts, delivery.Attempts)
+	}
+	if len(s.received()) != conf.Params.Webhooks.MaxAttempts {
+		t.Fatalf("expected %d requests, got %d", conf.Params.Webhooks.MaxAttempts, len(s.received()))
+	}
+	if delivery.LastStatusCode != http.StatusInternalServerError || delivery.LastError == "" {
+		t.Fatalf("unexpected delivery %+v", delivery)
+	}
+
+	file, err := os.Open(conf.Params.Webhooks.DeadLetterFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var lines []Delivery
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := Delivery{}
+		err = json.Unmarshal(scanner.Bytes(), &line)
+		if err != nil {
+			t.Fatalf("invalid dead letter line %q: %v", scanner.Text(), err)
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) != 1 || lines[0].ID != delivery.ID || lines[0].State != DELIVERY_STATE_DEAD ||
+		lines[0].Attempts != delivery.Attempts {
+		t.Fatalf("unexpected dead letter lines %+v", lines)
+	}
+
+	saved := &Delivery{}
+	isPresent, err := d.bucket.Get(delivery.ID, saved)
+	if err != nil || !isPresent || saved.State != DELIVERY_STATE_DEAD {
+		t.Fatalf("dead state is not saved: %+v, %v", saved, err)
+	}
+}
+
+func TestDeadLetterOfUnknownSubscription(t *testing.T) {
+	s := newSubscriber(t, http.StatusOK)
+	d := newTestDispatcher(t, s)
+	delivery := newTestDelivery()
+	delivery.Subscription = "removed"
+
+	d.deliver(delivery)
+
+	if delivery.State != DELIVERY_STATE_DEAD || delivery.Attempts != 0 || len(s.received()) != 0 {
+		t.Fatalf("unexpected delivery %+v", delivery)
+	}
+}